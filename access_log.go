@@ -0,0 +1,362 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+const (
+	// accessLogTimeLayout is the layout of the time field of server access logs.
+	accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+	// accessLogKeyTimeLayout is the layout of the time in keys of log objects, which are named like
+	// `<target-prefix>2006-01-02-15-04-05-<unique-string>`.
+	accessLogKeyTimeLayout = "2006-01-02-15-04-05"
+	// accessLogMinFields is the number of fields of the earliest log format, fields added later
+	// are optional.
+	accessLogMinFields = 18
+	// accessLogFields is the number of fields of the current log format.
+	accessLogFields = 26
+	// defaultAccessLogPollInterval is the default interval between two listings of the logging
+	// bucket while following logs, as S3 delivers logs every few minutes.
+	defaultAccessLogPollInterval = time.Minute
+)
+
+// AccessLogRecord is a record of S3 server access logs, fields logged as `-` are left empty.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/LogFormat.html
+type AccessLogRecord struct {
+	BucketOwner string
+	Bucket      string
+	Time        time.Time
+	RemoteIP    string
+	Requester   string
+	RequestID   string
+	// Operation is like `REST.GET.OBJECT`.
+	Operation string
+	// Key is the unescaped key of the request.
+	Key        string
+	RequestURI string
+	HTTPStatus int
+	ErrorCode  string
+	BytesSent  int64
+	ObjectSize int64
+	// TotalTime is the time the request was in flight from the server's perspective.
+	TotalTime time.Duration
+	// TurnAroundTime is the time S3 spent processing the request.
+	TurnAroundTime time.Duration
+	Referer        string
+	UserAgent      string
+	VersionID      string
+	HostID         string
+
+	// Fields below are added to the format later, they're empty in older logs.
+	SignatureVersion   string
+	CipherSuite        string
+	AuthenticationType string
+	HostHeader         string
+	TLSVersion         string
+	AccessPointARN     string
+	ACLRequired        string
+}
+
+// ParseAccessLogLine will parse a line of S3 server access logs, an error wrapping
+// ErrAccessLogMalformed will be returned if the line could not be parsed.
+func ParseAccessLogLine(line string) (r *AccessLogRecord, err error) {
+	fields, err := splitAccessLogFields(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) < accessLogMinFields {
+		return nil, fmt.Errorf("%w: %d fields, expected at least %d", ErrAccessLogMalformed, len(fields), accessLogMinFields)
+	}
+	// Extra fields are ignored, as new fields may be appended to the format.
+	for len(fields) < accessLogFields {
+		fields = append(fields, "")
+	}
+
+	r = &AccessLogRecord{
+		BucketOwner:        fields[0],
+		Bucket:             fields[1],
+		RemoteIP:           fields[3],
+		Requester:          fields[4],
+		RequestID:          fields[5],
+		Operation:          fields[6],
+		RequestURI:         fields[8],
+		ErrorCode:          fields[10],
+		Referer:            fields[15],
+		UserAgent:          fields[16],
+		VersionID:          fields[17],
+		HostID:             fields[18],
+		SignatureVersion:   fields[19],
+		CipherSuite:        fields[20],
+		AuthenticationType: fields[21],
+		HostHeader:         fields[22],
+		TLSVersion:         fields[23],
+		AccessPointARN:     fields[24],
+		ACLRequired:        fields[25],
+	}
+	if r.Time, err = time.Parse(accessLogTimeLayout, fields[2]); err != nil {
+		return nil, fmt.Errorf("%w: time %q", ErrAccessLogMalformed, fields[2])
+	}
+	r.Key = fields[7]
+	if v, err := url.QueryUnescape(fields[7]); err == nil {
+		r.Key = v
+	}
+
+	ints := []struct {
+		field string
+		value *int64
+	}{{"bytes sent", &r.BytesSent}, {"object size", &r.ObjectSize}}
+	for i, v := range ints {
+		if *v.value, err = parseAccessLogInt(fields[11+i]); err != nil {
+			return nil, fmt.Errorf("%w: %s %q", ErrAccessLogMalformed, v.field, fields[11+i])
+		}
+	}
+	durations := []struct {
+		field string
+		value *time.Duration
+	}{{"total time", &r.TotalTime}, {"turn-around time", &r.TurnAroundTime}}
+	for i, v := range durations {
+		ms, err := parseAccessLogInt(fields[13+i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %q", ErrAccessLogMalformed, v.field, fields[13+i])
+		}
+		*v.value = time.Duration(ms) * time.Millisecond
+	}
+	status, err := parseAccessLogInt(fields[9])
+	if err != nil {
+		return nil, fmt.Errorf("%w: http status %q", ErrAccessLogMalformed, fields[9])
+	}
+	r.HTTPStatus = int(status)
+	return r, nil
+}
+
+// splitAccessLogFields splits the line by spaces, fields in brackets and quotes are kept as a
+// whole without them, and `-` is replaced by an empty string.
+func splitAccessLogFields(line string) (fields []string, err error) {
+	line = strings.TrimRight(line, "\r\n")
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		var end int
+		switch line[i] {
+		case '[', '"':
+			closing := "]"
+			if line[i] == '"' {
+				closing = `"`
+			}
+			j := strings.Index(line[i+1:], closing)
+			if j < 0 {
+				return nil, fmt.Errorf("%w: unclosed %c", ErrAccessLogMalformed, line[i])
+			}
+			fields = append(fields, line[i+1:i+1+j])
+			end = i + j + 2
+		default:
+			j := strings.IndexByte(line[i:], ' ')
+			if j < 0 {
+				j = len(line) - i
+			}
+			fields = append(fields, line[i:i+j])
+			end = i + j
+		}
+		if fields[len(fields)-1] == "-" {
+			fields[len(fields)-1] = ""
+		}
+		i = end
+	}
+	return fields, nil
+}
+
+// parseAccessLogInt parses an integer field, which is logged as `-` if it's zero.
+func parseAccessLogInt(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// AccessLogOptions controls the behavior of ReadAccessLogs.
+type AccessLogOptions struct {
+	// StartAfter is the path of the log object after which logs are read, which could be the Path
+	// of the iterator recorded by a previous run.
+	StartAfter string
+	// Since skips log objects delivered before it, which only works for log objects named in the
+	// simple format as `<prefix>2006-01-02-15-04-05-<unique-string>`.
+	Since time.Time
+	// Follow will wait for new log objects instead of stopping at the last one, like `tail -f`.
+	Follow bool
+	// PollInterval is the interval between two listings while following, 1m by default.
+	PollInterval time.Duration
+}
+
+// AccessLogIterator iterates records of server access logs.
+type AccessLogIterator struct {
+	s        *Storage
+	ctx      context.Context
+	prefix   string
+	follow   bool
+	interval time.Duration
+
+	// startAfter is the key of the last listed log object.
+	startAfter string
+	keys       []string
+	path       string
+	records    []*AccessLogRecord
+	errs       []error
+}
+
+// ReadAccessLogs will read the records of server access logs delivered under prefix of the
+// logging bucket, log objects are read in the order of their keys, which is the order of
+// delivery time.
+func (s *Storage) ReadAccessLogs(ctx context.Context, prefix string, opt AccessLogOptions) (it *AccessLogIterator, err error) {
+	rp, err := s.getAbsPath(prefix)
+	if err != nil {
+		return nil, s.formatError("read_access_logs", err, prefix)
+	}
+
+	it = &AccessLogIterator{
+		s:        s,
+		ctx:      ctx,
+		prefix:   rp,
+		follow:   opt.Follow,
+		interval: opt.PollInterval,
+	}
+	if it.interval <= 0 {
+		it.interval = defaultAccessLogPollInterval
+	}
+	if !opt.Since.IsZero() {
+		it.startAfter = rp + opt.Since.UTC().Format(accessLogKeyTimeLayout)
+	}
+	if opt.StartAfter != "" {
+		start, err := s.getAbsPath(opt.StartAfter)
+		if err != nil {
+			return nil, s.formatError("read_access_logs", err, opt.StartAfter)
+		}
+		if start > it.startAfter {
+			it.startAfter = start
+		}
+	}
+	return it, nil
+}
+
+// Next returns the next record, typ.IterateDone will be returned after all log objects have been
+// read if Follow is not set.
+//
+// Errors of malformed lines carry the path of the log object and the line number, and the
+// following records could still be read by calling Next again.
+func (it *AccessLogIterator) Next() (*AccessLogRecord, error) {
+	for {
+		if len(it.errs) > 0 {
+			return it.pop()
+		}
+		if len(it.keys) > 0 {
+			if err := it.read(it.keys[0]); err != nil {
+				return nil, err
+			}
+			it.keys = it.keys[1:]
+			continue
+		}
+
+		if err := it.list(); err != nil {
+			return nil, err
+		}
+		if len(it.keys) > 0 {
+			continue
+		}
+		if !it.follow {
+			return nil, typ.IterateDone
+		}
+
+		timer := time.NewTimer(it.interval)
+		select {
+		case <-timer.C:
+		case <-it.ctx.Done():
+			timer.Stop()
+			return nil, it.ctx.Err()
+		}
+	}
+}
+
+// Path returns the path of the log object of the last returned record, which could be passed
+// as StartAfter to resume after the object.
+func (it *AccessLogIterator) Path() string {
+	return it.path
+}
+
+func (it *AccessLogIterator) pop() (*AccessLogRecord, error) {
+	// errs holds the error of each line with a nil placeholder for every record, so that records
+	// and errors are returned in the order of lines.
+	err := it.errs[0]
+	it.errs = it.errs[1:]
+	if err != nil {
+		return nil, err
+	}
+	r := it.records[0]
+	it.records = it.records[1:]
+	return r, nil
+}
+
+// list fetches the keys of log objects delivered after the last listed one.
+func (it *AccessLogIterator) list() error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(it.s.name),
+		Prefix: aws.String(it.prefix),
+	}
+	if it.startAfter != "" {
+		input.StartAfter = aws.String(it.startAfter)
+	}
+	err := it.s.service.ListObjectsV2PagesWithContext(it.ctx, input, func(output *s3.ListObjectsV2Output, last bool) bool {
+		for _, v := range output.Contents {
+			it.keys = append(it.keys, aws.StringValue(v.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return it.s.formatError("read_access_logs", err, it.prefix)
+	}
+	if len(it.keys) > 0 {
+		it.startAfter = it.keys[len(it.keys)-1]
+	}
+	return nil
+}
+
+// read parses the log object at key into records.
+func (it *AccessLogIterator) read(key string) error {
+	path := it.s.getRelPath(key)
+	buf := &bytes.Buffer{}
+	if _, err := it.s.ReadWithContext(it.ctx, path, buf); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(buf)
+	// Lines with long request uris and user agents may exceed the default limit.
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		r, err := ParseAccessLogLine(line)
+		if err != nil {
+			it.errs = append(it.errs, fmt.Errorf("%s:%d: %w", path, n, err))
+			continue
+		}
+		it.errs = append(it.errs, nil)
+		it.records = append(it.records, r)
+	}
+	it.path = path
+	return scanner.Err()
+}
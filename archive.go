@@ -0,0 +1,308 @@
+package s3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// ArchiveFormat is the format of archives.
+type ArchiveFormat string
+
+// All available archive formats are listed here.
+const (
+	ArchiveFormatTar ArchiveFormat = "tar"
+	ArchiveFormatZip ArchiveFormat = "zip"
+)
+
+const (
+	// archiveConcurrency is the number of objects prefetched concurrently while archiving.
+	archiveConcurrency = 8
+	// archivePrefetchSize is the maximum size of objects prefetched into memory while archiving,
+	// larger objects are streamed into the archive when their turn comes.
+	archivePrefetchSize = 8 * 1024 * 1024
+)
+
+// archiveEntry is an object to be written into the archive.
+type archiveEntry struct {
+	o *typ.Object
+	// done will be closed after content is prefetched, it's nil if the object is not prefetched.
+	done    chan struct{}
+	content *bytes.Buffer
+	err     error
+}
+
+// archiveWriter writes entries of an archive.
+type archiveWriter interface {
+	create(name string, o *typ.Object) (io.Writer, error)
+	Close() error
+}
+
+type tarArchiveWriter struct {
+	*tar.Writer
+}
+
+func (w tarArchiveWriter) create(name string, o *typ.Object) (io.Writer, error) {
+	modified, _ := o.GetLastModified()
+	err := w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     o.MustGetContentLength(),
+		Mode:     0644,
+		ModTime:  modified,
+	})
+	return w.Writer, err
+}
+
+type zipArchiveWriter struct {
+	*zip.Writer
+}
+
+func (w zipArchiveWriter) create(name string, o *typ.Object) (io.Writer, error) {
+	modified, _ := o.GetLastModified()
+	return w.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+}
+
+// ArchivePrefix will write all objects under prefix into a tar or zip archive streamed to w, for
+// example, an HTTP response to download a folder.
+//
+// Entries are named by paths relative to the dir of prefix, so both `photos/` and `photos/a`
+// archive `photos/a/b` as `a/b`. Small objects are read concurrently ahead, while entries are
+// written sequentially in the order of paths. Any error will stop the archive, and w will be left
+// with an incomplete archive.
+func (s *Storage) ArchivePrefix(ctx context.Context, prefix string, w io.Writer, format ArchiveFormat) (err error) {
+	var aw archiveWriter
+	switch format {
+	case ArchiveFormatTar:
+		aw = tarArchiveWriter{tar.NewWriter(w)}
+	case ArchiveFormatZip:
+		aw = zipArchiveWriter{zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("archive format %q: %w", format, services.ErrCapabilityInsufficient)
+	}
+
+	it, err := s.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix))
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// entries are sent in the order of listing, and sem limits the prefetching objects.
+	entries := make(chan *archiveEntry, archiveConcurrency)
+	sem := make(chan struct{}, archiveConcurrency)
+	var listErr error
+	go func() {
+		defer close(entries)
+
+		for {
+			o, err := it.Next()
+			if err == typ.IterateDone {
+				return
+			}
+			if err != nil {
+				listErr = err
+				return
+			}
+			if o.Mode.IsDir() {
+				continue
+			}
+
+			e := &archiveEntry{o: o}
+			if o.MustGetContentLength() <= archivePrefetchSize {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				e.done = make(chan struct{})
+				e.content = &bytes.Buffer{}
+				go func() {
+					defer func() { <-sem }()
+					defer close(e.done)
+
+					_, e.err = s.ReadWithContext(ctx, e.o.Path, e.content)
+				}()
+			}
+			select {
+			case entries <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	for e := range entries {
+		if err = s.writeArchiveEntry(ctx, aw, strings.TrimPrefix(e.o.Path, dir), e); err != nil {
+			// Stop the listing and prefetching, and wait for the listing to exit.
+			cancel()
+			for range entries {
+			}
+			return err
+		}
+	}
+	if listErr != nil {
+		return listErr
+	}
+	return aw.Close()
+}
+
+func (s *Storage) writeArchiveEntry(ctx context.Context, aw archiveWriter, name string, e *archiveEntry) error {
+	if e.done != nil {
+		<-e.done
+		if e.err != nil {
+			return e.err
+		}
+	}
+
+	ew, err := aw.create(name, e.o)
+	if err != nil {
+		return err
+	}
+	if e.content != nil {
+		_, err = e.content.WriteTo(ew)
+		return err
+	}
+	_, err = s.ReadWithContext(ctx, e.o.Path, ew)
+	return err
+}
+
+// ExtractArchiveOptions controls the behavior of ExtractArchive.
+type ExtractArchiveOptions struct {
+	// PreserveMode will store the permission bits of entries as the user metadata `mode` in
+	// decimal, which is the same as s3fs.
+	PreserveMode bool
+	// Pairs are passed to every write, for example, WithStorageClass.
+	Pairs []typ.Pair
+}
+
+// ExtractArchive will read the tar (optionally gzipped) or zip archive from r, and write every
+// regular file as an object at destPrefix joined with its path in the archive. The format is
+// detected from the content.
+//
+// Entries larger than 8MiB are uploaded via multipart uploads, while dirs, links and other
+// entries are skipped. Paths are cleaned so that entries like `../a` could not escape destPrefix.
+// As the central directory of zip archives is at the end, zip archives will be spooled into a
+// temporary file first.
+func (s *Storage) ExtractArchive(ctx context.Context, r io.Reader, destPrefix string, opt ExtractArchiveOptions) (err error) {
+	br := bufio.NewReader(r)
+	// The magic of tar is at offset 257, while the ones of zip and gzip are at the beginning.
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF {
+		return
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return s.extractZip(ctx, br, destPrefix, opt)
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		return s.extractTar(ctx, gr, destPrefix, opt)
+	case len(head) > 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		return s.extractTar(ctx, br, destPrefix, opt)
+	default:
+		return fmt.Errorf("archive format unknown: %w", services.ErrCapabilityInsufficient)
+	}
+}
+
+func (s *Storage) extractTar(ctx context.Context, r io.Reader, destPrefix string, opt ExtractArchiveOptions) error {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg && h.Typeflag != tar.TypeRegA {
+			continue
+		}
+		err = s.extractEntry(ctx, tr, h.Size, destPrefix, h.Name, h.FileInfo().Mode(), opt)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Storage) extractZip(ctx context.Context, r io.Reader, destPrefix string, opt ExtractArchiveOptions) (err error) {
+	f, err := ioutil.TempFile("", "s3-extract-*.zip")
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return
+	}
+
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return
+	}
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		err = s.extractEntry(ctx, rc, int64(zf.UncompressedSize64), destPrefix, zf.Name, zf.Mode(), opt)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractEntry writes the entry named name in the archive with size bytes from r.
+func (s *Storage) extractEntry(ctx context.Context, r io.Reader, size int64, destPrefix, name string, mode os.FileMode, opt ExtractArchiveOptions) (err error) {
+	p := destPrefix + strings.TrimPrefix(path.Clean("/"+name), "/")
+	pairs := opt.Pairs
+	if opt.PreserveMode {
+		pairs = append([]typ.Pair{WithUserMetadata(map[string]string{
+			"mode": strconv.FormatUint(uint64(mode.Perm()), 10),
+		})}, pairs...)
+	}
+
+	if size <= writerPartSize {
+		_, err = s.WriteWithContext(ctx, p, io.LimitReader(r, size), size, pairs...)
+		return
+	}
+	w := s.NewWriter(ctx, p, pairs...)
+	if _, err = io.Copy(w, r); err != nil {
+		// Closing the writer with an error will abort the multipart upload.
+		w.setErr(err)
+		_ = w.Close()
+		return
+	}
+	return w.Close()
+}
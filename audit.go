@@ -0,0 +1,184 @@
+package s3
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// EncryptionFinding is the reason why an object is reported by AuditEncryption.
+type EncryptionFinding string
+
+// All available encryption findings are listed here.
+const (
+	// EncryptionFindingUnencrypted means the object is not encrypted on server side.
+	EncryptionFindingUnencrypted EncryptionFinding = "unencrypted"
+	// EncryptionFindingWrongKmsKey means the object is not encrypted by the expected AWS KMS key,
+	// including objects encrypted by SSE-S3.
+	EncryptionFindingWrongKmsKey EncryptionFinding = "wrong_kms_key"
+	// EncryptionFindingHeadFailed means the encryption of the object could not be detected, for
+	// example, objects encrypted by SSE-C could not be HEAD without the key.
+	EncryptionFindingHeadFailed EncryptionFinding = "head_failed"
+)
+
+// EncryptionAuditOptions controls the behavior of AuditEncryption.
+type EncryptionAuditOptions struct {
+	// KmsKeyID is the expected AWS KMS key id or ARN, objects encrypted by other keys will be
+	// reported. Aliases could not be used, as they are resolved by KMS. Any encryption is accepted
+	// if it's empty.
+	KmsKeyID string
+	// Concurrency is the number of objects checked concurrently, 8 by default.
+	Concurrency int
+}
+
+// EncryptionAuditEntry is an object reported by AuditEncryption.
+type EncryptionAuditEntry struct {
+	Path    string
+	Finding EncryptionFinding
+	// Err is the error returned while HEAD the object, only set for EncryptionFindingHeadFailed.
+	Err error
+
+	ServerSideEncryption            string
+	ServerSideEncryptionAwsKmsKeyID string
+	// ClientSideEncrypted is true if the object has been encrypted by a S3 encryption client,
+	// which is still reported as it's not encrypted on server side.
+	ClientSideEncrypted bool
+}
+
+// EncryptionAuditIterator iterates the objects reported by AuditEncryption.
+type EncryptionAuditIterator struct {
+	cancel  context.CancelFunc
+	entries chan *EncryptionAuditEntry
+	// err is the listing error, which is set before entries is closed.
+	err error
+}
+
+// Next returns the next object reported, typ.IterateDone will be returned after all objects
+// have been checked.
+func (it *EncryptionAuditIterator) Next() (*EncryptionAuditEntry, error) {
+	e, ok := <-it.entries
+	if ok {
+		return e, nil
+	}
+	if it.err != nil {
+		return nil, it.err
+	}
+	return nil, typ.IterateDone
+}
+
+// Close will stop the audit, it must be called if the iterator is not drained.
+func (it *EncryptionAuditIterator) Close() {
+	it.cancel()
+	for range it.entries {
+	}
+}
+
+// AuditEncryption will walk all objects under prefix and HEAD them concurrently, objects not
+// encrypted on server side or encrypted by another AWS KMS key will be reported via the returned
+// iterator, in no particular order.
+//
+// Failing to HEAD an object will not stop the audit, it will be reported as
+// EncryptionFindingHeadFailed. Listing errors will be returned by the iterator.
+func (s *Storage) AuditEncryption(ctx context.Context, prefix string, opt EncryptionAuditOptions) (*EncryptionAuditIterator, error) {
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	lit, err := s.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	it := &EncryptionAuditIterator{
+		cancel:  cancel,
+		entries: make(chan *EncryptionAuditEntry, concurrency),
+	}
+
+	ch := make(chan *typ.Object)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for o := range ch {
+				e := s.auditObject(ctx, o, opt)
+				if e == nil {
+					continue
+				}
+				select {
+				case it.entries <- e:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		var err error
+		for {
+			var o *typ.Object
+			o, err = lit.Next()
+			if err != nil {
+				break
+			}
+
+			select {
+			case ch <- o:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if err != nil {
+				break
+			}
+		}
+		close(ch)
+		wg.Wait()
+
+		if err != typ.IterateDone {
+			it.err = err
+		}
+		close(it.entries)
+	}()
+	return it, nil
+}
+
+// auditObject returns nil if the object is compliant.
+func (s *Storage) auditObject(ctx context.Context, o *typ.Object, opt EncryptionAuditOptions) *EncryptionAuditEntry {
+	e := &EncryptionAuditEntry{Path: o.Path}
+
+	output, err := s.service.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(o.ID),
+	})
+	if err != nil {
+		e.Finding = EncryptionFindingHeadFailed
+		e.Err = s.formatError("audit_encryption", err, o.Path)
+		return e
+	}
+	e.ServerSideEncryption = aws.StringValue(output.ServerSideEncryption)
+	e.ServerSideEncryptionAwsKmsKeyID = aws.StringValue(output.SSEKMSKeyId)
+	e.ClientSideEncrypted = isClientSideEncrypted(output.Metadata)
+
+	switch {
+	case e.ServerSideEncryption == "":
+		e.Finding = EncryptionFindingUnencrypted
+	case opt.KmsKeyID != "" && !matchKmsKeyID(e.ServerSideEncryptionAwsKmsKeyID, opt.KmsKeyID):
+		e.Finding = EncryptionFindingWrongKmsKey
+	default:
+		return nil
+	}
+	return e
+}
+
+// matchKmsKeyID checks whether the key ARN returned by S3 is the expected key id or ARN.
+func matchKmsKeyID(actual, expected string) bool {
+	return actual == expected || strings.HasSuffix(actual, ":key/"+expected)
+}
@@ -0,0 +1,181 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ReadTransform wraps the body of the object received from S3, for example, to decrypt it with a
+// custom scheme or to collect metrics. It's applied after io_callback and progress_callback, which
+// count the bytes received, and before client-side decryption and decompression. The returned
+// reader will be closed after the read.
+//
+// Only one ReadTransform is accepted by read, transforms should be chained by the function itself,
+// and WithDefaultStoragePairs could be used to apply it to all reads.
+type ReadTransform func(rc io.ReadCloser) io.ReadCloser
+
+// TruncatedBodyError will be returned while the body of the response ends before all bytes
+// declared by `Content-Length` have been received, for example, the connection is closed in the
+// middle, instead of a silent short read.
+//
+// TruncatedBodyError wraps ErrTruncatedBody, so both `errors.Is(err, ErrTruncatedBody)` and
+// `errors.As(err, &TruncatedBodyError{})` could be used.
+type TruncatedBodyError struct {
+	// Expected is the `Content-Length` of the response.
+	Expected int64
+	// Received is the number of bytes received before the body ends.
+	Received int64
+}
+
+func (e TruncatedBodyError) Error() string {
+	return fmt.Sprintf("%v: expected %d bytes, received %d", ErrTruncatedBody, e.Expected, e.Received)
+}
+
+func (e TruncatedBodyError) Unwrap() error {
+	return ErrTruncatedBody
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e TruncatedBodyError) IsInternalError() {}
+
+// truncationReader validates the body against `Content-Length` while it's being read, so that
+// the truncation is reported by the read hitting the end of the body.
+type truncationReader struct {
+	rc       io.ReadCloser
+	expected int64
+	received int64
+}
+
+// newTruncationReader wraps body with the `Content-Length` of the response, body is returned as
+// is if the length is unknown.
+func newTruncationReader(body io.ReadCloser, contentLength *int64) io.ReadCloser {
+	if contentLength == nil || *contentLength < 0 {
+		return body
+	}
+	return &truncationReader{rc: body, expected: *contentLength}
+}
+
+func (r *truncationReader) Read(p []byte) (n int, err error) {
+	n, err = r.rc.Read(p)
+	r.received += int64(n)
+	// net/http returns io.ErrUnexpectedEOF while the connection is closed before Content-Length.
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && r.received < r.expected) {
+		err = TruncatedBodyError{Expected: r.expected, Received: r.received}
+	}
+	return
+}
+
+func (r *truncationReader) Close() error {
+	return r.rc.Close()
+}
+
+// resumableReader will resume the body of GetObject from the last received byte via a range
+// request while the stream breaks, the object is pinned by the etag of the first response so
+// that bytes of different versions are never mixed.
+type resumableReader struct {
+	s     *Storage
+	ctx   context.Context
+	input *s3.GetObjectInput
+	rc    io.ReadCloser
+
+	// start and end are the absolute range (both inclusive) of the first response.
+	start, end int64
+	received   int64
+
+	attempts    int
+	maxAttempts int
+}
+
+// newResumableReader returns the body as is if the range of the response is unknown.
+func (s *Storage) newResumableReader(ctx context.Context, input *s3.GetObjectInput, output *s3.GetObjectOutput, maxAttempts int) io.ReadCloser {
+	rc := newTruncationReader(output.Body, output.ContentLength)
+	if output.ETag == nil || output.ContentLength == nil || *output.ContentLength < 0 {
+		return rc
+	}
+
+	start, end := int64(0), *output.ContentLength-1
+	if v := aws.StringValue(output.ContentRange); v != "" {
+		var ok bool
+		start, end, ok = parseContentRange(v)
+		if !ok {
+			return rc
+		}
+	}
+
+	// Copy the input to keep the original one untouched.
+	resumeInput := *input
+	resumeInput.IfMatch = output.ETag
+	return &resumableReader{
+		s:           s,
+		ctx:         ctx,
+		input:       &resumeInput,
+		rc:          rc,
+		start:       start,
+		end:         end,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (r *resumableReader) Read(p []byte) (n int, err error) {
+	for {
+		n, err = r.rc.Read(p)
+		r.received += int64(n)
+		if err == nil || err == io.EOF || r.attempts >= r.maxAttempts || r.start+r.received > r.end {
+			return
+		}
+		if r.ctx.Err() != nil {
+			return
+		}
+
+		r.attempts++
+		if err = r.resume(); err != nil {
+			return
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *resumableReader) resume() error {
+	_ = r.rc.Close()
+
+	r.input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", r.start+r.received, r.end))
+	output, err := r.s.service.GetObjectWithContext(r.ctx, r.input)
+	if err != nil {
+		return err
+	}
+	r.rc = newTruncationReader(output.Body, output.ContentLength)
+	return nil
+}
+
+func (r *resumableReader) Close() error {
+	return r.rc.Close()
+}
+
+// parseContentRange parses the absolute range from `Content-Range` like `bytes 0-99/1000`.
+func parseContentRange(v string) (start, end int64, ok bool) {
+	v = strings.TrimPrefix(v, "bytes ")
+	if i := strings.Index(v, "/"); i >= 0 {
+		v = v[:i]
+	}
+	i := strings.Index(v, "-")
+	if i < 0 {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(v[:i], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err = strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
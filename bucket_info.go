@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// getBucketInfo returns the bucket info used to enrich the storage metadata, the info will be
+// cached after it has been fetched successfully.
+func (s *Storage) getBucketInfo(ctx context.Context) (sm StorageSystemMetadata, err error) {
+	s.bucketInfoLock.Lock()
+	defer s.bucketInfoLock.Unlock()
+
+	if s.bucketInfo != nil {
+		return *s.bucketInfo, nil
+	}
+
+	sm, err = s.fetchBucketInfo(ctx)
+	if err != nil {
+		return
+	}
+	s.bucketInfo = &sm
+	return sm, nil
+}
+
+func (s *Storage) fetchBucketInfo(ctx context.Context) (sm StorageSystemMetadata, err error) {
+	location, err := s.service.GetBucketLocationWithContext(ctx, &s3.GetBucketLocationInput{
+		Bucket: aws.String(s.name),
+	})
+	if err != nil {
+		return
+	}
+	// An empty LocationConstraint means the bucket is in us-east-1.
+	sm.BucketRegion = s3.NormalizeBucketLocation(aws.StringValue(location.LocationConstraint))
+
+	// S3 doesn't provide an API to get the creation date of a single bucket.
+	buckets, err := s.service.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return
+	}
+	for _, v := range buckets.Buckets {
+		if aws.StringValue(v.Name) == s.name {
+			sm.BucketCreationDate = aws.TimeValue(v.CreationDate)
+			break
+		}
+	}
+
+	versioning, err := s.service.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{
+		Bucket: aws.String(s.name),
+	})
+	if err != nil {
+		return
+	}
+	sm.VersioningStatus = aws.StringValue(versioning.Status)
+
+	sm.DefaultServerSideEncryption, sm.DefaultServerSideEncryptionAwsKmsKeyID, err = s.fetchDefaultEncryption(ctx)
+	if err != nil {
+		return
+	}
+	return sm, nil
+}
@@ -0,0 +1,241 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// BucketPolicyRequirement is a requirement of the bucket policy detected by the policy preflight.
+type BucketPolicyRequirement string
+
+// All available bucket policy requirements are listed here.
+const (
+	// BucketPolicyRequirementSecureTransport means requests over plain HTTP are denied by the
+	// `aws:SecureTransport` condition.
+	BucketPolicyRequirementSecureTransport BucketPolicyRequirement = "secure_transport"
+	// BucketPolicyRequirementSignatureVersion means requests not signed by Signature Version 4,
+	// including anonymous ones, are denied by the `s3:signatureversion` condition.
+	BucketPolicyRequirementSignatureVersion BucketPolicyRequirement = "signature_version"
+	// BucketPolicyRequirementServerSideEncryption means writes without the expected
+	// `x-amz-server-side-encryption` header are denied.
+	BucketPolicyRequirementServerSideEncryption BucketPolicyRequirement = "server_side_encryption"
+	// BucketPolicyRequirementKmsKey means writes not encrypted by the expected AWS KMS key are denied
+	// by the `s3:x-amz-server-side-encryption-aws-kms-key-id` condition.
+	BucketPolicyRequirementKmsKey BucketPolicyRequirement = "kms_key"
+)
+
+// BucketPolicyError will be returned while creating the storage with policy_preflight enabled,
+// and the bucket policy denies requests sent with the config of the storage.
+//
+// BucketPolicyError wraps ErrBucketPolicyUnsatisfied, so both `errors.Is(err, ErrBucketPolicyUnsatisfied)`
+// and `errors.As(err, &BucketPolicyError{})` could be used.
+type BucketPolicyError struct {
+	Requirement BucketPolicyRequirement
+	// Sid is the id of the policy statement, which could be empty.
+	Sid string
+	// Hint describes how to change the config to satisfy the requirement.
+	Hint string
+}
+
+func (e BucketPolicyError) Error() string {
+	sid := e.Sid
+	if sid == "" {
+		sid = "<no sid>"
+	}
+	return fmt.Sprintf("%s required by statement %s: %v, %s", e.Requirement, sid, ErrBucketPolicyUnsatisfied, e.Hint)
+}
+
+func (e BucketPolicyError) Unwrap() error {
+	return ErrBucketPolicyUnsatisfied
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e BucketPolicyError) IsInternalError() {}
+
+// bucketPolicy is the subset of the IAM policy document used by the preflight.
+//
+// ref: https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_grammar.html
+type bucketPolicy struct {
+	Statement bucketPolicyStatements
+}
+
+type bucketPolicyStatement struct {
+	Sid       string
+	Effect    string
+	Action    policyValues
+	Condition map[string]map[string]policyValues
+}
+
+// bucketPolicyStatements could be either a single statement or an array.
+type bucketPolicyStatements []bucketPolicyStatement
+
+func (v *bucketPolicyStatements) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]bucketPolicyStatement)(v))
+	}
+	var st bucketPolicyStatement
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	*v = bucketPolicyStatements{st}
+	return nil
+}
+
+// policyValues could be either a single value or an array, values like booleans will be
+// converted to strings.
+type policyValues []string
+
+func (v *policyValues) UnmarshalJSON(data []byte) error {
+	var values []interface{}
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return err
+		}
+	} else {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		values = []interface{}{value}
+	}
+	*v = make(policyValues, 0, len(values))
+	for _, value := range values {
+		*v = append(*v, fmt.Sprint(value))
+	}
+	return nil
+}
+
+// match checks whether any of the patterns matches the value case-insensitively, `*` and `?`
+// are supported as wildcards.
+func (v policyValues) match(value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range v {
+		// Actions and the values checked never contain `/`, so path.Match could be used.
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// preflightBucketPolicy will check the Deny statements of the bucket policy against the config
+// of the storage, so that misconfiguration is reported while creating the storage instead of
+// AccessDenied on the first request. Buckets without a policy always pass.
+//
+// Only the conditions commonly used to enforce TLS, SigV4 and encryption are recognized, and
+// the resources of statements are not checked.
+func (s *Storage) preflightBucketPolicy(ctx context.Context) error {
+	output, err := s.service.GetBucketPolicyWithContext(ctx, &s3.GetBucketPolicyInput{
+		Bucket: aws.String(s.name),
+	})
+	if err != nil {
+		if e, ok := err.(awserr.Error); ok && e.Code() == "NoSuchBucketPolicy" {
+			return nil
+		}
+		return err
+	}
+
+	var policy bucketPolicy
+	if err = json.Unmarshal([]byte(aws.StringValue(output.Policy)), &policy); err != nil {
+		return fmt.Errorf("parse bucket policy: %w", err)
+	}
+
+	for _, st := range policy.Statement {
+		if !strings.EqualFold(st.Effect, "Deny") {
+			continue
+		}
+		for operator, conditions := range st.Condition {
+			for key, values := range conditions {
+				err = s.checkPolicyCondition(st, normalizePolicyOperator(operator), strings.ToLower(key), values)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Storage) checkPolicyCondition(st bucketPolicyStatement, operator, key string, values policyValues) error {
+	newError := func(requirement BucketPolicyRequirement, hint string) error {
+		return BucketPolicyError{Requirement: requirement, Sid: st.Sid, Hint: hint}
+	}
+
+	switch key {
+	case "aws:securetransport":
+		if operator == "bool" && values.match("false") && strings.HasPrefix(s.service.Endpoint, "http://") {
+			return newError(BucketPolicyRequirementSecureTransport, "use an https endpoint")
+		}
+	case "s3:signatureversion":
+		if operator == "stringnotequals" && s.service.Config.Credentials == credentials.AnonymousCredentials {
+			return newError(BucketPolicyRequirementSignatureVersion, "set a credential instead of anonymous access")
+		}
+	case "s3:x-amz-server-side-encryption":
+		if !st.Action.match("s3:PutObject") {
+			return nil
+		}
+		for _, pairs := range [][]typ.Pair{s.defaultPairs.Write, s.defaultPairs.CreateMultipart} {
+			sse := defaultPairValue(pairs, "server_side_encryption")
+			switch {
+			case operator == "null" && values.match("true") && sse == "":
+				return newError(BucketPolicyRequirementServerSideEncryption,
+					"set server_side_encryption in default_storage_pairs of write and create_multipart")
+			case operator == "stringnotequals" && !values.match(sse):
+				return newError(BucketPolicyRequirementServerSideEncryption,
+					fmt.Sprintf("set server_side_encryption to %s in default_storage_pairs of write and create_multipart", strings.Join(values, " or ")))
+			}
+		}
+	case "s3:x-amz-server-side-encryption-aws-kms-key-id":
+		if !st.Action.match("s3:PutObject") {
+			return nil
+		}
+		for _, pairs := range [][]typ.Pair{s.defaultPairs.Write, s.defaultPairs.CreateMultipart} {
+			keyID := defaultPairValue(pairs, "server_side_encryption_aws_kms_key_id")
+			matched := false
+			for _, v := range values {
+				if keyID != "" && (matchKmsKeyID(v, keyID) || policyValues{v}.match(keyID)) {
+					matched = true
+					break
+				}
+			}
+			switch {
+			case operator == "null" && values.match("true") && keyID == "":
+				return newError(BucketPolicyRequirementKmsKey,
+					"set server_side_encryption_aws_kms_key_id in default_storage_pairs of write and create_multipart")
+			case (operator == "stringnotequals" || operator == "stringnotlike" || operator == "arnnotequals" || operator == "arnnotlike") && !matched:
+				return newError(BucketPolicyRequirementKmsKey,
+					fmt.Sprintf("set server_side_encryption_aws_kms_key_id to %s in default_storage_pairs of write and create_multipart", strings.Join(values, " or ")))
+			}
+		}
+	}
+	return nil
+}
+
+// normalizePolicyOperator will remove the set operator prefix and the IfExists suffix, and
+// convert the condition operator to lower case.
+func normalizePolicyOperator(operator string) string {
+	operator = strings.ToLower(operator)
+	operator = strings.TrimPrefix(operator, "foranyvalue:")
+	operator = strings.TrimPrefix(operator, "forallvalues:")
+	return strings.TrimSuffix(operator, "ifexists")
+}
+
+// defaultPairValue returns the string value of the first pair with the key.
+func defaultPairValue(pairs []typ.Pair, key string) string {
+	for _, p := range pairs {
+		if p.Key == key {
+			v, _ := p.Value.(string)
+			return v
+		}
+	}
+	return ""
+}
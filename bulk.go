@@ -0,0 +1,244 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// defaultBulkConcurrency is the default number of objects updated concurrently.
+const defaultBulkConcurrency = 8
+
+// BulkUpdateOptions describes the updates applied by UpdatePrefix, only non-zero fields take effect.
+type BulkUpdateOptions struct {
+	// Tags will replace the tag set of every object.
+	Tags map[string]string
+	// StorageClass will transit every object to the storage class via self-copy.
+	StorageClass string
+	// LegalHold will set the legal hold status of every object, could be
+	// s3.ObjectLockLegalHoldStatusOn or s3.ObjectLockLegalHoldStatusOff.
+	LegalHold string
+	// RetentionMode and RetainUntilDate will set the object lock retention of every object,
+	// RetentionMode could be s3.ObjectLockRetentionModeGovernance or s3.ObjectLockRetentionModeCompliance.
+	RetentionMode   string
+	RetainUntilDate time.Time
+
+	// Concurrency is the number of objects updated concurrently, 8 by default.
+	Concurrency int
+	// Progress will be called after each object has been updated, it could be called concurrently.
+	Progress func(BulkUpdateProgress)
+}
+
+// BulkUpdateProgress carries the progress of UpdatePrefix.
+type BulkUpdateProgress struct {
+	// Path is the path of the object just updated.
+	Path string
+	// Err is the error returned while updating the object.
+	Err error
+	// Done is the number of objects updated so far, including failed ones.
+	Done int64
+	// Failed is the number of objects failed to update so far.
+	Failed int64
+}
+
+// BulkUpdateSummary is the result of UpdatePrefix.
+type BulkUpdateSummary struct {
+	Total  int64
+	Failed int64
+}
+
+// UpdatePrefix will walk all objects under prefix and apply the updates in opt concurrently.
+//
+// Failing to update an object will not stop the walk, the error will be reported via Progress
+// and counted in the summary. Only listing errors will be returned.
+//
+// Tags are rejected while disable_tagging is set, and LegalHold and RetentionMode require enable_object_lock.
+func (s *Storage) UpdatePrefix(ctx context.Context, prefix string, opt BulkUpdateOptions) (sum BulkUpdateSummary, err error) {
+	if len(opt.Tags) > 0 && s.taggingDisabled {
+		return sum, fmt.Errorf("tagging not enabled: %w", services.ErrCapabilityInsufficient)
+	}
+	if (opt.LegalHold != "" || opt.RetentionMode != "") && !s.objectLockEnabled {
+		return sum, fmt.Errorf("object lock not enabled: %w", services.ErrCapabilityInsufficient)
+	}
+
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	it, err := s.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix))
+	if err != nil {
+		return
+	}
+
+	ch := make(chan *typ.Object)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for o := range ch {
+				uerr := s.updateObject(ctx, o, opt)
+
+				done := atomic.AddInt64(&sum.Total, 1)
+				failed := atomic.LoadInt64(&sum.Failed)
+				if uerr != nil {
+					failed = atomic.AddInt64(&sum.Failed, 1)
+				}
+				if opt.Progress != nil {
+					opt.Progress(BulkUpdateProgress{Path: o.Path, Err: uerr, Done: done, Failed: failed})
+				}
+			}
+		}()
+	}
+
+	for {
+		var o *typ.Object
+		o, err = it.Next()
+		if err != nil {
+			break
+		}
+
+		select {
+		case ch <- o:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
+
+	if err == typ.IterateDone {
+		err = nil
+	}
+	return sum, err
+}
+
+func (s *Storage) updateObject(ctx context.Context, o *typ.Object, opt BulkUpdateOptions) (err error) {
+	// Storage class transition must happen first, as the self-copy creates a new object
+	// which the following updates should be applied to.
+	if opt.StorageClass != "" {
+		err = s.CopyWithContext(ctx, o.Path, o.Path,
+			WithStorageClass(opt.StorageClass),
+			WithMetadataDirective(s3.MetadataDirectiveCopy),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	defer func() {
+		err = s.formatError("update", err, o.Path)
+	}()
+
+	if opt.Tags != nil {
+		input := &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(s.name),
+			Key:     aws.String(o.ID),
+			Tagging: &s3.Tagging{},
+		}
+		for k, v := range opt.Tags {
+			input.Tagging.TagSet = append(input.Tagging.TagSet, &s3.Tag{
+				Key:   aws.String(k),
+				Value: aws.String(v),
+			})
+		}
+		if _, err = s.service.PutObjectTaggingWithContext(ctx, input); err != nil {
+			return
+		}
+	}
+	if opt.LegalHold != "" {
+		_, err = s.service.PutObjectLegalHoldWithContext(ctx, &s3.PutObjectLegalHoldInput{
+			Bucket:    aws.String(s.name),
+			Key:       aws.String(o.ID),
+			LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(opt.LegalHold)},
+		})
+		if err != nil {
+			return
+		}
+	}
+	if opt.RetentionMode != "" {
+		_, err = s.service.PutObjectRetentionWithContext(ctx, &s3.PutObjectRetentionInput{
+			Bucket: aws.String(s.name),
+			Key:    aws.String(o.ID),
+			Retention: &s3.ObjectLockRetention{
+				Mode:            aws.String(opt.RetentionMode),
+				RetainUntilDate: aws.Time(opt.RetainUntilDate),
+			},
+		})
+		if err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// BatchStatOptions controls the behavior of BatchStat.
+type BatchStatOptions struct {
+	// Pairs are passed to every stat, for example, WithExceptedBucketOwner.
+	Pairs []typ.Pair
+	// Concurrency is the number of objects stated concurrently, 8 by default.
+	Concurrency int
+}
+
+// BatchStatResult is the result of a path stated by BatchStat, only one of Object and Err is set.
+type BatchStatResult struct {
+	Object *typ.Object
+	Err    error
+}
+
+// BatchStat will stat (HEAD) all paths concurrently, results are returned in the same order as
+// paths, so that a manifest of keys could be verified without stating them one by one.
+//
+// Failing to stat a path will not stop the others, the error is carried by its result, objects
+// not found return services.ErrObjectNotExist as Stat does. Paths not stated before ctx is done
+// carry the error of ctx.
+func (s *Storage) BatchStat(ctx context.Context, paths []string, opt BatchStatOptions) []BatchStatResult {
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	results := make([]BatchStatResult, len(paths))
+	ch := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Every worker writes different indexes of results, so no lock is needed.
+			for idx := range ch {
+				o, err := s.StatWithContext(ctx, paths[idx], opt.Pairs...)
+				results[idx] = BatchStatResult{Object: o, Err: err}
+			}
+		}()
+	}
+
+	for idx := range paths {
+		select {
+		case ch <- idx:
+			continue
+		case <-ctx.Done():
+		}
+		for ; idx < len(paths); idx++ {
+			results[idx] = BatchStatResult{Err: ctx.Err()}
+		}
+		break
+	}
+	close(ch)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,170 @@
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// defaultMetadataCacheTTL is the default time to live of results cached by metadata_cache.
+const defaultMetadataCacheTTL = time.Minute
+
+// MetadataCache is the backend of metadata_cache, which must be safe for concurrent use.
+//
+// Values are the responses of S3 which must not be modified, so backends out of process need to
+// encode them by themselves.
+type MetadataCache interface {
+	// Get returns the value of key, ok will be false if it's not found or has expired.
+	Get(key string) (value interface{}, ok bool)
+	// Set stores the value of key, which should expire after ttl.
+	Set(key string, value interface{}, ttl time.Duration)
+}
+
+// memoryMetadataCache is a MetadataCache in memory.
+type memoryMetadataCache struct {
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// NewMemoryMetadataCache returns a MetadataCache in memory which holds maxEntries at most,
+// expired entries are evicted while it's full. It's unbounded if maxEntries is not positive.
+func NewMemoryMetadataCache(maxEntries int) MetadataCache {
+	return &memoryMetadataCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]memoryCacheEntry),
+	}
+}
+
+func (c *memoryMetadataCache) Get(key string) (value interface{}, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *memoryMetadataCache) Set(key string, value interface{}, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[key]; !ok && c.maxEntries > 0 && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = memoryCacheEntry{value: value, expires: time.Now().Add(ttl)}
+}
+
+// evict removes the expired entries, or a random one if none has expired.
+func (c *memoryMetadataCache) evict() {
+	now := time.Now()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	for k := range c.entries {
+		delete(c.entries, k)
+		return
+	}
+}
+
+// metadataCache caches the responses of HeadObject and ListObjectsV2 sent by stat and list.
+//
+// The generation is a part of keys, which will be bumped after every write or delete sent through
+// the storage, so that all results cached before are invalidated at once.
+type metadataCache struct {
+	backend    MetadataCache
+	ttl        time.Duration
+	generation int64
+}
+
+// metadataCacheInvalidatingOperations are the operations which change objects or listings.
+var metadataCacheInvalidatingOperations = map[string]struct{}{
+	"PutObject":               {},
+	"CopyObject":              {},
+	"DeleteObject":            {},
+	"DeleteObjects":           {},
+	"CompleteMultipartUpload": {},
+	"RestoreObject":           {},
+	"PutObjectRetention":      {},
+	"PutObjectLegalHold":      {},
+}
+
+// invalidateHandler is added to the complete handlers, so that failed requests which may have
+// changed objects also count.
+func (c *metadataCache) invalidateHandler(r *request.Request) {
+	if _, ok := metadataCacheInvalidatingOperations[r.Operation.Name]; ok {
+		atomic.AddInt64(&c.generation, 1)
+	}
+}
+
+// key formats the key of the request, inputs are hashed as they may carry SSE-C keys.
+func (c *metadataCache) key(op, endpoint string, input fmt.Stringer) string {
+	sum := sha256.Sum256([]byte(endpoint + "\n" + input.String()))
+	return fmt.Sprintf("s3:%s:%d:%s", op, atomic.LoadInt64(&c.generation), hex.EncodeToString(sum[:]))
+}
+
+// headObject sends HeadObject via metadata_cache if it's enabled.
+func (s *Storage) headObject(ctx context.Context, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	c := s.metadataCache
+	if c == nil {
+		return s.service.HeadObjectWithContext(ctx, input)
+	}
+
+	key := c.key("HeadObject", s.service.Endpoint, input)
+	if v, ok := c.backend.Get(key); ok {
+		if output, ok := v.(*s3.HeadObjectOutput); ok {
+			return output, nil
+		}
+	}
+	output, err := s.service.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	c.backend.Set(key, output, c.ttl)
+	return output, nil
+}
+
+// listObjectsV2 sends ListObjectsV2 via metadata_cache if it's enabled.
+func (s *Storage) listObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error) {
+	c := s.metadataCache
+	if c == nil {
+		return s.service.ListObjectsV2WithContext(ctx, input)
+	}
+
+	key := c.key("ListObjectsV2", s.service.Endpoint, input)
+	if v, ok := c.backend.Get(key); ok {
+		if output, ok := v.(*s3.ListObjectsV2Output); ok {
+			return output, nil
+		}
+	}
+	output, err := s.service.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	c.backend.Set(key, output, c.ttl)
+	return output, nil
+}
@@ -0,0 +1,214 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+const (
+	// CassetteModeReplay will serve requests from the interactions in the cassette without network.
+	CassetteModeReplay = "replay"
+	// CassetteModeRecord will send requests and save the interactions into the cassette.
+	CassetteModeRecord = "record"
+)
+
+// cassetteStrippedHeaders are the request headers which will not be saved into the cassette, as
+// they carry credentials or customer-provided encryption keys.
+var cassetteStrippedHeaders = []string{
+	"Authorization",
+	"X-Amz-Security-Token",
+	"X-Amz-Server-Side-Encryption-Customer-Key",
+	"X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key",
+}
+
+// cassetteStrippedQueries are the query parameters of presigned urls which will not be saved into
+// the cassette, as they carry credentials and change on every request.
+var cassetteStrippedQueries = []string{
+	"X-Amz-Credential",
+	"X-Amz-Date",
+	"X-Amz-Security-Token",
+	"X-Amz-Signature",
+}
+
+type cassette struct {
+	Interactions []*interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  interactionRequest  `json:"request"`
+	Response interactionResponse `json:"response"`
+}
+
+type interactionRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+}
+
+type interactionResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// cassetteTape holds the interactions of a cassette file, which could be shared by transports
+// wrapping different http clients, so that they are recorded into and replayed from one file.
+//
+// Interactions are replayed in the order they were recorded, and matched by method and url.
+type cassetteTape struct {
+	mode string
+	path string
+
+	lock     sync.Mutex
+	cassette cassette
+	// replayed is the number of interactions replayed so far.
+	replayed int
+}
+
+func newCassetteTape(path, mode string) (*cassetteTape, error) {
+	t := &cassetteTape{
+		mode: mode,
+		path: path,
+	}
+
+	switch mode {
+	case CassetteModeRecord:
+		return t, nil
+	case CassetteModeReplay:
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read cassette: %w", err)
+		}
+		if err = json.Unmarshal(content, &t.cassette); err != nil {
+			return nil, fmt.Errorf("parse cassette: %w", err)
+		}
+		return t, nil
+	default:
+		return nil, services.PairUnsupportedError{Pair: WithCassetteMode(mode)}
+	}
+}
+
+// wrap will wrap next with a transport recording into or replaying from the tape.
+//
+// The tape must be applied after the session is created, as the SDK requires the transport of
+// the http client to be a *http.Transport while loading the custom CA bundle.
+func (t *cassetteTape) wrap(next http.RoundTripper) *cassetteTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cassetteTransport{cassetteTape: t, next: next}
+}
+
+// cassetteTransport is a http.RoundTripper which records HTTP interactions into a cassette file
+// and replays them later, so that tests could be deterministic and run offline.
+type cassetteTransport struct {
+	*cassetteTape
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *cassetteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.mode == CassetteModeReplay {
+		return t.replay(r)
+	}
+	return t.record(r)
+}
+
+func (t *cassetteTransport) record(r *http.Request) (*http.Response, error) {
+	req := formatInteractionRequest(r)
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.cassette.Interactions = append(t.cassette.Interactions, &interaction{
+		Request: req,
+		Response: interactionResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       body,
+		},
+	})
+	// The cassette is saved after every interaction, as the transport will never be closed.
+	content, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(t.path, content, 0644); err != nil {
+		return nil, fmt.Errorf("write cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *cassetteTape) replay(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		// Drain the body as the real transport does, so that callers waiting on it won't block.
+		_, _ = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	u := formatInteractionURL(r.URL)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.replayed >= len(t.cassette.Interactions) {
+		return nil, fmt.Errorf("%s %s: %w", r.Method, u, ErrCassetteInteractionNotFound)
+	}
+	v := t.cassette.Interactions[t.replayed]
+	if v.Request.Method != r.Method || v.Request.URL != u {
+		return nil, fmt.Errorf("%s %s, expected %s %s: %w",
+			r.Method, u, v.Request.Method, v.Request.URL, ErrCassetteInteractionNotFound)
+	}
+	t.replayed++
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", v.Response.StatusCode, http.StatusText(v.Response.StatusCode)),
+		StatusCode:    v.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        v.Response.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(v.Response.Body)),
+		ContentLength: int64(len(v.Response.Body)),
+		Request:       r,
+	}, nil
+}
+
+func formatInteractionRequest(r *http.Request) interactionRequest {
+	req := interactionRequest{
+		Method: r.Method,
+		URL:    formatInteractionURL(r.URL),
+		Header: r.Header.Clone(),
+	}
+	for _, k := range cassetteStrippedHeaders {
+		req.Header.Del(k)
+	}
+	return req
+}
+
+func formatInteractionURL(u *url.URL) string {
+	v := *u
+	q := v.Query()
+	for _, k := range cassetteStrippedQueries {
+		q.Del(k)
+	}
+	v.RawQuery = q.Encode()
+	return v.String()
+}
@@ -0,0 +1,192 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+// All available compatibility modes are listed here.
+const (
+	// CompatibilityModeAWS is the default mode for AWS S3.
+	CompatibilityModeAWS = "aws"
+	// CompatibilityModeMinio is the mode for MinIO.
+	CompatibilityModeMinio = "minio"
+	// CompatibilityModeCeph is the mode for Ceph RADOS Gateway.
+	CompatibilityModeCeph = "ceph"
+	// CompatibilityModeR2 is the mode for Cloudflare R2.
+	CompatibilityModeR2 = "r2"
+	// CompatibilityModeB2 is the mode for Backblaze B2.
+	CompatibilityModeB2 = "b2"
+)
+
+// compatibility describes the differences between a backend and AWS S3.
+type compatibility struct {
+	// forcePathStyle and disable100Continue are the defaults of the service config,
+	// which could still be overridden by pairs.
+	forcePathStyle     bool
+	disable100Continue bool
+	// expectedBucketOwner is false while the backend rejects the `x-amz-expected-bucket-owner` header.
+	expectedBucketOwner bool
+	// acl is false while the backend rejects the `x-amz-acl` and `x-amz-grant-*` headers.
+	acl bool
+	// checksum is false while the backend doesn't support additional checksums, the
+	// `x-amz-checksum-*`, `x-amz-sdk-checksum-algorithm` and `x-amz-trailer` headers will be
+	// removed, while Content-MD5 is still sent to verify the content.
+	checksum bool
+	// accelerate is false while the backend doesn't support transfer acceleration.
+	accelerate bool
+	// relaxedErrors will map errors by status code while the error code is unknown.
+	relaxedErrors bool
+	// writeSizeMaximum is the maximum size of a single PUT.
+	writeSizeMaximum int64
+}
+
+var compatibilities = map[string]compatibility{
+	CompatibilityModeAWS: {
+		expectedBucketOwner: true,
+		acl:                 true,
+		checksum:            true,
+		accelerate:          true,
+		writeSizeMaximum:    writeSizeMaximum,
+	},
+	CompatibilityModeMinio: {
+		forcePathStyle:     true,
+		disable100Continue: true,
+		acl:                true,
+		checksum:           true,
+		relaxedErrors:      true,
+		// ref: https://min.io/docs/minio/linux/operations/concepts/thresholds.html
+		writeSizeMaximum: 5 * 1024 * 1024 * 1024 * 1024,
+	},
+	CompatibilityModeCeph: {
+		forcePathStyle:     true,
+		disable100Continue: true,
+		acl:                true,
+		checksum:           true,
+		relaxedErrors:      true,
+		// Limited by `rgw_max_put_size`, which is 5GB by default.
+		writeSizeMaximum: writeSizeMaximum,
+	},
+	// ref: https://developers.cloudflare.com/r2/api/s3/api/
+	CompatibilityModeR2: {
+		relaxedErrors:    true,
+		writeSizeMaximum: writeSizeMaximum,
+	},
+	// ref: https://www.backblaze.com/docs/cloud-storage-s3-compatible-api
+	CompatibilityModeB2: {
+		relaxedErrors:    true,
+		writeSizeMaximum: writeSizeMaximum,
+	},
+}
+
+// compatibilityEndpointSuffixes are used to detect the compatibility mode by endpoint host.
+var compatibilityEndpointSuffixes = map[string]string{
+	".r2.cloudflarestorage.com": CompatibilityModeR2,
+	".backblazeb2.com":          CompatibilityModeB2,
+}
+
+// detectCompatibilityMode returns the compatibility mode of the backend at endpoint url,
+// CompatibilityModeAWS will be returned if the backend is unknown.
+func detectCompatibilityMode(endpointURL string) string {
+	u, err := url.Parse(endpointURL)
+	if err != nil {
+		return CompatibilityModeAWS
+	}
+	for suffix, mode := range compatibilityEndpointSuffixes {
+		if strings.HasSuffix(u.Hostname(), suffix) {
+			return mode
+		}
+	}
+	return CompatibilityModeAWS
+}
+
+// parseCompatibilityMode returns the compatibility of mode.
+func parseCompatibilityMode(mode string) (compatibility, error) {
+	c, ok := compatibilities[mode]
+	if !ok {
+		return compatibility{}, services.PairUnsupportedError{Pair: WithCompatibilityMode(mode)}
+	}
+	return c, nil
+}
+
+// apply will install the handlers required by the backend into handlers.
+func (c compatibility) apply(h *request.Handlers) {
+	if !c.expectedBucketOwner {
+		h.Build.PushBackNamed(removeExpectedBucketOwnerHandler)
+	}
+	if !c.acl {
+		h.Build.PushBackNamed(removeACLHandler)
+	}
+	if !c.checksum {
+		h.Build.PushBackNamed(removeChecksumHandler)
+	}
+}
+
+// removeExpectedBucketOwnerHandler will remove the `x-amz-expected-bucket-owner` header, so that
+// excepted_bucket_owner pairs could be passed without breaking backends which don't support it.
+var removeExpectedBucketOwnerHandler = request.NamedHandler{
+	Name: "s3.RemoveExpectedBucketOwnerHandler",
+	Fn: func(r *request.Request) {
+		r.HTTPRequest.Header.Del("X-Amz-Expected-Bucket-Owner")
+	},
+}
+
+// removeACLHandler will remove the `x-amz-acl` and `x-amz-grant-*` headers, so that grant pairs
+// could be passed without breaking backends which don't support ACLs.
+var removeACLHandler = request.NamedHandler{
+	Name: "s3.RemoveACLHandler",
+	Fn: func(r *request.Request) {
+		for k := range r.HTTPRequest.Header {
+			if k == "X-Amz-Acl" || strings.HasPrefix(k, "X-Amz-Grant-") {
+				r.HTTPRequest.Header.Del(k)
+			}
+		}
+	},
+}
+
+// removeChecksumHandler will remove the headers of additional checksums, so that requests carrying
+// them (for example, set by request handlers of callers) won't be rejected by backends which only
+// support Content-MD5.
+var removeChecksumHandler = request.NamedHandler{
+	Name: "s3.RemoveChecksumHandler",
+	Fn: func(r *request.Request) {
+		for k := range r.HTTPRequest.Header {
+			if strings.HasPrefix(k, "X-Amz-Checksum-") || k == "X-Amz-Sdk-Checksum-Algorithm" || k == "X-Amz-Trailer" {
+				r.HTTPRequest.Header.Del(k)
+			}
+		}
+	},
+}
+
+// formatRelaxedError will fall back to status codes while the error code is unknown to formatError,
+// as compatible backends may return their own error codes.
+func formatRelaxedError(err error) error {
+	ferr := formatError(err)
+	if !errors.Is(ferr, services.ErrUnexpected) {
+		return ferr
+	}
+	e, ok := err.(awserr.RequestFailure)
+	if !ok {
+		return ferr
+	}
+
+	switch e.StatusCode() {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %v", services.ErrObjectNotExist, redactError(err))
+	case http.StatusForbidden:
+		return fmt.Errorf("%w: %v", services.ErrPermissionDenied, redactError(err))
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("%w: %v", ErrPreconditionFailed, redactError(err))
+	case http.StatusRequestedRangeNotSatisfiable:
+		return fmt.Errorf("%w: %v", ErrRangeNotSatisfiable, redactError(err))
+	default:
+		return ferr
+	}
+}
@@ -0,0 +1,55 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+)
+
+// All supported content encodings for compression and decompression are listed here.
+//
+// zstd is not supported as it's not available in the standard library.
+const (
+	ContentEncodingGzip = "gzip"
+)
+
+// newDecompressReader will wrap r with a decompressor for the content encoding, r will be
+// returned as is if the content is not encoded.
+func newDecompressReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "", "identity":
+		return ioutil.NopCloser(r), nil
+	case ContentEncodingGzip:
+		return gzip.NewReader(r)
+	default:
+		return nil, ErrContentEncodingUnsupported
+	}
+}
+
+// compressSizeMaximum is the maximum size of the content which could be compressed by write.
+//
+// The compressed content is buffered in memory, as the content length is required before
+// sending the request, so the memory used must be bounded. Larger content should be
+// compressed by the caller and written with content_encoding instead.
+const compressSizeMaximum = 64 * 1024 * 1024
+
+// compress will compress all content of r into memory, as the content length is required
+// before sending the request.
+func compress(encoding string, r io.Reader) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+
+	switch encoding {
+	case ContentEncodingGzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := io.Copy(w, r); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrContentEncodingUnsupported
+	}
+	return &buf, nil
+}
@@ -0,0 +1,45 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// ConditionalReadResult is the result of ReadIfModified.
+type ConditionalReadResult struct {
+	// NotModified is true if the object still has the etag passed in, nothing is written then.
+	NotModified bool
+	// Object is built from the response headers, which carries the etag to revalidate next time.
+	// It's nil if NotModified is true.
+	Object *typ.Object
+	// N is the number of bytes written.
+	N int64
+}
+
+// ReadIfModified will read the object into w only if its etag differs from etag via
+// `If-None-Match`, so that cached objects like config files could be revalidated without
+// downloading them again. Objects are always read if etag is empty.
+//
+// The object not modified is reported by ConditionalReadResult.NotModified instead of
+// ErrObjectNotModified. pairs of read are accepted, except if_none_match and object_callback
+// which are used by ReadIfModified.
+func (s *Storage) ReadIfModified(ctx context.Context, path, etag string, w io.Writer, pairs ...typ.Pair) (result ConditionalReadResult, err error) {
+	pairs = append([]typ.Pair{WithObjectCallback(func(o *typ.Object) {
+		result.Object = o
+	})}, pairs...)
+	if etag != "" {
+		pairs = append([]typ.Pair{WithIfNoneMatch(etag)}, pairs...)
+	}
+
+	result.N, err = s.ReadWithContext(ctx, path, w, pairs...)
+	if errors.Is(err, ErrObjectNotModified) {
+		return ConditionalReadResult{NotModified: true}, nil
+	}
+	if err != nil {
+		return ConditionalReadResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,510 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// Metadata of the envelope written by the S3 encryption client (V2 format), they are stored as
+// user-defined metadata so the headers are prefixed with `x-amz-meta-`.
+//
+// ref: https://docs.aws.amazon.com/amazon-s3-encryption-client/latest/developerguide/concepts.html
+const (
+	metadataCseKeyHeader                      = "x-amz-key-v2"
+	metadataCseIVHeader                       = "x-amz-iv"
+	metadataCseMatdescHeader                  = "x-amz-matdesc"
+	metadataCseWrapAlgHeader                  = "x-amz-wrap-alg"
+	metadataCseCekAlgHeader                   = "x-amz-cek-alg"
+	metadataCseTagLenHeader                   = "x-amz-tag-len"
+	metadataCseUnencryptedContentLengthHeader = "x-amz-unencrypted-content-length"
+)
+
+// metadataCseHeaders are the envelope metadata which will be excluded from user metadata.
+var metadataCseHeaders = map[string]bool{
+	metadataCseKeyHeader:                      true,
+	metadataCseIVHeader:                       true,
+	metadataCseMatdescHeader:                  true,
+	metadataCseWrapAlgHeader:                  true,
+	metadataCseCekAlgHeader:                   true,
+	metadataCseTagLenHeader:                   true,
+	metadataCseUnencryptedContentLengthHeader: true,
+}
+
+const (
+	// cseCekAlgAESGCM is the algorithm used to encrypt the content by default, which is the same
+	// as other S3 encryption clients, the content is sealed as one GCM message.
+	cseCekAlgAESGCM = "AES/GCM/NoPadding"
+	// cseCekAlgAESGCMStream is the algorithm used to encrypt the content if streaming is enabled,
+	// which is sealed in chunks by AES-GCM so that it could be authenticated before released, see
+	// gcmChunkCipher for details.
+	cseCekAlgAESGCMStream = "AES/GCM/STREAM"
+	// cseBufferedSizeMaximum is the maximum size of objects encrypted by cseCekAlgAESGCM which will
+	// be authenticated before any plaintext is released, as the tag is at the end of the content.
+	// Larger objects are released while read and authenticated at the end.
+	cseBufferedSizeMaximum = 64 * 1024 * 1024
+	// cseWrapAlgKMSContext wraps the data key with KMS, the encryption context is the material description.
+	cseWrapAlgKMSContext = "kms+context"
+	// cseWrapAlgAESGCM wraps the data key with AES-GCM locally, the content encryption algorithm
+	// is used as the additional data.
+	cseWrapAlgAESGCM = "AES/GCM"
+	// cseKMSContextCekAlgKey is the key of the content encryption algorithm in the encryption context,
+	// which will be verified while unwrapping the data key.
+	cseKMSContextCekAlgKey = "aws:x-amz-cek-alg"
+	// cseDataKeySize is the size of the AES-256 data key generated for every object.
+	cseDataKeySize = 32
+)
+
+// ClientSideEncryption is the config of client-side envelope encryption.
+//
+// Every object is encrypted with a new data key by AES-GCM before uploading, and the data key
+// is wrapped by either an AWS KMS key or a local master key and stored along with the object as
+// metadata, in the same format as the S3 encryption client V2, so that objects could be read by
+// other AWS SDKs and vice versa. As the tag is at the end of the content, objects larger than
+// 64 MiB are released while read, and ErrObjectDecryptionFailed is returned at the end if the
+// content doesn't match.
+//
+// Ranged reads of encrypted objects are not supported, and parts of encrypted multipart uploads
+// must be written in order and completed by the same storage which created the upload.
+type ClientSideEncryption struct {
+	// KmsKeyID is the id, alias or ARN of the AWS KMS key used to generate data keys. The KMS
+	// client is created in the region specified by kms_signing_region, the region of the ARN, or
+	// the location of the storage.
+	KmsKeyID string
+	// MasterKey is the AES key (16, 24 or 32 bytes) used to wrap data keys locally.
+	//
+	// Only one of KmsKeyID and MasterKey could be set.
+	MasterKey []byte
+	// Streaming will seal the content in chunks (AES/GCM/STREAM), so that no plaintext is
+	// released before it's authenticated, while objects written can't be read by other AWS SDKs.
+	Streaming bool
+}
+
+type clientSideEncryption struct {
+	kms            *kms.KMS
+	kmsKeyID       string
+	kmsGrantTokens []*string
+
+	masterKey cipher.AEAD
+	// cekAlg is the algorithm used to encrypt the content.
+	cekAlg string
+
+	// uploads are the states of multipart uploads created by this storage, keyed by upload id.
+	lock    sync.Mutex
+	uploads map[string]*cseMultipart
+}
+
+func newClientSideEncryption(sess *session.Session, opt pairStorageNew) (*clientSideEncryption, error) {
+	v := opt.ClientSideEncryption
+	if (v.KmsKeyID == "") == (len(v.MasterKey) == 0) {
+		return nil, services.PairUnsupportedError{Pair: WithClientSideEncryption(v)}
+	}
+
+	c := &clientSideEncryption{
+		cekAlg:  cseCekAlgAESGCM,
+		uploads: make(map[string]*cseMultipart),
+	}
+	if v.Streaming {
+		c.cekAlg = cseCekAlgAESGCMStream
+	}
+	if v.KmsKeyID != "" {
+		keyID, a, ok := parseKmsKeyID(v.KmsKeyID)
+		if !ok {
+			return nil, services.PairUnsupportedError{Pair: WithClientSideEncryption(v)}
+		}
+		region := opt.Location
+		if opt.HasKmsSigningRegion {
+			region = opt.KmsSigningRegion
+		} else if a != nil {
+			region = a.Region
+		}
+		// The endpoint of the session is for S3 (for example, a S3 compatible service), which
+		// should not be used by KMS.
+		c.kms = kms.New(sess, aws.NewConfig().WithRegion(region).WithEndpoint(""))
+		c.kmsKeyID = keyID
+		if opt.HasKmsGrantTokens {
+			c.kmsGrantTokens = aws.StringSlice(opt.KmsGrantTokens)
+		}
+		return c, nil
+	}
+
+	block, err := aes.NewCipher(v.MasterKey)
+	if err != nil {
+		return nil, services.PairUnsupportedError{Pair: WithClientSideEncryption(v)}
+	}
+	c.masterKey, err = cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newEnvelope will generate a new data key and IV, and return the envelope metadata which
+// carries the wrapped data key.
+func (c *clientSideEncryption) newEnvelope(ctx context.Context) (key, iv []byte, metadata map[string]*string, err error) {
+	iv = make([]byte, gcmNonceSize)
+	if _, err = rand.Read(iv); err != nil {
+		return
+	}
+
+	var wrapped []byte
+	var wrapAlg string
+	matdesc := map[string]string{}
+	if c.kms != nil {
+		matdesc[cseKMSContextCekAlgKey] = c.cekAlg
+
+		var output *kms.GenerateDataKeyOutput
+		output, err = c.kms.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+			KeyId:             aws.String(c.kmsKeyID),
+			KeySpec:           aws.String(kms.DataKeySpecAes256),
+			EncryptionContext: aws.StringMap(matdesc),
+			GrantTokens:       c.kmsGrantTokens,
+		})
+		if err != nil {
+			return nil, nil, nil, formatKmsError(err)
+		}
+		key, wrapped, wrapAlg = output.Plaintext, output.CiphertextBlob, cseWrapAlgKMSContext
+	} else {
+		key = make([]byte, cseDataKeySize)
+		nonce := make([]byte, c.masterKey.NonceSize())
+		if _, err = rand.Read(key); err != nil {
+			return
+		}
+		if _, err = rand.Read(nonce); err != nil {
+			return
+		}
+		wrapped = c.masterKey.Seal(nonce, nonce, key, []byte(c.cekAlg))
+		wrapAlg = cseWrapAlgAESGCM
+	}
+
+	content, err := json.Marshal(matdesc)
+	if err != nil {
+		return
+	}
+	metadata = map[string]*string{
+		metadataCseKeyHeader:     aws.String(base64.StdEncoding.EncodeToString(wrapped)),
+		metadataCseIVHeader:      aws.String(base64.StdEncoding.EncodeToString(iv)),
+		metadataCseMatdescHeader: aws.String(string(content)),
+		metadataCseWrapAlgHeader: aws.String(wrapAlg),
+		metadataCseCekAlgHeader:  aws.String(c.cekAlg),
+		metadataCseTagLenHeader:  aws.String(strconv.Itoa(gcmTagSize * 8)),
+	}
+	return key, iv, metadata, nil
+}
+
+// openEnvelope will unwrap the data key from the envelope metadata of an encrypted object, and
+// return the content encryption algorithm.
+func (c *clientSideEncryption) openEnvelope(ctx context.Context, m map[string]*string) (key, iv []byte, alg string, err error) {
+	alg = aws.StringValue(m[metadataCseCekAlgHeader])
+	if alg != cseCekAlgAESGCMStream && alg != cseCekAlgAESGCM {
+		return nil, nil, "", fmt.Errorf("content encryption algorithm %s: %w", alg, services.ErrCapabilityInsufficient)
+	}
+	if v := aws.StringValue(m[metadataCseTagLenHeader]); v != "" && v != strconv.Itoa(gcmTagSize*8) {
+		return nil, nil, "", fmt.Errorf("tag length %s: %w", v, services.ErrCapabilityInsufficient)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(aws.StringValue(m[metadataCseKeyHeader]))
+	if err != nil {
+		return nil, nil, "", ErrObjectDecryptionFailed
+	}
+	iv, err = base64.StdEncoding.DecodeString(aws.StringValue(m[metadataCseIVHeader]))
+	if err != nil || len(iv) != gcmNonceSize {
+		return nil, nil, "", ErrObjectDecryptionFailed
+	}
+
+	switch wrapAlg := aws.StringValue(m[metadataCseWrapAlgHeader]); wrapAlg {
+	case cseWrapAlgKMSContext:
+		if c.kms == nil {
+			return nil, nil, "", fmt.Errorf("object encrypted with kms key: %w", ErrObjectDecryptionFailed)
+		}
+		matdesc := map[string]string{}
+		if err = json.Unmarshal([]byte(aws.StringValue(m[metadataCseMatdescHeader])), &matdesc); err != nil {
+			return nil, nil, "", ErrObjectDecryptionFailed
+		}
+		// The content encryption algorithm is protected by the encryption context.
+		if matdesc[cseKMSContextCekAlgKey] != alg {
+			return nil, nil, "", ErrObjectDecryptionFailed
+		}
+
+		output, err := c.kms.DecryptWithContext(ctx, &kms.DecryptInput{
+			KeyId:             aws.String(c.kmsKeyID),
+			CiphertextBlob:    wrapped,
+			EncryptionContext: aws.StringMap(matdesc),
+			GrantTokens:       c.kmsGrantTokens,
+		})
+		if err != nil {
+			return nil, nil, "", formatKmsError(err)
+		}
+		return output.Plaintext, iv, alg, nil
+	case cseWrapAlgAESGCM:
+		if c.masterKey == nil {
+			return nil, nil, "", fmt.Errorf("object encrypted with master key: %w", ErrObjectDecryptionFailed)
+		}
+		n := c.masterKey.NonceSize()
+		if len(wrapped) < n {
+			return nil, nil, "", ErrObjectDecryptionFailed
+		}
+		key, err = c.masterKey.Open(nil, wrapped[:n], wrapped[n:], []byte(alg))
+		if err != nil {
+			return nil, nil, "", ErrObjectDecryptionFailed
+		}
+		return key, iv, alg, nil
+	default:
+		return nil, nil, "", fmt.Errorf("key wrap algorithm %s: %w", wrapAlg, services.ErrCapabilityInsufficient)
+	}
+}
+
+// isClientSideEncrypted returns true if the object has been encrypted by a S3 encryption client.
+func isClientSideEncrypted(m map[string]*string) bool {
+	_, ok := m[metadataCseCekAlgHeader]
+	return ok
+}
+
+// cseSealedSize returns the size of the content sealed from size bytes of plaintext by alg.
+func cseSealedSize(alg string, size int64) int64 {
+	if alg == cseCekAlgAESGCMStream {
+		return gcmSealedSize(size)
+	}
+	return size + gcmTagSize
+}
+
+// parseClientSideEncryptedSize returns the size of the plaintext of an encrypted object, objects
+// uploaded by multipart don't have the unencrypted content length.
+func parseClientSideEncryptedSize(m map[string]*string, size int64) int64 {
+	if v, err := strconv.ParseInt(aws.StringValue(m[metadataCseUnencryptedContentLengthHeader]), 10, 64); err == nil {
+		return v
+	}
+	if aws.StringValue(m[metadataCseCekAlgHeader]) == cseCekAlgAESGCMStream {
+		n, _ := gcmOpenedSize(size)
+		return n
+	}
+	if size < gcmTagSize {
+		return 0
+	}
+	return size - gcmTagSize
+}
+
+// newClientSideDecryptReader will return the reader of the plaintext of the content encrypted by alg.
+func newClientSideDecryptReader(r io.Reader, alg string, key, iv []byte) (io.Reader, error) {
+	if alg == cseCekAlgAESGCMStream {
+		return newGCMDecryptReader(r, key, iv)
+	}
+
+	// Content not larger than cseBufferedSizeMaximum is authenticated before released.
+	content, err := ioutil.ReadAll(io.LimitReader(r, cseBufferedSizeMaximum+gcmTagSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > cseBufferedSizeMaximum+gcmTagSize {
+		return newGCMMessageDecryptReader(io.MultiReader(bytes.NewReader(content), r), key, iv)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrObjectDecryptionFailed
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(content[:0], iv, content, nil)
+	if err != nil {
+		return nil, ErrObjectDecryptionFailed
+	}
+	return bytes.NewReader(plaintext), nil
+}
+
+// cseMultipart is the state of an encrypted multipart upload, parts are sealed by the same
+// cipher in order.
+type cseMultipart struct {
+	lock sync.Mutex
+
+	cipher gcmSealer
+	// plaintext is the content written but not sealed yet as it's shorter than a chunk.
+	plaintext []byte
+	// next is the index of the next part expected.
+	next int
+	// pending is the last part written, which will be uploaded while the next part is written or
+	// the upload is completed, as the last chunk must be appended to the last part.
+	pending *csePendingPart
+	// etags are the etags of uploaded parts keyed by index.
+	etags map[int]string
+	// last is the last chunk of the whole content, which is only available after all parts written.
+	last []byte
+}
+
+type csePendingPart struct {
+	index int
+	data  []byte
+	opt   pairStorageWriteMultipart
+}
+
+func (c *clientSideEncryption) addMultipart(id string, key, iv []byte) error {
+	g, err := newGCMSealer(c.cekAlg, key, iv)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.uploads[id] = &cseMultipart{
+		cipher: g,
+		etags:  make(map[int]string),
+	}
+	return nil
+}
+
+func (c *clientSideEncryption) getMultipart(id string) (*cseMultipart, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	u, ok := c.uploads[id]
+	if !ok {
+		return nil, fmt.Errorf("encrypted multipart %s not created by this storage: %w", id, services.ErrCapabilityInsufficient)
+	}
+	return u, nil
+}
+
+func (c *clientSideEncryption) removeMultipart(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.uploads, id)
+}
+
+// writeEncryptedMultipart will seal the full chunks of the part and upload the previous one, the
+// rest of the part will be sealed along with the next one.
+func (s *Storage) writeEncryptedMultipart(ctx context.Context, o *typ.Object, r io.Reader, size int64, index int, opt pairStorageWriteMultipart) (n int64, part *typ.Part, err error) {
+	u, err := s.cse.getMultipart(o.MustGetMultipartID())
+	if err != nil {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if index != u.next {
+		err = fmt.Errorf("encrypted parts must be written in order, expected %d: %w", u.next, services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return
+	}
+	if u.pending != nil {
+		if err = s.uploadEncryptedPart(ctx, o, u, nil); err != nil {
+			return
+		}
+	}
+
+	plaintext := append(u.plaintext, data...)
+	sealed := make([]byte, 0, cseSealedSize(s.cse.cekAlg, int64(len(plaintext))))
+	for len(plaintext) >= gcmChunkSize {
+		if sealed, err = u.cipher.seal(sealed, plaintext[:gcmChunkSize], false); err != nil {
+			return
+		}
+		plaintext = plaintext[gcmChunkSize:]
+	}
+	u.plaintext = append([]byte(nil), plaintext...)
+	u.pending = &csePendingPart{index: index, data: sealed, opt: opt}
+	u.next++
+
+	// The part is not uploaded yet, so the etag is unknown.
+	part = &typ.Part{
+		Index: index,
+		Size:  size,
+	}
+	return size, part, nil
+}
+
+// completeEncryptedMultipart will upload the last part with the last chunk appended, and complete
+// the upload with the etags recorded.
+func (s *Storage) completeEncryptedMultipart(ctx context.Context, o *typ.Object, parts []*typ.Part, opt pairStorageCompleteMultipart) (output *s3.CompleteMultipartUploadOutput, err error) {
+	id := o.MustGetMultipartID()
+	u, err := s.cse.getMultipart(id)
+	if err != nil {
+		return
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	// Parts could not be skipped or reordered, as they are encrypted in one stream.
+	if len(parts) != u.next {
+		return nil, fmt.Errorf("all %d encrypted parts must be completed: %w", u.next, services.ErrRestrictionDissatisfied)
+	}
+	if u.pending != nil {
+		// The last chunk is kept, so that the completion could be retried.
+		if u.last == nil {
+			if u.last, err = u.cipher.seal(nil, u.plaintext, true); err != nil {
+				return
+			}
+		}
+		if err = s.uploadEncryptedPart(ctx, o, u, u.last); err != nil {
+			return
+		}
+	}
+	completed := make([]*typ.Part, 0, len(parts))
+	for i, v := range parts {
+		if v.Index != i {
+			return nil, fmt.Errorf("encrypted parts must be completed in order: %w", services.ErrRestrictionDissatisfied)
+		}
+		p := *v
+		p.ETag = u.etags[i]
+		completed = append(completed, &p)
+	}
+
+	input := s.formatCompleteMultipartUploadInput(o, completed, opt)
+	output, err = s.service.CompleteMultipartUploadWithContext(ctx, input)
+	if err != nil {
+		return
+	}
+	s.cse.removeMultipart(id)
+	return output, nil
+}
+
+func (s *Storage) uploadEncryptedPart(ctx context.Context, o *typ.Object, u *cseMultipart, last []byte) error {
+	p := u.pending
+	data := p.data
+	if len(last) > 0 {
+		data = append(data[:len(data):len(data)], last...)
+	}
+
+	input, err := s.formatUploadPartInput(o, int64(len(data)), p.index, p.opt)
+	if err != nil {
+		return err
+	}
+	input.Body = aws.ReadSeekCloser(bytes.NewReader(data))
+	// The content_md5 passed in is calculated from the plaintext, so it's replaced by the one of
+	// the encrypted part.
+	if s.contentIntegrityMode != "" || p.opt.HasContentMd5 {
+		sum := md5.Sum(data)
+		input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	output, err := s.service.UploadPartWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	u.etags[p.index] = aws.StringValue(output.ETag)
+	u.pending = nil
+	return nil
+}
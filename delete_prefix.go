@@ -0,0 +1,171 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+// deleteObjectsMaximum is the max number of keys could be deleted in a DeleteObjects request.
+const deleteObjectsMaximum = 1000
+
+// DeletePrefixOptions controls the behavior of DeletePrefix.
+type DeletePrefixOptions struct {
+	// AllVersions will delete all versions and delete markers of the objects, which is
+	// required to free storage of versioned buckets. It requires enable_versioning.
+	AllVersions bool
+	// DryRun will only report objects which would be deleted without deleting them.
+	DryRun bool
+	// Progress will be called after each batch of objects has been deleted.
+	Progress func(DeletePrefixProgress)
+}
+
+// DeletePrefixProgress carries the progress of DeletePrefix.
+type DeletePrefixProgress struct {
+	// Paths are the paths of the objects in the batch just handled.
+	Paths []string
+	// Deleted is the number of objects deleted so far, or would be deleted in dry-run.
+	Deleted int64
+	// Failed is the number of objects failed to delete so far.
+	Failed int64
+}
+
+// DeletePrefixSummary is the result of DeletePrefix.
+type DeletePrefixSummary struct {
+	Deleted int64
+	Failed  int64
+}
+
+// DeletePrefix will delete all objects under prefix in batches. prefix is matched as is, so
+// `abc` will also match `abcd`, add a trailing `/` to restrict the deletion to a dir.
+//
+// Objects failed to delete will be counted in the summary without stopping the deletion, only
+// request errors will be returned.
+func (s *Storage) DeletePrefix(ctx context.Context, prefix string, opt DeletePrefixOptions) (sum DeletePrefixSummary, err error) {
+	defer func() {
+		err = s.formatError("delete_prefix", err, prefix)
+	}()
+
+	if opt.AllVersions && !s.versioningEnabled {
+		return sum, fmt.Errorf("versioning not enabled: %w", services.ErrCapabilityInsufficient)
+	}
+
+	rp, err := s.getAbsPath(prefix)
+	if err != nil {
+		return
+	}
+	return s.deletePrefix(ctx, rp, opt)
+}
+
+func (s *Storage) deletePrefix(ctx context.Context, rp string, opt DeletePrefixOptions) (sum DeletePrefixSummary, err error) {
+	next := s.nextKeysToDelete
+	if opt.AllVersions {
+		next = s.nextVersionsToDelete
+	}
+
+	var page deletePageStatus
+	for !page.done {
+		var ids []*s3.ObjectIdentifier
+		ids, err = next(ctx, rp, &page)
+		if err != nil {
+			return
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		failed := 0
+		if !opt.DryRun {
+			failed, err = s.deleteObjects(ctx, ids)
+			if err != nil {
+				return
+			}
+		}
+		sum.Deleted += int64(len(ids) - failed)
+		sum.Failed += int64(failed)
+
+		if opt.Progress != nil {
+			paths := make([]string, 0, len(ids))
+			for _, v := range ids {
+				paths = append(paths, s.getRelPath(aws.StringValue(v.Key)))
+			}
+			opt.Progress(DeletePrefixProgress{Paths: paths, Deleted: sum.Deleted, Failed: sum.Failed})
+		}
+	}
+	return sum, nil
+}
+
+// deletePageStatus is the listing status of DeletePrefix.
+type deletePageStatus struct {
+	continuationToken *string
+	keyMarker         *string
+	versionIDMarker   *string
+	done              bool
+}
+
+func (s *Storage) nextKeysToDelete(ctx context.Context, rp string, page *deletePageStatus) ([]*s3.ObjectIdentifier, error) {
+	output, err := s.service.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:            aws.String(s.name),
+		Prefix:            aws.String(rp),
+		MaxKeys:           aws.Int64(deleteObjectsMaximum),
+		ContinuationToken: page.continuationToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]*s3.ObjectIdentifier, 0, len(output.Contents))
+	for _, v := range output.Contents {
+		ids = append(ids, &s3.ObjectIdentifier{Key: v.Key})
+	}
+
+	page.continuationToken = output.NextContinuationToken
+	page.done = !aws.BoolValue(output.IsTruncated)
+	return ids, nil
+}
+
+func (s *Storage) nextVersionsToDelete(ctx context.Context, rp string, page *deletePageStatus) ([]*s3.ObjectIdentifier, error) {
+	output, err := s.service.ListObjectVersionsWithContext(ctx, &s3.ListObjectVersionsInput{
+		Bucket:          aws.String(s.name),
+		Prefix:          aws.String(rp),
+		MaxKeys:         aws.Int64(deleteObjectsMaximum),
+		KeyMarker:       page.keyMarker,
+		VersionIdMarker: page.versionIDMarker,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions and delete markers share the MaxKeys, so they fit into one batch.
+	ids := make([]*s3.ObjectIdentifier, 0, len(output.Versions)+len(output.DeleteMarkers))
+	for _, v := range output.Versions {
+		ids = append(ids, &s3.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+	}
+	for _, v := range output.DeleteMarkers {
+		ids = append(ids, &s3.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+	}
+
+	page.keyMarker = output.NextKeyMarker
+	page.versionIDMarker = output.NextVersionIdMarker
+	page.done = !aws.BoolValue(output.IsTruncated)
+	return ids, nil
+}
+
+// deleteObjects will delete objects in a batch, and return the number of objects failed to delete.
+func (s *Storage) deleteObjects(ctx context.Context, ids []*s3.ObjectIdentifier) (failed int, err error) {
+	output, err := s.service.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(s.name),
+		Delete: &s3.Delete{
+			Objects: ids,
+			// Only errors are needed.
+			Quiet: aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(output.Errors), nil
+}
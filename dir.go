@@ -0,0 +1,116 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// All available dir marker strategies are listed here.
+const (
+	// DirMarkerSlash uses a zero-byte object with a trailing `/` like `dir/` as the dir marker,
+	// which is the same as the AWS console. It's the default strategy.
+	DirMarkerSlash = "slash"
+	// DirMarkerFolder uses a zero-byte object with the `_$folder$` suffix like `dir_$folder$` as
+	// the dir marker, which is the same as Hadoop and EMR.
+	DirMarkerFolder = "folder"
+	// DirMarkerNone doesn't use dir markers at all, dirs only exist while there are objects under them.
+	DirMarkerNone = "none"
+)
+
+// dirMarkerFolderSuffix is the suffix of dir markers created by Hadoop.
+const dirMarkerFolderSuffix = "_$folder$"
+
+// formatDirMarker returns the key of the marker of the dir at rp, which has no trailing `/`.
+func (s *Storage) formatDirMarker(rp string) string {
+	if s.dirMarker == DirMarkerFolder {
+		return rp + dirMarkerFolderSuffix
+	}
+	return rp + "/"
+}
+
+// formatDirMarkerObject will build the dir object from a dir marker in listing, and return
+// nil if v is not a dir marker.
+func (s *Storage) formatDirMarkerObject(v *s3.Object) *typ.Object {
+	if s.dirMarker != DirMarkerFolder || !strings.HasSuffix(aws.StringValue(v.Key), dirMarkerFolderSuffix) {
+		return nil
+	}
+
+	o := s.newObject(true)
+	o.ID = *v.Key
+	o.Path = s.getRelPath(strings.TrimSuffix(*v.Key, dirMarkerFolderSuffix) + "/")
+	o.Mode |= typ.ModeDir
+	o.SetLastModified(aws.TimeValue(v.LastModified))
+	return o
+}
+
+// statDirPrefix will stat the dir at rp by checking whether there are objects under it,
+// which is used while dir markers are not in use.
+func (s *Storage) statDirPrefix(ctx context.Context, path, rp string, opt pairStorageStat) (o *typ.Object, err error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.name),
+		Prefix:  aws.String(rp + "/"),
+		MaxKeys: aws.Int64(1),
+	}
+	if opt.HasExceptedBucketOwner {
+		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+
+	output, err := s.service.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Contents) == 0 {
+		return nil, services.ErrObjectNotExist
+	}
+
+	o = s.newObject(true)
+	o.ID = rp + "/"
+	o.Path = path
+	o.Mode |= typ.ModeDir
+	return o, nil
+}
+
+// Exists will check whether the object exists via stat, pairs of stat like WithObjectMode are
+// accepted. Objects not found return (false, nil) instead of services.ErrObjectNotExist, while
+// other errors like permission denied are still returned.
+func (s *Storage) Exists(ctx context.Context, path string, pairs ...typ.Pair) (bool, error) {
+	_, err := s.StatWithContext(ctx, path, pairs...)
+	if errors.Is(err, services.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasPrefix will check whether any object exists under prefix via a single listing with
+// MaxKeys=1, which is the way to check whether a dir exists in S3 regardless of dir markers.
+//
+// prefix is used as is, so pass `dir/` instead of `dir` to exclude objects like `dir2`.
+func (s *Storage) HasPrefix(ctx context.Context, prefix string) (ok bool, err error) {
+	defer func() {
+		err = s.formatError("has_prefix", err, prefix)
+	}()
+
+	rp, err := s.getAbsPath(prefix)
+	if err != nil {
+		return
+	}
+
+	output, err := s.service.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.name),
+		Prefix:  aws.String(rp),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return
+	}
+	return len(output.Contents) > 0, nil
+}
@@ -0,0 +1,105 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// DownloadFile will download the object at path into localPath.
+//
+// Content is downloaded into `<localPath>.part` first, along with the object's etag kept in
+// `<localPath>.part.etag`. If a previous download has been interrupted, DownloadFile will resume
+// from the size of the partial file as long as the object's etag is not changed, otherwise the
+// download will start over. localPath will only be created after the download finished.
+//
+// pairs will be passed to every read. The progress reported by progress_callback covers the
+// whole object, including the resumed part.
+func (s *Storage) DownloadFile(ctx context.Context, path, localPath string, pairs ...typ.Pair) (err error) {
+	progress, pairs := splitProgressPair(pairs)
+
+	o, err := s.StatWithContext(ctx, path, filterPairs(pairs,
+		"excepted_bucket_owner",
+		"server_side_encryption_customer_algorithm",
+		"server_side_encryption_customer_key",
+	)...)
+	if err != nil {
+		return
+	}
+	size := o.MustGetContentLength()
+	etag, _ := o.GetEtag()
+
+	partPath := localPath + ".part"
+	etagPath := partPath + ".etag"
+
+	var offset int64
+	if prev, err := ioutil.ReadFile(etagPath); err == nil && etag != "" && string(prev) == etag {
+		if fi, err := os.Stat(partPath); err == nil && fi.Size() <= size {
+			offset = fi.Size()
+		}
+	}
+	if offset == 0 {
+		if err = ioutil.WriteFile(etagPath, []byte(etag), 0644); err != nil {
+			return
+		}
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(partPath, flag, 0644)
+	if err != nil {
+		return
+	}
+	var w io.Writer = f
+	var t *progressTracker
+	if progress != nil {
+		t = newProgressTracker(progress, size, offset)
+		w = &progressWriter{w: f, t: t}
+	}
+	err = s.downloadRange(ctx, path, f, w, offset, size, etag, pairs)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	if t != nil {
+		// The object may have been downloaded entirely before.
+		t.finish()
+	}
+
+	if err = os.Rename(partPath, localPath); err != nil {
+		return
+	}
+	return os.Remove(etagPath)
+}
+
+// downloadRange will download the object since offset into f via w, which wraps f.
+func (s *Storage) downloadRange(ctx context.Context, path string, f *os.File, w io.Writer, offset, size int64, etag string, pairs []typ.Pair) (err error) {
+	if offset < size {
+		// Pairs are parsed in order and the first one wins, so offset must come first.
+		readPairs := []typ.Pair{ps.WithOffset(offset)}
+		if etag != "" {
+			readPairs = append(readPairs, WithIfMatch(etag))
+		}
+		readPairs = append(readPairs, pairs...)
+
+		n, err := s.ReadWithContext(ctx, path, w, readPairs...)
+		if err != nil {
+			return err
+		}
+		if offset+n != size {
+			return fmt.Errorf("download %s: expected %d bytes, got %d: %w",
+				path, size, offset+n, services.ErrUnexpected)
+		}
+	}
+	return f.Sync()
+}
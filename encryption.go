@@ -0,0 +1,114 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// EncryptionRequiredError will be returned while require_encryption is enabled, and the write
+// would create an unencrypted object.
+//
+// EncryptionRequiredError wraps ErrEncryptionRequired, so both `errors.Is(err, ErrEncryptionRequired)`
+// and `errors.As(err, &EncryptionRequiredError{})` could be used.
+type EncryptionRequiredError struct {
+	// Operation is the name of the rejected S3 API operation, for example `PutObject`.
+	Operation string
+	// Key is the absolute key of the object to be written.
+	Key string
+}
+
+func (e EncryptionRequiredError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Operation, e.Key, ErrEncryptionRequired)
+}
+
+func (e EncryptionRequiredError) Unwrap() error {
+	return ErrEncryptionRequired
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e EncryptionRequiredError) IsInternalError() {}
+
+// requireEncryptionHandler will reject requests which create objects without encryption, it's
+// added as a request handler so that all writes (including dir placeholders, links, copies and
+// presigned requests) are covered.
+func (s *Storage) requireEncryptionHandler(r *request.Request) {
+	if r.Error != nil {
+		return
+	}
+
+	var key, sse, sseC *string
+	var metadata map[string]*string
+	switch v := r.Params.(type) {
+	case *s3.PutObjectInput:
+		key, sse, sseC, metadata = v.Key, v.ServerSideEncryption, v.SSECustomerAlgorithm, v.Metadata
+	case *s3.CopyObjectInput:
+		key, sse, sseC, metadata = v.Key, v.ServerSideEncryption, v.SSECustomerAlgorithm, v.Metadata
+	case *s3.CreateMultipartUploadInput:
+		key, sse, sseC, metadata = v.Key, v.ServerSideEncryption, v.SSECustomerAlgorithm, v.Metadata
+	default:
+		return
+	}
+	if aws.StringValue(sse) != "" || aws.StringValue(sseC) != "" || isClientSideEncrypted(metadata) {
+		return
+	}
+
+	algorithm, err := s.getDefaultEncryption(r.Context())
+	if err != nil {
+		r.Error = err
+		return
+	}
+	if algorithm == "" {
+		r.Error = EncryptionRequiredError{Operation: r.Operation.Name, Key: aws.StringValue(key)}
+	}
+}
+
+// getDefaultEncryption returns the default server-side encryption algorithm of the bucket, which
+// will be empty if the bucket has no default encryption. The result will be cached after it has
+// been fetched successfully.
+func (s *Storage) getDefaultEncryption(ctx context.Context) (string, error) {
+	s.defaultEncryptionLock.Lock()
+	defer s.defaultEncryptionLock.Unlock()
+
+	if s.defaultEncryption != nil {
+		return *s.defaultEncryption, nil
+	}
+
+	algorithm, _, err := s.fetchDefaultEncryption(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.defaultEncryption = &algorithm
+	return algorithm, nil
+}
+
+// fetchDefaultEncryption returns the algorithm and the AWS KMS key id of the default server-side
+// encryption of the bucket, both will be empty if the bucket has no default encryption.
+func (s *Storage) fetchDefaultEncryption(ctx context.Context) (algorithm, kmsKeyID string, err error) {
+	output, err := s.service.GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{
+		Bucket: aws.String(s.name),
+	})
+	if err != nil {
+		// Buckets without default encryption will return ServerSideEncryptionConfigurationNotFoundError.
+		if e, ok := err.(awserr.Error); ok && e.Code() == "ServerSideEncryptionConfigurationNotFoundError" {
+			return "", "", nil
+		}
+		return
+	}
+	if output.ServerSideEncryptionConfiguration == nil {
+		return
+	}
+	for _, rule := range output.ServerSideEncryptionConfiguration.Rules {
+		if rule.ApplyServerSideEncryptionByDefault == nil {
+			continue
+		}
+		algorithm = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+		kmsKeyID = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+		break
+	}
+	return
+}
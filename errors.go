@@ -1,10 +1,255 @@
 package s3
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+
 	"github.com/minhjh/go-storage/v4/services"
 )
 
 var (
 	// ErrServerSideEncryptionCustomerKeyInvalid will be returned while server-side encryption customer key is invalid.
 	ErrServerSideEncryptionCustomerKeyInvalid = services.NewErrorCode("invalid server-side encryption customer key")
+	// ErrBucketNotExist will be returned while the bucket does not exist.
+	ErrBucketNotExist = services.NewErrorCode("bucket not exist")
+	// ErrBucketAlreadyExists will be returned while the bucket name has been taken, either by others or by the caller itself.
+	ErrBucketAlreadyExists = services.NewErrorCode("bucket already exists")
+	// ErrMultipartNotExist will be returned while the multipart upload does not exist, it may have been aborted or completed.
+	ErrMultipartNotExist = services.NewErrorCode("multipart not exist")
+	// ErrRangeNotSatisfiable will be returned while the requested range can not be satisfied by the object.
+	ErrRangeNotSatisfiable = services.NewErrorCode("range not satisfiable")
+	// ErrPreconditionFailed will be returned while at least one of the preconditions specified did not hold.
+	ErrPreconditionFailed = services.NewErrorCode("precondition failed")
+	// ErrObjectNotModified will be returned while the object has not been modified according to the conditions specified.
+	ErrObjectNotModified = services.NewErrorCode("object not modified")
+	// ErrRateLimited will be returned while S3 asks the caller to reduce the request rate.
+	ErrRateLimited = services.NewErrorCode("rate limited")
+	// ErrRequestTimeout will be returned while the connection was not read from or written to within the timeout period.
+	ErrRequestTimeout = services.NewErrorCode("request timeout")
+	// ErrKmsRequestFailed will be returned while S3 failed to use the AWS KMS key for server-side encryption.
+	ErrKmsRequestFailed = services.NewErrorCode("kms request failed")
+	// ErrKmsAccessDenied will be returned while the caller is not allowed to use the AWS KMS key, either by S3 for
+	// server-side encryption or directly for client-side encryption.
+	ErrKmsAccessDenied = services.NewErrorCode("kms access denied")
+	// ErrPathInvalid will be returned while the path is invalid, for example, escapes the work dir.
+	ErrPathInvalid = services.NewErrorCode("invalid path")
+	// ErrContentEncodingUnsupported will be returned while the content encoding is not supported for compression or decompression.
+	ErrContentEncodingUnsupported = services.NewErrorCode("content encoding unsupported")
+	// ErrObjectArchived will be returned while reading an object in archived storage class which has not been restored.
+	ErrObjectArchived = services.NewErrorCode("object archived")
+	// ErrLinkLoop will be returned while following a chain of links which is a loop or too deep.
+	ErrLinkLoop = services.NewErrorCode("too many levels of links")
+	// ErrNotLink will be returned while reading the target of an object which is not a link.
+	ErrNotLink = services.NewErrorCode("not a link")
+	// ErrLeaseHeld will be returned while acquiring a lease which is held by others.
+	ErrLeaseHeld = services.NewErrorCode("lease held")
+	// ErrLeaseLost will be returned while renewing or releasing a lease which has been taken over by others.
+	ErrLeaseLost = services.NewErrorCode("lease lost")
+	// ErrNetworkUnreachable will be returned while the request could not reach S3, for example, DNS or connection failures.
+	ErrNetworkUnreachable = services.NewErrorCode("network unreachable")
+	// ErrEncryptionRequired will be returned while the write would create an unencrypted object with require_encryption enabled.
+	ErrEncryptionRequired = services.NewErrorCode("encryption required")
+	// ErrContentUnverifiable will be returned while writing unseekable content without content_md5 in the strict content integrity mode.
+	ErrContentUnverifiable = services.NewErrorCode("content unverifiable")
+	// ErrBucketPolicyUnsatisfied will be returned while the bucket policy denies requests sent with the config of the storage with policy_preflight enabled.
+	ErrBucketPolicyUnsatisfied = services.NewErrorCode("bucket policy unsatisfied")
+	// ErrOperationDenied will be returned while the operation is denied by the operation policy of the storage.
+	ErrOperationDenied = services.NewErrorCode("operation denied")
+	// ErrObjectDecryptionFailed will be returned while the client-side encrypted object could not be decrypted,
+	// for example, it's been tampered or encrypted with another key.
+	ErrObjectDecryptionFailed = services.NewErrorCode("object decryption failed")
+	// ErrCassetteInteractionNotFound will be returned while replaying a request which is not the next one recorded in the cassette.
+	ErrCassetteInteractionNotFound = services.NewErrorCode("cassette interaction not found")
+	// ErrMultipartPartInvalid will be returned while completing a multipart upload with parts missing, mismatched or too small.
+	ErrMultipartPartInvalid = services.NewErrorCode("multipart part invalid")
+	// ErrListPageLimitExceeded will be returned while the listing requests more pages than list_max_pages.
+	ErrListPageLimitExceeded = services.NewErrorCode("list page limit exceeded")
+	// ErrTruncatedBody will be returned while the body of the response ends before Content-Length bytes are received.
+	ErrTruncatedBody = services.NewErrorCode("truncated body")
+	// ErrMirrorWriteFailed will be returned while the object failed to be written to some of write_mirrors.
+	ErrMirrorWriteFailed = services.NewErrorCode("mirror write failed")
+	// ErrAccessLogMalformed will be returned while a line of server access logs could not be parsed.
+	ErrAccessLogMalformed = services.NewErrorCode("access log malformed")
+	// ErrNotificationMalformed will be returned while a message of event notifications could not be parsed.
+	ErrNotificationMalformed = services.NewErrorCode("notification malformed")
 )
+
+// RateLimitedError will be returned while S3 asks the caller to reduce the request rate.
+//
+// RateLimitedError wraps ErrRateLimited, so both `errors.Is(err, ErrRateLimited)` and
+// `errors.As(err, &RateLimitedError{})` could be used.
+type RateLimitedError struct {
+	// RetryAfter is the backoff suggested by S3 via the `Retry-After` header.
+	// It will be zero if S3 doesn't suggest one.
+	RetryAfter time.Duration
+
+	Err error
+}
+
+func (e RateLimitedError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v, retry after %s", e.Err, e.RetryAfter)
+	}
+	return e.Err.Error()
+}
+
+func (e RateLimitedError) Unwrap() error {
+	return e.Err
+}
+
+// ObjectArchivedError will be returned while reading an object in archived storage class
+// (GLACIER, DEEP_ARCHIVE and so on) which has not been restored.
+//
+// ObjectArchivedError wraps ErrObjectArchived, so both `errors.Is(err, ErrObjectArchived)` and
+// `errors.As(err, &ObjectArchivedError{})` could be used. Restore could be used to make it readable.
+type ObjectArchivedError struct {
+	// StorageClass is the storage class of the object, it will be empty if it can't be detected.
+	StorageClass string
+	// RestoreOngoing is true if a restore has been initiated but not completed yet.
+	RestoreOngoing bool
+
+	Err error
+}
+
+func (e ObjectArchivedError) Error() string {
+	msg := e.Err.Error()
+	if e.StorageClass != "" {
+		msg += ", storage class " + e.StorageClass
+	}
+	if e.RestoreOngoing {
+		msg += ", restore ongoing"
+	}
+	return msg
+}
+
+func (e ObjectArchivedError) Unwrap() error {
+	return e.Err
+}
+
+// MultipartPartError will be returned while S3 rejects parts passed to complete_multipart with
+// `InvalidPart` or `EntityTooSmall`, so that only the rejected parts need to be uploaded again.
+//
+// MultipartPartError wraps ErrMultipartPartInvalid, so both `errors.Is(err, ErrMultipartPartInvalid)`
+// and `errors.As(err, &MultipartPartError{})` could be used.
+type MultipartPartError struct {
+	// Code is the error code returned by S3, either `InvalidPart` or `EntityTooSmall`.
+	Code string
+	// Indexes are the zero-based indexes of the rejected parts, the same as typ.Part.Index.
+	// It will be empty if they can't be detected.
+	Indexes []int
+
+	Err error
+}
+
+func (e MultipartPartError) Error() string {
+	if len(e.Indexes) > 0 {
+		return fmt.Sprintf("%v, parts %v", e.Err, e.Indexes)
+	}
+	return e.Err.Error()
+}
+
+func (e MultipartPartError) Unwrap() error {
+	return e.Err
+}
+
+// multipartPartError carries the indexes of the rejected parts along with the original request
+// failure.
+type multipartPartError struct {
+	awserr.RequestFailure
+
+	indexes []int
+}
+
+// objectArchivedError carries the archive status got from HeadObject along with the original
+// request failure.
+type objectArchivedError struct {
+	awserr.RequestFailure
+
+	storageClass   string
+	restoreOngoing bool
+}
+
+// kmsRequestError marks the request failure returned by KMS directly (for client-side
+// encryption), whose error codes are not prefixed by `KMS.` as forwarded by S3.
+type kmsRequestError struct {
+	awserr.RequestFailure
+}
+
+// formatKmsError will mark the error as returned by KMS, so that it could be formatted as
+// ErrKmsRequestFailed or ErrKmsAccessDenied.
+func formatKmsError(err error) error {
+	if e, ok := err.(awserr.RequestFailure); ok {
+		return kmsRequestError{RequestFailure: e}
+	}
+	return err
+}
+
+// retryAfterError carries the `Retry-After` header along with the original request failure,
+// so that SDK retry logic still works as expected.
+type retryAfterError struct {
+	awserr.RequestFailure
+
+	retryAfter time.Duration
+}
+
+// retryAfterHandler will keep the `Retry-After` header returned by S3 in request error.
+var retryAfterHandler = request.NamedHandler{
+	Name: "s3.RetryAfterHandler",
+	Fn: func(r *request.Request) {
+		e, ok := r.Error.(awserr.RequestFailure)
+		if !ok || r.HTTPResponse == nil {
+			return
+		}
+		d := parseRetryAfter(r.HTTPResponse.Header.Get("Retry-After"))
+		if d <= 0 {
+			return
+		}
+		r.Error = retryAfterError{RequestFailure: e, retryAfter: d}
+	},
+}
+
+// completeMultipartResultHandler will fail CompleteMultipartUpload responded with 200 but
+// without a result, which is retryable.
+//
+// S3 may respond 200 before the upload is completed, and put the error into the body later. The
+// SDK detects error bodies, but bodies of other formats returned by some S3 compatible services
+// will be unmarshalled into an empty output, which must not be reported as success.
+var completeMultipartResultHandler = request.NamedHandler{
+	Name: "s3.CompleteMultipartResultHandler",
+	Fn: func(r *request.Request) {
+		if r.Error != nil || r.Operation.Name != "CompleteMultipartUpload" {
+			return
+		}
+		output, ok := r.Data.(*s3.CompleteMultipartUploadOutput)
+		if !ok || aws.StringValue(output.ETag) != "" {
+			return
+		}
+		r.Error = awserr.NewRequestFailure(
+			awserr.New("InternalError", "CompleteMultipartUpload responded without result", nil),
+			r.HTTPResponse.StatusCode, r.RequestID)
+		r.Retryable = aws.Bool(true)
+	},
+}
+
+// parseRetryAfter parses `Retry-After` header which could be either delay seconds or a HTTP date.
+//
+// ref: https://datatracker.ietf.org/doc/html/rfc7231#section-7.1.3
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
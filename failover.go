@@ -0,0 +1,237 @@
+package s3
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+// failoverCooldown is the time an endpoint is skipped after it failed to be connected, it's
+// doubled for every failed health check of the endpoint up to failoverCooldownMaximum.
+const failoverCooldown = 30 * time.Second
+
+// failoverCooldownMaximum is the maximum time between health checks of an unhealthy endpoint.
+const failoverCooldownMaximum = 5 * time.Minute
+
+// failoverProbeTimeout is the timeout of connecting to an endpoint while checking its health.
+const failoverProbeTimeout = 5 * time.Second
+
+// failoverWriteSpoolThreshold is the write_spool_threshold used while failover endpoints are set
+// but write_spool_threshold is not, as unseekable content could not be sent again to the next
+// endpoint unless it's spooled.
+const failoverWriteSpoolThreshold = 8 * 1024 * 1024
+
+// endpointPool routes requests to the first healthy endpoint, endpoints are marked unhealthy
+// while they could not be connected, which is detected passively by requests sent.
+//
+// Unhealthy endpoints work like an open circuit breaker: requests are not sent to them until a
+// health check connecting to the endpoint in the background passes, so that requests stay on
+// the failover endpoints instead of paying a timeout every time the cooldown ends.
+//
+// The first endpoint is the primary one, which will be used again once it's healthy.
+type endpointPool struct {
+	endpoints []*url.URL
+	// probe checks whether the endpoint could be connected.
+	probe func(u *url.URL) error
+
+	lock sync.Mutex
+	// health is the state of the endpoint of the same index.
+	health []endpointHealth
+}
+
+type endpointHealth struct {
+	// down is set after the endpoint failed to be connected, until a health check passes.
+	down bool
+	// failures is the count of consecutive failed health checks.
+	failures int
+	// checkAt is the time after which the endpoint will be checked again.
+	checkAt time.Time
+	// checking is set while a health check of the endpoint is running.
+	checking bool
+}
+
+func newEndpointPool(primary string, failovers []string) (*endpointPool, error) {
+	p := &endpointPool{probe: dialEndpoint}
+	for _, v := range append([]string{primary}, failovers...) {
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		p.endpoints = append(p.endpoints, u)
+	}
+	p.health = make([]endpointHealth, len(p.endpoints))
+	return p, nil
+}
+
+// apply adds the handlers into the storage's client handlers.
+func (p *endpointPool) apply(h *request.Handlers) {
+	// The endpoint must be chosen before the request is signed, as the host is signed.
+	h.Sign.PushFrontNamed(request.NamedHandler{
+		Name: "s3.FailoverEndpointHandler",
+		Fn:   p.endpointHandler,
+	})
+	h.Retry.PushBackNamed(request.NamedHandler{
+		Name: "s3.FailoverRetryHandler",
+		Fn:   p.retryHandler,
+	})
+}
+
+// match returns the index of the endpoint serving host, prefix is the bucket of virtual hosted
+// style requests like `bucket.`.
+func (p *endpointPool) match(host string) (idx int, prefix string, ok bool) {
+	for i, u := range p.endpoints {
+		if host == u.Host {
+			return i, "", true
+		}
+		if strings.HasSuffix(host, "."+u.Host) {
+			return i, strings.TrimSuffix(host, u.Host), true
+		}
+	}
+	return 0, "", false
+}
+
+// pick returns the first healthy endpoint, or the one which will be checked first if all of them
+// are unhealthy. Health checks of unhealthy endpoints are started once their cooldown ends.
+func (p *endpointPool) pick() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	idx, next := -1, 0
+	for i := range p.health {
+		h := &p.health[i]
+		if !h.down {
+			if idx < 0 {
+				idx = i
+			}
+			continue
+		}
+		if !h.checking && !now.Before(h.checkAt) {
+			h.checking = true
+			go p.check(i)
+		}
+		if h.checkAt.Before(p.health[next].checkAt) {
+			next = i
+		}
+	}
+	if idx < 0 {
+		return next
+	}
+	return idx
+}
+
+func (p *endpointPool) markDown(idx int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	h := &p.health[idx]
+	h.down = true
+	h.checkAt = time.Now().Add(failoverBackoff(h.failures))
+}
+
+// check will mark the endpoint healthy if it could be connected, or wait for a longer cooldown
+// before the next check.
+func (p *endpointPool) check(idx int) {
+	err := p.probe(p.endpoints[idx])
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	h := &p.health[idx]
+	h.checking = false
+	if err == nil {
+		*h = endpointHealth{}
+		return
+	}
+	h.failures++
+	h.checkAt = time.Now().Add(failoverBackoff(h.failures))
+}
+
+// failoverBackoff returns the cooldown of an endpoint after failures failed health checks.
+func failoverBackoff(failures int) time.Duration {
+	d := failoverCooldown
+	for i := 0; i < failures && d < failoverCooldownMaximum; i++ {
+		d *= 2
+	}
+	if d > failoverCooldownMaximum {
+		d = failoverCooldownMaximum
+	}
+	return d
+}
+
+// dialEndpoint checks the health of the endpoint by connecting to it, which is the failure
+// failover is triggered by.
+func dialEndpoint(u *url.URL) error {
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, failoverProbeTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// endpointHandler will route the request to the picked endpoint, it runs before every attempt.
+func (p *endpointPool) endpointHandler(r *request.Request) {
+	u := r.HTTPRequest.URL
+	_, prefix, ok := p.match(u.Host)
+	if !ok {
+		return
+	}
+	ep := p.endpoints[p.pick()]
+	u.Scheme = ep.Scheme
+	u.Host = prefix + ep.Host
+	// The Host header follows the url if it's empty.
+	r.HTTPRequest.Host = ""
+}
+
+// retryHandler will mark the endpoint unhealthy while it could not be connected, and retry the
+// request on the next endpoint.
+func (p *endpointPool) retryHandler(r *request.Request) {
+	if r.Error == nil || r.Context().Err() != nil {
+		return
+	}
+	// Request failures carry the status code, which means the endpoint has responded.
+	if _, ok := r.Error.(awserr.RequestFailure); ok {
+		return
+	}
+	if e, ok := r.Error.(awserr.Error); !ok || e.Code() != request.ErrCodeRequestError {
+		return
+	}
+
+	idx, _, ok := p.match(r.HTTPRequest.URL.Host)
+	if !ok {
+		return
+	}
+	p.markDown(idx)
+	r.Retryable = aws.Bool(true)
+}
+
+// parseFailoverEndpoints parses failover_endpoints in the format of the endpoint pair.
+func parseFailoverEndpoints(primary string, vs []string) (*endpointPool, error) {
+	if primary == "" {
+		return nil, services.PairUnsupportedError{Pair: WithFailoverEndpoints(vs)}
+	}
+	failovers := make([]string, 0, len(vs))
+	for _, v := range vs {
+		u, err := parseEndpoint(v)
+		if err != nil {
+			return nil, err
+		}
+		failovers = append(failovers, u)
+	}
+	return newEndpointPool(primary, failovers)
+}
@@ -0,0 +1,151 @@
+package s3
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// faultErrorBody is the body of the injected error response, which is the same as the one
+// returned by S3 while asking the caller to reduce the request rate.
+const faultErrorBody = `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>SlowDown</Code><Message>Please reduce your request rate. (injected fault)</Message></Error>`
+
+// FaultPolicy describes the faults injected into requests sent to S3, so that retry logic could be
+// verified against simulated S3 flakiness without external proxies.
+//
+// Faults are injected in the send handler of the SDK, so injected errors go through the same retry
+// and error handling as real ones.
+type FaultPolicy struct {
+	// ErrorRate is the probability in [0, 1] that a request fails with 503 SlowDown without being sent.
+	ErrorRate float64
+	// Latency is the extra delay before every request is sent.
+	Latency time.Duration
+	// TruncateRate is the probability in [0, 1] that the body of a GetObject response is truncated
+	// in half, reading from it will return io.ErrUnexpectedEOF.
+	TruncateRate float64
+	// Operations limits the faults to the listed S3 API operations like `GetObject`, faults will be
+	// injected into all operations if it's empty.
+	Operations []string
+	// Seed is the seed of the random source, the current time will be used if it's zero.
+	Seed int64
+}
+
+func (p FaultPolicy) apply(h *request.Handlers) {
+	seed := p.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	f := &faultInjector{
+		policy: p,
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+	h.Send.Swap(corehandlers.SendHandler.Name, request.NamedHandler{
+		Name: "s3.FaultSendHandler",
+		Fn:   f.send,
+	})
+}
+
+type faultInjector struct {
+	policy FaultPolicy
+
+	// rand is not safe for concurrent use.
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+func (f *faultInjector) send(r *request.Request) {
+	if !f.match(r.Operation.Name) {
+		corehandlers.SendHandler.Fn(r)
+		return
+	}
+
+	if f.policy.Latency > 0 {
+		t := time.NewTimer(f.policy.Latency)
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			t.Stop()
+			r.Error = awserr.New(request.CanceledErrorCode, "request context canceled", r.Context().Err())
+			return
+		}
+	}
+
+	if f.hit(f.policy.ErrorRate) {
+		r.HTTPResponse = &http.Response{
+			Status:     "503 Service Unavailable",
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Content-Type": []string{"application/xml"}},
+			Body:       ioutil.NopCloser(strings.NewReader(faultErrorBody)),
+			Request:    r.HTTPRequest,
+		}
+		return
+	}
+
+	corehandlers.SendHandler.Fn(r)
+
+	if r.Error == nil && r.HTTPResponse != nil && r.Operation.Name == "GetObject" &&
+		r.HTTPResponse.StatusCode < 300 && f.hit(f.policy.TruncateRate) {
+		n := r.HTTPResponse.ContentLength / 2
+		if n < 0 {
+			n = 0
+		}
+		r.HTTPResponse.Body = &truncatedReadCloser{rc: r.HTTPResponse.Body, n: n}
+	}
+}
+
+func (f *faultInjector) match(operation string) bool {
+	if len(f.policy.Operations) == 0 {
+		return true
+	}
+	for _, v := range f.policy.Operations {
+		if v == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *faultInjector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.rand.Float64() < rate
+}
+
+// truncatedReadCloser returns io.ErrUnexpectedEOF after n bytes have been read, like a connection
+// closed by S3 in the middle of the response.
+type truncatedReadCloser struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+func (t *truncatedReadCloser) Read(p []byte) (int, error) {
+	if t.n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > t.n {
+		p = p[:t.n]
+	}
+	n, err := t.rc.Read(p)
+	t.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (t *truncatedReadCloser) Close() error {
+	return t.rc.Close()
+}
@@ -0,0 +1,566 @@
+package s3
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// gcmNonceSize is the size of the IV stored in the envelope.
+	gcmNonceSize = 12
+	// gcmTagSize is the size of the authentication tag appended to every chunk.
+	gcmTagSize = 16
+	// gcmChunkSize is the size of the plaintext sealed in every chunk but the last one.
+	gcmChunkSize = 64 * 1024
+	// gcmNoncePrefixSize is the size of the part of the IV used as the prefix of chunk nonces,
+	// which are followed by a 4 bytes chunk counter and a 1 byte flag of the last chunk.
+	gcmNoncePrefixSize = gcmNonceSize - 5
+)
+
+// gcmChunkCipher seals and opens content in chunks with AES-GCM following the STREAM construction,
+// so that content could be encrypted and decrypted incrementally while every chunk is
+// authenticated before released. Chunks can't be reordered, dropped or truncated, as the nonce of
+// every chunk is derived from its index and whether it's the last one.
+//
+// Every chunk but the last one carries gcmChunkSize bytes of plaintext, the last one is always
+// shorter (and could be empty), so a stream must be ended by a chunk sealed with last set.
+//
+// ref: https://eprint.iacr.org/2015/189.pdf
+type gcmChunkCipher struct {
+	aead  cipher.AEAD
+	nonce [gcmNonceSize]byte
+	// counter is the index of the next chunk.
+	counter uint32
+	// done is set after the last chunk, no more chunks could be sealed or opened.
+	done bool
+}
+
+func newGCMChunkCipher(key, iv []byte) (*gcmChunkCipher, error) {
+	if len(iv) != gcmNonceSize {
+		return nil, errors.New("invalid gcm nonce size")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &gcmChunkCipher{aead: aead}
+	copy(c.nonce[:], iv[:gcmNoncePrefixSize])
+	return c, nil
+}
+
+// next returns the nonce of the next chunk.
+func (c *gcmChunkCipher) next(last bool) ([]byte, error) {
+	if c.done || (c.counter == 1<<32-1 && !last) {
+		return nil, errors.New("gcm chunk stream exhausted")
+	}
+	binary.BigEndian.PutUint32(c.nonce[gcmNoncePrefixSize:], c.counter)
+	c.nonce[gcmNonceSize-1] = 0
+	if last {
+		c.nonce[gcmNonceSize-1] = 1
+		c.done = true
+	}
+	c.counter++
+	return c.nonce[:], nil
+}
+
+// seal appends the sealed chunk of plaintext to dst.
+func (c *gcmChunkCipher) seal(dst, plaintext []byte, last bool) ([]byte, error) {
+	nonce, err := c.next(last)
+	if err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(dst, nonce, plaintext, nil), nil
+}
+
+// open appends the plaintext of the sealed chunk to dst, ErrObjectDecryptionFailed will be
+// returned if the chunk is not authenticated.
+func (c *gcmChunkCipher) open(dst, chunk []byte, last bool) ([]byte, error) {
+	nonce, err := c.next(last)
+	if err != nil {
+		return nil, err
+	}
+	dst, err = c.aead.Open(dst, nonce, chunk, nil)
+	if err != nil {
+		return nil, ErrObjectDecryptionFailed
+	}
+	return dst, nil
+}
+
+// gcmSealedSize returns the size of the content sealed from size bytes of plaintext.
+func gcmSealedSize(size int64) int64 {
+	return size + (size/gcmChunkSize+1)*gcmTagSize
+}
+
+// gcmOpenedSize returns the size of the plaintext of size bytes of sealed content.
+func gcmOpenedSize(size int64) (int64, bool) {
+	if size < gcmTagSize {
+		return 0, false
+	}
+	size -= gcmTagSize
+	n, last := size/(gcmChunkSize+gcmTagSize), size%(gcmChunkSize+gcmTagSize)
+	if last >= gcmChunkSize {
+		return 0, false
+	}
+	return n*gcmChunkSize + last, true
+}
+
+// gcmSealer seals content in pieces, the last piece must be sealed with last set.
+type gcmSealer interface {
+	seal(dst, plaintext []byte, last bool) ([]byte, error)
+}
+
+// newGCMSealer returns the gcmSealer of the content encryption algorithm alg.
+func newGCMSealer(alg string, key, iv []byte) (gcmSealer, error) {
+	if alg == cseCekAlgAESGCMStream {
+		return newGCMChunkCipher(key, iv)
+	}
+	return newGCMMessageCipher(key, iv)
+}
+
+// gcmEncryptReader seals the content read from r in chunks by the content encryption algorithm.
+type gcmEncryptReader struct {
+	r       io.Reader
+	alg     string
+	key, iv []byte
+	cipher  gcmSealer
+	// offset is the size of the sealed content read.
+	offset int64
+
+	plaintext []byte
+	// buf is the sealed content not read yet.
+	buf []byte
+	eof bool
+}
+
+func newGCMEncryptReader(r io.Reader, alg string, key, iv []byte) (*gcmEncryptReader, error) {
+	c, err := newGCMSealer(alg, key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmEncryptReader{
+		r:         r,
+		alg:       alg,
+		key:       key,
+		iv:        iv,
+		cipher:    c,
+		plaintext: make([]byte, gcmChunkSize),
+		buf:       make([]byte, 0, gcmChunkSize+gcmTagSize),
+	}, nil
+}
+
+func (e *gcmEncryptReader) Read(p []byte) (int, error) {
+	for len(e.buf) == 0 {
+		if e.eof {
+			return 0, io.EOF
+		}
+
+		// A full chunk is never the last one, the content ends with a shorter chunk.
+		n, err := io.ReadFull(e.r, e.plaintext)
+		last := false
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			last, e.eof = true, true
+		} else if err != nil {
+			return 0, err
+		}
+		if e.buf, err = e.cipher.seal(e.buf[:0], e.plaintext[:n], last); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+	e.offset += int64(n)
+	return n, nil
+}
+
+// gcmEncryptReadSeeker is a gcmEncryptReader of seekable content, which could be rewound to the
+// start, so that the sealed content could be checksummed in advance and resent while retrying.
+type gcmEncryptReadSeeker struct {
+	*gcmEncryptReader
+	rs io.ReadSeeker
+	// base is the offset of rs where the content starts.
+	base int64
+	// size is the size of the sealed content.
+	size int64
+}
+
+// newGCMEncryptReadSeeker returns a gcmEncryptReadSeeker of the next size bytes of r if r is
+// seekable, otherwise a gcmEncryptReader.
+func newGCMEncryptReadSeeker(r io.Reader, size int64, alg string, key, iv []byte) (io.Reader, error) {
+	e, err := newGCMEncryptReader(r, alg, key, iv)
+	if err != nil {
+		return nil, err
+	}
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return e, nil
+	}
+	base, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmEncryptReadSeeker{gcmEncryptReader: e, rs: rs, base: base, size: cseSealedSize(alg, size)}, nil
+}
+
+// Seek only supports seeking to the current offset, the start or the end, which is enough to
+// checksum the content in advance, and to compute the size and rewind it while retrying.
+func (e *gcmEncryptReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += e.offset
+	case io.SeekEnd:
+		offset += e.size
+	default:
+		return 0, errors.New("gcm encrypt reader: invalid whence")
+	}
+	switch offset {
+	case e.offset:
+		return offset, nil
+	case e.size:
+		// Nothing is left to read at the end, the cipher will be reset while seeking to the start.
+		e.offset, e.buf, e.eof = offset, e.buf[:0], true
+		return offset, nil
+	case 0:
+	default:
+		return 0, errors.New("gcm encrypt reader: only seeking to the start or the end is supported")
+	}
+
+	if _, err := e.rs.Seek(e.base, io.SeekStart); err != nil {
+		return 0, err
+	}
+	c, err := newGCMSealer(e.alg, e.key, e.iv)
+	if err != nil {
+		return 0, err
+	}
+	e.cipher, e.offset = c, 0
+	e.buf, e.eof = e.buf[:0], false
+	return 0, nil
+}
+
+// gcmDecryptReader opens the content sealed in chunks read from r.
+//
+// Plaintext of a chunk is only returned after the chunk is authenticated, ErrObjectDecryptionFailed
+// will be returned if any chunk doesn't match or the content is truncated.
+type gcmDecryptReader struct {
+	r      io.Reader
+	cipher *gcmChunkCipher
+
+	chunk []byte
+	// buf is the plaintext authenticated but not read yet.
+	buf []byte
+	eof bool
+}
+
+func newGCMDecryptReader(r io.Reader, key, iv []byte) (*gcmDecryptReader, error) {
+	c, err := newGCMChunkCipher(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmDecryptReader{
+		r:      r,
+		cipher: c,
+		chunk:  make([]byte, gcmChunkSize+gcmTagSize),
+		buf:    make([]byte, 0, gcmChunkSize),
+	}, nil
+}
+
+func (d *gcmDecryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.eof {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(d.r, d.chunk)
+		last := false
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			last, d.eof = true, true
+		} else if err != nil {
+			return 0, err
+		}
+		if d.buf, err = d.cipher.open(d.buf[:0], d.chunk[:n], last); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// gcmMessageDecryptReader opens the content sealed as one GCM message read from r.
+//
+// The plaintext is released before the tag at the end is verified, ErrObjectDecryptionFailed will
+// be returned instead of io.EOF if the content doesn't match.
+type gcmMessageDecryptReader struct {
+	r      io.Reader
+	cipher *gcmMessageCipher
+
+	// sealed is the content read but not opened yet, the last gcmTagSize bytes are always held
+	// back as they could be the tag.
+	sealed []byte
+	// buf is the plaintext opened but not read yet.
+	buf []byte
+	eof bool
+}
+
+func newGCMMessageDecryptReader(r io.Reader, key, iv []byte) (*gcmMessageDecryptReader, error) {
+	c, err := newGCMMessageCipher(key, iv)
+	if err != nil {
+		return nil, err
+	}
+	return &gcmMessageDecryptReader{
+		r:      r,
+		cipher: c,
+		sealed: make([]byte, 0, gcmChunkSize+gcmTagSize),
+		buf:    make([]byte, 0, gcmChunkSize),
+	}, nil
+}
+
+func (d *gcmMessageDecryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.eof {
+			return 0, io.EOF
+		}
+
+		n, err := io.ReadFull(d.r, d.sealed[len(d.sealed):cap(d.sealed)])
+		d.sealed = d.sealed[:len(d.sealed)+n]
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			d.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		if len(d.sealed) < gcmTagSize {
+			return 0, ErrObjectDecryptionFailed
+		}
+
+		m := len(d.sealed) - gcmTagSize
+		if d.buf, err = d.cipher.open(d.buf[:0], d.sealed[:m]); err != nil {
+			return 0, err
+		}
+		d.sealed = d.sealed[:copy(d.sealed, d.sealed[m:])]
+		if d.eof {
+			if err = d.cipher.verify(d.sealed); err != nil {
+				return 0, err
+			}
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// gcmMessageCipher seals and opens content as one AES-GCM message incrementally, which is the
+// same as sealing the whole content by cipher.AEAD (AES/GCM/NoPadding used by other S3 encryption
+// clients), while the content doesn't need to be buffered in memory. The content is encrypted
+// by AES-CTR and authenticated by GHASH as described in NIST SP 800-38D.
+//
+// As the tag is at the end of the message, content opened is not authenticated until the tag is
+// verified.
+type gcmMessageCipher struct {
+	ctr cipher.Stream
+	// tagMask is the encrypted initial counter block, which is xored with the hash as the tag.
+	tagMask [gcmTagSize]byte
+	// productTable is the multiples of the hash key used by mul.
+	productTable [16]gcmFieldElement
+	// y is the hash of the blocks processed.
+	y gcmFieldElement
+	// partial is the part of the last block not processed yet.
+	partial [gcmBlockSize]byte
+	// n is the size of the content sealed or opened.
+	n uint64
+}
+
+// gcmBlockSize is the size of an AES block.
+const gcmBlockSize = 16
+
+// gcmMessageSizeMaximum is the maximum size of the content of a GCM message, as the counter is
+// 32 bits and the first block is used by the tag.
+const gcmMessageSizeMaximum = (1<<32 - 2) * gcmBlockSize
+
+// gcmFieldElement is an element of GF(2^128) in the bit order of GCM, low is the first 8 bytes.
+type gcmFieldElement struct {
+	low, high uint64
+}
+
+// gcmReductionTable is the reduction of the 4 bits shifted out while multiplying by x^4.
+var gcmReductionTable = []uint16{
+	0x0000, 0x1c20, 0x3840, 0x2460, 0x7080, 0x6ca0, 0x48c0, 0x54e0,
+	0xe100, 0xfd20, 0xd940, 0xc560, 0x9180, 0x8da0, 0xa9c0, 0xb5e0,
+}
+
+func newGCMMessageCipher(key, iv []byte) (*gcmMessageCipher, error) {
+	if len(iv) != gcmNonceSize {
+		return nil, errors.New("invalid gcm nonce size")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &gcmMessageCipher{}
+	var h [gcmBlockSize]byte
+	block.Encrypt(h[:], h[:])
+	x := gcmFieldElement{
+		low:  binary.BigEndian.Uint64(h[:8]),
+		high: binary.BigEndian.Uint64(h[8:]),
+	}
+	c.productTable[gcmReverseBits(1)] = x
+	for i := 2; i < 16; i += 2 {
+		c.productTable[gcmReverseBits(i)] = gcmDouble(&c.productTable[gcmReverseBits(i/2)])
+		c.productTable[gcmReverseBits(i+1)] = gcmAdd(&c.productTable[gcmReverseBits(i)], &x)
+	}
+
+	// The counter block is the IV followed by a 32 bits counter starting from 1, which is used
+	// by the tag, and the content is encrypted from 2.
+	var counter [gcmBlockSize]byte
+	copy(counter[:], iv)
+	counter[gcmBlockSize-1] = 1
+	block.Encrypt(c.tagMask[:], counter[:])
+	counter[gcmBlockSize-1] = 2
+	c.ctr = cipher.NewCTR(block, counter[:])
+	return c, nil
+}
+
+// seal appends the ciphertext of plaintext to dst, the tag is appended if last is set.
+func (c *gcmMessageCipher) seal(dst, plaintext []byte, last bool) ([]byte, error) {
+	if err := c.grow(len(plaintext)); err != nil {
+		return nil, err
+	}
+	n := len(dst)
+	dst = append(dst, plaintext...)
+	c.ctr.XORKeyStream(dst[n:], dst[n:])
+	c.update(dst[n:])
+	if last {
+		dst = append(dst, c.tag()...)
+	}
+	return dst, nil
+}
+
+// open appends the plaintext of ciphertext to dst, which is not authenticated until verify.
+func (c *gcmMessageCipher) open(dst, ciphertext []byte) ([]byte, error) {
+	if err := c.grow(len(ciphertext)); err != nil {
+		return nil, err
+	}
+	c.update(ciphertext)
+	n := len(dst)
+	dst = append(dst, ciphertext...)
+	c.ctr.XORKeyStream(dst[n:], dst[n:])
+	return dst, nil
+}
+
+// tag returns the tag of the content sealed or opened, no more content could be processed after.
+func (c *gcmMessageCipher) tag() []byte {
+	if r := int(c.n % gcmBlockSize); r > 0 {
+		for i := r; i < gcmBlockSize; i++ {
+			c.partial[i] = 0
+		}
+		c.updateBlock(c.partial[:])
+	}
+	// The length block of the additional data (always empty) and the content in bits.
+	c.y.high ^= c.n * 8
+	c.mul(&c.y)
+
+	tag := make([]byte, gcmTagSize)
+	binary.BigEndian.PutUint64(tag[:8], c.y.low)
+	binary.BigEndian.PutUint64(tag[8:], c.y.high)
+	for i := range tag {
+		tag[i] ^= c.tagMask[i]
+	}
+	return tag
+}
+
+// verify returns ErrObjectDecryptionFailed if tag doesn't match the content opened.
+func (c *gcmMessageCipher) verify(tag []byte) error {
+	if subtle.ConstantTimeCompare(c.tag(), tag) != 1 {
+		return ErrObjectDecryptionFailed
+	}
+	return nil
+}
+
+func (c *gcmMessageCipher) grow(n int) error {
+	if c.n+uint64(n) > gcmMessageSizeMaximum {
+		return errors.New("gcm message too large")
+	}
+	return nil
+}
+
+// update hashes ciphertext, the part of the last block is kept until the block is full.
+func (c *gcmMessageCipher) update(ciphertext []byte) {
+	if r := int(c.n % gcmBlockSize); r > 0 {
+		m := copy(c.partial[r:], ciphertext)
+		c.n += uint64(m)
+		ciphertext = ciphertext[m:]
+		if r+m < gcmBlockSize {
+			return
+		}
+		c.updateBlock(c.partial[:])
+	}
+	for len(ciphertext) >= gcmBlockSize {
+		c.updateBlock(ciphertext[:gcmBlockSize])
+		ciphertext = ciphertext[gcmBlockSize:]
+		c.n += gcmBlockSize
+	}
+	c.n += uint64(copy(c.partial[:], ciphertext))
+}
+
+func (c *gcmMessageCipher) updateBlock(block []byte) {
+	c.y.low ^= binary.BigEndian.Uint64(block[:8])
+	c.y.high ^= binary.BigEndian.Uint64(block[8:])
+	c.mul(&c.y)
+}
+
+// mul sets y to y*H, where H is the hash key.
+func (c *gcmMessageCipher) mul(y *gcmFieldElement) {
+	var z gcmFieldElement
+	for i := 0; i < 2; i++ {
+		word := y.high
+		if i == 1 {
+			word = y.low
+		}
+		// Multiply by 4 bits of y at a time, from the last bits.
+		for j := 0; j < 64; j += 4 {
+			msw := z.high & 0xf
+			z.high >>= 4
+			z.high |= z.low << 60
+			z.low >>= 4
+			z.low ^= uint64(gcmReductionTable[msw]) << 48
+
+			t := &c.productTable[word&0xf]
+			z.low ^= t.low
+			z.high ^= t.high
+			word >>= 4
+		}
+	}
+	*y = z
+}
+
+// gcmReverseBits reverses the order of the 4 bits of i.
+func gcmReverseBits(i int) int {
+	i = ((i << 2) & 0xc) | ((i >> 2) & 0x3)
+	i = ((i << 1) & 0xa) | ((i >> 1) & 0x5)
+	return i
+}
+
+func gcmAdd(x, y *gcmFieldElement) gcmFieldElement {
+	return gcmFieldElement{x.low ^ y.low, x.high ^ y.high}
+}
+
+// gcmDouble returns x multiplied by 2 in the field.
+func gcmDouble(x *gcmFieldElement) (double gcmFieldElement) {
+	msbSet := x.high&1 == 1
+	double.high = x.high >> 1
+	double.high |= x.low << 63
+	double.low = x.low >> 1
+	if msbSet {
+		double.low ^= 0xe100000000000000
+	}
+	return
+}
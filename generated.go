@@ -28,13 +28,31 @@ const Type = "s3"
 
 // ObjectSystemMetadata stores system metadata for object.
 type ObjectSystemMetadata struct {
-	ServerSideEncryption                  string
-	ServerSideEncryptionAwsKmsKeyID       string
-	ServerSideEncryptionBucketKeyEnabled  bool
-	ServerSideEncryptionContext           string
-	ServerSideEncryptionCustomerAlgorithm string
-	ServerSideEncryptionCustomerKeyMd5    string
-	StorageClass                          string
+	BucketCreationDate                     time.Time
+	BucketRegion                           string
+	CacheControl                           string
+	ContentDisposition                     string
+	ContentEncoding                        string
+	ContentLanguage                        string
+	DefaultServerSideEncryption            string
+	DefaultServerSideEncryptionAwsKmsKeyID string
+	LinkTargetEtag                         string
+	Location                               string
+	MultipartInitiated                     time.Time
+	MultipartInitiatorDisplayName          string
+	MultipartInitiatorID                   string
+	RestoreExpiryDate                      time.Time
+	RestoreOngoing                         bool
+	RestoreRequested                       bool
+	ServerSideEncryption                   string
+	ServerSideEncryptionAwsKmsKeyID        string
+	ServerSideEncryptionBucketKeyEnabled   bool
+	ServerSideEncryptionContext            string
+	ServerSideEncryptionCustomerAlgorithm  string
+	ServerSideEncryptionCustomerKeyMd5     string
+	StorageClass                           string
+	VersionID                              string
+	VersioningStatus                       string
 }
 
 // GetObjectSystemMetadata will get ObjectSystemMetadata from Object.
@@ -58,13 +76,31 @@ func setObjectSystemMetadata(o *Object, sm ObjectSystemMetadata) {
 
 // StorageSystemMetadata stores system metadata for object.
 type StorageSystemMetadata struct {
-	ServerSideEncryption                  string
-	ServerSideEncryptionAwsKmsKeyID       string
-	ServerSideEncryptionBucketKeyEnabled  bool
-	ServerSideEncryptionContext           string
-	ServerSideEncryptionCustomerAlgorithm string
-	ServerSideEncryptionCustomerKeyMd5    string
-	StorageClass                          string
+	BucketCreationDate                     time.Time
+	BucketRegion                           string
+	CacheControl                           string
+	ContentDisposition                     string
+	ContentEncoding                        string
+	ContentLanguage                        string
+	DefaultServerSideEncryption            string
+	DefaultServerSideEncryptionAwsKmsKeyID string
+	LinkTargetEtag                         string
+	Location                               string
+	MultipartInitiated                     time.Time
+	MultipartInitiatorDisplayName          string
+	MultipartInitiatorID                   string
+	RestoreExpiryDate                      time.Time
+	RestoreOngoing                         bool
+	RestoreRequested                       bool
+	ServerSideEncryption                   string
+	ServerSideEncryptionAwsKmsKeyID        string
+	ServerSideEncryptionBucketKeyEnabled   bool
+	ServerSideEncryptionContext            string
+	ServerSideEncryptionCustomerAlgorithm  string
+	ServerSideEncryptionCustomerKeyMd5     string
+	StorageClass                           string
+	VersionID                              string
+	VersioningStatus                       string
 }
 
 // GetStorageSystemMetadata will get StorageSystemMetadata from Storage.
@@ -86,6 +122,165 @@ func setStorageSystemMetadata(s *StorageMeta, sm StorageSystemMetadata) {
 	s.SetSystemMetadata(sm)
 }
 
+// WithAbortOnCancel will apply abort_on_cancel value to Options.
+//
+// specifies whether to abort the multipart upload while the part upload fails as the context is canceled,
+// so that uploaded parts will not be left behind
+func WithAbortOnCancel() Pair {
+	return Pair{Key: "abort_on_cancel", Value: true}
+}
+
+// WithAutoContentType will apply auto_content_type value to Options.
+//
+// will detect the content type by the file extension or the first 512 bytes of the content if content_type
+// is not set
+func WithAutoContentType() Pair {
+	return Pair{Key: "auto_content_type", Value: true}
+}
+
+// WithCacheControl will apply cache_control value to Options.
+//
+// specifies caching behavior of the object, will be returned as the Cache-Control header while reading
+func WithCacheControl(v string) Pair {
+	return Pair{Key: "cache_control", Value: v}
+}
+
+// WithCassette will apply cassette value to Options.
+//
+// is the path of the cassette file used to record or replay HTTP interactions, see cassette_mode
+func WithCassette(v string) Pair {
+	return Pair{Key: "cassette", Value: v}
+}
+
+// WithCassetteMode will apply cassette_mode value to Options.
+//
+// could be replay (by default) which serves requests from the cassette without network, or record
+// which sends requests and saves the interactions into the cassette
+func WithCassetteMode(v string) Pair {
+	return Pair{Key: "cassette_mode", Value: v}
+}
+
+// WithClientSideEncryption will apply client_side_encryption value to Options.
+//
+// enables client-side envelope encryption in the envelope format of the S3 encryption client, objects
+// are encrypted with AES-GCM (in chunks if streaming is enabled) before uploading and decrypted
+// transparently while reading
+func WithClientSideEncryption(v ClientSideEncryption) Pair {
+	return Pair{Key: "client_side_encryption", Value: v}
+}
+
+// WithCompatibilityMode will apply compatibility_mode value to Options.
+//
+// adjusts defaults for S3 compatible backends, could be aws (by default), minio, ceph, r2 or b2
+func WithCompatibilityMode(v string) Pair {
+	return Pair{Key: "compatibility_mode", Value: v}
+}
+
+// WithCompress will apply compress value to Options.
+//
+// specifies the content encoding used to compress the content on the fly, only gzip is supported for
+// now. The compressed content will be buffered in memory and the Content-Encoding will be set, so
+// content larger than 64 MiB will be rejected
+func WithCompress(v string) Pair {
+	return Pair{Key: "compress", Value: v}
+}
+
+// WithContentDisposition will apply content_disposition value to Options.
+//
+// specifies presentational information of the object, will be returned as the Content-Disposition
+// header while reading
+func WithContentDisposition(v string) Pair {
+	return Pair{Key: "content_disposition", Value: v}
+}
+
+// WithContentEncoding will apply content_encoding value to Options.
+//
+// specifies the content encodings applied to the object, will be returned as the Content-Encoding
+// header while reading
+func WithContentEncoding(v string) Pair {
+	return Pair{Key: "content_encoding", Value: v}
+}
+
+// WithContentIntegrityMode will apply content_integrity_mode value to Options.
+//
+// requires every write to carry a Content-MD5 checksum, unseekable content without content_md5
+// will be rejected in strict mode or spooled to a temporary file in spool mode
+func WithContentIntegrityMode(v string) Pair {
+	return Pair{Key: "content_integrity_mode", Value: v}
+}
+
+// WithContentLanguage will apply content_language value to Options.
+//
+// specifies the language the object is in, will be returned as the Content-Language header while
+// reading
+func WithContentLanguage(v string) Pair {
+	return Pair{Key: "content_language", Value: v}
+}
+
+// WithCopySourceServerSideEncryptionCustomerAlgorithm will apply copy_source_server_side_encryption_customer_algorithm
+// value to Options.
+//
+// specifies the algorithm used to decrypt the source object which is encrypted with a customer-provided
+// key, must be AES256
+func WithCopySourceServerSideEncryptionCustomerAlgorithm(v string) Pair {
+	return Pair{Key: "copy_source_server_side_encryption_customer_algorithm", Value: v}
+}
+
+// WithCopySourceServerSideEncryptionCustomerKey will apply copy_source_server_side_encryption_customer_key
+// value to Options.
+//
+// specifies the customer-provided encryption key used to decrypt the source object, must be a 32-byte
+// AES-256 key
+func WithCopySourceServerSideEncryptionCustomerKey(v []byte) Pair {
+	return Pair{Key: "copy_source_server_side_encryption_customer_key", Value: v}
+}
+
+// WithCreateParents will apply create_parents value to Options.
+//
+// will create all parent dirs that do not exist, like mkdir -p
+func WithCreateParents() Pair {
+	return Pair{Key: "create_parents", Value: true}
+}
+
+// WithCredentialProvider will apply credential_provider value to Options.
+//
+// specifies a function to fetch the credential at call time, which wins over credential
+func WithCredentialProvider(v CredentialProvider) Pair {
+	return Pair{Key: "credential_provider", Value: v}
+}
+
+// WithDecompress will apply decompress value to Options.
+//
+// will decompress the content according to the Content-Encoding of the object, only gzip is supported
+// for now and it should not be used with offset or size
+func WithDecompress() Pair {
+	return Pair{Key: "decompress", Value: true}
+}
+
+// WithDefaultServerSideEncryption will apply default_server_side_encryption value to Options.
+//
+// the server-side encryption algorithm used when storing this object in Amazon
+func WithDefaultServerSideEncryption(v string) Pair {
+	return Pair{Key: "default_server_side_encryption", Value: v}
+}
+
+// WithDefaultServerSideEncryptionAwsKmsKeyID will apply default_server_side_encryption_aws_kms_key_id
+// value to Options.
+//
+// specifies the AWS KMS key ID to use for object encryption
+func WithDefaultServerSideEncryptionAwsKmsKeyID(v string) Pair {
+	return Pair{Key: "default_server_side_encryption_aws_kms_key_id", Value: v}
+}
+
+// WithDefaultServerSideEncryptionContext will apply default_server_side_encryption_context
+// value to Options.
+//
+// specifies the AWS KMS Encryption Context to use for object encryption. The value of this header
+// is a base64-encoded UTF-8 string holding JSON with the encryption context key-value pairs.
+func WithDefaultServerSideEncryptionContext(v string) Pair {
+	return Pair{Key: "default_server_side_encryption_context", Value: v}
+}
+
 // WithDefaultServicePairs will apply default_service_pairs value to Options.
 func WithDefaultServicePairs(v DefaultServicePairs) Pair {
 	return Pair{Key: "default_service_pairs", Value: v}
@@ -101,6 +296,34 @@ func WithDefaultStoragePairs(v DefaultStoragePairs) Pair {
 	return Pair{Key: "default_storage_pairs", Value: v}
 }
 
+// WithDelimiter will apply delimiter value to Options.
+//
+// specifies the delimiter used to group keys into dirs in dir list mode, default to `/`
+func WithDelimiter(v string) Pair {
+	return Pair{Key: "delimiter", Value: v}
+}
+
+// WithDetectLink will apply detect_link value to Options.
+//
+// will stat zero-byte objects while listing to mark virtual links, which costs extra requests
+func WithDetectLink() Pair {
+	return Pair{Key: "detect_link", Value: true}
+}
+
+// WithDirMarker will apply dir_marker value to Options.
+//
+// is the dir marker strategy used by virtual dir, could be slash (by default), folder or none
+func WithDirMarker(v string) Pair {
+	return Pair{Key: "dir_marker", Value: v}
+}
+
+// WithDirOnly will apply dir_only value to Options.
+//
+// list only dirs (common prefixes) in dir list mode, objects are skipped
+func WithDirOnly() Pair {
+	return Pair{Key: "dir_only", Value: true}
+}
+
 // WithDisable100Continue will apply disable_100_continue value to Options.
 //
 // set this to `true` to disable the SDK adding the `Expect: 100-Continue` header to PUT requests over
@@ -109,6 +332,45 @@ func WithDisable100Continue() Pair {
 	return Pair{Key: "disable_100_continue", Value: true}
 }
 
+// WithDisableACL will apply disable_acl value to Options.
+//
+// will reject the grant pairs with PairUnsupportedError instead of sending them, which should be
+// set for buckets with object ownership enforced as ACLs are disabled for them
+func WithDisableACL() Pair {
+	return Pair{Key: "disable_acl", Value: true}
+}
+
+// WithDisableTagging will apply disable_tagging value to Options.
+//
+// will reject the tagging pairs with PairUnsupportedError and tags in UpdatePrefix with ErrCapabilityInsufficient,
+// which should be set for services without object tagging support
+func WithDisableTagging() Pair {
+	return Pair{Key: "disable_tagging", Value: true}
+}
+
+// WithEnableObjectLock will apply enable_object_lock value to Options.
+//
+// will allow setting legal hold and retention via UpdatePrefix, which requires object lock enabled
+// while creating the bucket
+func WithEnableObjectLock() Pair {
+	return Pair{Key: "enable_object_lock", Value: true}
+}
+
+// WithEnableSelect will apply enable_select value to Options.
+//
+// will allow running SQL queries on server side via Select, which is not supported by most S3 compatible
+// services
+func WithEnableSelect() Pair {
+	return Pair{Key: "enable_select", Value: true}
+}
+
+// WithEnableVersioning will apply enable_versioning value to Options.
+//
+// will allow deleting all versions via DeletePrefix, which requires versioning enabled on the bucket
+func WithEnableVersioning() Pair {
+	return Pair{Key: "enable_versioning", Value: true}
+}
+
 // WithEnableVirtualDir will apply enable_virtual_dir value to Options.
 //
 // virtual_dir feature is designed for a service that doesn't have native dir support but wants to
@@ -144,6 +406,55 @@ func WithExceptedBucketOwner(v string) Pair {
 	return Pair{Key: "excepted_bucket_owner", Value: v}
 }
 
+// WithExpectedEtag will apply expected_etag value to Options.
+//
+// the entity tag (ETag) the object is expected to have, the write will fail with a 412 (precondition
+// failed) if the object has been changed
+func WithExpectedEtag(v string) Pair {
+	return Pair{Key: "expected_etag", Value: v}
+}
+
+// WithFailoverEndpoints will apply failover_endpoints value to Options.
+//
+// are the endpoints to fail over to while the endpoint could not be connected, in the same format as
+// endpoint, which must be set as the primary one. Endpoints failed to be connected are skipped until
+// they could be connected again, which is checked in the background with a growing cooldown from 30
+// seconds up to 5 minutes. Unseekable content up to 8 MiB will be spooled in memory to be sent again unless
+// write_spool_threshold is set
+func WithFailoverEndpoints(v []string) Pair {
+	return Pair{Key: "failover_endpoints", Value: v}
+}
+
+// WithFaultPolicy will apply fault_policy value to Options.
+//
+// specifies the faults injected into requests for resilience testing, like errors, latency and
+// truncated bodies
+func WithFaultPolicy(v FaultPolicy) Pair {
+	return Pair{Key: "fault_policy", Value: v}
+}
+
+// WithFetchBucketInfo will apply fetch_bucket_info value to Options.
+//
+// will fetch the region, creation date, versioning and default encryption of the bucket, which will
+// be cached after the first success
+func WithFetchBucketInfo() Pair {
+	return Pair{Key: "fetch_bucket_info", Value: true}
+}
+
+// WithFollowLink will apply follow_link value to Options.
+//
+// will follow the virtual link to the target object, chains of links are followed as well
+func WithFollowLink() Pair {
+	return Pair{Key: "follow_link", Value: true}
+}
+
+// WithFollowLinkDepth will apply follow_link_depth value to Options.
+//
+// is the max number of links followed in a chain, 8 by default
+func WithFollowLinkDepth(v int) Pair {
+	return Pair{Key: "follow_link_depth", Value: v}
+}
+
 // WithForcePathStyle will apply force_path_style value to Options.
 //
 // see http://docs.aws.amazon.com/AmazonS3/latest/dev/VirtualHosting.html for Amazon S3:
@@ -152,6 +463,293 @@ func WithForcePathStyle() Pair {
 	return Pair{Key: "force_path_style", Value: true}
 }
 
+// WithGrantFullControl will apply grant_full_control value to Options.
+//
+// gives the grantee READ, READ_ACP, and WRITE_ACP permissions on the object, for example `id="<canonical
+// user id>"`
+func WithGrantFullControl(v string) Pair {
+	return Pair{Key: "grant_full_control", Value: v}
+}
+
+// WithGrantRead will apply grant_read value to Options.
+//
+// allows the grantee to read the object data and its metadata
+func WithGrantRead(v string) Pair {
+	return Pair{Key: "grant_read", Value: v}
+}
+
+// WithGrantReadAcp will apply grant_read_acp value to Options.
+//
+// allows the grantee to read the object ACL
+func WithGrantReadAcp(v string) Pair {
+	return Pair{Key: "grant_read_acp", Value: v}
+}
+
+// WithGrantWriteAcp will apply grant_write_acp value to Options.
+//
+// allows the grantee to write the ACL for the applicable object
+func WithGrantWriteAcp(v string) Pair {
+	return Pair{Key: "grant_write_acp", Value: v}
+}
+
+// WithIfMatch will apply if_match value to Options.
+//
+// return the object only if its entity tag (ETag) is the same as the one specified, otherwise return
+// a 412 (precondition failed)
+func WithIfMatch(v string) Pair {
+	return Pair{Key: "if_match", Value: v}
+}
+
+// WithIfModifiedSince will apply if_modified_since value to Options.
+//
+// return the object only if it has been modified since the specified time, otherwise return a 304 (not
+// modified)
+func WithIfModifiedSince(v time.Time) Pair {
+	return Pair{Key: "if_modified_since", Value: v}
+}
+
+// WithIfNoneMatch will apply if_none_match value to Options.
+//
+// return the object only if its entity tag (ETag) is different from the one specified, otherwise return
+// a 304 (not modified). For write, only `*` is supported which means the object will be written only
+// if it does not exist
+func WithIfNoneMatch(v string) Pair {
+	return Pair{Key: "if_none_match", Value: v}
+}
+
+// WithIfUnmodifiedSince will apply if_unmodified_since value to Options.
+//
+// return the object only if it has not been modified since the specified time, otherwise return a 412
+// (precondition failed)
+func WithIfUnmodifiedSince(v time.Time) Pair {
+	return Pair{Key: "if_unmodified_since", Value: v}
+}
+
+// WithKeyTimeLayout will apply key_time_layout value to Options.
+//
+// specifies the time layout of keys after the listing prefix like `2006/01/02/`, so that listing
+// in prefix mode could start from modified_after and stop after modified_before
+func WithKeyTimeLayout(v string) Pair {
+	return Pair{Key: "key_time_layout", Value: v}
+}
+
+// WithKmsGrantTokens will apply kms_grant_tokens value to Options.
+//
+// specifies the grant tokens passed to AWS KMS while generating or decrypting data keys for client-side
+// encryption
+func WithKmsGrantTokens(v []string) Pair {
+	return Pair{Key: "kms_grant_tokens", Value: v}
+}
+
+// WithKmsSigningRegion will apply kms_signing_region value to Options.
+//
+// specifies the region to send and sign requests to AWS KMS for client-side encryption, which overrides
+// the region in the key ARN
+func WithKmsSigningRegion(v string) Pair {
+	return Pair{Key: "kms_signing_region", Value: v}
+}
+
+// WithLinkReference will apply link_reference value to Options.
+//
+// will record the etag of the target while creating links, so that links could be verified and rewritten
+// after the target moves
+func WithLinkReference() Pair {
+	return Pair{Key: "link_reference", Value: true}
+}
+
+// WithListLimit will apply list_limit value to Options.
+//
+// specifies the maximum number of objects returned by the listing, pages requested will be shrunk
+// to the remaining number
+func WithListLimit(v int64) Pair {
+	return Pair{Key: "list_limit", Value: v}
+}
+
+// WithListMaxPages will apply list_max_pages value to Options.
+//
+// specifies the maximum number of pages requested by the listing, ListPageLimitError will be returned
+// after that. 0 means unbounded, which could be used to override the one in default_storage_pairs
+func WithListMaxPages(v int64) Pair {
+	return Pair{Key: "list_max_pages", Value: v}
+}
+
+// WithMetadataCache will apply metadata_cache value to Options.
+//
+// will cache the results of stat and list pages in the backend, which are invalidated by writes and
+// deletes through the storage
+func WithMetadataCache(v MetadataCache) Pair {
+	return Pair{Key: "metadata_cache", Value: v}
+}
+
+// WithMetadataCacheTTL will apply metadata_cache_ttl value to Options.
+//
+// is the time to live of results cached by metadata_cache, 1 minute by default
+func WithMetadataCacheTTL(v time.Duration) Pair {
+	return Pair{Key: "metadata_cache_ttl", Value: v}
+}
+
+// WithMetadataDirective will apply metadata_directive value to Options.
+//
+// specifies whether the metadata is copied from the source object (COPY, the default) or replaced
+// with metadata provided in the request (REPLACE)
+func WithMetadataDirective(v string) Pair {
+	return Pair{Key: "metadata_directive", Value: v}
+}
+
+// WithModifiedAfter will apply modified_after value to Options.
+//
+// list only objects last modified after the time, dirs are not filtered
+func WithModifiedAfter(v time.Time) Pair {
+	return Pair{Key: "modified_after", Value: v}
+}
+
+// WithModifiedBefore will apply modified_before value to Options.
+//
+// list only objects last modified before the time, dirs are not filtered
+func WithModifiedBefore(v time.Time) Pair {
+	return Pair{Key: "modified_before", Value: v}
+}
+
+// WithObjectCallback will apply object_callback value to Options.
+//
+// will be called with the object built from the response headers before the content is read, so that
+// metadata could be got without an extra stat
+func WithObjectCallback(v func(*Object)) Pair {
+	return Pair{Key: "object_callback", Value: v}
+}
+
+// WithOperationPolicy will apply operation_policy value to Options.
+//
+// restricts the S3 API operations the storage could perform, like read-only mode or denying DeleteObject
+func WithOperationPolicy(v OperationPolicy) Pair {
+	return Pair{Key: "operation_policy", Value: v}
+}
+
+// WithPolicyPreflight will apply policy_preflight value to Options.
+//
+// check the bucket policy while creating the storage, and reject the config which would be denied
+// by it
+func WithPolicyPreflight() Pair {
+	return Pair{Key: "policy_preflight", Value: true}
+}
+
+// WithPrefixRules will apply prefix_rules value to Options.
+//
+// specifies the pairs applied to writes under key prefixes, like storage class, tagging and server-side
+// encryption
+func WithPrefixRules(v []PrefixRule) Pair {
+	return Pair{Key: "prefix_rules", Value: v}
+}
+
+// WithPresignCacheReuseFraction will apply presign_cache_reuse_fraction value to Options.
+//
+// is the fraction of the lifetime of cached presigned requests during which they are reused, 0.5 by
+// default
+func WithPresignCacheReuseFraction(v float64) Pair {
+	return Pair{Key: "presign_cache_reuse_fraction", Value: v}
+}
+
+// WithPresignCacheSize will apply presign_cache_size value to Options.
+//
+// is the max number of presigned read requests cached, which are reused instead of signing again,
+// 0 means presigned requests are not cached
+func WithPresignCacheSize(v int) Pair {
+	return Pair{Key: "presign_cache_size", Value: v}
+}
+
+// WithProgressCallback will apply progress_callback value to Options.
+//
+// specifies a function to report the progress of the transfer, which carries the total size, rate
+// and ETA
+func WithProgressCallback(v ProgressFunc) Pair {
+	return Pair{Key: "progress_callback", Value: v}
+}
+
+// WithReadCacheDir will apply read_cache_dir value to Options.
+//
+// is the local dir to cache objects read, which are validated by conditional requests and served locally
+// while not modified. Objects encrypted with customer-provided keys are not cached, and objects
+// with client-side encryption are cached encrypted
+func WithReadCacheDir(v string) Pair {
+	return Pair{Key: "read_cache_dir", Value: v}
+}
+
+// WithReadCacheMaxSize will apply read_cache_max_size value to Options.
+//
+// is the max total size of objects cached in read_cache_dir, least recently used objects will be evicted,
+// 0 means unbounded
+func WithReadCacheMaxSize(v int64) Pair {
+	return Pair{Key: "read_cache_max_size", Value: v}
+}
+
+// WithReadReplica will apply read_replica value to Options.
+//
+// is the storager of the replicated bucket which reads are routed to, reads will fall back to the storage
+// while the object has not been replicated
+func WithReadReplica(v Storager) Pair {
+	return Pair{Key: "read_replica", Value: v}
+}
+
+// WithReadResumeAttempts will apply read_resume_attempts value to Options.
+//
+// is the max number of attempts to resume the read from the last received byte via a range request while
+// the body stream breaks, 0 means the read will not be resumed
+func WithReadResumeAttempts(v int) Pair {
+	return Pair{Key: "read_resume_attempts", Value: v}
+}
+
+// WithReadTransform will apply read_transform value to Options.
+//
+// will wrap the body of the object received from S3 before it is decrypted or decompressed, so that
+// custom decryption, decompression or metrics could be chained in one place
+func WithReadTransform(v ReadTransform) Pair {
+	return Pair{Key: "read_transform", Value: v}
+}
+
+// WithRecursive will apply recursive value to Options.
+//
+// will delete all objects under the dir as well, only works with object_mode dir
+func WithRecursive() Pair {
+	return Pair{Key: "recursive", Value: true}
+}
+
+// WithRequestCostCallback will apply request_cost_callback value to Options.
+//
+// specifies a callback that will be invoked after every request with its billing tier and transferred
+// bytes
+func WithRequestCostCallback(v func(RequestCostEvent)) Pair {
+	return Pair{Key: "request_cost_callback", Value: v}
+}
+
+// WithRequestHandlers will apply request_handlers value to Options.
+//
+// specifies custom request handlers which will be appended to every request sent by the SDK
+func WithRequestHandlers(v RequestHandlers) Pair {
+	return Pair{Key: "request_handlers", Value: v}
+}
+
+// WithRequireEncryption will apply require_encryption value to Options.
+//
+// rejects writes which would create unencrypted objects, while neither server-side nor client-side
+// encryption is specified and the bucket has no default encryption
+func WithRequireEncryption() Pair {
+	return Pair{Key: "require_encryption", Value: true}
+}
+
+// WithResponseContentDisposition will apply response_content_disposition value to Options.
+//
+// overrides the Content-Disposition header of the response while reading
+func WithResponseContentDisposition(v string) Pair {
+	return Pair{Key: "response_content_disposition", Value: v}
+}
+
+// WithRetryCallback will apply retry_callback value to Options.
+//
+// specifies a callback that will be invoked before each retry attempted by the SDK
+func WithRetryCallback(v func(RetryEvent)) Pair {
+	return Pair{Key: "retry_callback", Value: v}
+}
+
 // WithServerSideEncryption will apply server_side_encryption value to Options.
 //
 // the server-side encryption algorithm used when storing this object in Amazon
@@ -201,11 +799,51 @@ func WithServerSideEncryptionCustomerKey(v []byte) Pair {
 	return Pair{Key: "server_side_encryption_customer_key", Value: v}
 }
 
+// WithServerSideEncryptionCustomerKeyProvider will apply server_side_encryption_customer_key_provider
+// value to Options.
+//
+// specifies a function to fetch the SSE-C key of objects at call time, which is used while server_side_encryption_customer_key
+// is not passed in
+func WithServerSideEncryptionCustomerKeyProvider(v CustomerKeyProvider) Pair {
+	return Pair{Key: "server_side_encryption_customer_key_provider", Value: v}
+}
+
 // WithServiceFeatures will apply service_features value to Options.
 func WithServiceFeatures(v ServiceFeatures) Pair {
 	return Pair{Key: "service_features", Value: v}
 }
 
+// WithSkipIfExists will apply skip_if_exists value to Options.
+//
+// will check whether the dir already exists (as a placeholder object or a non-empty prefix) before
+// creating it, and skip the write if it does
+func WithSkipIfExists() Pair {
+	return Pair{Key: "skip_if_exists", Value: true}
+}
+
+// WithSlowOperationCallback will apply slow_operation_callback value to Options.
+//
+// specifies a callback that will be invoked for every slow operation, which is required to report
+// slow operations
+func WithSlowOperationCallback(v func(SlowOperationEvent)) Pair {
+	return Pair{Key: "slow_operation_callback", Value: v}
+}
+
+// WithSlowOperationThreshold will apply slow_operation_threshold value to Options.
+//
+// specifies the duration after which a read or write will be reported as a slow operation
+func WithSlowOperationThreshold(v time.Duration) Pair {
+	return Pair{Key: "slow_operation_threshold", Value: v}
+}
+
+// WithStatFast will apply stat_fast value to Options.
+//
+// will stat the object via a single-key ListObjectsV2 call instead of HeadObject, only size, etag,
+// last modified and storage class will be returned
+func WithStatFast() Pair {
+	return Pair{Key: "stat_fast", Value: true}
+}
+
 // WithStorageClass will apply storage_class value to Options.
 func WithStorageClass(v string) Pair {
 	return Pair{Key: "storage_class", Value: v}
@@ -216,6 +854,28 @@ func WithStorageFeatures(v StorageFeatures) Pair {
 	return Pair{Key: "storage_features", Value: v}
 }
 
+// WithSuffixSize will apply suffix_size value to Options.
+//
+// specifies the size of the content to read from the end of the object, conflicts with offset and size
+func WithSuffixSize(v int64) Pair {
+	return Pair{Key: "suffix_size", Value: v}
+}
+
+// WithTagging will apply tagging value to Options.
+//
+// specifies the tag set of the object
+func WithTagging(v map[string]string) Pair {
+	return Pair{Key: "tagging", Value: v}
+}
+
+// WithTaggingDirective will apply tagging_directive value to Options.
+//
+// specifies whether the tag set is copied from the source object (COPY, the default) or replaced with
+// tags provided in the request (REPLACE)
+func WithTaggingDirective(v string) Pair {
+	return Pair{Key: "tagging_directive", Value: v}
+}
+
 // WithUseAccelerate will apply use_accelerate value to Options.
 //
 // set this to `true` to enable S3 Accelerate feature
@@ -231,7 +891,46 @@ func WithUseArnRegion() Pair {
 	return Pair{Key: "use_arn_region", Value: true}
 }
 
-var pairMap = map[string]string{"content_md5": "string", "content_type": "string", "context": "context.Context", "continuation_token": "string", "credential": "string", "default_content_type": "string", "default_io_callback": "func([]byte)", "default_service_pairs": "DefaultServicePairs", "default_storage_class": "string", "default_storage_pairs": "DefaultStoragePairs", "disable_100_continue": "bool", "enable_virtual_dir": "bool", "enable_virtual_link": "bool", "endpoint": "string", "excepted_bucket_owner": "string", "expire": "time.Duration", "force_path_style": "bool", "http_client_options": "*httpclient.Options", "interceptor": "Interceptor", "io_callback": "func([]byte)", "list_mode": "ListMode", "location": "string", "multipart_id": "string", "name": "string", "object_mode": "ObjectMode", "offset": "int64", "server_side_encryption": "string", "server_side_encryption_aws_kms_key_id": "string", "server_side_encryption_bucket_key_enabled": "bool", "server_side_encryption_context": "string", "server_side_encryption_customer_algorithm": "string", "server_side_encryption_customer_key": "[]byte", "service_features": "ServiceFeatures", "size": "int64", "storage_class": "string", "storage_features": "StorageFeatures", "use_accelerate": "bool", "use_arn_region": "bool", "work_dir": "string"}
+// WithUseDualStack will apply use_dual_stack value to Options.
+//
+// will use the dual-stack (IPv4 and IPv6) endpoints
+func WithUseDualStack() Pair {
+	return Pair{Key: "use_dual_stack", Value: true}
+}
+
+// WithUserMetadata will apply user_metadata value to Options.
+//
+// specifies the user-defined metadata (x-amz-meta-*) of the object, keys are case-insensitive
+// and will be stored in lower case
+func WithUserMetadata(v map[string]string) Pair {
+	return Pair{Key: "user_metadata", Value: v}
+}
+
+// WithWriteMirrors will apply write_mirrors value to Options.
+//
+// are the storagers which objects written are mirrored to synchronously, the write will fail with
+// MirrorWriteError if any of them failed
+func WithWriteMirrors(v []Storager) Pair {
+	return Pair{Key: "write_mirrors", Value: v}
+}
+
+// WithWriteResult will apply write_result value to Options.
+//
+// is an out pair which will be filled with the metadata returned by S3 after the object has been written
+func WithWriteResult(v *WriteResult) Pair {
+	return Pair{Key: "write_result", Value: v}
+}
+
+// WithWriteSpoolThreshold will apply write_spool_threshold value to Options.
+//
+// specifies the maximum size of content buffered in memory before sent by write and write_multipart
+// if it is unseekable, so that requests could be retried after network failures. Content is sent as
+// is if it is 0
+func WithWriteSpoolThreshold(v int64) Pair {
+	return Pair{Key: "write_spool_threshold", Value: v}
+}
+
+var pairMap = map[string]string{"abort_on_cancel": "bool", "auto_content_type": "bool", "cache_control": "string", "cassette": "string", "cassette_mode": "string", "client_side_encryption": "ClientSideEncryption", "compatibility_mode": "string", "compress": "string", "content_disposition": "string", "content_encoding": "string", "content_integrity_mode": "string", "content_language": "string", "content_md5": "string", "content_type": "string", "context": "context.Context", "continuation_token": "string", "copy_source_server_side_encryption_customer_algorithm": "string", "copy_source_server_side_encryption_customer_key": "[]byte", "create_parents": "bool", "credential": "string", "credential_provider": "CredentialProvider", "decompress": "bool", "default_content_type": "string", "default_io_callback": "func([]byte)", "default_server_side_encryption": "string", "default_server_side_encryption_aws_kms_key_id": "string", "default_server_side_encryption_context": "string", "default_service_pairs": "DefaultServicePairs", "default_storage_class": "string", "default_storage_pairs": "DefaultStoragePairs", "delimiter": "string", "detect_link": "bool", "dir_marker": "string", "dir_only": "bool", "disable_100_continue": "bool", "disable_acl": "bool", "disable_tagging": "bool", "enable_object_lock": "bool", "enable_select": "bool", "enable_versioning": "bool", "enable_virtual_dir": "bool", "enable_virtual_link": "bool", "endpoint": "string", "excepted_bucket_owner": "string", "expected_etag": "string", "expire": "time.Duration", "failover_endpoints": "[]string", "fault_policy": "FaultPolicy", "fetch_bucket_info": "bool", "follow_link": "bool", "follow_link_depth": "int", "force_path_style": "bool", "grant_full_control": "string", "grant_read": "string", "grant_read_acp": "string", "grant_write_acp": "string", "http_client_options": "*httpclient.Options", "if_match": "string", "if_modified_since": "time.Time", "if_none_match": "string", "if_unmodified_since": "time.Time", "interceptor": "Interceptor", "io_callback": "func([]byte)", "key_time_layout": "string", "kms_grant_tokens": "[]string", "kms_signing_region": "string", "link_reference": "bool", "list_limit": "int64", "list_max_pages": "int64", "list_mode": "ListMode", "location": "string", "metadata_cache": "MetadataCache", "metadata_cache_ttl": "time.Duration", "metadata_directive": "string", "modified_after": "time.Time", "modified_before": "time.Time", "multipart_id": "string", "name": "string", "object_callback": "func(*Object)", "object_mode": "ObjectMode", "offset": "int64", "operation_policy": "OperationPolicy", "policy_preflight": "bool", "prefix_rules": "[]PrefixRule", "presign_cache_reuse_fraction": "float64", "presign_cache_size": "int", "progress_callback": "ProgressFunc", "read_cache_dir": "string", "read_cache_max_size": "int64", "read_replica": "Storager", "read_resume_attempts": "int", "read_transform": "ReadTransform", "recursive": "bool", "request_cost_callback": "func(RequestCostEvent)", "request_handlers": "RequestHandlers", "require_encryption": "bool", "response_content_disposition": "string", "retry_callback": "func(RetryEvent)", "server_side_encryption": "string", "server_side_encryption_aws_kms_key_id": "string", "server_side_encryption_bucket_key_enabled": "bool", "server_side_encryption_context": "string", "server_side_encryption_customer_algorithm": "string", "server_side_encryption_customer_key": "[]byte", "server_side_encryption_customer_key_provider": "CustomerKeyProvider", "service_features": "ServiceFeatures", "size": "int64", "skip_if_exists": "bool", "slow_operation_callback": "func(SlowOperationEvent)", "slow_operation_threshold": "time.Duration", "stat_fast": "bool", "storage_class": "string", "storage_features": "StorageFeatures", "suffix_size": "int64", "tagging": "map[string]string", "tagging_directive": "string", "use_accelerate": "bool", "use_arn_region": "bool", "use_dual_stack": "bool", "user_metadata": "map[string]string", "work_dir": "string", "write_mirrors": "[]Storager", "write_result": "*WriteResult", "write_spool_threshold": "int64"}
 var _ Servicer = &Service{}
 
 type ServiceFeatures struct {
@@ -242,25 +941,43 @@ type pairServiceNew struct {
 	pairs []Pair
 
 	// Required pairs
-	HasCredential bool
-	Credential    string
 	// Optional pairs
+	HasCassette            bool
+	Cassette               string
+	HasCassetteMode        bool
+	CassetteMode           string
+	HasCompatibilityMode   bool
+	CompatibilityMode      string
+	HasCredential          bool
+	Credential             string
+	HasCredentialProvider  bool
+	CredentialProvider     CredentialProvider
 	HasDefaultServicePairs bool
 	DefaultServicePairs    DefaultServicePairs
 	HasDisable100Continue  bool
 	Disable100Continue     bool
 	HasEndpoint            bool
 	Endpoint               string
+	HasFaultPolicy         bool
+	FaultPolicy            FaultPolicy
 	HasForcePathStyle      bool
 	ForcePathStyle         bool
 	HasHTTPClientOptions   bool
 	HTTPClientOptions      *httpclient.Options
+	HasRequestCostCallback bool
+	RequestCostCallback    func(RequestCostEvent)
+	HasRequestHandlers     bool
+	RequestHandlers        RequestHandlers
+	HasRetryCallback       bool
+	RetryCallback          func(RetryEvent)
 	HasServiceFeatures     bool
 	ServiceFeatures        ServiceFeatures
 	HasUseAccelerate       bool
 	UseAccelerate          bool
 	HasUseArnRegion        bool
 	UseArnRegion           bool
+	HasUseDualStack        bool
+	UseDualStack           bool
 	// Enable features
 }
 
@@ -271,12 +988,36 @@ func parsePairServiceNew(opts []Pair) (pairServiceNew, error) {
 
 	for _, v := range opts {
 		switch v.Key {
+		case "cassette":
+			if result.HasCassette {
+				continue
+			}
+			result.HasCassette = true
+			result.Cassette = v.Value.(string)
+		case "cassette_mode":
+			if result.HasCassetteMode {
+				continue
+			}
+			result.HasCassetteMode = true
+			result.CassetteMode = v.Value.(string)
+		case "compatibility_mode":
+			if result.HasCompatibilityMode {
+				continue
+			}
+			result.HasCompatibilityMode = true
+			result.CompatibilityMode = v.Value.(string)
 		case "credential":
 			if result.HasCredential {
 				continue
 			}
 			result.HasCredential = true
 			result.Credential = v.Value.(string)
+		case "credential_provider":
+			if result.HasCredentialProvider {
+				continue
+			}
+			result.HasCredentialProvider = true
+			result.CredentialProvider = v.Value.(CredentialProvider)
 		case "default_service_pairs":
 			if result.HasDefaultServicePairs {
 				continue
@@ -295,6 +1036,12 @@ func parsePairServiceNew(opts []Pair) (pairServiceNew, error) {
 			}
 			result.HasEndpoint = true
 			result.Endpoint = v.Value.(string)
+		case "fault_policy":
+			if result.HasFaultPolicy {
+				continue
+			}
+			result.HasFaultPolicy = true
+			result.FaultPolicy = v.Value.(FaultPolicy)
 		case "force_path_style":
 			if result.HasForcePathStyle {
 				continue
@@ -307,6 +1054,24 @@ func parsePairServiceNew(opts []Pair) (pairServiceNew, error) {
 			}
 			result.HasHTTPClientOptions = true
 			result.HTTPClientOptions = v.Value.(*httpclient.Options)
+		case "request_cost_callback":
+			if result.HasRequestCostCallback {
+				continue
+			}
+			result.HasRequestCostCallback = true
+			result.RequestCostCallback = v.Value.(func(RequestCostEvent))
+		case "request_handlers":
+			if result.HasRequestHandlers {
+				continue
+			}
+			result.HasRequestHandlers = true
+			result.RequestHandlers = v.Value.(RequestHandlers)
+		case "retry_callback":
+			if result.HasRetryCallback {
+				continue
+			}
+			result.HasRetryCallback = true
+			result.RetryCallback = v.Value.(func(RetryEvent))
 		case "service_features":
 			if result.HasServiceFeatures {
 				continue
@@ -325,15 +1090,18 @@ func parsePairServiceNew(opts []Pair) (pairServiceNew, error) {
 			}
 			result.HasUseArnRegion = true
 			result.UseArnRegion = v.Value.(bool)
+		case "use_dual_stack":
+			if result.HasUseDualStack {
+				continue
+			}
+			result.HasUseDualStack = true
+			result.UseDualStack = v.Value.(bool)
 		}
 	}
 	// Enable features
 
 	// Default pairs
 
-	if !result.HasCredential {
-		return pairServiceNew{}, services.PairRequiredError{Keys: []string{"credential"}}
-	}
 	return result, nil
 }
 
@@ -413,9 +1181,17 @@ func (s *Service) parsePairServiceDelete(opts []Pair) (pairServiceDelete, error)
 type pairServiceGet struct {
 	pairs []Pair
 	// Required pairs
-	// Optional pairs
-	HasLocation bool
-	Location    string
+	// Optional pairs
+	HasCredential        bool
+	Credential           string
+	HasEndpoint          bool
+	Endpoint             string
+	HasForcePathStyle    bool
+	ForcePathStyle       bool
+	HasHTTPClientOptions bool
+	HTTPClientOptions    *httpclient.Options
+	HasLocation          bool
+	Location             string
 }
 
 func (s *Service) parsePairServiceGet(opts []Pair) (pairServiceGet, error) {
@@ -424,6 +1200,30 @@ func (s *Service) parsePairServiceGet(opts []Pair) (pairServiceGet, error) {
 
 	for _, v := range opts {
 		switch v.Key {
+		case "credential":
+			if result.HasCredential {
+				continue
+			}
+			result.HasCredential = true
+			result.Credential = v.Value.(string)
+		case "endpoint":
+			if result.HasEndpoint {
+				continue
+			}
+			result.HasEndpoint = true
+			result.Endpoint = v.Value.(string)
+		case "force_path_style":
+			if result.HasForcePathStyle {
+				continue
+			}
+			result.HasForcePathStyle = true
+			result.ForcePathStyle = v.Value.(bool)
+		case "http_client_options":
+			if result.HasHTTPClientOptions {
+				continue
+			}
+			result.HasHTTPClientOptions = true
+			result.HTTPClientOptions = v.Value.(*httpclient.Options)
 		case "location":
 			if result.HasLocation {
 				continue
@@ -535,6 +1335,7 @@ func (s *Service) ListWithContext(ctx context.Context, pairs ...Pair) (sti *Stor
 }
 
 var (
+	_ Copier              = &Storage{}
 	_ Direr               = &Storage{}
 	_ Linker              = &Storage{}
 	_ MultipartHTTPSigner = &Storage{}
@@ -573,18 +1374,92 @@ type pairStorageNew struct {
 	HasName     bool
 	Name        string
 	// Optional pairs
-	HasDefaultContentType  bool
-	DefaultContentType     string
-	HasDefaultIoCallback   bool
-	DefaultIoCallback      func([]byte)
-	HasDefaultStorageClass bool
-	DefaultStorageClass    string
-	HasDefaultStoragePairs bool
-	DefaultStoragePairs    DefaultStoragePairs
-	HasStorageFeatures     bool
-	StorageFeatures        StorageFeatures
-	HasWorkDir             bool
-	WorkDir                string
+	HasClientSideEncryption                    bool
+	ClientSideEncryption                       ClientSideEncryption
+	HasCompatibilityMode                       bool
+	CompatibilityMode                          string
+	HasContentIntegrityMode                    bool
+	ContentIntegrityMode                       string
+	HasCredential                              bool
+	Credential                                 string
+	HasCredentialProvider                      bool
+	CredentialProvider                         CredentialProvider
+	HasDefaultContentType                      bool
+	DefaultContentType                         string
+	HasDefaultIoCallback                       bool
+	DefaultIoCallback                          func([]byte)
+	HasDefaultServerSideEncryption             bool
+	DefaultServerSideEncryption                string
+	HasDefaultServerSideEncryptionAwsKmsKeyID  bool
+	DefaultServerSideEncryptionAwsKmsKeyID     string
+	HasDefaultServerSideEncryptionContext      bool
+	DefaultServerSideEncryptionContext         string
+	HasDefaultStorageClass                     bool
+	DefaultStorageClass                        string
+	HasDefaultStoragePairs                     bool
+	DefaultStoragePairs                        DefaultStoragePairs
+	HasDirMarker                               bool
+	DirMarker                                  string
+	HasDisableACL                              bool
+	DisableACL                                 bool
+	HasDisableTagging                          bool
+	DisableTagging                             bool
+	HasEnableObjectLock                        bool
+	EnableObjectLock                           bool
+	HasEnableSelect                            bool
+	EnableSelect                               bool
+	HasEnableVersioning                        bool
+	EnableVersioning                           bool
+	HasEndpoint                                bool
+	Endpoint                                   string
+	HasFailoverEndpoints                       bool
+	FailoverEndpoints                          []string
+	HasForcePathStyle                          bool
+	ForcePathStyle                             bool
+	HasHTTPClientOptions                       bool
+	HTTPClientOptions                          *httpclient.Options
+	HasKmsGrantTokens                          bool
+	KmsGrantTokens                             []string
+	HasKmsSigningRegion                        bool
+	KmsSigningRegion                           string
+	HasLinkReference                           bool
+	LinkReference                              bool
+	HasMetadataCache                           bool
+	MetadataCache                              MetadataCache
+	HasMetadataCacheTTL                        bool
+	MetadataCacheTTL                           time.Duration
+	HasOperationPolicy                         bool
+	OperationPolicy                            OperationPolicy
+	HasPolicyPreflight                         bool
+	PolicyPreflight                            bool
+	HasPrefixRules                             bool
+	PrefixRules                                []PrefixRule
+	HasPresignCacheReuseFraction               bool
+	PresignCacheReuseFraction                  float64
+	HasPresignCacheSize                        bool
+	PresignCacheSize                           int
+	HasReadCacheDir                            bool
+	ReadCacheDir                               string
+	HasReadCacheMaxSize                        bool
+	ReadCacheMaxSize                           int64
+	HasReadReplica                             bool
+	ReadReplica                                Storager
+	HasRequireEncryption                       bool
+	RequireEncryption                          bool
+	HasServerSideEncryptionCustomerKeyProvider bool
+	ServerSideEncryptionCustomerKeyProvider    CustomerKeyProvider
+	HasSlowOperationCallback                   bool
+	SlowOperationCallback                      func(SlowOperationEvent)
+	HasSlowOperationThreshold                  bool
+	SlowOperationThreshold                     time.Duration
+	HasStorageFeatures                         bool
+	StorageFeatures                            StorageFeatures
+	HasWorkDir                                 bool
+	WorkDir                                    string
+	HasWriteMirrors                            bool
+	WriteMirrors                               []Storager
+	HasWriteSpoolThreshold                     bool
+	WriteSpoolThreshold                        int64
 	// Enable features
 	hasEnableVirtualDir  bool
 	EnableVirtualDir     bool
@@ -611,6 +1486,36 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 			}
 			result.HasName = true
 			result.Name = v.Value.(string)
+		case "client_side_encryption":
+			if result.HasClientSideEncryption {
+				continue
+			}
+			result.HasClientSideEncryption = true
+			result.ClientSideEncryption = v.Value.(ClientSideEncryption)
+		case "compatibility_mode":
+			if result.HasCompatibilityMode {
+				continue
+			}
+			result.HasCompatibilityMode = true
+			result.CompatibilityMode = v.Value.(string)
+		case "content_integrity_mode":
+			if result.HasContentIntegrityMode {
+				continue
+			}
+			result.HasContentIntegrityMode = true
+			result.ContentIntegrityMode = v.Value.(string)
+		case "credential":
+			if result.HasCredential {
+				continue
+			}
+			result.HasCredential = true
+			result.Credential = v.Value.(string)
+		case "credential_provider":
+			if result.HasCredentialProvider {
+				continue
+			}
+			result.HasCredentialProvider = true
+			result.CredentialProvider = v.Value.(CredentialProvider)
 		case "default_content_type":
 			if result.HasDefaultContentType {
 				continue
@@ -623,6 +1528,24 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 			}
 			result.HasDefaultIoCallback = true
 			result.DefaultIoCallback = v.Value.(func([]byte))
+		case "default_server_side_encryption":
+			if result.HasDefaultServerSideEncryption {
+				continue
+			}
+			result.HasDefaultServerSideEncryption = true
+			result.DefaultServerSideEncryption = v.Value.(string)
+		case "default_server_side_encryption_aws_kms_key_id":
+			if result.HasDefaultServerSideEncryptionAwsKmsKeyID {
+				continue
+			}
+			result.HasDefaultServerSideEncryptionAwsKmsKeyID = true
+			result.DefaultServerSideEncryptionAwsKmsKeyID = v.Value.(string)
+		case "default_server_side_encryption_context":
+			if result.HasDefaultServerSideEncryptionContext {
+				continue
+			}
+			result.HasDefaultServerSideEncryptionContext = true
+			result.DefaultServerSideEncryptionContext = v.Value.(string)
 		case "default_storage_class":
 			if result.HasDefaultStorageClass {
 				continue
@@ -635,6 +1558,168 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 			}
 			result.HasDefaultStoragePairs = true
 			result.DefaultStoragePairs = v.Value.(DefaultStoragePairs)
+		case "dir_marker":
+			if result.HasDirMarker {
+				continue
+			}
+			result.HasDirMarker = true
+			result.DirMarker = v.Value.(string)
+		case "disable_acl":
+			if result.HasDisableACL {
+				continue
+			}
+			result.HasDisableACL = true
+			result.DisableACL = v.Value.(bool)
+		case "disable_tagging":
+			if result.HasDisableTagging {
+				continue
+			}
+			result.HasDisableTagging = true
+			result.DisableTagging = v.Value.(bool)
+		case "enable_object_lock":
+			if result.HasEnableObjectLock {
+				continue
+			}
+			result.HasEnableObjectLock = true
+			result.EnableObjectLock = v.Value.(bool)
+		case "enable_select":
+			if result.HasEnableSelect {
+				continue
+			}
+			result.HasEnableSelect = true
+			result.EnableSelect = v.Value.(bool)
+		case "enable_versioning":
+			if result.HasEnableVersioning {
+				continue
+			}
+			result.HasEnableVersioning = true
+			result.EnableVersioning = v.Value.(bool)
+		case "endpoint":
+			if result.HasEndpoint {
+				continue
+			}
+			result.HasEndpoint = true
+			result.Endpoint = v.Value.(string)
+		case "failover_endpoints":
+			if result.HasFailoverEndpoints {
+				continue
+			}
+			result.HasFailoverEndpoints = true
+			result.FailoverEndpoints = v.Value.([]string)
+		case "force_path_style":
+			if result.HasForcePathStyle {
+				continue
+			}
+			result.HasForcePathStyle = true
+			result.ForcePathStyle = v.Value.(bool)
+		case "http_client_options":
+			if result.HasHTTPClientOptions {
+				continue
+			}
+			result.HasHTTPClientOptions = true
+			result.HTTPClientOptions = v.Value.(*httpclient.Options)
+		case "kms_grant_tokens":
+			if result.HasKmsGrantTokens {
+				continue
+			}
+			result.HasKmsGrantTokens = true
+			result.KmsGrantTokens = v.Value.([]string)
+		case "kms_signing_region":
+			if result.HasKmsSigningRegion {
+				continue
+			}
+			result.HasKmsSigningRegion = true
+			result.KmsSigningRegion = v.Value.(string)
+		case "link_reference":
+			if result.HasLinkReference {
+				continue
+			}
+			result.HasLinkReference = true
+			result.LinkReference = v.Value.(bool)
+		case "metadata_cache":
+			if result.HasMetadataCache {
+				continue
+			}
+			result.HasMetadataCache = true
+			result.MetadataCache = v.Value.(MetadataCache)
+		case "metadata_cache_ttl":
+			if result.HasMetadataCacheTTL {
+				continue
+			}
+			result.HasMetadataCacheTTL = true
+			result.MetadataCacheTTL = v.Value.(time.Duration)
+		case "operation_policy":
+			if result.HasOperationPolicy {
+				continue
+			}
+			result.HasOperationPolicy = true
+			result.OperationPolicy = v.Value.(OperationPolicy)
+		case "policy_preflight":
+			if result.HasPolicyPreflight {
+				continue
+			}
+			result.HasPolicyPreflight = true
+			result.PolicyPreflight = v.Value.(bool)
+		case "prefix_rules":
+			if result.HasPrefixRules {
+				continue
+			}
+			result.HasPrefixRules = true
+			result.PrefixRules = v.Value.([]PrefixRule)
+		case "presign_cache_reuse_fraction":
+			if result.HasPresignCacheReuseFraction {
+				continue
+			}
+			result.HasPresignCacheReuseFraction = true
+			result.PresignCacheReuseFraction = v.Value.(float64)
+		case "presign_cache_size":
+			if result.HasPresignCacheSize {
+				continue
+			}
+			result.HasPresignCacheSize = true
+			result.PresignCacheSize = v.Value.(int)
+		case "read_cache_dir":
+			if result.HasReadCacheDir {
+				continue
+			}
+			result.HasReadCacheDir = true
+			result.ReadCacheDir = v.Value.(string)
+		case "read_cache_max_size":
+			if result.HasReadCacheMaxSize {
+				continue
+			}
+			result.HasReadCacheMaxSize = true
+			result.ReadCacheMaxSize = v.Value.(int64)
+		case "read_replica":
+			if result.HasReadReplica {
+				continue
+			}
+			result.HasReadReplica = true
+			result.ReadReplica = v.Value.(Storager)
+		case "require_encryption":
+			if result.HasRequireEncryption {
+				continue
+			}
+			result.HasRequireEncryption = true
+			result.RequireEncryption = v.Value.(bool)
+		case "server_side_encryption_customer_key_provider":
+			if result.HasServerSideEncryptionCustomerKeyProvider {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKeyProvider = true
+			result.ServerSideEncryptionCustomerKeyProvider = v.Value.(CustomerKeyProvider)
+		case "slow_operation_callback":
+			if result.HasSlowOperationCallback {
+				continue
+			}
+			result.HasSlowOperationCallback = true
+			result.SlowOperationCallback = v.Value.(func(SlowOperationEvent))
+		case "slow_operation_threshold":
+			if result.HasSlowOperationThreshold {
+				continue
+			}
+			result.HasSlowOperationThreshold = true
+			result.SlowOperationThreshold = v.Value.(time.Duration)
 		case "storage_features":
 			if result.HasStorageFeatures {
 				continue
@@ -647,6 +1732,18 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 			}
 			result.HasWorkDir = true
 			result.WorkDir = v.Value.(string)
+		case "write_mirrors":
+			if result.HasWriteMirrors {
+				continue
+			}
+			result.HasWriteMirrors = true
+			result.WriteMirrors = v.Value.([]Storager)
+		case "write_spool_threshold":
+			if result.HasWriteSpoolThreshold {
+				continue
+			}
+			result.HasWriteSpoolThreshold = true
+			result.WriteSpoolThreshold = v.Value.(int64)
 		case "enable_virtual_dir":
 			if result.hasEnableVirtualDir {
 				continue
@@ -673,6 +1770,8 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 	// Default pairs
 	if result.HasDefaultContentType {
 		result.HasDefaultStoragePairs = true
+		result.DefaultStoragePairs.Copy = append(result.DefaultStoragePairs.Copy, WithContentType(result.DefaultContentType))
+		result.DefaultStoragePairs.CreateMultipart = append(result.DefaultStoragePairs.CreateMultipart, WithContentType(result.DefaultContentType))
 		result.DefaultStoragePairs.QuerySignHTTPWrite = append(result.DefaultStoragePairs.QuerySignHTTPWrite, WithContentType(result.DefaultContentType))
 		result.DefaultStoragePairs.Write = append(result.DefaultStoragePairs.Write, WithContentType(result.DefaultContentType))
 	}
@@ -682,9 +1781,32 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 		result.DefaultStoragePairs.Write = append(result.DefaultStoragePairs.Write, WithIoCallback(result.DefaultIoCallback))
 		result.DefaultStoragePairs.WriteMultipart = append(result.DefaultStoragePairs.WriteMultipart, WithIoCallback(result.DefaultIoCallback))
 	}
+	if result.HasDefaultServerSideEncryption {
+		result.HasDefaultStoragePairs = true
+		result.DefaultStoragePairs.Copy = append(result.DefaultStoragePairs.Copy, WithServerSideEncryption(result.DefaultServerSideEncryption))
+		result.DefaultStoragePairs.CreateMultipart = append(result.DefaultStoragePairs.CreateMultipart, WithServerSideEncryption(result.DefaultServerSideEncryption))
+		result.DefaultStoragePairs.QuerySignHTTPWrite = append(result.DefaultStoragePairs.QuerySignHTTPWrite, WithServerSideEncryption(result.DefaultServerSideEncryption))
+		result.DefaultStoragePairs.Write = append(result.DefaultStoragePairs.Write, WithServerSideEncryption(result.DefaultServerSideEncryption))
+	}
+	if result.HasDefaultServerSideEncryptionAwsKmsKeyID {
+		result.HasDefaultStoragePairs = true
+		result.DefaultStoragePairs.Copy = append(result.DefaultStoragePairs.Copy, WithServerSideEncryptionAwsKmsKeyID(result.DefaultServerSideEncryptionAwsKmsKeyID))
+		result.DefaultStoragePairs.CreateMultipart = append(result.DefaultStoragePairs.CreateMultipart, WithServerSideEncryptionAwsKmsKeyID(result.DefaultServerSideEncryptionAwsKmsKeyID))
+		result.DefaultStoragePairs.QuerySignHTTPWrite = append(result.DefaultStoragePairs.QuerySignHTTPWrite, WithServerSideEncryptionAwsKmsKeyID(result.DefaultServerSideEncryptionAwsKmsKeyID))
+		result.DefaultStoragePairs.Write = append(result.DefaultStoragePairs.Write, WithServerSideEncryptionAwsKmsKeyID(result.DefaultServerSideEncryptionAwsKmsKeyID))
+	}
+	if result.HasDefaultServerSideEncryptionContext {
+		result.HasDefaultStoragePairs = true
+		result.DefaultStoragePairs.Copy = append(result.DefaultStoragePairs.Copy, WithServerSideEncryptionContext(result.DefaultServerSideEncryptionContext))
+		result.DefaultStoragePairs.CreateMultipart = append(result.DefaultStoragePairs.CreateMultipart, WithServerSideEncryptionContext(result.DefaultServerSideEncryptionContext))
+		result.DefaultStoragePairs.QuerySignHTTPWrite = append(result.DefaultStoragePairs.QuerySignHTTPWrite, WithServerSideEncryptionContext(result.DefaultServerSideEncryptionContext))
+		result.DefaultStoragePairs.Write = append(result.DefaultStoragePairs.Write, WithServerSideEncryptionContext(result.DefaultServerSideEncryptionContext))
+	}
 	if result.HasDefaultStorageClass {
 		result.HasDefaultStoragePairs = true
+		result.DefaultStoragePairs.Copy = append(result.DefaultStoragePairs.Copy, WithStorageClass(result.DefaultStorageClass))
 		result.DefaultStoragePairs.CreateDir = append(result.DefaultStoragePairs.CreateDir, WithStorageClass(result.DefaultStorageClass))
+		result.DefaultStoragePairs.CreateMultipart = append(result.DefaultStoragePairs.CreateMultipart, WithStorageClass(result.DefaultStorageClass))
 		result.DefaultStoragePairs.QuerySignHTTPWrite = append(result.DefaultStoragePairs.QuerySignHTTPWrite, WithStorageClass(result.DefaultStorageClass))
 		result.DefaultStoragePairs.Write = append(result.DefaultStoragePairs.Write, WithStorageClass(result.DefaultStorageClass))
 	}
@@ -700,6 +1822,7 @@ func parsePairStorageNew(opts []Pair) (pairStorageNew, error) {
 // DefaultStoragePairs is default pairs for specific action
 type DefaultStoragePairs struct {
 	CompleteMultipart              []Pair
+	Copy                           []Pair
 	Create                         []Pair
 	CreateDir                      []Pair
 	CreateLink                     []Pair
@@ -726,6 +1849,8 @@ type pairStorageCompleteMultipart struct {
 	// Optional pairs
 	HasExceptedBucketOwner bool
 	ExceptedBucketOwner    string
+	HasWriteResult         bool
+	WriteResult            *WriteResult
 }
 
 func (s *Storage) parsePairStorageCompleteMultipart(opts []Pair) (pairStorageCompleteMultipart, error) {
@@ -740,6 +1865,12 @@ func (s *Storage) parsePairStorageCompleteMultipart(opts []Pair) (pairStorageCom
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "write_result":
+			if result.HasWriteResult {
+				continue
+			}
+			result.HasWriteResult = true
+			result.WriteResult = v.Value.(*WriteResult)
 		default:
 			return pairStorageCompleteMultipart{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -748,6 +1879,210 @@ func (s *Storage) parsePairStorageCompleteMultipart(opts []Pair) (pairStorageCom
 	return result, nil
 }
 
+type pairStorageCopy struct {
+	pairs []Pair
+	// Required pairs
+	// Optional pairs
+	HasCacheControl                                    bool
+	CacheControl                                       string
+	HasContentDisposition                              bool
+	ContentDisposition                                 string
+	HasContentEncoding                                 bool
+	ContentEncoding                                    string
+	HasContentLanguage                                 bool
+	ContentLanguage                                    string
+	HasContentType                                     bool
+	ContentType                                        string
+	HasCopySourceServerSideEncryptionCustomerAlgorithm bool
+	CopySourceServerSideEncryptionCustomerAlgorithm    string
+	HasCopySourceServerSideEncryptionCustomerKey       bool
+	CopySourceServerSideEncryptionCustomerKey          []byte
+	HasExceptedBucketOwner                             bool
+	ExceptedBucketOwner                                string
+	HasGrantFullControl                                bool
+	GrantFullControl                                   string
+	HasGrantRead                                       bool
+	GrantRead                                          string
+	HasGrantReadAcp                                    bool
+	GrantReadAcp                                       string
+	HasGrantWriteAcp                                   bool
+	GrantWriteAcp                                      string
+	HasMetadataDirective                               bool
+	MetadataDirective                                  string
+	HasServerSideEncryption                            bool
+	ServerSideEncryption                               string
+	HasServerSideEncryptionAwsKmsKeyID                 bool
+	ServerSideEncryptionAwsKmsKeyID                    string
+	HasServerSideEncryptionBucketKeyEnabled            bool
+	ServerSideEncryptionBucketKeyEnabled               bool
+	HasServerSideEncryptionContext                     bool
+	ServerSideEncryptionContext                        string
+	HasServerSideEncryptionCustomerAlgorithm           bool
+	ServerSideEncryptionCustomerAlgorithm              string
+	HasServerSideEncryptionCustomerKey                 bool
+	ServerSideEncryptionCustomerKey                    []byte
+	HasStorageClass                                    bool
+	StorageClass                                       string
+	HasTagging                                         bool
+	Tagging                                            map[string]string
+	HasTaggingDirective                                bool
+	TaggingDirective                                   string
+	HasUserMetadata                                    bool
+	UserMetadata                                       map[string]string
+}
+
+func (s *Storage) parsePairStorageCopy(opts []Pair) (pairStorageCopy, error) {
+	result :=
+		pairStorageCopy{pairs: opts}
+
+	for _, v := range opts {
+		switch v.Key {
+		case "cache_control":
+			if result.HasCacheControl {
+				continue
+			}
+			result.HasCacheControl = true
+			result.CacheControl = v.Value.(string)
+		case "content_disposition":
+			if result.HasContentDisposition {
+				continue
+			}
+			result.HasContentDisposition = true
+			result.ContentDisposition = v.Value.(string)
+		case "content_encoding":
+			if result.HasContentEncoding {
+				continue
+			}
+			result.HasContentEncoding = true
+			result.ContentEncoding = v.Value.(string)
+		case "content_language":
+			if result.HasContentLanguage {
+				continue
+			}
+			result.HasContentLanguage = true
+			result.ContentLanguage = v.Value.(string)
+		case "content_type":
+			if result.HasContentType {
+				continue
+			}
+			result.HasContentType = true
+			result.ContentType = v.Value.(string)
+		case "copy_source_server_side_encryption_customer_algorithm":
+			if result.HasCopySourceServerSideEncryptionCustomerAlgorithm {
+				continue
+			}
+			result.HasCopySourceServerSideEncryptionCustomerAlgorithm = true
+			result.CopySourceServerSideEncryptionCustomerAlgorithm = v.Value.(string)
+		case "copy_source_server_side_encryption_customer_key":
+			if result.HasCopySourceServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasCopySourceServerSideEncryptionCustomerKey = true
+			result.CopySourceServerSideEncryptionCustomerKey = v.Value.([]byte)
+		case "excepted_bucket_owner":
+			if result.HasExceptedBucketOwner {
+				continue
+			}
+			result.HasExceptedBucketOwner = true
+			result.ExceptedBucketOwner = v.Value.(string)
+		case "grant_full_control":
+			if result.HasGrantFullControl {
+				continue
+			}
+			result.HasGrantFullControl = true
+			result.GrantFullControl = v.Value.(string)
+		case "grant_read":
+			if result.HasGrantRead {
+				continue
+			}
+			result.HasGrantRead = true
+			result.GrantRead = v.Value.(string)
+		case "grant_read_acp":
+			if result.HasGrantReadAcp {
+				continue
+			}
+			result.HasGrantReadAcp = true
+			result.GrantReadAcp = v.Value.(string)
+		case "grant_write_acp":
+			if result.HasGrantWriteAcp {
+				continue
+			}
+			result.HasGrantWriteAcp = true
+			result.GrantWriteAcp = v.Value.(string)
+		case "metadata_directive":
+			if result.HasMetadataDirective {
+				continue
+			}
+			result.HasMetadataDirective = true
+			result.MetadataDirective = v.Value.(string)
+		case "server_side_encryption":
+			if result.HasServerSideEncryption {
+				continue
+			}
+			result.HasServerSideEncryption = true
+			result.ServerSideEncryption = v.Value.(string)
+		case "server_side_encryption_aws_kms_key_id":
+			if result.HasServerSideEncryptionAwsKmsKeyID {
+				continue
+			}
+			result.HasServerSideEncryptionAwsKmsKeyID = true
+			result.ServerSideEncryptionAwsKmsKeyID = v.Value.(string)
+		case "server_side_encryption_bucket_key_enabled":
+			if result.HasServerSideEncryptionBucketKeyEnabled {
+				continue
+			}
+			result.HasServerSideEncryptionBucketKeyEnabled = true
+			result.ServerSideEncryptionBucketKeyEnabled = v.Value.(bool)
+		case "server_side_encryption_context":
+			if result.HasServerSideEncryptionContext {
+				continue
+			}
+			result.HasServerSideEncryptionContext = true
+			result.ServerSideEncryptionContext = v.Value.(string)
+		case "server_side_encryption_customer_algorithm":
+			if result.HasServerSideEncryptionCustomerAlgorithm {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerAlgorithm = true
+			result.ServerSideEncryptionCustomerAlgorithm = v.Value.(string)
+		case "server_side_encryption_customer_key":
+			if result.HasServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKey = true
+			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
+		case "storage_class":
+			if result.HasStorageClass {
+				continue
+			}
+			result.HasStorageClass = true
+			result.StorageClass = v.Value.(string)
+		case "tagging":
+			if result.HasTagging {
+				continue
+			}
+			result.HasTagging = true
+			result.Tagging = v.Value.(map[string]string)
+		case "tagging_directive":
+			if result.HasTaggingDirective {
+				continue
+			}
+			result.HasTaggingDirective = true
+			result.TaggingDirective = v.Value.(string)
+		case "user_metadata":
+			if result.HasUserMetadata {
+				continue
+			}
+			result.HasUserMetadata = true
+			result.UserMetadata = v.Value.(map[string]string)
+		default:
+			return pairStorageCopy{}, services.PairUnsupportedError{Pair: v}
+		}
+	}
+
+	return result, nil
+}
+
 type pairStorageCreate struct {
 	pairs []Pair
 	// Required pairs
@@ -788,8 +2123,12 @@ type pairStorageCreateDir struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasCreateParents       bool
+	CreateParents          bool
 	HasExceptedBucketOwner bool
 	ExceptedBucketOwner    string
+	HasSkipIfExists        bool
+	SkipIfExists           bool
 	HasStorageClass        bool
 	StorageClass           string
 }
@@ -800,12 +2139,24 @@ func (s *Storage) parsePairStorageCreateDir(opts []Pair) (pairStorageCreateDir,
 
 	for _, v := range opts {
 		switch v.Key {
+		case "create_parents":
+			if result.HasCreateParents {
+				continue
+			}
+			result.HasCreateParents = true
+			result.CreateParents = v.Value.(bool)
 		case "excepted_bucket_owner":
 			if result.HasExceptedBucketOwner {
 				continue
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "skip_if_exists":
+			if result.HasSkipIfExists {
+				continue
+			}
+			result.HasSkipIfExists = true
+			result.SkipIfExists = v.Value.(bool)
 		case "storage_class":
 			if result.HasStorageClass {
 				continue
@@ -824,6 +2175,12 @@ type pairStorageCreateLink struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasExceptedBucketOwner                   bool
+	ExceptedBucketOwner                      string
+	HasServerSideEncryptionCustomerAlgorithm bool
+	ServerSideEncryptionCustomerAlgorithm    string
+	HasServerSideEncryptionCustomerKey       bool
+	ServerSideEncryptionCustomerKey          []byte
 }
 
 func (s *Storage) parsePairStorageCreateLink(opts []Pair) (pairStorageCreateLink, error) {
@@ -832,6 +2189,24 @@ func (s *Storage) parsePairStorageCreateLink(opts []Pair) (pairStorageCreateLink
 
 	for _, v := range opts {
 		switch v.Key {
+		case "excepted_bucket_owner":
+			if result.HasExceptedBucketOwner {
+				continue
+			}
+			result.HasExceptedBucketOwner = true
+			result.ExceptedBucketOwner = v.Value.(string)
+		case "server_side_encryption_customer_algorithm":
+			if result.HasServerSideEncryptionCustomerAlgorithm {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerAlgorithm = true
+			result.ServerSideEncryptionCustomerAlgorithm = v.Value.(string)
+		case "server_side_encryption_customer_key":
+			if result.HasServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKey = true
+			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
 		default:
 			return pairStorageCreateLink{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -844,8 +2219,26 @@ type pairStorageCreateMultipart struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasCacheControl                          bool
+	CacheControl                             string
+	HasContentDisposition                    bool
+	ContentDisposition                       string
+	HasContentEncoding                       bool
+	ContentEncoding                          string
+	HasContentLanguage                       bool
+	ContentLanguage                          string
+	HasContentType                           bool
+	ContentType                              string
 	HasExceptedBucketOwner                   bool
 	ExceptedBucketOwner                      string
+	HasGrantFullControl                      bool
+	GrantFullControl                         string
+	HasGrantRead                             bool
+	GrantRead                                string
+	HasGrantReadAcp                          bool
+	GrantReadAcp                             string
+	HasGrantWriteAcp                         bool
+	GrantWriteAcp                            string
 	HasServerSideEncryption                  bool
 	ServerSideEncryption                     string
 	HasServerSideEncryptionAwsKmsKeyID       bool
@@ -858,8 +2251,10 @@ type pairStorageCreateMultipart struct {
 	ServerSideEncryptionCustomerAlgorithm    string
 	HasServerSideEncryptionCustomerKey       bool
 	ServerSideEncryptionCustomerKey          []byte
-	HasContentType 						     bool
-	ContentType 						     string
+	HasStorageClass                          bool
+	StorageClass                             string
+	HasUserMetadata                          bool
+	UserMetadata                             map[string]string
 }
 
 func (s *Storage) parsePairStorageCreateMultipart(opts []Pair) (pairStorageCreateMultipart, error) {
@@ -868,12 +2263,66 @@ func (s *Storage) parsePairStorageCreateMultipart(opts []Pair) (pairStorageCreat
 
 	for _, v := range opts {
 		switch v.Key {
+		case "cache_control":
+			if result.HasCacheControl {
+				continue
+			}
+			result.HasCacheControl = true
+			result.CacheControl = v.Value.(string)
+		case "content_disposition":
+			if result.HasContentDisposition {
+				continue
+			}
+			result.HasContentDisposition = true
+			result.ContentDisposition = v.Value.(string)
+		case "content_encoding":
+			if result.HasContentEncoding {
+				continue
+			}
+			result.HasContentEncoding = true
+			result.ContentEncoding = v.Value.(string)
+		case "content_language":
+			if result.HasContentLanguage {
+				continue
+			}
+			result.HasContentLanguage = true
+			result.ContentLanguage = v.Value.(string)
+		case "content_type":
+			if result.HasContentType {
+				continue
+			}
+			result.HasContentType = true
+			result.ContentType = v.Value.(string)
 		case "excepted_bucket_owner":
 			if result.HasExceptedBucketOwner {
 				continue
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "grant_full_control":
+			if result.HasGrantFullControl {
+				continue
+			}
+			result.HasGrantFullControl = true
+			result.GrantFullControl = v.Value.(string)
+		case "grant_read":
+			if result.HasGrantRead {
+				continue
+			}
+			result.HasGrantRead = true
+			result.GrantRead = v.Value.(string)
+		case "grant_read_acp":
+			if result.HasGrantReadAcp {
+				continue
+			}
+			result.HasGrantReadAcp = true
+			result.GrantReadAcp = v.Value.(string)
+		case "grant_write_acp":
+			if result.HasGrantWriteAcp {
+				continue
+			}
+			result.HasGrantWriteAcp = true
+			result.GrantWriteAcp = v.Value.(string)
 		case "server_side_encryption":
 			if result.HasServerSideEncryption {
 				continue
@@ -910,12 +2359,18 @@ func (s *Storage) parsePairStorageCreateMultipart(opts []Pair) (pairStorageCreat
 			}
 			result.HasServerSideEncryptionCustomerKey = true
 			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
-		case "content_type":
-			if result.HasContentType {
+		case "storage_class":
+			if result.HasStorageClass {
 				continue
 			}
-			result.HasContentType = true
-			result.ContentType = v.Value.(string)
+			result.HasStorageClass = true
+			result.StorageClass = v.Value.(string)
+		case "user_metadata":
+			if result.HasUserMetadata {
+				continue
+			}
+			result.HasUserMetadata = true
+			result.UserMetadata = v.Value.(map[string]string)
 		default:
 			return pairStorageCreateMultipart{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -934,6 +2389,8 @@ type pairStorageDelete struct {
 	MultipartID            string
 	HasObjectMode          bool
 	ObjectMode             ObjectMode
+	HasRecursive           bool
+	Recursive              bool
 }
 
 func (s *Storage) parsePairStorageDelete(opts []Pair) (pairStorageDelete, error) {
@@ -960,6 +2417,12 @@ func (s *Storage) parsePairStorageDelete(opts []Pair) (pairStorageDelete, error)
 			}
 			result.HasObjectMode = true
 			result.ObjectMode = v.Value.(ObjectMode)
+		case "recursive":
+			if result.HasRecursive {
+				continue
+			}
+			result.HasRecursive = true
+			result.Recursive = v.Value.(bool)
 		default:
 			return pairStorageDelete{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -972,10 +2435,28 @@ type pairStorageList struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasContinuationToken   bool
+	ContinuationToken      string
+	HasDelimiter           bool
+	Delimiter              string
+	HasDetectLink          bool
+	DetectLink             bool
+	HasDirOnly             bool
+	DirOnly                bool
 	HasExceptedBucketOwner bool
 	ExceptedBucketOwner    string
+	HasKeyTimeLayout       bool
+	KeyTimeLayout          string
+	HasListLimit           bool
+	ListLimit              int64
+	HasListMaxPages        bool
+	ListMaxPages           int64
 	HasListMode            bool
 	ListMode               ListMode
+	HasModifiedAfter       bool
+	ModifiedAfter          time.Time
+	HasModifiedBefore      bool
+	ModifiedBefore         time.Time
 }
 
 func (s *Storage) parsePairStorageList(opts []Pair) (pairStorageList, error) {
@@ -984,18 +2465,72 @@ func (s *Storage) parsePairStorageList(opts []Pair) (pairStorageList, error) {
 
 	for _, v := range opts {
 		switch v.Key {
+		case "continuation_token":
+			if result.HasContinuationToken {
+				continue
+			}
+			result.HasContinuationToken = true
+			result.ContinuationToken = v.Value.(string)
+		case "delimiter":
+			if result.HasDelimiter {
+				continue
+			}
+			result.HasDelimiter = true
+			result.Delimiter = v.Value.(string)
+		case "detect_link":
+			if result.HasDetectLink {
+				continue
+			}
+			result.HasDetectLink = true
+			result.DetectLink = v.Value.(bool)
+		case "dir_only":
+			if result.HasDirOnly {
+				continue
+			}
+			result.HasDirOnly = true
+			result.DirOnly = v.Value.(bool)
 		case "excepted_bucket_owner":
 			if result.HasExceptedBucketOwner {
 				continue
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "key_time_layout":
+			if result.HasKeyTimeLayout {
+				continue
+			}
+			result.HasKeyTimeLayout = true
+			result.KeyTimeLayout = v.Value.(string)
+		case "list_limit":
+			if result.HasListLimit {
+				continue
+			}
+			result.HasListLimit = true
+			result.ListLimit = v.Value.(int64)
+		case "list_max_pages":
+			if result.HasListMaxPages {
+				continue
+			}
+			result.HasListMaxPages = true
+			result.ListMaxPages = v.Value.(int64)
 		case "list_mode":
 			if result.HasListMode {
 				continue
 			}
 			result.HasListMode = true
 			result.ListMode = v.Value.(ListMode)
+		case "modified_after":
+			if result.HasModifiedAfter {
+				continue
+			}
+			result.HasModifiedAfter = true
+			result.ModifiedAfter = v.Value.(time.Time)
+		case "modified_before":
+			if result.HasModifiedBefore {
+				continue
+			}
+			result.HasModifiedBefore = true
+			result.ModifiedBefore = v.Value.(time.Time)
 		default:
 			return pairStorageList{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1036,6 +2571,8 @@ type pairStorageMetadata struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasFetchBucketInfo bool
+	FetchBucketInfo    bool
 }
 
 func (s *Storage) parsePairStorageMetadata(opts []Pair) (pairStorageMetadata, error) {
@@ -1044,6 +2581,12 @@ func (s *Storage) parsePairStorageMetadata(opts []Pair) (pairStorageMetadata, er
 
 	for _, v := range opts {
 		switch v.Key {
+		case "fetch_bucket_info":
+			if result.HasFetchBucketInfo {
+				continue
+			}
+			result.HasFetchBucketInfo = true
+			result.FetchBucketInfo = v.Value.(bool)
 		default:
 			return pairStorageMetadata{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1162,16 +2705,26 @@ type pairStorageQuerySignHTTPRead struct {
 	// Optional pairs
 	HasExceptedBucketOwner                   bool
 	ExceptedBucketOwner                      string
+	HasIfMatch                               bool
+	IfMatch                                  string
+	HasIfModifiedSince                       bool
+	IfModifiedSince                          time.Time
+	HasIfNoneMatch                           bool
+	IfNoneMatch                              string
+	HasIfUnmodifiedSince                     bool
+	IfUnmodifiedSince                        time.Time
 	HasOffset                                bool
 	Offset                                   int64
+	HasResponseContentDisposition            bool
+	ResponseContentDisposition               string
 	HasServerSideEncryptionCustomerAlgorithm bool
 	ServerSideEncryptionCustomerAlgorithm    string
 	HasServerSideEncryptionCustomerKey       bool
 	ServerSideEncryptionCustomerKey          []byte
 	HasSize                                  bool
 	Size                                     int64
-	HasResponseContentDisposition 		     bool
-	ResponseContentDisposition 			     string
+	HasSuffixSize                            bool
+	SuffixSize                               int64
 }
 
 func (s *Storage) parsePairStorageQuerySignHTTPRead(opts []Pair) (pairStorageQuerySignHTTPRead, error) {
@@ -1186,12 +2739,42 @@ func (s *Storage) parsePairStorageQuerySignHTTPRead(opts []Pair) (pairStorageQue
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "if_match":
+			if result.HasIfMatch {
+				continue
+			}
+			result.HasIfMatch = true
+			result.IfMatch = v.Value.(string)
+		case "if_modified_since":
+			if result.HasIfModifiedSince {
+				continue
+			}
+			result.HasIfModifiedSince = true
+			result.IfModifiedSince = v.Value.(time.Time)
+		case "if_none_match":
+			if result.HasIfNoneMatch {
+				continue
+			}
+			result.HasIfNoneMatch = true
+			result.IfNoneMatch = v.Value.(string)
+		case "if_unmodified_since":
+			if result.HasIfUnmodifiedSince {
+				continue
+			}
+			result.HasIfUnmodifiedSince = true
+			result.IfUnmodifiedSince = v.Value.(time.Time)
 		case "offset":
 			if result.HasOffset {
 				continue
 			}
 			result.HasOffset = true
 			result.Offset = v.Value.(int64)
+		case "response_content_disposition":
+			if result.HasResponseContentDisposition {
+				continue
+			}
+			result.HasResponseContentDisposition = true
+			result.ResponseContentDisposition = v.Value.(string)
 		case "server_side_encryption_customer_algorithm":
 			if result.HasServerSideEncryptionCustomerAlgorithm {
 				continue
@@ -1210,12 +2793,12 @@ func (s *Storage) parsePairStorageQuerySignHTTPRead(opts []Pair) (pairStorageQue
 			}
 			result.HasSize = true
 			result.Size = v.Value.(int64)
-		case "response_content_disposition":
-			if result.HasResponseContentDisposition {
+		case "suffix_size":
+			if result.HasSuffixSize {
 				continue
 			}
-			result.HasResponseContentDisposition = true
-			result.ResponseContentDisposition = v.Value.(string)
+			result.HasSuffixSize = true
+			result.SuffixSize = v.Value.(int64)
 		default:
 			return pairStorageQuerySignHTTPRead{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1248,10 +2831,6 @@ type pairStorageQuerySignHTTPWrite struct {
 	ServerSideEncryptionCustomerKey          []byte
 	HasStorageClass                          bool
 	StorageClass                             string
-	HasCacheControl                          bool
-	CacheControl                             string
-	HasContentEncoding                       bool
-	ContentEncoding                          string
 }
 
 func (s *Storage) parsePairStorageQuerySignHTTPWrite(opts []Pair) (pairStorageQuerySignHTTPWrite, error) {
@@ -1320,18 +2899,6 @@ func (s *Storage) parsePairStorageQuerySignHTTPWrite(opts []Pair) (pairStorageQu
 			}
 			result.HasStorageClass = true
 			result.StorageClass = v.Value.(string)
-		case "cache_control":
-			if result.HasCacheControl {
-				continue
-			}
-			result.HasCacheControl = true
-			result.CacheControl = v.Value.(string)
-		case "content_encoding":
-			if result.HasContentEncoding {
-				continue
-			}
-			result.HasContentEncoding = true
-			result.ContentEncoding = v.Value.(string)
 		default:
 			return pairStorageQuerySignHTTPWrite{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1344,6 +2911,14 @@ type pairStorageQuerySignHTTPWriteMultipart struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasContentMd5                            bool
+	ContentMd5                               string
+	HasExceptedBucketOwner                   bool
+	ExceptedBucketOwner                      string
+	HasServerSideEncryptionCustomerAlgorithm bool
+	ServerSideEncryptionCustomerAlgorithm    string
+	HasServerSideEncryptionCustomerKey       bool
+	ServerSideEncryptionCustomerKey          []byte
 }
 
 func (s *Storage) parsePairStorageQuerySignHTTPWriteMultipart(opts []Pair) (pairStorageQuerySignHTTPWriteMultipart, error) {
@@ -1352,6 +2927,30 @@ func (s *Storage) parsePairStorageQuerySignHTTPWriteMultipart(opts []Pair) (pair
 
 	for _, v := range opts {
 		switch v.Key {
+		case "content_md5":
+			if result.HasContentMd5 {
+				continue
+			}
+			result.HasContentMd5 = true
+			result.ContentMd5 = v.Value.(string)
+		case "excepted_bucket_owner":
+			if result.HasExceptedBucketOwner {
+				continue
+			}
+			result.HasExceptedBucketOwner = true
+			result.ExceptedBucketOwner = v.Value.(string)
+		case "server_side_encryption_customer_algorithm":
+			if result.HasServerSideEncryptionCustomerAlgorithm {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerAlgorithm = true
+			result.ServerSideEncryptionCustomerAlgorithm = v.Value.(string)
+		case "server_side_encryption_customer_key":
+			if result.HasServerSideEncryptionCustomerKey {
+				continue
+			}
+			result.HasServerSideEncryptionCustomerKey = true
+			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
 		default:
 			return pairStorageQuerySignHTTPWriteMultipart{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1364,20 +2963,44 @@ type pairStorageRead struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasDecompress                            bool
+	Decompress                               bool
 	HasExceptedBucketOwner                   bool
 	ExceptedBucketOwner                      string
+	HasFollowLink                            bool
+	FollowLink                               bool
+	HasFollowLinkDepth                       bool
+	FollowLinkDepth                          int
+	HasIfMatch                               bool
+	IfMatch                                  string
+	HasIfModifiedSince                       bool
+	IfModifiedSince                          time.Time
+	HasIfNoneMatch                           bool
+	IfNoneMatch                              string
+	HasIfUnmodifiedSince                     bool
+	IfUnmodifiedSince                        time.Time
 	HasIoCallback                            bool
 	IoCallback                               func([]byte)
+	HasObjectCallback                        bool
+	ObjectCallback                           func(*Object)
 	HasOffset                                bool
 	Offset                                   int64
+	HasProgressCallback                      bool
+	ProgressCallback                         ProgressFunc
+	HasReadResumeAttempts                    bool
+	ReadResumeAttempts                       int
+	HasReadTransform                         bool
+	ReadTransform                            ReadTransform
+	HasResponseContentDisposition            bool
+	ResponseContentDisposition               string
 	HasServerSideEncryptionCustomerAlgorithm bool
 	ServerSideEncryptionCustomerAlgorithm    string
 	HasServerSideEncryptionCustomerKey       bool
 	ServerSideEncryptionCustomerKey          []byte
 	HasSize                                  bool
 	Size                                     int64
-	HasResponseContentDisposition 		     bool
-	ResponseContentDisposition 			     string
+	HasSuffixSize                            bool
+	SuffixSize                               int64
 }
 
 func (s *Storage) parsePairStorageRead(opts []Pair) (pairStorageRead, error) {
@@ -1386,24 +3009,96 @@ func (s *Storage) parsePairStorageRead(opts []Pair) (pairStorageRead, error) {
 
 	for _, v := range opts {
 		switch v.Key {
+		case "decompress":
+			if result.HasDecompress {
+				continue
+			}
+			result.HasDecompress = true
+			result.Decompress = v.Value.(bool)
 		case "excepted_bucket_owner":
 			if result.HasExceptedBucketOwner {
 				continue
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "follow_link":
+			if result.HasFollowLink {
+				continue
+			}
+			result.HasFollowLink = true
+			result.FollowLink = v.Value.(bool)
+		case "follow_link_depth":
+			if result.HasFollowLinkDepth {
+				continue
+			}
+			result.HasFollowLinkDepth = true
+			result.FollowLinkDepth = v.Value.(int)
+		case "if_match":
+			if result.HasIfMatch {
+				continue
+			}
+			result.HasIfMatch = true
+			result.IfMatch = v.Value.(string)
+		case "if_modified_since":
+			if result.HasIfModifiedSince {
+				continue
+			}
+			result.HasIfModifiedSince = true
+			result.IfModifiedSince = v.Value.(time.Time)
+		case "if_none_match":
+			if result.HasIfNoneMatch {
+				continue
+			}
+			result.HasIfNoneMatch = true
+			result.IfNoneMatch = v.Value.(string)
+		case "if_unmodified_since":
+			if result.HasIfUnmodifiedSince {
+				continue
+			}
+			result.HasIfUnmodifiedSince = true
+			result.IfUnmodifiedSince = v.Value.(time.Time)
 		case "io_callback":
 			if result.HasIoCallback {
 				continue
 			}
 			result.HasIoCallback = true
 			result.IoCallback = v.Value.(func([]byte))
+		case "object_callback":
+			if result.HasObjectCallback {
+				continue
+			}
+			result.HasObjectCallback = true
+			result.ObjectCallback = v.Value.(func(*Object))
 		case "offset":
 			if result.HasOffset {
 				continue
 			}
 			result.HasOffset = true
 			result.Offset = v.Value.(int64)
+		case "progress_callback":
+			if result.HasProgressCallback {
+				continue
+			}
+			result.HasProgressCallback = true
+			result.ProgressCallback = v.Value.(ProgressFunc)
+		case "read_resume_attempts":
+			if result.HasReadResumeAttempts {
+				continue
+			}
+			result.HasReadResumeAttempts = true
+			result.ReadResumeAttempts = v.Value.(int)
+		case "read_transform":
+			if result.HasReadTransform {
+				continue
+			}
+			result.HasReadTransform = true
+			result.ReadTransform = v.Value.(ReadTransform)
+		case "response_content_disposition":
+			if result.HasResponseContentDisposition {
+				continue
+			}
+			result.HasResponseContentDisposition = true
+			result.ResponseContentDisposition = v.Value.(string)
 		case "server_side_encryption_customer_algorithm":
 			if result.HasServerSideEncryptionCustomerAlgorithm {
 				continue
@@ -1422,12 +3117,12 @@ func (s *Storage) parsePairStorageRead(opts []Pair) (pairStorageRead, error) {
 			}
 			result.HasSize = true
 			result.Size = v.Value.(int64)
-		case "response_content_disposition":
-			if result.HasResponseContentDisposition {
+		case "suffix_size":
+			if result.HasSuffixSize {
 				continue
 			}
-			result.HasResponseContentDisposition = true
-			result.ResponseContentDisposition = v.Value.(string)
+			result.HasSuffixSize = true
+			result.SuffixSize = v.Value.(int64)
 		default:
 			return pairStorageRead{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1442,6 +3137,18 @@ type pairStorageStat struct {
 	// Optional pairs
 	HasExceptedBucketOwner                   bool
 	ExceptedBucketOwner                      string
+	HasFollowLink                            bool
+	FollowLink                               bool
+	HasFollowLinkDepth                       bool
+	FollowLinkDepth                          int
+	HasIfMatch                               bool
+	IfMatch                                  string
+	HasIfModifiedSince                       bool
+	IfModifiedSince                          time.Time
+	HasIfNoneMatch                           bool
+	IfNoneMatch                              string
+	HasIfUnmodifiedSince                     bool
+	IfUnmodifiedSince                        time.Time
 	HasMultipartID                           bool
 	MultipartID                              string
 	HasObjectMode                            bool
@@ -1450,6 +3157,8 @@ type pairStorageStat struct {
 	ServerSideEncryptionCustomerAlgorithm    string
 	HasServerSideEncryptionCustomerKey       bool
 	ServerSideEncryptionCustomerKey          []byte
+	HasStatFast                              bool
+	StatFast                                 bool
 }
 
 func (s *Storage) parsePairStorageStat(opts []Pair) (pairStorageStat, error) {
@@ -1464,6 +3173,42 @@ func (s *Storage) parsePairStorageStat(opts []Pair) (pairStorageStat, error) {
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "follow_link":
+			if result.HasFollowLink {
+				continue
+			}
+			result.HasFollowLink = true
+			result.FollowLink = v.Value.(bool)
+		case "follow_link_depth":
+			if result.HasFollowLinkDepth {
+				continue
+			}
+			result.HasFollowLinkDepth = true
+			result.FollowLinkDepth = v.Value.(int)
+		case "if_match":
+			if result.HasIfMatch {
+				continue
+			}
+			result.HasIfMatch = true
+			result.IfMatch = v.Value.(string)
+		case "if_modified_since":
+			if result.HasIfModifiedSince {
+				continue
+			}
+			result.HasIfModifiedSince = true
+			result.IfModifiedSince = v.Value.(time.Time)
+		case "if_none_match":
+			if result.HasIfNoneMatch {
+				continue
+			}
+			result.HasIfNoneMatch = true
+			result.IfNoneMatch = v.Value.(string)
+		case "if_unmodified_since":
+			if result.HasIfUnmodifiedSince {
+				continue
+			}
+			result.HasIfUnmodifiedSince = true
+			result.IfUnmodifiedSince = v.Value.(time.Time)
 		case "multipart_id":
 			if result.HasMultipartID {
 				continue
@@ -1488,6 +3233,12 @@ func (s *Storage) parsePairStorageStat(opts []Pair) (pairStorageStat, error) {
 			}
 			result.HasServerSideEncryptionCustomerKey = true
 			result.ServerSideEncryptionCustomerKey = v.Value.([]byte)
+		case "stat_fast":
+			if result.HasStatFast {
+				continue
+			}
+			result.HasStatFast = true
+			result.StatFast = v.Value.(bool)
 		default:
 			return pairStorageStat{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1500,14 +3251,40 @@ type pairStorageWrite struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasAutoContentType                       bool
+	AutoContentType                          bool
+	HasCacheControl                          bool
+	CacheControl                             string
+	HasCompress                              bool
+	Compress                                 string
+	HasContentDisposition                    bool
+	ContentDisposition                       string
+	HasContentEncoding                       bool
+	ContentEncoding                          string
+	HasContentLanguage                       bool
+	ContentLanguage                          string
 	HasContentMd5                            bool
 	ContentMd5                               string
 	HasContentType                           bool
 	ContentType                              string
 	HasExceptedBucketOwner                   bool
 	ExceptedBucketOwner                      string
+	HasExpectedEtag                          bool
+	ExpectedEtag                             string
+	HasGrantFullControl                      bool
+	GrantFullControl                         string
+	HasGrantRead                             bool
+	GrantRead                                string
+	HasGrantReadAcp                          bool
+	GrantReadAcp                             string
+	HasGrantWriteAcp                         bool
+	GrantWriteAcp                            string
+	HasIfNoneMatch                           bool
+	IfNoneMatch                              string
 	HasIoCallback                            bool
 	IoCallback                               func([]byte)
+	HasProgressCallback                      bool
+	ProgressCallback                         ProgressFunc
 	HasServerSideEncryption                  bool
 	ServerSideEncryption                     string
 	HasServerSideEncryptionAwsKmsKeyID       bool
@@ -1522,10 +3299,12 @@ type pairStorageWrite struct {
 	ServerSideEncryptionCustomerKey          []byte
 	HasStorageClass                          bool
 	StorageClass                             string
-	HasCacheControl                          bool
-	CacheControl                             string
-	HasContentEncoding                       bool
-	ContentEncoding                          string
+	HasTagging                               bool
+	Tagging                                  map[string]string
+	HasUserMetadata                          bool
+	UserMetadata                             map[string]string
+	HasWriteResult                           bool
+	WriteResult                              *WriteResult
 }
 
 func (s *Storage) parsePairStorageWrite(opts []Pair) (pairStorageWrite, error) {
@@ -1534,6 +3313,42 @@ func (s *Storage) parsePairStorageWrite(opts []Pair) (pairStorageWrite, error) {
 
 	for _, v := range opts {
 		switch v.Key {
+		case "auto_content_type":
+			if result.HasAutoContentType {
+				continue
+			}
+			result.HasAutoContentType = true
+			result.AutoContentType = v.Value.(bool)
+		case "cache_control":
+			if result.HasCacheControl {
+				continue
+			}
+			result.HasCacheControl = true
+			result.CacheControl = v.Value.(string)
+		case "compress":
+			if result.HasCompress {
+				continue
+			}
+			result.HasCompress = true
+			result.Compress = v.Value.(string)
+		case "content_disposition":
+			if result.HasContentDisposition {
+				continue
+			}
+			result.HasContentDisposition = true
+			result.ContentDisposition = v.Value.(string)
+		case "content_encoding":
+			if result.HasContentEncoding {
+				continue
+			}
+			result.HasContentEncoding = true
+			result.ContentEncoding = v.Value.(string)
+		case "content_language":
+			if result.HasContentLanguage {
+				continue
+			}
+			result.HasContentLanguage = true
+			result.ContentLanguage = v.Value.(string)
 		case "content_md5":
 			if result.HasContentMd5 {
 				continue
@@ -1552,12 +3367,54 @@ func (s *Storage) parsePairStorageWrite(opts []Pair) (pairStorageWrite, error) {
 			}
 			result.HasExceptedBucketOwner = true
 			result.ExceptedBucketOwner = v.Value.(string)
+		case "expected_etag":
+			if result.HasExpectedEtag {
+				continue
+			}
+			result.HasExpectedEtag = true
+			result.ExpectedEtag = v.Value.(string)
+		case "grant_full_control":
+			if result.HasGrantFullControl {
+				continue
+			}
+			result.HasGrantFullControl = true
+			result.GrantFullControl = v.Value.(string)
+		case "grant_read":
+			if result.HasGrantRead {
+				continue
+			}
+			result.HasGrantRead = true
+			result.GrantRead = v.Value.(string)
+		case "grant_read_acp":
+			if result.HasGrantReadAcp {
+				continue
+			}
+			result.HasGrantReadAcp = true
+			result.GrantReadAcp = v.Value.(string)
+		case "grant_write_acp":
+			if result.HasGrantWriteAcp {
+				continue
+			}
+			result.HasGrantWriteAcp = true
+			result.GrantWriteAcp = v.Value.(string)
+		case "if_none_match":
+			if result.HasIfNoneMatch {
+				continue
+			}
+			result.HasIfNoneMatch = true
+			result.IfNoneMatch = v.Value.(string)
 		case "io_callback":
 			if result.HasIoCallback {
 				continue
 			}
 			result.HasIoCallback = true
 			result.IoCallback = v.Value.(func([]byte))
+		case "progress_callback":
+			if result.HasProgressCallback {
+				continue
+			}
+			result.HasProgressCallback = true
+			result.ProgressCallback = v.Value.(ProgressFunc)
 		case "server_side_encryption":
 			if result.HasServerSideEncryption {
 				continue
@@ -1600,18 +3457,24 @@ func (s *Storage) parsePairStorageWrite(opts []Pair) (pairStorageWrite, error) {
 			}
 			result.HasStorageClass = true
 			result.StorageClass = v.Value.(string)
-		case "cache_control":
-			if result.HasCacheControl {
+		case "tagging":
+			if result.HasTagging {
 				continue
 			}
-			result.HasCacheControl = true
-			result.CacheControl = v.Value.(string)
-		case "content_encoding":
-			if result.HasContentEncoding {
+			result.HasTagging = true
+			result.Tagging = v.Value.(map[string]string)
+		case "user_metadata":
+			if result.HasUserMetadata {
 				continue
 			}
-			result.HasContentEncoding = true
-			result.ContentEncoding = v.Value.(string)
+			result.HasUserMetadata = true
+			result.UserMetadata = v.Value.(map[string]string)
+		case "write_result":
+			if result.HasWriteResult {
+				continue
+			}
+			result.HasWriteResult = true
+			result.WriteResult = v.Value.(*WriteResult)
 		default:
 			return pairStorageWrite{}, services.PairUnsupportedError{Pair: v}
 		}
@@ -1624,10 +3487,16 @@ type pairStorageWriteMultipart struct {
 	pairs []Pair
 	// Required pairs
 	// Optional pairs
+	HasAbortOnCancel                         bool
+	AbortOnCancel                            bool
+	HasContentMd5                            bool
+	ContentMd5                               string
 	HasExceptedBucketOwner                   bool
 	ExceptedBucketOwner                      string
 	HasIoCallback                            bool
 	IoCallback                               func([]byte)
+	HasProgressCallback                      bool
+	ProgressCallback                         ProgressFunc
 	HasServerSideEncryptionCustomerAlgorithm bool
 	ServerSideEncryptionCustomerAlgorithm    string
 	HasServerSideEncryptionCustomerKey       bool
@@ -1640,6 +3509,18 @@ func (s *Storage) parsePairStorageWriteMultipart(opts []Pair) (pairStorageWriteM
 
 	for _, v := range opts {
 		switch v.Key {
+		case "abort_on_cancel":
+			if result.HasAbortOnCancel {
+				continue
+			}
+			result.HasAbortOnCancel = true
+			result.AbortOnCancel = v.Value.(bool)
+		case "content_md5":
+			if result.HasContentMd5 {
+				continue
+			}
+			result.HasContentMd5 = true
+			result.ContentMd5 = v.Value.(string)
 		case "excepted_bucket_owner":
 			if result.HasExceptedBucketOwner {
 				continue
@@ -1652,6 +3533,12 @@ func (s *Storage) parsePairStorageWriteMultipart(opts []Pair) (pairStorageWriteM
 			}
 			result.HasIoCallback = true
 			result.IoCallback = v.Value.(func([]byte))
+		case "progress_callback":
+			if result.HasProgressCallback {
+				continue
+			}
+			result.HasProgressCallback = true
+			result.ProgressCallback = v.Value.(ProgressFunc)
 		case "server_side_encryption_customer_algorithm":
 			if result.HasServerSideEncryptionCustomerAlgorithm {
 				continue
@@ -1693,6 +3580,25 @@ func (s *Storage) CompleteMultipartWithContext(ctx context.Context, o *Object, p
 	}
 	return s.completeMultipart(ctx, o, parts, opt)
 }
+func (s *Storage) Copy(src string, dst string, pairs ...Pair) (err error) {
+	ctx := context.Background()
+	return s.CopyWithContext(ctx, src, dst, pairs...)
+}
+func (s *Storage) CopyWithContext(ctx context.Context, src string, dst string, pairs ...Pair) (err error) {
+	defer func() {
+		err =
+			s.formatError("copy", err, src, dst)
+	}()
+
+	pairs = append(pairs, s.defaultPairs.Copy...)
+	var opt pairStorageCopy
+
+	opt, err = s.parsePairStorageCopy(pairs)
+	if err != nil {
+		return
+	}
+	return s.copy(ctx, strings.ReplaceAll(src, "\\", "/"), strings.ReplaceAll(dst, "\\", "/"), opt)
+}
 func (s *Storage) Create(path string, pairs ...Pair) (o *Object) {
 	pairs = append(pairs, s.defaultPairs.Create...)
 	var opt pairStorageCreate
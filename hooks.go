@@ -0,0 +1,200 @@
+package s3
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// RetryEvent carries the details of a retry attempted by the SDK.
+type RetryEvent struct {
+	// Operation is the name of the S3 API operation, for example `PutObject`.
+	Operation string
+	// Attempt is the 1-based number of the retry that is about to be made.
+	Attempt int
+	// Err is the error that caused the retry, signatures and secrets in its message are redacted,
+	// the original error could be retrieved via errors.As.
+	Err error
+	// Delay is the backoff the SDK will sleep before the retry.
+	Delay time.Duration
+}
+
+// callbackRetryer wraps a request.Retryer so that every retry could be observed.
+type callbackRetryer struct {
+	request.Retryer
+
+	callback func(RetryEvent)
+}
+
+// newCallbackRetryer wraps the retryer configured in cfg, which falls back to the default
+// retryer with cfg.MaxRetries the same as the SDK does.
+func newCallbackRetryer(cfg *aws.Config, fn func(RetryEvent)) request.Retryer {
+	retryer, ok := cfg.Retryer.(request.Retryer)
+	if !ok {
+		maxRetries := aws.IntValue(cfg.MaxRetries)
+		if cfg.MaxRetries == nil || maxRetries == aws.UseServiceDefaultRetries {
+			maxRetries = client.DefaultRetryerMaxNumRetries
+		}
+		retryer = client.DefaultRetryer{NumMaxRetries: maxRetries}
+	}
+	return callbackRetryer{
+		Retryer:  retryer,
+		callback: fn,
+	}
+}
+
+// RetryRules will only be called by the SDK while the request is going to be retried,
+// so it's the right place to emit the retry event.
+func (r callbackRetryer) RetryRules(req *request.Request) time.Duration {
+	delay := r.Retryer.RetryRules(req)
+
+	r.callback(RetryEvent{
+		Operation: req.Operation.Name,
+		Attempt:   req.RetryCount + 1,
+		Err:       redactError(req.Error),
+		Delay:     delay,
+	})
+	return delay
+}
+
+// SlowOperationEvent carries the details of an operation which took longer than
+// the configured slow operation threshold.
+type SlowOperationEvent struct {
+	// Operation is the name of the storager operation, for example `read`.
+	Operation string
+	// Path is the path passed to the operation.
+	Path string
+	// Bytes is the number of bytes transferred by the operation.
+	Bytes int64
+	// Duration is the time spent by the operation.
+	Duration time.Duration
+}
+
+// Throughput returns the computed throughput in bytes per second.
+func (e SlowOperationEvent) Throughput() float64 {
+	if e.Duration <= 0 {
+		return 0
+	}
+	return float64(e.Bytes) / e.Duration.Seconds()
+}
+
+// reportSlowOperation will emit a SlowOperationEvent to the slow operation callback if the
+// operation started at start exceeds the slow operation threshold.
+func (s *Storage) reportSlowOperation(op, path string, n int64, start time.Time) {
+	if s.slowOperationThreshold <= 0 || s.slowOperationCallback == nil {
+		return
+	}
+	d := time.Since(start)
+	if d < s.slowOperationThreshold {
+		return
+	}
+
+	s.slowOperationCallback(SlowOperationEvent{
+		Operation: op,
+		Path:      path,
+		Bytes:     n,
+		Duration:  d,
+	})
+}
+
+// RequestHandlers contains custom handlers which will be appended into the SDK
+// request handler lists.
+//
+// Handlers are added into the session, so they will run before the handlers added by
+// the s3 client itself. For example, Sign handlers here run before the V4 signer, which
+// makes it possible to add custom headers that will be signed.
+type RequestHandlers struct {
+	Build     []request.NamedHandler
+	Sign      []request.NamedHandler
+	Send      []request.NamedHandler
+	Unmarshal []request.NamedHandler
+}
+
+func (rh RequestHandlers) apply(h *request.Handlers) {
+	for _, v := range rh.Build {
+		h.Build.PushBackNamed(v)
+	}
+	for _, v := range rh.Sign {
+		h.Sign.PushBackNamed(v)
+	}
+	for _, v := range rh.Send {
+		h.Send.PushBackNamed(v)
+	}
+	for _, v := range rh.Unmarshal {
+		h.Unmarshal.PushBackNamed(v)
+	}
+}
+
+// RequestTier is the billing tier of a S3 request.
+type RequestTier string
+
+// All available request tiers are listed here.
+//
+// ref: https://aws.amazon.com/s3/pricing/
+const (
+	// RequestTierGet contains GET, HEAD, SELECT and all other requests.
+	RequestTierGet RequestTier = "GET"
+	// RequestTierPut contains PUT, COPY and POST requests.
+	RequestTierPut RequestTier = "PUT"
+	// RequestTierList contains LIST requests.
+	RequestTierList RequestTier = "LIST"
+	// RequestTierDelete contains DELETE requests, which are free of charge.
+	RequestTierDelete RequestTier = "DELETE"
+)
+
+// RequestCostEvent carries the details of a sent request for cost estimation.
+type RequestCostEvent struct {
+	// Operation is the name of the S3 API operation, for example `PutObject`.
+	Operation string
+	// Tier is the billing tier of the operation.
+	Tier RequestTier
+	// StatusCode is the HTTP status code returned by S3.
+	StatusCode int
+	// BytesSent is the size of the request body.
+	BytesSent int64
+	// BytesReceived is the size of the response body declared by S3.
+	BytesReceived int64
+}
+
+func requestTierOf(operation string) RequestTier {
+	switch {
+	case strings.HasPrefix(operation, "List"):
+		return RequestTierList
+	case strings.HasPrefix(operation, "Delete"), operation == "AbortMultipartUpload":
+		return RequestTierDelete
+	case strings.HasPrefix(operation, "Put"), strings.HasPrefix(operation, "Create"),
+		strings.HasPrefix(operation, "Copy"), strings.HasPrefix(operation, "Upload"),
+		operation == "CompleteMultipartUpload", operation == "RestoreObject":
+		return RequestTierPut
+	default:
+		return RequestTierGet
+	}
+}
+
+func newRequestCostHandler(fn func(RequestCostEvent)) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "s3.RequestCostHandler",
+		Fn: func(r *request.Request) {
+			// Requests that never reach S3 will not be charged.
+			if r.HTTPResponse == nil {
+				return
+			}
+
+			e := RequestCostEvent{
+				Operation:  r.Operation.Name,
+				Tier:       requestTierOf(r.Operation.Name),
+				StatusCode: r.HTTPResponse.StatusCode,
+			}
+			if r.HTTPRequest != nil && r.HTTPRequest.ContentLength > 0 {
+				e.BytesSent = r.HTTPRequest.ContentLength
+			}
+			if r.HTTPResponse.ContentLength > 0 {
+				e.BytesReceived = r.HTTPResponse.ContentLength
+			}
+			fn(e)
+		},
+	}
+}
@@ -0,0 +1,145 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// All available content integrity modes are listed here.
+//
+// Content is uploaded with `UNSIGNED-PAYLOAD`, so it will not be verified by S3 end-to-end
+// unless a Content-MD5 checksum is sent. In both modes, the checksum will be calculated by
+// reading seekable content in advance while content_md5 is not passed in.
+const (
+	// ContentIntegrityModeStrict rejects unseekable content without content_md5 with ErrContentUnverifiable.
+	ContentIntegrityModeStrict = "strict"
+	// ContentIntegrityModeSpool spools unseekable content without content_md5 to a temporary file
+	// to calculate the checksum, which requires disk space as large as the content.
+	ContentIntegrityModeSpool = "spool"
+)
+
+// checksumReader returns the base64 encoded md5 of the next size bytes of r, and a reader which
+// yields the same content. cleanup must be called after the reader is used.
+func (s *Storage) checksumReader(r io.Reader, size int64) (cr io.Reader, sum string, cleanup func(), err error) {
+	h := md5.New()
+
+	if rs, ok := r.(io.ReadSeeker); ok {
+		var offset int64
+		if offset, err = rs.Seek(0, io.SeekCurrent); err != nil {
+			return
+		}
+		if _, err = io.CopyN(h, rs, size); err != nil {
+			return
+		}
+		if _, err = rs.Seek(offset, io.SeekStart); err != nil {
+			return
+		}
+		return rs, base64.StdEncoding.EncodeToString(h.Sum(nil)), func() {}, nil
+	}
+
+	if s.contentIntegrityMode != ContentIntegrityModeSpool {
+		return nil, "", nil, ErrContentUnverifiable
+	}
+
+	f, err := ioutil.TempFile("", "go-service-s3-")
+	if err != nil {
+		return
+	}
+	cleanup = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err = io.CopyN(io.MultiWriter(f, h), r, size); err != nil {
+		cleanup()
+		return nil, "", nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, "", nil, err
+	}
+	return f, base64.StdEncoding.EncodeToString(h.Sum(nil)), cleanup, nil
+}
+
+// limitReader will limit r to the next size bytes. Seekable r is kept seekable, so that it could
+// be rewound by the SDK while retrying without being spooled.
+func limitReader(r io.Reader, size int64) (io.Reader, error) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		return io.LimitReader(r, size), nil
+	}
+	offset, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if ra, ok := r.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, offset, size), nil
+	}
+	return &limitedReadSeeker{rs: rs, base: offset, size: size}, nil
+}
+
+// limitedReadSeeker is a io.ReadSeeker limited to size bytes starting at base of rs.
+type limitedReadSeeker struct {
+	rs         io.ReadSeeker
+	base, size int64
+	// offset is the position relative to base.
+	offset int64
+}
+
+func (l *limitedReadSeeker) Read(p []byte) (int, error) {
+	if l.offset >= l.size {
+		return 0, io.EOF
+	}
+	if max := l.size - l.offset; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := l.rs.Read(p)
+	l.offset += int64(n)
+	return n, err
+}
+
+func (l *limitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += l.offset
+	case io.SeekEnd:
+		offset += l.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	if _, err := l.rs.Seek(l.base+offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	l.offset = offset
+	return offset, nil
+}
+
+// spoolReader will buffer the next size bytes of r in memory if r is unseekable and size is
+// not larger than write_spool_threshold, so that the SDK could rewind the body while retrying.
+// Otherwise, a request failed after the body has been read could not be retried.
+func (s *Storage) spoolReader(r io.Reader, size int64) (io.Reader, error) {
+	switch v := r.(type) {
+	case io.ReadSeeker:
+		return r, nil
+	case *bytes.Buffer:
+		// Content compressed has been buffered already.
+		return bytes.NewReader(v.Bytes()), nil
+	}
+	if s.writeSpoolThreshold <= 0 || size > s.writeSpoolThreshold {
+		return r, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	if _, err := io.CopyN(buf, r, size); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(buf.Bytes()), nil
+}
@@ -0,0 +1,219 @@
+package s3
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// InventoryManifest is the `manifest.json` of an S3 Inventory report.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/storage-inventory-location.html
+type InventoryManifest struct {
+	SourceBucket      string `json:"sourceBucket"`
+	DestinationBucket string `json:"destinationBucket"`
+	Version           string `json:"version"`
+	CreationTimestamp string `json:"creationTimestamp"`
+	FileFormat        string `json:"fileFormat"`
+	FileSchema        string `json:"fileSchema"`
+	Files             []struct {
+		Key         string `json:"key"`
+		Size        int64  `json:"size"`
+		MD5Checksum string `json:"MD5checksum"`
+	} `json:"files"`
+}
+
+// inventoryFileFormatCSV is the only inventory format supported, as ORC and Parquet are not
+// available in the standard library.
+const inventoryFileFormatCSV = "CSV"
+
+// LatestInventoryManifest will locate the latest inventory manifest under prefix, which should
+// be the `<destination-prefix>/<source-bucket>/<config-id>/` of an inventory configuration.
+//
+// The returned path could be passed to ListInventory.
+func (s *Storage) LatestInventoryManifest(ctx context.Context, prefix string) (path string, err error) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	it, err := s.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModeDir))
+	if err != nil {
+		return
+	}
+
+	var latest string
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		// Reports are stored in dirs named by creation time like `2021-10-01T00-00Z`, which
+		// could be compared lexicographically. Other dirs like `data/` and `hive/` are skipped.
+		name := strings.TrimPrefix(strings.TrimSuffix(o.Path, "/"), strings.TrimPrefix(prefix, "/"))
+		if !o.Mode.IsDir() || name == "" || name[0] < '0' || name[0] > '9' {
+			continue
+		}
+		if o.Path > latest {
+			latest = o.Path
+		}
+	}
+	if latest == "" {
+		return "", s.formatError("latest_inventory_manifest", services.ErrObjectNotExist, prefix)
+	}
+	return strings.TrimSuffix(latest, "/") + "/manifest.json", nil
+}
+
+// ListInventory will list the entries of the inventory report described by the manifest at path,
+// so that objects in the source bucket could be walked without listing.
+//
+// Only the CSV format is supported. Entries are returned with ID and Path set to the key in the
+// source bucket, along with size, etag, last modified and storage class if present in the schema.
+func (s *Storage) ListInventory(ctx context.Context, path string) (oi *typ.ObjectIterator, err error) {
+	defer func() {
+		err = s.formatError("list_inventory", err, path)
+	}()
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	output, err := s.service.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(rp),
+	})
+	if err != nil {
+		return
+	}
+	defer output.Body.Close()
+
+	var manifest InventoryManifest
+	if err = json.NewDecoder(output.Body).Decode(&manifest); err != nil {
+		return
+	}
+	if manifest.FileFormat != inventoryFileFormatCSV {
+		return nil, fmt.Errorf("inventory format %s: %w", manifest.FileFormat, services.ErrCapabilityInsufficient)
+	}
+
+	status := &inventoryPageStatus{
+		schema: strings.Split(manifest.FileSchema, ", "),
+	}
+	for _, v := range manifest.Files {
+		status.files = append(status.files, v.Key)
+	}
+	return typ.NewObjectIterator(ctx, s.nextInventoryPage, status), nil
+}
+
+func (s *Storage) nextInventoryPage(ctx context.Context, page *typ.ObjectPage) error {
+	input := page.Status.(*inventoryPageStatus)
+
+	for len(page.Data) < inventoryPageSize {
+		if input.reader == nil {
+			if input.fileIndex >= len(input.files) {
+				return typ.IterateDone
+			}
+			if err := s.openInventoryFile(ctx, input); err != nil {
+				return err
+			}
+		}
+
+		record, err := input.reader.Read()
+		if err == io.EOF {
+			input.body.Close()
+			input.body, input.reader = nil, nil
+			input.fileIndex++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		o, err := s.formatInventoryRecord(input.schema, record)
+		if err != nil {
+			return err
+		}
+		page.Data = append(page.Data, o)
+	}
+	return nil
+}
+
+func (s *Storage) openInventoryFile(ctx context.Context, input *inventoryPageStatus) error {
+	// Inventory files are stored with absolute keys in the destination bucket.
+	output, err := s.service.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(input.files[input.fileIndex]),
+	})
+	if err != nil {
+		return err
+	}
+	gr, err := gzip.NewReader(output.Body)
+	if err != nil {
+		output.Body.Close()
+		return err
+	}
+
+	input.body = output.Body
+	input.reader = csv.NewReader(gr)
+	input.reader.FieldsPerRecord = len(input.schema)
+	return nil
+}
+
+// inventoryPageSize is the max entries returned in a page of inventory.
+const inventoryPageSize = 1000
+
+func (s *Storage) formatInventoryRecord(schema, record []string) (o *typ.Object, err error) {
+	o = s.newObject(true)
+	o.Mode |= typ.ModeRead
+
+	var sm ObjectSystemMetadata
+	for i, field := range schema {
+		v := record[i]
+		if v == "" {
+			continue
+		}
+
+		switch field {
+		case "Key":
+			// Keys in CSV inventory are URL-encoded.
+			if v, err = url.QueryUnescape(v); err != nil {
+				return nil, err
+			}
+			o.ID = v
+			o.Path = v
+		case "Size":
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			o.SetContentLength(size)
+		case "LastModifiedDate":
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, err
+			}
+			o.SetLastModified(t)
+		case "ETag":
+			o.SetEtag(v)
+		case "StorageClass":
+			sm.StorageClass = v
+		}
+	}
+	o.SetSystemMetadata(sm)
+	return o, nil
+}
@@ -1,6 +1,9 @@
 package s3
 
 import (
+	"encoding/csv"
+	"io"
+	"net/url"
 	"strconv"
 )
 
@@ -11,12 +14,43 @@ type objectPageStatus struct {
 
 	// Only used for object
 	continuationToken string
+	// Only used for prefix, the listing will start after the key if continuationToken is empty.
+	startAfter string
 
 	// Only used for part object
+	part           bool
 	keyMarker      string
 	uploadIdMarker string
 
+	// pageToken is the token of the page being iterated.
+	pageToken string
+
 	expectedBucketOwner string
+	// Only used for object, will HEAD zero-byte objects to mark links.
+	detectLink bool
+	// Only used for dir, objects other than dir markers will be skipped.
+	dirOnly bool
+	// Only used for object, objects will be marked as done so that the fields in the listing could
+	// be read without a stat, which is only set by callers needing no other fields.
+	listedOnly bool
+
+	// limit is the maximum number of objects returned, 0 means no limit.
+	limit    int64
+	returned int64
+
+	// maxPages is the maximum number of pages requested, 0 means unbounded.
+	maxPages int64
+	pages    int64
+}
+
+// getServiceMaxKeys returns maxKeys, which will be shrunk to the number of objects remaining
+// under limit, so that no more objects than needed are requested.
+func (i objectPageStatus) getServiceMaxKeys() *int64 {
+	n := i.maxKeys
+	if i.limit > 0 && i.limit-i.returned < n {
+		n = i.limit - i.returned
+	}
+	return &n
 }
 
 // getServiceContinuationToken equals aws.String, but return nil while empty.
@@ -31,11 +65,47 @@ func (i objectPageStatus) getServiceContinuationToken() *string {
 	return &i.continuationToken
 }
 
+// ContinuationToken returns the token of the page which the last returned object belongs to, so
+// that the listing resumed from it via the continuation_token pair will start from the same page.
+// Objects will not be missed, but the ones returned before the checkpoint in the page will be
+// returned again.
 func (i *objectPageStatus) ContinuationToken() string {
-	if i.uploadIdMarker != "" {
-		return i.continuationToken + "/" + i.uploadIdMarker
+	return i.pageToken
+}
+
+// startPage should be called before fetching the next page.
+func (i *objectPageStatus) startPage() {
+	if !i.part {
+		i.pageToken = i.continuationToken
+		return
+	}
+	if i.keyMarker == "" && i.uploadIdMarker == "" {
+		i.pageToken = ""
+		return
 	}
-	return i.continuationToken
+	// Both markers are kept, as keys could contain any characters.
+	i.pageToken = url.Values{
+		"key-marker":       []string{i.keyMarker},
+		"upload-id-marker": []string{i.uploadIdMarker},
+	}.Encode()
+}
+
+// setContinuationToken will resume the listing from the token returned by ContinuationToken.
+func (i *objectPageStatus) setContinuationToken(token string) error {
+	if !i.part {
+		i.continuationToken = token
+		return nil
+	}
+	if token == "" {
+		return nil
+	}
+	v, err := url.ParseQuery(token)
+	if err != nil {
+		return err
+	}
+	i.keyMarker = v.Get("key-marker")
+	i.uploadIdMarker = v.Get("upload-id-marker")
+	return nil
 }
 
 type storagePageStatus struct {
@@ -60,3 +130,16 @@ type partPageStatus struct {
 func (i *partPageStatus) ContinuationToken() string {
 	return strconv.FormatInt(i.partNumberMarker, 10)
 }
+
+type inventoryPageStatus struct {
+	schema []string
+	files  []string
+
+	fileIndex int
+	body      io.ReadCloser
+	reader    *csv.Reader
+}
+
+func (i *inventoryPageStatus) ContinuationToken() string {
+	return strconv.Itoa(i.fileIndex)
+}
@@ -0,0 +1,170 @@
+package s3
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// metadataLeaseExpire is the name of the user-defined metadata used to store the lease expire time.
+const metadataLeaseExpire = "bs-lease-expire"
+
+// Lease is an exclusive lease on a path acquired by AcquireLease.
+//
+// Leases rely on the clocks of holders, so ttl should be much larger than the clock skew between them.
+type Lease struct {
+	s    *Storage
+	path string
+	rp   string
+
+	token  string
+	etag   string
+	expire time.Time
+}
+
+// AcquireLease will acquire an exclusive lease on path for ttl, by creating the lease object at path
+// only if it doesn't exist. An expired lease held by others will be taken over.
+//
+// ErrLeaseHeld will be returned if the lease is held by others and not expired yet.
+func (s *Storage) AcquireLease(ctx context.Context, path string, ttl time.Duration) (l *Lease, err error) {
+	defer func() {
+		err = s.formatError("acquire_lease", err, path)
+	}()
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	token := make([]byte, 16)
+	if _, err = rand.Read(token); err != nil {
+		return
+	}
+	l = &Lease{
+		s:     s,
+		path:  path,
+		rp:    rp,
+		token: hex.EncodeToString(token),
+	}
+
+	err = l.put(ctx, ttl, map[string]string{"If-None-Match": "*"})
+	if err == nil {
+		return l, nil
+	}
+	if !isConditionFailed(err) {
+		return nil, err
+	}
+
+	output, err := s.service.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(rp),
+	})
+	if err != nil {
+		return nil, err
+	}
+	expire, err := time.Parse(time.RFC3339Nano, metadataValue(output.Metadata, metadataLeaseExpire))
+	if err != nil {
+		return nil, fmt.Errorf("parse lease expire: %w", err)
+	}
+	if time.Now().Before(expire) {
+		return nil, fmt.Errorf("lease held until %s: %w", expire.Format(time.RFC3339), ErrLeaseHeld)
+	}
+
+	// Take over the expired lease, only one of the contenders will succeed.
+	err = l.put(ctx, ttl, map[string]string{"If-Match": aws.StringValue(output.ETag)})
+	if isConditionFailed(err) {
+		return nil, ErrLeaseHeld
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Path returns the path of the lease.
+func (l *Lease) Path() string {
+	return l.path
+}
+
+// Expire returns the time the lease expires at.
+func (l *Lease) Expire() time.Time {
+	return l.expire
+}
+
+// Renew will extend the lease for ttl from now.
+//
+// ErrLeaseLost will be returned if the lease has been taken over by others.
+func (l *Lease) Renew(ctx context.Context, ttl time.Duration) (err error) {
+	err = l.put(ctx, ttl, map[string]string{"If-Match": l.etag})
+	if isConditionFailed(err) {
+		err = ErrLeaseLost
+	}
+	return l.s.formatError("renew_lease", err, l.path)
+}
+
+// Release will release the lease so that others could acquire it.
+//
+// ErrLeaseLost will be returned if the lease has been taken over by others.
+func (l *Lease) Release(ctx context.Context) (err error) {
+	_, err = l.s.service.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(l.s.name),
+		Key:    aws.String(l.rp),
+	}, request.WithSetRequestHeaders(map[string]string{"If-Match": l.etag}))
+	if isConditionFailed(err) {
+		err = ErrLeaseLost
+	}
+	return l.s.formatError("release_lease", err, l.path)
+}
+
+func (l *Lease) put(ctx context.Context, ttl time.Duration, headers map[string]string) error {
+	expire := time.Now().Add(ttl)
+	ts := expire.UTC().Format(time.RFC3339Nano)
+
+	// The token and expire time are written as content, so that every write of the lease
+	// has a distinct etag which conditional writes could rely on.
+	output, err := l.s.service.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(l.s.name),
+		Key:    aws.String(l.rp),
+		Body:   aws.ReadSeekCloser(strings.NewReader(l.token + " " + ts)),
+		Metadata: map[string]*string{
+			metadataLeaseExpire: aws.String(ts),
+		},
+	}, request.WithSetRequestHeaders(headers))
+	if err != nil {
+		return err
+	}
+
+	l.etag = aws.StringValue(output.ETag)
+	l.expire = expire
+	return nil
+}
+
+// isConditionFailed checks whether err is caused by a conditional write failure, S3 returns
+// 409 instead of 412 while a concurrent conditional write is in progress.
+func isConditionFailed(err error) bool {
+	e, ok := err.(awserr.RequestFailure)
+	if !ok {
+		return false
+	}
+	return e.StatusCode() == http.StatusPreconditionFailed || e.StatusCode() == http.StatusConflict
+}
+
+// metadataValue will look up user-defined metadata case-insensitively, as the SDK canonicalizes
+// the keys returned in headers.
+func metadataValue(m map[string]*string, key string) string {
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return aws.StringValue(v)
+		}
+	}
+	return ""
+}
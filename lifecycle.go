@@ -0,0 +1,281 @@
+package s3
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+const (
+	// defaultLifecycleInfrequentAccessDays is the minimum days before objects could be transitioned
+	// to STANDARD_IA.
+	defaultLifecycleInfrequentAccessDays = 30
+	// defaultLifecycleArchiveDays leaves objects in STANDARD_IA for the minimum storage duration of
+	// 30 days before they're archived.
+	defaultLifecycleArchiveDays = 90
+	// defaultLifecycleColdRatio is the default minimum ratio of cold bytes to recommend a transition.
+	defaultLifecycleColdRatio = 0.8
+	// lifecycleAbortMultipartDays is the days after which incomplete multipart uploads are aborted
+	// by the recommended rule, as their parts are charged but never visible.
+	lifecycleAbortMultipartDays = 7
+	// lifecycleSmallObjectSize is the minimum billable size of STANDARD_IA, smaller objects are
+	// charged as this size so they're not counted as cold.
+	lifecycleSmallObjectSize = 128 * 1024
+)
+
+// lifecycleAgeDays is the lower bounds of the age buckets in LifecycleAnalysis.
+var lifecycleAgeDays = []int64{0, 30, 90, 180, 365}
+
+// GetBucketLifecycle returns the lifecycle rules of the bucket, which will be empty if the bucket
+// has no lifecycle configuration.
+func (s *Storage) GetBucketLifecycle(ctx context.Context) (rules []*s3.LifecycleRule, err error) {
+	defer func() {
+		err = s.formatError("get_bucket_lifecycle", err)
+	}()
+
+	output, err := s.service.GetBucketLifecycleConfigurationWithContext(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.name),
+	})
+	if err != nil {
+		if e, ok := err.(awserr.Error); ok && e.Code() == "NoSuchLifecycleConfiguration" {
+			return nil, nil
+		}
+		return
+	}
+	return output.Rules, nil
+}
+
+// PutBucketLifecycle will replace the lifecycle configuration of the bucket with rules, the
+// configuration will be deleted if rules is empty.
+func (s *Storage) PutBucketLifecycle(ctx context.Context, rules []*s3.LifecycleRule) (err error) {
+	defer func() {
+		err = s.formatError("put_bucket_lifecycle", err)
+	}()
+
+	// S3 rejects configurations without rules.
+	if len(rules) == 0 {
+		_, err = s.service.DeleteBucketLifecycleWithContext(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(s.name),
+		})
+		return
+	}
+	_, err = s.service.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.name),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: rules,
+		},
+	})
+	return
+}
+
+// ApplyLifecycleRule will add rule into the lifecycle configuration of the bucket, the existing
+// rule with the same ID will be replaced.
+func (s *Storage) ApplyLifecycleRule(ctx context.Context, rule *s3.LifecycleRule) error {
+	rules, err := s.GetBucketLifecycle(ctx)
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, v := range rules {
+		if aws.StringValue(v.ID) == aws.StringValue(rule.ID) {
+			rules[i] = rule
+			replaced = true
+		}
+	}
+	if !replaced {
+		rules = append(rules, rule)
+	}
+	return s.PutBucketLifecycle(ctx, rules)
+}
+
+// LifecycleAnalysisOptions controls the behavior of AnalyzeLifecycle.
+type LifecycleAnalysisOptions struct {
+	// Inventory is the path of the inventory manifest (see LatestInventoryManifest) of the bucket,
+	// objects will be read from the inventory report instead of listing the prefix if it's set.
+	Inventory string
+	// LastAccess returns the last access time of the object at path, for example, collected from
+	// server access logs. Objects are considered never accessed if it's nil or returns false.
+	LastAccess func(path string) (t time.Time, ok bool)
+	// InfrequentAccessDays is the age in days after which objects are transitioned to STANDARD_IA
+	// by the recommended rule, 30 by default.
+	InfrequentAccessDays int64
+	// ArchiveDays is the age in days after which objects are transitioned to ArchiveStorageClass
+	// by the recommended rule, 90 by default.
+	ArchiveDays int64
+	// ArchiveStorageClass is the storage class of archived objects, GLACIER by default.
+	ArchiveStorageClass string
+	// ExpirationDays is the age in days after which objects are expired by the recommended rule,
+	// objects never expire if it's zero.
+	ExpirationDays int64
+	// ColdRatio is the minimum ratio of cold bytes among objects old enough to recommend a
+	// transition, 0.8 by default.
+	ColdRatio float64
+	// Now is the time ages are calculated against, the current time by default.
+	Now time.Time
+}
+
+// LifecycleAgeBucket is the objects whose ages are between MinDays and the MinDays of the next
+// bucket.
+type LifecycleAgeBucket struct {
+	MinDays int64
+	Objects int64
+	Bytes   int64
+}
+
+// LifecycleAnalysis is the result of AnalyzeLifecycle.
+type LifecycleAnalysis struct {
+	Objects int64
+	Bytes   int64
+	// SmallObjects is the number of objects smaller than 128KiB, which are not worth transitioning
+	// as they're charged as 128KiB in STANDARD_IA.
+	SmallObjects int64
+	// Ages is the distribution of objects by the days since last modified.
+	Ages []LifecycleAgeBucket
+	// InfrequentAccessBytes and ArchiveBytes are the bytes of objects old enough to be transitioned,
+	// while InfrequentAccessColdBytes and ArchiveColdBytes are the ones not accessed since then.
+	InfrequentAccessBytes     int64
+	InfrequentAccessColdBytes int64
+	ArchiveBytes              int64
+	ArchiveColdBytes          int64
+	// Rule is the recommended lifecycle rule of the prefix, which could be applied via
+	// ApplyLifecycleRule. Incomplete multipart uploads are always aborted after 7 days.
+	Rule *s3.LifecycleRule
+}
+
+// AnalyzeLifecycle will walk objects under prefix to collect the distribution of ages and access
+// patterns, and recommend a lifecycle rule of the prefix.
+//
+// A transition is recommended only if most bytes of the objects old enough (see ColdRatio) have
+// not been accessed since they reached the age, objects smaller than 128KiB are not counted.
+func (s *Storage) AnalyzeLifecycle(ctx context.Context, prefix string, opt LifecycleAnalysisOptions) (a *LifecycleAnalysis, err error) {
+	iaDays := opt.InfrequentAccessDays
+	if iaDays <= 0 {
+		iaDays = defaultLifecycleInfrequentAccessDays
+	}
+	archiveDays := opt.ArchiveDays
+	if archiveDays <= 0 {
+		archiveDays = defaultLifecycleArchiveDays
+	}
+	archiveClass := opt.ArchiveStorageClass
+	if archiveClass == "" {
+		archiveClass = s3.TransitionStorageClassGlacier
+	}
+	coldRatio := opt.ColdRatio
+	if coldRatio <= 0 {
+		coldRatio = defaultLifecycleColdRatio
+	}
+	now := opt.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	rp, err := s.getAbsPath(prefix)
+	if err != nil {
+		return nil, s.formatError("analyze_lifecycle", err, prefix)
+	}
+
+	var it *typ.ObjectIterator
+	if opt.Inventory != "" {
+		it, err = s.ListInventory(ctx, opt.Inventory)
+	} else {
+		it, err = s.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix))
+	}
+	if err != nil {
+		return
+	}
+
+	a = &LifecycleAnalysis{}
+	for _, days := range lifecycleAgeDays {
+		a.Ages = append(a.Ages, LifecycleAgeBucket{MinDays: days})
+	}
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if o.Mode.IsDir() {
+			continue
+		}
+		path := o.Path
+		if opt.Inventory != "" {
+			// Entries of inventory reports carry keys of the whole bucket.
+			if !strings.HasPrefix(path, rp) {
+				continue
+			}
+			path = s.getRelPath(path)
+		}
+
+		size, _ := o.GetContentLength()
+		modified, _ := o.GetLastModified()
+		age := int64(now.Sub(modified) / (24 * time.Hour))
+		a.Objects++
+		a.Bytes += size
+		for i := len(a.Ages) - 1; i >= 0; i-- {
+			if age >= a.Ages[i].MinDays {
+				a.Ages[i].Objects++
+				a.Ages[i].Bytes += size
+				break
+			}
+		}
+		if size < lifecycleSmallObjectSize {
+			a.SmallObjects++
+			continue
+		}
+
+		// Days since the object is accessed, which is its age if it's never accessed.
+		idle := age
+		if opt.LastAccess != nil {
+			if t, ok := opt.LastAccess(path); ok && t.After(modified) {
+				idle = int64(now.Sub(t) / (24 * time.Hour))
+			}
+		}
+		if age >= iaDays {
+			a.InfrequentAccessBytes += size
+			if idle >= iaDays {
+				a.InfrequentAccessColdBytes += size
+			}
+		}
+		if age >= archiveDays {
+			a.ArchiveBytes += size
+			if idle >= archiveDays {
+				a.ArchiveColdBytes += size
+			}
+		}
+	}
+
+	rule := &s3.LifecycleRule{
+		ID:     aws.String("recommended:" + rp),
+		Filter: &s3.LifecycleRuleFilter{Prefix: aws.String(rp)},
+		Status: aws.String(s3.ExpirationStatusEnabled),
+		AbortIncompleteMultipartUpload: &s3.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int64(lifecycleAbortMultipartDays),
+		},
+	}
+	if a.InfrequentAccessBytes > 0 && float64(a.InfrequentAccessColdBytes) >= coldRatio*float64(a.InfrequentAccessBytes) {
+		rule.Transitions = append(rule.Transitions, &s3.Transition{
+			Days:         aws.Int64(iaDays),
+			StorageClass: aws.String(s3.TransitionStorageClassStandardIa),
+		})
+	}
+	if a.ArchiveBytes > 0 && float64(a.ArchiveColdBytes) >= coldRatio*float64(a.ArchiveBytes) {
+		rule.Transitions = append(rule.Transitions, &s3.Transition{
+			Days:         aws.Int64(archiveDays),
+			StorageClass: aws.String(archiveClass),
+		})
+	}
+	if opt.ExpirationDays > 0 {
+		rule.Expiration = &s3.LifecycleExpiration{Days: aws.Int64(opt.ExpirationDays)}
+	}
+	a.Rule = rule
+	return a, nil
+}
@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// ReadLink will return the target of the virtual link at path, in the same form as
+// the LinkTarget returned by stat.
+//
+// ErrNotLink will be returned if the object at path is not a link.
+func (s *Storage) ReadLink(ctx context.Context, path string) (target string, err error) {
+	defer func() {
+		err = s.formatError("read_link", err, path)
+	}()
+
+	if !s.features.VirtualLink {
+		return "", fmt.Errorf("virtual link not enabled: %w", services.ErrCapabilityInsufficient)
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	output, err := s.service.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(rp),
+	})
+	if err != nil {
+		return
+	}
+
+	t, ok := output.Metadata[metadataLinkTargetHeader]
+	if !ok {
+		return "", ErrNotLink
+	}
+	// Keep the same as stat, see the comments there.
+	return "/" + *t, nil
+}
+
+// detectLinkConcurrency is the max number of HeadObject requests sent concurrently while
+// detecting links in a page.
+const detectLinkConcurrency = 8
+
+// detectLinks will mark the links in objects returned by listing via HeadObject, candidates are
+// the indexes of zero-byte objects as links are always empty.
+//
+// The candidates are replaced by the objects built from HeadObject, so that the listed ones which
+// are not done won't be stat again while reading the link target.
+func (s *Storage) detectLinks(ctx context.Context, objects []*typ.Object, candidates []int, expectedBucketOwner string) error {
+	ch := make(chan int)
+	errs := make(chan error, 1)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < detectLinkConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for idx := range ch {
+				if err := s.detectLink(ctx, objects, idx, expectedBucketOwner); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	for _, idx := range candidates {
+		if !objects[idx].Mode.IsRead() {
+			continue
+		}
+		ch <- idx
+	}
+	close(ch)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (s *Storage) detectLink(ctx context.Context, objects []*typ.Object, idx int, expectedBucketOwner string) error {
+	o := objects[idx]
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(o.ID),
+	}
+	if expectedBucketOwner != "" {
+		input.ExpectedBucketOwner = &expectedBucketOwner
+	}
+
+	output, err := s.service.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		// The object could have been deleted since listed.
+		if e, ok := err.(awserr.Error); ok && e.Code() == "NotFound" {
+			return nil
+		}
+		return err
+	}
+
+	objects[idx] = s.formatHeadObject(o.ID, o.Path, output, false)
+	return nil
+}
+
+// RewriteLinks will point all links under prefix which target oldTarget to newTarget, which
+// should be called after the target has been moved. It returns the number of links rewritten.
+//
+// If the etag of the target has been recorded in a link (see link_reference), the link will only
+// be rewritten while newTarget has the same etag, otherwise ErrPreconditionFailed will be returned.
+func (s *Storage) RewriteLinks(ctx context.Context, prefix, oldTarget, newTarget string) (n int64, err error) {
+	defer func() {
+		err = s.formatError("rewrite_links", err, prefix, oldTarget, newTarget)
+	}()
+
+	if !s.features.VirtualLink {
+		return 0, fmt.Errorf("virtual link not enabled: %w", services.ErrCapabilityInsufficient)
+	}
+
+	ro, err := s.getAbsPath(oldTarget)
+	if err != nil {
+		return
+	}
+	rn, err := s.getAbsPath(newTarget)
+	if err != nil {
+		return
+	}
+	output, err := s.service.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(rn),
+	})
+	if err != nil {
+		return
+	}
+	etag := aws.StringValue(output.ETag)
+
+	it, err := s.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix), WithDetectLink())
+	if err != nil {
+		return
+	}
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if !o.Mode.IsLink() || o.MustGetLinkTarget() != "/"+ro {
+			continue
+		}
+
+		if v := GetObjectSystemMetadata(o).LinkTargetEtag; v != "" && v != etag {
+			return n, fmt.Errorf("link %s refers to etag %s: %w", o.Path, v, ErrPreconditionFailed)
+		}
+		if _, err = s.createLink(ctx, o.Path, newTarget, pairStorageCreateLink{}); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,169 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// objectFilter filters objects by the last modified time during pagination.
+type objectFilter struct {
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+
+	// stopKey is the key prefix formatted from modifiedBefore by key_time_layout, objects after
+	// all keys with the prefix are modified after modifiedBefore, so the listing could stop.
+	stopKey string
+}
+
+// newObjectFilter returns nil if no filter is specified. startAfter is the key which the listing
+// in prefix mode could start after.
+func newObjectFilter(prefix string, opt pairStorageList) (f *objectFilter, startAfter string) {
+	if !opt.HasModifiedAfter && !opt.HasModifiedBefore {
+		return nil, ""
+	}
+
+	f = &objectFilter{}
+	if opt.HasModifiedAfter {
+		f.modifiedAfter = opt.ModifiedAfter
+	}
+	if opt.HasModifiedBefore {
+		f.modifiedBefore = opt.ModifiedBefore
+	}
+	if opt.HasKeyTimeLayout && opt.KeyTimeLayout != "" {
+		// Keys are expected to be formatted in UTC, as the layout must be sorted as strings.
+		if opt.HasModifiedAfter {
+			startAfter = prefix + opt.ModifiedAfter.UTC().Format(opt.KeyTimeLayout)
+		}
+		if opt.HasModifiedBefore {
+			f.stopKey = prefix + opt.ModifiedBefore.UTC().Format(opt.KeyTimeLayout)
+		}
+	}
+	return f, startAfter
+}
+
+func (f *objectFilter) match(o *typ.Object) bool {
+	// Dirs have no last modified time.
+	if o.Mode.IsDir() {
+		return true
+	}
+	t, ok := o.GetLastModified()
+	if !ok {
+		return true
+	}
+	if !f.modifiedAfter.IsZero() && !t.After(f.modifiedAfter) {
+		return false
+	}
+	if !f.modifiedBefore.IsZero() && !t.Before(f.modifiedBefore) {
+		return false
+	}
+	return true
+}
+
+// passed checks whether the key is after all keys prefixed by stopKey.
+func (f *objectFilter) passed(key string) bool {
+	return f.stopKey != "" && key > f.stopKey && !strings.HasPrefix(key, f.stopKey)
+}
+
+// filterObjectPages wraps next so that pages are filtered, pages are fetched until there are
+// objects left or the listing is done, as an empty page will stop the iterator.
+//
+// The listing will stop at the first object passed stopKey, so sorted must be true only if
+// objects of pages are sorted by key.
+func (f *objectFilter) filterObjectPages(next typ.NextObjectFunc, sorted bool) typ.NextObjectFunc {
+	return func(ctx context.Context, page *typ.ObjectPage) error {
+		for {
+			err := next(ctx, page)
+			if err != nil && err != typ.IterateDone {
+				return err
+			}
+
+			data := page.Data[:0]
+			for _, o := range page.Data {
+				if sorted && f.passed(o.ID) {
+					page.Data = data
+					return typ.IterateDone
+				}
+				if f.match(o) {
+					data = append(data, o)
+				}
+			}
+			page.Data = data
+
+			if err != nil || len(page.Data) > 0 {
+				return err
+			}
+		}
+	}
+}
+
+// limitObjectPages wraps next so that the listing stops once limit objects have been returned or
+// ctx is done, instead of fetching the following pages.
+func (i *objectPageStatus) limitObjectPages(next typ.NextObjectFunc) typ.NextObjectFunc {
+	return func(ctx context.Context, page *typ.ObjectPage) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := next(ctx, page)
+		if err != nil && err != typ.IterateDone {
+			return err
+		}
+		if remaining := i.limit - i.returned; int64(len(page.Data)) >= remaining {
+			page.Data = page.Data[:remaining]
+			err = typ.IterateDone
+		}
+		i.returned += int64(len(page.Data))
+		return err
+	}
+}
+
+// ListPageLimitError will be returned by the iterator while the listing is going to request more
+// pages than list_max_pages, so that tools will not walk a huge bucket by accident.
+//
+// ListPageLimitError wraps ErrListPageLimitExceeded, so both `errors.Is(err, ErrListPageLimitExceeded)`
+// and `errors.As(err, &ListPageLimitError{})` could be used.
+type ListPageLimitError struct {
+	// MaxPages is the list_max_pages of the listing.
+	MaxPages int64
+}
+
+func (e ListPageLimitError) Error() string {
+	return fmt.Sprintf("%d pages listed: %v", e.MaxPages, ErrListPageLimitExceeded)
+}
+
+func (e ListPageLimitError) Unwrap() error {
+	return ErrListPageLimitExceeded
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e ListPageLimitError) IsInternalError() {}
+
+// guardObjectPages wraps next so that ListPageLimitError is returned instead of requesting the
+// page after maxPages.
+func (i *objectPageStatus) guardObjectPages(next typ.NextObjectFunc) typ.NextObjectFunc {
+	return func(ctx context.Context, page *typ.ObjectPage) error {
+		if i.pages >= i.maxPages {
+			return ListPageLimitError{MaxPages: i.maxPages}
+		}
+		i.pages++
+		return next(ctx, page)
+	}
+}
+
+// skipEmptyObjectPages wraps next so that empty pages which are not the last one are skipped, as
+// an empty page ends the iterator. Compatible services like Cloudflare R2 may return truncated
+// pages without any object, and pages of dir markers or files skipped are empty as well.
+func skipEmptyObjectPages(next typ.NextObjectFunc) typ.NextObjectFunc {
+	return func(ctx context.Context, page *typ.ObjectPage) error {
+		for {
+			err := next(ctx, page)
+			if err != nil || len(page.Data) > 0 {
+				return err
+			}
+		}
+	}
+}
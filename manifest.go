@@ -0,0 +1,235 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// ManifestFormat is the format of the manifest read by ReadManifest.
+type ManifestFormat string
+
+// All available manifest formats are listed here.
+const (
+	// ManifestFormatCSV is the CSV manifest of S3 Batch Operations, every line is
+	// `bucket,key[,version-id]` with the key URL encoded.
+	//
+	// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/batch-ops-create-job.html#specify-batchjob-manifest
+	ManifestFormatCSV ManifestFormat = "csv"
+	// ManifestFormatJSON is JSON lines, every line is an object like `{"key": "a/b", "bucket": "name"}`,
+	// bucket is optional and the key is not encoded.
+	ManifestFormatJSON ManifestFormat = "json"
+)
+
+// ManifestReadOptions controls the behavior of ReadManifest.
+type ManifestReadOptions struct {
+	Format ManifestFormat
+	// Content will read the content of objects into memory, so that it's fetched concurrently,
+	// which should only be used for objects small enough. Only objects are stated otherwise.
+	Content bool
+	// Pairs are passed to every stat or read, for example, WithExceptedBucketOwner.
+	Pairs []typ.Pair
+	// Concurrency is the number of objects fetched concurrently, 8 by default.
+	Concurrency int
+}
+
+// ManifestEntry is an object listed in the manifest.
+type ManifestEntry struct {
+	// Line is the line number of the entry in the manifest, starting from 1.
+	Line int
+	Path string
+	// Object is the object stated or read, which is nil if Err is set.
+	Object *typ.Object
+	// Content is the content of the object if ManifestReadOptions.Content is set.
+	Content io.Reader
+	// Err is the error returned while fetching the object, or the entry is not in the bucket.
+	Err error
+}
+
+// ManifestIterator iterates the objects listed in the manifest.
+type ManifestIterator struct {
+	cancel  context.CancelFunc
+	entries chan *ManifestEntry
+	// err is the error of parsing the manifest, which is set before entries is closed.
+	err error
+}
+
+// Next returns the next object fetched, typ.IterateDone will be returned after all objects have
+// been fetched.
+func (it *ManifestIterator) Next() (*ManifestEntry, error) {
+	e, ok := <-it.entries
+	if ok {
+		return e, nil
+	}
+	if it.err != nil {
+		return nil, it.err
+	}
+	return nil, typ.IterateDone
+}
+
+// Close will stop fetching, it must be called if the iterator is not drained.
+func (it *ManifestIterator) Close() {
+	it.cancel()
+	for range it.entries {
+	}
+}
+
+// manifestKey is a key parsed from the manifest.
+type manifestKey struct {
+	line   int
+	bucket string
+	key    string
+}
+
+// ReadManifest will parse the manifest of keys, like the ones of S3 Batch Operations, and stat or
+// read the objects concurrently, so that curated datasets could be consumed via the returned
+// iterator, in no particular order.
+//
+// Keys are absolute in the bucket, keys of other buckets or outside the work dir are returned
+// with Err set. Version ids in manifests are ignored, current versions are fetched. Failing to
+// fetch an object will not stop the iteration, and parsing errors will be returned by the iterator.
+func (s *Storage) ReadManifest(ctx context.Context, manifest io.Reader, opt ManifestReadOptions) (*ManifestIterator, error) {
+	var next func() (manifestKey, error)
+	switch opt.Format {
+	case ManifestFormatCSV:
+		next = newCSVManifestParser(manifest)
+	case ManifestFormatJSON:
+		next = newJSONManifestParser(manifest)
+	default:
+		return nil, fmt.Errorf("manifest format %q: %w", opt.Format, services.ErrCapabilityInsufficient)
+	}
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	it := &ManifestIterator{
+		cancel:  cancel,
+		entries: make(chan *ManifestEntry, concurrency),
+	}
+
+	ch := make(chan manifestKey)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for k := range ch {
+				e := s.fetchManifestEntry(ctx, k, opt)
+				select {
+				case it.entries <- e:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	go func() {
+		var err error
+		for {
+			var k manifestKey
+			k, err = next()
+			if err != nil {
+				break
+			}
+
+			select {
+			case ch <- k:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if err != nil {
+				break
+			}
+		}
+		close(ch)
+		wg.Wait()
+
+		if err != io.EOF {
+			it.err = err
+		}
+		close(it.entries)
+	}()
+	return it, nil
+}
+
+func (s *Storage) fetchManifestEntry(ctx context.Context, k manifestKey, opt ManifestReadOptions) *ManifestEntry {
+	e := &ManifestEntry{Line: k.line, Path: s.getRelPath(k.key)}
+	switch {
+	case k.bucket != "" && k.bucket != s.name:
+		e.Err = fmt.Errorf("bucket %s of key %s: %w", k.bucket, k.key, services.ErrRestrictionDissatisfied)
+		return e
+	case !strings.HasPrefix(k.key, strings.TrimPrefix(s.workDir, "/")):
+		e.Err = fmt.Errorf("key %s outside work dir %s: %w", k.key, s.workDir, services.ErrRestrictionDissatisfied)
+		return e
+	}
+
+	if !opt.Content {
+		e.Object, e.Err = s.StatWithContext(ctx, e.Path, opt.Pairs...)
+		return e
+	}
+
+	var o *typ.Object
+	buf := &bytes.Buffer{}
+	pairs := append([]typ.Pair{WithObjectCallback(func(v *typ.Object) { o = v })}, opt.Pairs...)
+	if _, e.Err = s.ReadWithContext(ctx, e.Path, buf, pairs...); e.Err != nil {
+		return e
+	}
+	e.Object, e.Content = o, buf
+	return e
+}
+
+func newCSVManifestParser(r io.Reader) func() (manifestKey, error) {
+	cr := csv.NewReader(r)
+	// Version ids are optional.
+	cr.FieldsPerRecord = -1
+	line := 0
+	return func() (k manifestKey, err error) {
+		record, err := cr.Read()
+		if err != nil {
+			return
+		}
+		line++
+		if len(record) < 2 {
+			return k, fmt.Errorf("manifest line %d: %d fields: %w", line, len(record), services.ErrRestrictionDissatisfied)
+		}
+		key, err := url.QueryUnescape(record[1])
+		if err != nil {
+			return k, fmt.Errorf("manifest line %d: key %q: %w", line, record[1], services.ErrRestrictionDissatisfied)
+		}
+		return manifestKey{line: line, bucket: record[0], key: key}, nil
+	}
+}
+
+func newJSONManifestParser(r io.Reader) func() (manifestKey, error) {
+	d := json.NewDecoder(r)
+	line := 0
+	return func() (k manifestKey, err error) {
+		var v struct {
+			Bucket string `json:"bucket"`
+			Key    string `json:"key"`
+		}
+		if err = d.Decode(&v); err != nil {
+			if err != io.EOF {
+				err = fmt.Errorf("manifest line %d: %w", line+1, err)
+			}
+			return
+		}
+		line++
+		if v.Key == "" {
+			return k, fmt.Errorf("manifest line %d: empty key: %w", line, services.ErrRestrictionDissatisfied)
+		}
+		return manifestKey{line: line, bucket: v.Bucket, key: v.Key}, nil
+	}
+}
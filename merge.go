@@ -0,0 +1,137 @@
+package s3
+
+import (
+	"container/heap"
+	"context"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// mergePageSize is the number of objects merged into a page.
+const mergePageSize = 200
+
+// MergeSource is a listing to be merged by MergeList.
+type MergeSource struct {
+	Storager typ.Storager
+	// Path is the path to list, objects are listed in prefix mode.
+	Path string
+}
+
+// MergeList will list all sources and merge objects into a single stream sorted by path, which
+// is the key relative to the work dir of the storager. If objects with the same path are listed
+// by multiple sources, only the one from the first source will be returned.
+//
+// Sources are listed lazily, a page of each source is held in memory at most. Listing errors of
+// any source will be returned by the iterator.
+func MergeList(ctx context.Context, sources []MergeSource) (*typ.ObjectIterator, error) {
+	status := &mergePageStatus{}
+	for i, src := range sources {
+		it, err := src.Storager.ListWithContext(ctx, src.Path, ps.WithListMode(typ.ListModePrefix))
+		if err != nil {
+			return nil, err
+		}
+		status.sources = append(status.sources, &mergeCursor{index: i, it: it})
+	}
+	return typ.NewObjectIterator(ctx, nextMergedObjectPage, status), nil
+}
+
+type mergePageStatus struct {
+	sources []*mergeCursor
+	// heads is a min-heap of sources by the path of their next object.
+	heads   mergeHeap
+	started bool
+	// lastPath is the path of the last returned object, which is used to drop duplicates.
+	lastPath string
+	returned bool
+}
+
+// ContinuationToken returns the path of the last merged object, merged listings could not be
+// resumed.
+func (i *mergePageStatus) ContinuationToken() string {
+	return i.lastPath
+}
+
+// mergeCursor is the next object of a source.
+type mergeCursor struct {
+	index int
+	it    *typ.ObjectIterator
+	o     *typ.Object
+}
+
+// advance moves the cursor to the next object, ok will be false if the source is drained.
+func (c *mergeCursor) advance() (ok bool, err error) {
+	c.o, err = c.it.Next()
+	if err == typ.IterateDone {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type mergeHeap []*mergeCursor
+
+func (h mergeHeap) Len() int { return len(h) }
+
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].o.Path != h[j].o.Path {
+		return h[i].o.Path < h[j].o.Path
+	}
+	// The first source wins for duplicated paths.
+	return h[i].index < h[j].index
+}
+
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(*mergeCursor)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func nextMergedObjectPage(ctx context.Context, page *typ.ObjectPage) error {
+	input := page.Status.(*mergePageStatus)
+
+	if !input.started {
+		for _, c := range input.sources {
+			ok, err := c.advance()
+			if err != nil {
+				return err
+			}
+			if ok {
+				input.heads = append(input.heads, c)
+			}
+		}
+		heap.Init(&input.heads)
+		input.started = true
+	}
+
+	for len(page.Data) < mergePageSize && input.heads.Len() > 0 {
+		c := input.heads[0]
+		if !input.returned || c.o.Path != input.lastPath {
+			page.Data = append(page.Data, c.o)
+			input.lastPath = c.o.Path
+			input.returned = true
+		}
+
+		ok, err := c.advance()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&input.heads, 0)
+		} else {
+			heap.Pop(&input.heads)
+		}
+	}
+
+	if input.heads.Len() == 0 {
+		return typ.IterateDone
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+const (
+	// defaultMigrateMaxAttempts is the default number of attempts to migrate an object.
+	defaultMigrateMaxAttempts = 3
+	// defaultMigrateRetryBackoff is the default backoff before the second attempt, which is
+	// doubled for every following attempt.
+	defaultMigrateRetryBackoff = time.Second
+	// defaultMigrateCheckpointInterval is the default interval between two writes of the state file.
+	defaultMigrateCheckpointInterval = 10 * time.Second
+)
+
+// MigrateOptions controls the behavior of Migrate.
+type MigrateOptions struct {
+	// Prefix is the path under which objects will be migrated, in both src and the storage.
+	Prefix string
+	// KeyMapper maps paths of src to the storage, paths are kept as is if it's nil.
+	KeyMapper KeyMapper
+	// Concurrency is the number of objects migrated concurrently, 8 by default.
+	Concurrency int
+	// BytesPerSecond caps the total bandwidth of content streamed through, shared by all workers.
+	// The bandwidth is unlimited if it's zero. Objects copied on server side are not counted.
+	BytesPerSecond int64
+	// MaxAttempts is the number of attempts to migrate an object before it's reported as failed,
+	// 3 by default.
+	MaxAttempts int
+	// RetryBackoff is the backoff before the second attempt, which is doubled for every following
+	// attempt, 1s by default. The backoff suggested by S3 will be used if it's longer.
+	RetryBackoff time.Duration
+	// StateFile is the local file where the progress is checkpointed, a migration started with an
+	// existing state file will resume from it. Progress is not persisted if it's empty.
+	StateFile string
+	// CheckpointInterval is the interval between two writes of StateFile, 10s by default. The state
+	// file is always written before Migrate returns.
+	CheckpointInterval time.Duration
+	// Progress will be called after each object has been migrated or failed, it could be called
+	// concurrently.
+	Progress func(MigrateEvent)
+}
+
+// MigrateEvent carries the result of an object during Migrate.
+type MigrateEvent struct {
+	Path string
+	// DstPath is the path in the storage, which differs from Path only if KeyMapper is set.
+	DstPath string
+	Bytes   int64
+	// Attempts is the number of attempts made on the object.
+	Attempts int
+	Err      error
+}
+
+// MigrateSummary is the result of Migrate.
+type MigrateSummary struct {
+	Migrated int64
+	// Skipped is the number of objects migrated by a previous run recorded in the state file.
+	Skipped int64
+	Failed  int64
+	// Bytes is the total size of migrated objects.
+	Bytes int64
+}
+
+// Migrate will copy all objects under opt.Prefix in src into the storage, it's designed for
+// long-running migrations between clouds which could be interrupted and resumed.
+//
+// Objects will be copied on server side if src is an s3 storager sharing the same service,
+// otherwise content will be streamed through with opt.BytesPerSecond applied. Failing objects
+// are retried up to opt.MaxAttempts, then reported via Progress and counted in the summary,
+// which will not stop the migration. Only listing and state file errors will be returned.
+//
+// The state file records the last path before which all objects have been attempted and the
+// paths failed, so a resumed migration skips migrated objects and retries failed ones. src must
+// list objects in prefix mode sorted by path, as s3 and most object storages do.
+func (s *Storage) Migrate(ctx context.Context, src typ.Storager, opt MigrateOptions) (sum MigrateSummary, err error) {
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+	maxAttempts := opt.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMigrateMaxAttempts
+	}
+	backoff := opt.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultMigrateRetryBackoff
+	}
+	interval := opt.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultMigrateCheckpointInterval
+	}
+	mapKey := opt.KeyMapper
+	if mapKey == nil {
+		mapKey = func(path string) string { return path }
+	}
+	var limiter *bandwidthLimiter
+	if opt.BytesPerSecond > 0 {
+		limiter = &bandwidthLimiter{rate: float64(opt.BytesPerSecond)}
+	}
+
+	cp, err := loadMigrateCheckpoint(opt.StateFile, opt.Prefix)
+	if err != nil {
+		return
+	}
+	// The state file is written even if the migration is interrupted, so that it could be resumed.
+	defer func() {
+		if serr := cp.save(opt.StateFile); err == nil {
+			err = serr
+		}
+	}()
+
+	// Only the size of listed objects is read, which is returned in the listing of s3 storagers.
+	var it *typ.ObjectIterator
+	if ss, ok := src.(*Storage); ok {
+		it, err = ss.listFiles(ctx, opt.Prefix)
+	} else {
+		it, err = src.ListWithContext(ctx, opt.Prefix, ps.WithListMode(typ.ListModePrefix))
+	}
+	if err != nil {
+		return
+	}
+
+	type task struct {
+		o    *typ.Object
+		item *migrateItem
+	}
+	ch := make(chan task)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for t := range ch {
+				e := MigrateEvent{Path: t.o.Path, DstPath: mapKey(t.o.Path), Bytes: t.o.MustGetContentLength()}
+				e.Attempts, e.Err = s.migrateWithRetry(ctx, src, t.o, e.DstPath, limiter, maxAttempts, backoff)
+				cp.finish(t.item, e.Err)
+
+				if e.Err != nil {
+					atomic.AddInt64(&sum.Failed, 1)
+				} else {
+					atomic.AddInt64(&sum.Migrated, 1)
+					atomic.AddInt64(&sum.Bytes, e.Bytes)
+				}
+				if opt.Progress != nil {
+					opt.Progress(e)
+				}
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	checkpointDone := make(chan struct{})
+	go func() {
+		defer close(checkpointDone)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// Errors will be returned by the final save.
+				_ = cp.save(opt.StateFile)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		var o *typ.Object
+		o, err = it.Next()
+		if err == typ.IterateDone {
+			err = nil
+			break
+		}
+		if err != nil {
+			break
+		}
+		if o.Mode.IsDir() {
+			continue
+		}
+
+		item, ok := cp.add(o.Path)
+		if !ok {
+			sum.Skipped++
+			continue
+		}
+		select {
+		case ch <- task{o: o, item: item}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
+	close(stop)
+	<-checkpointDone
+	return
+}
+
+func (s *Storage) migrateWithRetry(ctx context.Context, src typ.Storager, o *typ.Object, dstPath string, limiter *bandwidthLimiter, maxAttempts int, backoff time.Duration) (attempt int, err error) {
+	for attempt = 1; ; attempt++ {
+		err = s.migrateObject(ctx, src, o, dstPath, limiter)
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil {
+			return
+		}
+
+		wait := backoff << (attempt - 1)
+		var re RateLimitedError
+		if errors.As(err, &re) && re.RetryAfter > wait {
+			wait = re.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (s *Storage) migrateObject(ctx context.Context, src typ.Storager, o *typ.Object, dstPath string, limiter *bandwidthLimiter) (err error) {
+	if ss, ok := src.(*Storage); ok && s.canCopyFrom(ss) {
+		return s.copyFrom(ctx, ss, o.Path, dstPath)
+	}
+
+	size := o.MustGetContentLength()
+	r, w := io.Pipe()
+	go func() {
+		_, err := src.ReadWithContext(ctx, o.Path, w)
+		w.CloseWithError(err)
+	}()
+	var body io.Reader = r
+	if limiter != nil {
+		body = &throttledReader{ctx: ctx, r: r, l: limiter}
+	}
+	_, err = s.WriteWithContext(ctx, dstPath, body, size)
+	r.CloseWithError(err)
+	return err
+}
+
+// migrateState is the content of the state file.
+type migrateState struct {
+	Prefix string `json:"prefix"`
+	// Watermark is the last path before which all objects have been attempted.
+	Watermark string `json:"watermark,omitempty"`
+	// Failed is the paths failed to migrate, which will be retried while resuming.
+	Failed []string `json:"failed,omitempty"`
+}
+
+// migrateItem is an object being migrated.
+type migrateItem struct {
+	path string
+	done bool
+}
+
+// migrateCheckpoint tracks the progress of Migrate.
+type migrateCheckpoint struct {
+	mu     sync.Mutex
+	prefix string
+	// watermark is the last path before which all objects have been attempted.
+	watermark string
+	// pending is the objects being migrated in the order of listing, the watermark advances once
+	// the head is done.
+	pending []*migrateItem
+	failed  map[string]struct{}
+	// retry is the paths failed in the previous run, listed is the last path listed in this run,
+	// paths of retry after it have not been attempted yet.
+	retry  map[string]struct{}
+	listed string
+	dirty  bool
+}
+
+func loadMigrateCheckpoint(path, prefix string) (*migrateCheckpoint, error) {
+	cp := &migrateCheckpoint{
+		prefix: prefix,
+		failed: make(map[string]struct{}),
+		retry:  make(map[string]struct{}),
+	}
+	if path == "" {
+		return cp, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	var st migrateState
+	if err = json.Unmarshal(content, &st); err != nil {
+		return nil, fmt.Errorf("parse state file: %w", err)
+	}
+	if st.Prefix != prefix {
+		return nil, fmt.Errorf("state file of prefix %q: %w", st.Prefix, services.ErrRestrictionDissatisfied)
+	}
+	cp.watermark = st.Watermark
+	for _, p := range st.Failed {
+		cp.retry[p] = struct{}{}
+	}
+	return cp, nil
+}
+
+// add returns the item to migrate the object at path, ok will be false if the object has been
+// migrated by the previous run.
+func (c *migrateCheckpoint) add(path string) (item *migrateItem, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.listed = path
+	if _, retry := c.retry[path]; !retry && path <= c.watermark {
+		return nil, false
+	}
+	item = &migrateItem{path: path}
+	c.pending = append(c.pending, item)
+	return item, true
+}
+
+func (c *migrateCheckpoint) finish(item *migrateItem, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item.done = true
+	if err != nil {
+		c.failed[item.path] = struct{}{}
+	}
+	for len(c.pending) > 0 && c.pending[0].done {
+		// Retried paths could be before the watermark of the previous run.
+		if p := c.pending[0].path; p > c.watermark {
+			c.watermark = p
+		}
+		c.pending = c.pending[1:]
+	}
+	c.dirty = true
+}
+
+// save writes the state into path atomically if it has changed.
+func (c *migrateCheckpoint) save(path string) (err error) {
+	if path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	st := migrateState{Prefix: c.prefix, Watermark: c.watermark}
+	for p := range c.failed {
+		st.Failed = append(st.Failed, p)
+	}
+	for p := range c.retry {
+		if p > c.listed {
+			st.Failed = append(st.Failed, p)
+		}
+	}
+	c.dirty = false
+	c.mu.Unlock()
+	sort.Strings(st.Failed)
+
+	content, err := json.Marshal(st)
+	if err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	_, err = f.Write(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return nil
+}
+
+// bandwidthLimiter caps the rate of bytes shared by multiple readers.
+type bandwidthLimiter struct {
+	// rate is the number of bytes per second.
+	rate float64
+
+	mu sync.Mutex
+	// next is the time when all bytes reserved so far are allowed.
+	next time.Time
+}
+
+// wait blocks until n more bytes are allowed.
+func (l *bandwidthLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	// Unused bandwidth is not accumulated, so there will be no bursts after idling.
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader reads from r at the rate allowed by l.
+type throttledReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *bandwidthLimiter
+}
+
+func (r *throttledReader) Read(p []byte) (n int, err error) {
+	// Reads are capped to a second of bandwidth, so that bytes are sent smoothly.
+	if max := int(r.l.rate); max > 0 && len(p) > max {
+		p = p[:max]
+	}
+	n, err = r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return
+}
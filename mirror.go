@@ -0,0 +1,140 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// MirrorWriteError will be returned while the object has been written to the storage, but failed
+// to be written to some of write_mirrors.
+//
+// MirrorWriteError wraps ErrMirrorWriteFailed, so both `errors.Is(err, ErrMirrorWriteFailed)` and
+// `errors.As(err, &MirrorWriteError{})` could be used.
+type MirrorWriteError struct {
+	// Errs are the errors of write_mirrors in the same order, which are nil for mirrors written.
+	Errs []error
+}
+
+func (e MirrorWriteError) Error() string {
+	var msgs []string
+	for i, err := range e.Errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("mirror %d: %v", i, err))
+		}
+	}
+	return fmt.Sprintf("%v: %s", ErrMirrorWriteFailed, strings.Join(msgs, "; "))
+}
+
+func (e MirrorWriteError) Unwrap() error {
+	return ErrMirrorWriteFailed
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e MirrorWriteError) IsInternalError() {}
+
+// mirrorStrippedPairs are the pairs of write which are not passed to mirrors, as they report the
+// result of the write to the caller.
+var mirrorStrippedPairs = map[string]struct{}{
+	"write_result":      {},
+	"io_callback":       {},
+	"progress_callback": {},
+}
+
+// startMirrors prepares the write to mirrors, the returned reader must be used by the write to
+// the storage, and wait must be called with its error.
+//
+// Seekable content is written to mirrors one by one after the storage, while unseekable content
+// is streamed to all mirrors along with the storage.
+func (s *Storage) startMirrors(ctx context.Context, path string, r io.Reader, size int64, opt pairStorageWrite) (io.Reader, func(err error) error) {
+	var pairs []typ.Pair
+	for _, v := range opt.pairs {
+		if _, ok := mirrorStrippedPairs[v.Key]; !ok {
+			pairs = append(pairs, v)
+		}
+	}
+
+	if rs, ok := r.(io.ReadSeeker); ok || r == nil {
+		var offset int64
+		if rs != nil {
+			offset, _ = rs.Seek(0, io.SeekCurrent)
+		}
+		return r, func(err error) error {
+			if err != nil {
+				return err
+			}
+			errs := make([]error, len(s.mirrors))
+			for i, m := range s.mirrors {
+				if rs != nil {
+					if _, errs[i] = rs.Seek(offset, io.SeekStart); errs[i] != nil {
+						continue
+					}
+				}
+				_, errs[i] = m.WriteWithContext(ctx, path, r, size, pairs...)
+			}
+			return formatMirrorErrors(errs)
+		}
+	}
+
+	fw := &fanoutWriter{}
+	errs := make([]error, len(s.mirrors))
+	wg := &sync.WaitGroup{}
+	for i, m := range s.mirrors {
+		pr, pw := io.Pipe()
+		fw.writers = append(fw.writers, pw)
+
+		wg.Add(1)
+		go func(i int, m typ.Storager) {
+			defer wg.Done()
+
+			_, errs[i] = m.WriteWithContext(ctx, path, pr, size, pairs...)
+			// Unblock the fan-out if the mirror failed before reading all content.
+			pr.CloseWithError(errs[i])
+		}(i, m)
+	}
+	return io.TeeReader(r, fw), func(err error) error {
+		for _, pw := range fw.writers {
+			pw.CloseWithError(err)
+		}
+		wg.Wait()
+		if err != nil {
+			return err
+		}
+		return formatMirrorErrors(errs)
+	}
+}
+
+func formatMirrorErrors(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return MirrorWriteError{Errs: errs}
+		}
+	}
+	return nil
+}
+
+// fanoutWriter writes to all pipes of mirrors, mirrors failed are skipped so that the write to
+// the storage won't be affected.
+type fanoutWriter struct {
+	writers []*io.PipeWriter
+	failed  []bool
+}
+
+func (w *fanoutWriter) Write(p []byte) (int, error) {
+	if w.failed == nil {
+		w.failed = make([]bool, len(w.writers))
+	}
+	for i, pw := range w.writers {
+		if w.failed[i] {
+			continue
+		}
+		if _, err := pw.Write(p); err != nil {
+			w.failed[i] = true
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,228 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// NotificationEventType is the type of an object event notification.
+type NotificationEventType string
+
+// All available notification event types are listed here.
+const (
+	NotificationEventCreated NotificationEventType = "created"
+	// NotificationEventRemoved covers both deletions and lifecycle expirations.
+	NotificationEventRemoved NotificationEventType = "removed"
+	// NotificationEventOther covers other events like restores and replications.
+	NotificationEventOther NotificationEventType = "other"
+)
+
+// defaultNotificationWaitTime is the default long polling time, which is the maximum of SQS.
+const defaultNotificationWaitTime = 20 * time.Second
+
+// NotificationEvent is an object event notification of the bucket.
+type NotificationEvent struct {
+	Type NotificationEventType
+	// Name is the raw event name, like `ObjectCreated:Put` of S3 notifications or `Object Created`
+	// of EventBridge.
+	Name string
+	Time time.Time
+	// Object is the object of the event, with ID and Path set. Size and etag are set if they're
+	// carried by the event, and the version id is set in ObjectSystemMetadata.
+	Object *typ.Object
+	// Sequencer could be compared as strings to order events of the same key.
+	Sequencer string
+}
+
+// s3EventMessage is the message of S3 event notifications, which is wrapped as `Message` by SNS.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/notification-content-structure.html
+type s3EventMessage struct {
+	Records []struct {
+		EventTime time.Time `json:"eventTime"`
+		EventName string    `json:"eventName"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object s3EventObject `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	// Type and Message are set for messages delivered via SNS.
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+
+	// DetailType, Time and Detail are set for EventBridge events.
+	//
+	// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/ev-events.html
+	DetailType string    `json:"detail-type"`
+	Time       time.Time `json:"time"`
+	Detail     struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key       string `json:"key"`
+			Size      *int64 `json:"size"`
+			ETag      string `json:"etag"`
+			VersionID string `json:"version-id"`
+			Sequencer string `json:"sequencer"`
+		} `json:"object"`
+	} `json:"detail"`
+}
+
+type s3EventObject struct {
+	Key       string `json:"key"`
+	Size      *int64 `json:"size"`
+	ETag      string `json:"eTag"`
+	VersionID string `json:"versionId"`
+	Sequencer string `json:"sequencer"`
+}
+
+// ParseNotification will parse the body of a message of event notifications, which could be
+// delivered by S3 directly, via SNS or via EventBridge.
+//
+// Events of other buckets or keys outside the work dir are skipped, and test events sent while
+// configuring notifications carry no events. An error wrapping ErrNotificationMalformed will be
+// returned if the message could not be parsed.
+func (s *Storage) ParseNotification(body []byte) (events []NotificationEvent, err error) {
+	var m s3EventMessage
+	if err = json.Unmarshal(body, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotificationMalformed, err)
+	}
+	if m.Type == "Notification" && m.Message != "" {
+		return s.ParseNotification([]byte(m.Message))
+	}
+
+	if m.DetailType != "" {
+		e, ok := s.formatNotificationEvent(m.DetailType, m.Time, m.Detail.Bucket.Name, m.Detail.Object.Key, s3EventObject(m.Detail.Object))
+		if ok {
+			events = append(events, e)
+		}
+		return events, nil
+	}
+
+	for _, r := range m.Records {
+		// Keys of S3 notifications are URL encoded with spaces as `+`.
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q", ErrNotificationMalformed, r.S3.Object.Key)
+		}
+		e, ok := s.formatNotificationEvent(r.EventName, r.EventTime, r.S3.Bucket.Name, key, r.S3.Object)
+		if ok {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *Storage) formatNotificationEvent(name string, t time.Time, bucket, key string, v s3EventObject) (e NotificationEvent, ok bool) {
+	if bucket != s.name || !strings.HasPrefix(key, strings.TrimPrefix(s.workDir, "/")) {
+		return e, false
+	}
+
+	e = NotificationEvent{
+		Type:      NotificationEventOther,
+		Name:      name,
+		Time:      t,
+		Sequencer: v.Sequencer,
+	}
+	switch {
+	case strings.HasPrefix(name, "ObjectCreated:"), name == "Object Created":
+		e.Type = NotificationEventCreated
+	case strings.HasPrefix(name, "ObjectRemoved:"), strings.HasPrefix(name, "LifecycleExpiration:"), name == "Object Deleted":
+		e.Type = NotificationEventRemoved
+	}
+
+	// The object is built from the event only, which describes the object when the event happened.
+	o := s.newObject(true)
+	o.ID = key
+	o.Path = s.getRelPath(key)
+	o.Mode |= typ.ModeRead
+	if v.Size != nil {
+		o.SetContentLength(*v.Size)
+	}
+	// Etags are quoted in other responses of S3.
+	if v.ETag != "" {
+		o.SetEtag(`"` + v.ETag + `"`)
+	}
+	var sm ObjectSystemMetadata
+	sm.VersionID = v.VersionID
+	o.SetSystemMetadata(sm)
+	e.Object = o
+	return e, true
+}
+
+// NotificationOptions controls the behavior of PollNotifications.
+type NotificationOptions struct {
+	// Client is the SQS client, which could be created from the session returned by
+	// Service.Session.
+	Client sqsiface.SQSAPI
+	// QueueURL is the url of the SQS queue subscribed to the notifications of the bucket.
+	QueueURL string
+	// WaitTime is the long polling time of every receive, 20s by default.
+	WaitTime time.Duration
+	// VisibilityTimeout overrides the visibility timeout of the queue if it's not zero, which
+	// should be longer than handling the events of a message.
+	VisibilityTimeout time.Duration
+}
+
+// PollNotifications will poll the SQS queue until ctx is done, and call fn with the events of
+// every message in order. A message is deleted after fn succeeds on all its events.
+//
+// Errors returned by fn, malformed messages and SQS errors will stop the polling and be returned,
+// messages not deleted will be delivered again after the visibility timeout, or moved to the
+// dead-letter queue by the redrive policy of the queue.
+func (s *Storage) PollNotifications(ctx context.Context, opt NotificationOptions, fn func(NotificationEvent) error) error {
+	waitTime := opt.WaitTime
+	if waitTime <= 0 {
+		waitTime = defaultNotificationWaitTime
+	}
+
+	input := &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(opt.QueueURL),
+		MaxNumberOfMessages: aws.Int64(10),
+		WaitTimeSeconds:     aws.Int64(int64(waitTime / time.Second)),
+	}
+	if opt.VisibilityTimeout > 0 {
+		input.VisibilityTimeout = aws.Int64(int64(opt.VisibilityTimeout / time.Second))
+	}
+	for {
+		output, err := opt.Client.ReceiveMessageWithContext(ctx, input)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, m := range output.Messages {
+			events, err := s.ParseNotification([]byte(aws.StringValue(m.Body)))
+			if err != nil {
+				return fmt.Errorf("message %s: %w", aws.StringValue(m.MessageId), err)
+			}
+			for _, e := range events {
+				if err = fn(e); err != nil {
+					return err
+				}
+			}
+			_, err = opt.Client.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(opt.QueueURL),
+				ReceiptHandle: m.ReceiptHandle,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
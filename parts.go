@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// PartDetail is a part listed by ListPartDetails, which carries the fields returned by ListParts
+// but not by typ.Part, so that resuming logic could detect stale or corrupted parts.
+type PartDetail struct {
+	*typ.Part
+
+	// LastModified is the time when the part was uploaded.
+	LastModified time.Time
+	// ContentMD5 is the hex encoded md5 of the part taken from the ETag. It will be empty if the
+	// ETag is not a md5, but S3 doesn't tell whether the upload is encrypted by SSE-KMS or SSE-C,
+	// whose ETags are not the md5 of the content even if they look like one.
+	ContentMD5 string
+}
+
+// formatPart converts a part returned by ListParts.
+func formatPart(v *s3.Part) *typ.Part {
+	return &typ.Part{
+		// The returned `PartNumber` is [1, 10000].
+		// Set Index=*v.PartNumber-1 here to make the `PartNumber` zero-based for user.
+		Index: int(*v.PartNumber) - 1,
+		Size:  *v.Size,
+		ETag:  aws.StringValue(v.ETag),
+	}
+}
+
+func formatPartDetail(v *s3.Part) *PartDetail {
+	p := &PartDetail{
+		Part:         formatPart(v),
+		LastModified: aws.TimeValue(v.LastModified),
+	}
+	if etag := strings.Trim(aws.StringValue(v.ETag), `"`); len(etag) == 32 {
+		if _, err := hex.DecodeString(etag); err == nil {
+			p.ContentMD5 = strings.ToLower(etag)
+		}
+	}
+	return p
+}
+
+// PartDetailIterator iterates the parts listed by ListPartDetails.
+type PartDetailIterator struct {
+	ctx   context.Context
+	s     *Storage
+	path  string
+	input *partPageStatus
+
+	parts []*PartDetail
+	done  bool
+}
+
+// Next returns the next part, typ.IterateDone will be returned after all parts have been listed.
+func (it *PartDetailIterator) Next() (p *PartDetail, err error) {
+	for len(it.parts) == 0 {
+		if it.done {
+			return nil, typ.IterateDone
+		}
+
+		parts, done, err := it.s.listParts(it.ctx, it.input)
+		if err != nil {
+			return nil, it.s.formatError("list_part_details", err, it.path)
+		}
+		for _, v := range parts {
+			it.parts = append(it.parts, formatPartDetail(v))
+		}
+		it.done = done
+	}
+
+	p, it.parts = it.parts[0], it.parts[1:]
+	return p, nil
+}
+
+// ListPartDetails will list the parts of the multipart upload o like ListMultipart, along with
+// the last modified time and md5 of them.
+func (s *Storage) ListPartDetails(ctx context.Context, o *typ.Object) *PartDetailIterator {
+	return &PartDetailIterator{
+		ctx:  ctx,
+		s:    s,
+		path: o.Path,
+		input: &partPageStatus{
+			maxParts: 200,
+			key:      o.ID,
+			uploadId: o.MustGetMultipartID(),
+		},
+	}
+}
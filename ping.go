@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+// Ping will check the connectivity and credential of the service via ListBuckets, which requires
+// the `s3:ListAllMyBuckets` permission. It returns the latency of the request.
+//
+// Errors will be classified into services.ErrPermissionDenied and ErrNetworkUnreachable, so that
+// readiness probes could tell misconfiguration from outages.
+func (s *Service) Ping(ctx context.Context) (latency time.Duration, err error) {
+	start := time.Now()
+	_, err = s.service.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	latency = time.Since(start)
+	if err != nil {
+		return latency, services.ServiceError{Op: "ping", Err: formatPingError(err), Servicer: s}
+	}
+	return latency, nil
+}
+
+// Ping will check the connectivity, credential and existence of the bucket via HeadBucket.
+// It returns the latency of the request.
+//
+// Errors will be classified into services.ErrPermissionDenied, ErrBucketNotExist and
+// ErrNetworkUnreachable, so that readiness probes could tell misconfiguration from outages.
+func (s *Storage) Ping(ctx context.Context) (latency time.Duration, err error) {
+	start := time.Now()
+	_, err = s.service.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(s.name),
+	})
+	latency = time.Since(start)
+	if err != nil {
+		return latency, services.StorageError{Op: "ping", Err: formatPingError(err), Storager: s}
+	}
+	return latency, nil
+}
+
+// formatPingError will classify errors by status code, as HEAD responses carry no error code.
+func formatPingError(err error) error {
+	if e, ok := err.(awserr.RequestFailure); ok {
+		switch e.StatusCode() {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("%w: %v", services.ErrPermissionDenied, redactError(err))
+		case http.StatusNotFound:
+			return fmt.Errorf("%w: %v", ErrBucketNotExist, redactError(err))
+		}
+		return formatError(err)
+	}
+	if e, ok := err.(awserr.Error); ok && e.Code() == request.ErrCodeRequestError {
+		return fmt.Errorf("%w: %v", ErrNetworkUnreachable, redactError(err))
+	}
+	return formatError(err)
+}
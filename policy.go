@@ -0,0 +1,77 @@
+package s3
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// OperationPolicy restricts the S3 API operations a Storage could perform, so that constrained
+// Storager handles could be handed out to shared components.
+//
+// The policy is enforced while validating requests, before anything is sent to S3. It also applies
+// to presigned requests and the client returned by Storage.Client.
+type OperationPolicy struct {
+	// ReadOnly denies all operations which modify the bucket, like PutObject, CopyObject and DeleteObject.
+	ReadOnly bool
+	// Allow lists the allowed S3 API operations like `GetObject`, all operations are allowed if it's empty.
+	Allow []string
+	// Deny lists the denied S3 API operations like `DeleteObject`, which wins over Allow.
+	Deny []string
+}
+
+// OperationDeniedError will be returned while the operation is denied by the OperationPolicy.
+//
+// OperationDeniedError wraps ErrOperationDenied, so both `errors.Is(err, ErrOperationDenied)` and
+// `errors.As(err, &OperationDeniedError{})` could be used.
+type OperationDeniedError struct {
+	// Operation is the name of the denied S3 API operation, for example `DeleteObject`.
+	Operation string
+}
+
+func (e OperationDeniedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Operation, ErrOperationDenied)
+}
+
+func (e OperationDeniedError) Unwrap() error {
+	return ErrOperationDenied
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e OperationDeniedError) IsInternalError() {}
+
+// Allowed checks whether the S3 API operation is allowed by the policy.
+func (p OperationPolicy) Allowed(operation string) bool {
+	for _, v := range p.Deny {
+		if v == operation {
+			return false
+		}
+	}
+	if p.ReadOnly {
+		// Billing tiers of requests tell whether they modify the bucket.
+		switch requestTierOf(operation) {
+		case RequestTierPut, RequestTierDelete:
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, v := range p.Allow {
+		if v == operation {
+			return true
+		}
+	}
+	return false
+}
+
+func (p OperationPolicy) apply(h *request.Handlers) {
+	h.Validate.PushFrontNamed(request.NamedHandler{
+		Name: "s3.OperationPolicyHandler",
+		Fn: func(r *request.Request) {
+			if !p.Allowed(r.Operation.Name) {
+				r.Error = OperationDeniedError{Operation: r.Operation.Name}
+			}
+		},
+	})
+}
@@ -0,0 +1,118 @@
+package s3
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// PrefixRule specifies the pairs applied to writes (Write and CreateMultipart, including the
+// presigned ones) of objects under a key prefix, for example:
+//
+//	s3.PrefixRule{
+//		Prefix: "logs/",
+//		Pairs:  []types.Pair{s3.WithStorageClass(s3.StorageClassStandardIa), s3.WithTagging(map[string]string{"retention": "30d"})},
+//	}
+//
+// Rules are layered over the default pairs of the storage: pairs passed in win over Pairs of
+// rules, which win over default pairs. If multiple rules match, rules with longer prefixes win.
+type PrefixRule struct {
+	// Prefix is matched against the object key in the bucket, which means the work dir is
+	// included, without the leading `/`.
+	Prefix string
+	// Pairs are the default pairs of writes under the prefix.
+	Pairs []typ.Pair
+	// RequiredPairs are always applied to writes under the prefix, and override pairs passed in,
+	// for example, to require SSE-KMS with a specific key.
+	RequiredPairs []typ.Pair
+}
+
+// prefixRule is the PrefixRule with pairs split by operations, as not all pairs are supported
+// by both Write and CreateMultipart.
+type prefixRule struct {
+	prefix string
+
+	write             []typ.Pair
+	writeRequired     []typ.Pair
+	multipart         []typ.Pair
+	multipartRequired []typ.Pair
+}
+
+// parsePrefixRules will split pairs of rules by operations, and sort rules by prefix length
+// in descending order. Pairs supported by neither Write nor CreateMultipart will be rejected.
+func (s *Storage) parsePrefixRules(rules []PrefixRule) ([]prefixRule, error) {
+	result := make([]prefixRule, 0, len(rules))
+	for _, rule := range rules {
+		r := prefixRule{prefix: strings.TrimPrefix(rule.Prefix, "/")}
+		for i, pairs := range [][]typ.Pair{rule.Pairs, rule.RequiredPairs} {
+			for _, p := range pairs {
+				_, writeErr := s.parsePairStorageWrite([]typ.Pair{p})
+				_, multipartErr := s.parsePairStorageCreateMultipart([]typ.Pair{p})
+				if writeErr != nil && multipartErr != nil {
+					return nil, services.PairUnsupportedError{Pair: p}
+				}
+				if writeErr == nil {
+					if i == 0 {
+						r.write = append(r.write, p)
+					} else {
+						r.writeRequired = append(r.writeRequired, p)
+					}
+				}
+				if multipartErr == nil {
+					if i == 0 {
+						r.multipart = append(r.multipart, p)
+					} else {
+						r.multipartRequired = append(r.multipartRequired, p)
+					}
+				}
+			}
+		}
+		result = append(result, r)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return len(result[i].prefix) > len(result[j].prefix)
+	})
+	return result, nil
+}
+
+// applyPrefixRules will insert pairs of rules matching the path into pairs, which are the pairs
+// passed in followed by defaults as generated functions do. As parsing pairs keeps the first
+// value of a key, the result is ordered as required pairs, pairs passed in, pairs of rules and
+// default pairs.
+func (s *Storage) applyPrefixRules(path string, pairs, defaults []typ.Pair, multipart bool) ([]typ.Pair, error) {
+	if len(s.prefixRules) == 0 {
+		return pairs, nil
+	}
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var required, ruleDefaults []typ.Pair
+	for _, r := range s.prefixRules {
+		if !strings.HasPrefix(rp, r.prefix) {
+			continue
+		}
+		if multipart {
+			required = append(required, r.multipartRequired...)
+			ruleDefaults = append(ruleDefaults, r.multipart...)
+		} else {
+			required = append(required, r.writeRequired...)
+			ruleDefaults = append(ruleDefaults, r.write...)
+		}
+	}
+	if len(required) == 0 && len(ruleDefaults) == 0 {
+		return pairs, nil
+	}
+
+	n := len(pairs) - len(defaults)
+	result := make([]typ.Pair, 0, len(required)+len(pairs)+len(ruleDefaults))
+	result = append(result, required...)
+	result = append(result, pairs[:n]...)
+	result = append(result, ruleDefaults...)
+	result = append(result, pairs[n:]...)
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// defaultPresignCacheReuseFraction is the default fraction of the lifetime of cached presigned
+// requests during which they are reused.
+const defaultPresignCacheReuseFraction = 0.5
+
+// presignCache caches presigned read requests, so that hot objects are not signed again on every
+// query_sign_http_read.
+//
+// Requests are reused for a fraction of their lifetime only, so that callers always get requests
+// valid for at least the rest of the lifetime.
+type presignCache struct {
+	entries       MetadataCache
+	reuseFraction float64
+}
+
+type presignedRequest struct {
+	url    string
+	header http.Header
+}
+
+func newPresignCache(size int, reuseFraction float64) *presignCache {
+	return &presignCache{
+		entries:       NewMemoryMetadataCache(size),
+		reuseFraction: reuseFraction,
+	}
+}
+
+// key formats the key of the input, inputs are hashed as they may carry SSE-C keys.
+func (c *presignCache) key(input *s3.GetObjectInput, expire time.Duration) string {
+	sum := sha256.Sum256([]byte(expire.String() + "\n" + input.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// presign returns the cached request of the input, or presigns it and caches the result.
+func (c *presignCache) presign(input *s3.GetObjectInput, expire time.Duration, fn func() (string, http.Header, error)) (*http.Request, error) {
+	key := c.key(input, expire)
+	if v, ok := c.entries.Get(key); ok {
+		p := v.(presignedRequest)
+		return newPresignedRequest(p.url, p.header)
+	}
+
+	url, header, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Set(key, presignedRequest{url: url, header: header}, time.Duration(float64(expire)*c.reuseFraction))
+	return newPresignedRequest(url, header)
+}
+
+// newPresignedRequest builds a new request every time, as requests could be modified by callers.
+func newPresignedRequest(url string, header http.Header) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header.Clone()
+	return req, nil
+}
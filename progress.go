@@ -0,0 +1,172 @@
+package s3
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// progressInterval is the minimum interval between two progress reports, except the final one.
+const progressInterval = 200 * time.Millisecond
+
+// Progress is the progress of a transfer reported by progress_callback.
+type Progress struct {
+	// Done is the number of bytes transferred.
+	Done int64
+	// Total is the number of bytes to transfer, it will be -1 if it's unknown.
+	Total int64
+	// Rate is the transfer rate in bytes per second since the last report.
+	Rate float64
+	// ETA is the estimated time to finish the transfer, it will be -1 if it's unknown.
+	ETA time.Duration
+}
+
+// ProgressFunc is the function called with the progress of a transfer, which will be called at
+// most every 200ms, and once after the transfer is finished.
+type ProgressFunc func(p Progress)
+
+// progressTracker counts the transferred bytes and reports the progress.
+type progressTracker struct {
+	fn ProgressFunc
+
+	mu    sync.Mutex
+	total int64
+	done  int64
+	// start and startDone are used to calculate the average rate.
+	start     time.Time
+	startDone int64
+	last      time.Time
+	lastDone  int64
+	rate      float64
+	finished  bool
+}
+
+// newProgressTracker creates a tracker for a transfer which has done bytes transferred before,
+// for example, a resumed download.
+func newProgressTracker(fn ProgressFunc, total, done int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		fn:        fn,
+		total:     total,
+		done:      done,
+		start:     now,
+		startDone: done,
+		last:      now,
+		lastDone:  done,
+	}
+}
+
+// setTotal sets the total size once it's known.
+func (t *progressTracker) setTotal(total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total = total
+}
+
+func (t *progressTracker) add(n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done += n
+	final := t.total >= 0 && t.done >= t.total
+	if final || time.Since(t.last) >= progressInterval {
+		t.report(final)
+	}
+}
+
+// finish reports the final progress if it hasn't been reported.
+func (t *progressTracker) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.report(true)
+}
+
+func (t *progressTracker) report(final bool) {
+	if t.finished {
+		return
+	}
+	t.finished = final
+
+	now := time.Now()
+	if elapsed := now.Sub(t.last); elapsed >= progressInterval {
+		t.rate = float64(t.done-t.lastDone) / elapsed.Seconds()
+	} else if elapsed := now.Sub(t.start); elapsed > 0 {
+		// The interval is too short to measure the rate, use the average one instead.
+		t.rate = float64(t.done-t.startDone) / elapsed.Seconds()
+	}
+	t.last, t.lastDone = now, t.done
+
+	p := Progress{
+		Done:  t.done,
+		Total: t.total,
+		Rate:  t.rate,
+		ETA:   -1,
+	}
+	switch {
+	case final:
+		p.ETA = 0
+	case t.total >= 0 && t.rate > 0:
+		p.ETA = time.Duration(float64(t.total-t.done) / t.rate * float64(time.Second))
+	}
+	t.fn(p)
+}
+
+// progressReader reports the progress of bytes read from r.
+type progressReader struct {
+	r io.Reader
+	t *progressTracker
+}
+
+func newProgressReader(r io.Reader, fn ProgressFunc, total int64) *progressReader {
+	return &progressReader{r: r, t: newProgressTracker(fn, total, 0)}
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.t.add(int64(n))
+	if err == io.EOF {
+		r.t.finish()
+	}
+	return
+}
+
+// progressReadCloser is the progressReader which closes the underlying reader.
+type progressReadCloser struct {
+	*progressReader
+	c io.Closer
+}
+
+func (r progressReadCloser) Close() error {
+	return r.c.Close()
+}
+
+// progressWriter reports the progress of bytes written to w.
+type progressWriter struct {
+	w io.Writer
+	t *progressTracker
+}
+
+func (w *progressWriter) Write(p []byte) (n int, err error) {
+	n, err = w.w.Write(p)
+	w.t.add(int64(n))
+	return
+}
+
+// splitProgressPair returns the function of progress_callback in pairs, and the pairs without
+// it, which is used by helpers reporting the progress by themselves.
+func splitProgressPair(pairs []typ.Pair) (fn ProgressFunc, rest []typ.Pair) {
+	for _, v := range pairs {
+		if v.Key != "progress_callback" {
+			rest = append(rest, v)
+			continue
+		}
+		if fn == nil {
+			fn = v.Value.(ProgressFunc)
+		}
+	}
+	return fn, rest
+}
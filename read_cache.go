@@ -0,0 +1,315 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// readCache caches objects read on local disk, each object is stored as two files:
+//
+//   - `<key hash>.json` carries the etag and headers of the object
+//   - `<key hash>.<etag hash>.data` is the content of the object
+//
+// Content files are named by the etag, so that the headers never point to content of another
+// version, and both files are written to a temp file and renamed.
+//
+// Content is stored as returned by S3: objects encrypted with customer-provided keys (SSE-C) are
+// never cached, as they are returned in plaintext, while objects with client-side encryption are
+// cached as the ciphertext along with the envelope and decrypted on every read, so that no
+// plaintext of encrypted objects is stored on disk.
+type readCache struct {
+	dir     string
+	maxSize int64
+
+	// lock serializes eviction.
+	lock sync.Mutex
+}
+
+// readCacheEntry is the headers of the cached object, which are needed to serve reads.
+type readCacheEntry struct {
+	ETag            string             `json:"etag"`
+	ContentLength   int64              `json:"content_length"`
+	ContentType     string             `json:"content_type,omitempty"`
+	ContentEncoding string             `json:"content_encoding,omitempty"`
+	LastModified    time.Time          `json:"last_modified"`
+	Metadata        map[string]*string `json:"metadata,omitempty"`
+}
+
+func newReadCache(dir string, maxSize int64) (*readCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create read cache dir: %w", err)
+	}
+	return &readCache{dir: dir, maxSize: maxSize}, nil
+}
+
+func (c *readCache) keyHash(bucket, key string) string {
+	sum := sha256.Sum256([]byte(bucket + "/" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *readCache) dataPath(keyHash, etag string) string {
+	sum := sha256.Sum256([]byte(etag))
+	return filepath.Join(c.dir, keyHash+"."+hex.EncodeToString(sum[:8])+".data")
+}
+
+// lookup returns nil if the object is not cached, all errors are treated as cache misses.
+func (c *readCache) lookup(bucket, key string) *readCacheEntry {
+	h := c.keyHash(bucket, key)
+	content, err := ioutil.ReadFile(filepath.Join(c.dir, h+".json"))
+	if err != nil {
+		return nil
+	}
+	e := &readCacheEntry{}
+	if err = json.Unmarshal(content, e); err != nil || e.ETag == "" {
+		return nil
+	}
+	fi, err := os.Stat(c.dataPath(h, e.ETag))
+	if err != nil || fi.Size() != e.ContentLength {
+		return nil
+	}
+	return e
+}
+
+// open builds the response of GetObject from the cached object, the range header is applied if
+// it's not nil.
+func (c *readCache) open(bucket, key string, e *readCacheEntry, rangeHeader *string) (*s3.GetObjectOutput, error) {
+	p := c.dataPath(c.keyHash(bucket, key), e.ETag)
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	// Touch the content so that it's evicted as recently used.
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+
+	output := &s3.GetObjectOutput{
+		ETag:          aws.String(e.ETag),
+		ContentLength: aws.Int64(e.ContentLength),
+		LastModified:  aws.Time(e.LastModified),
+		Metadata:      e.Metadata,
+		Body:          f,
+	}
+	if e.ContentType != "" {
+		output.ContentType = aws.String(e.ContentType)
+	}
+	if e.ContentEncoding != "" {
+		output.ContentEncoding = aws.String(e.ContentEncoding)
+	}
+	if rangeHeader == nil {
+		return output, nil
+	}
+
+	start, end, ok := parseRangeHeader(*rangeHeader, e.ContentLength)
+	if !ok {
+		f.Close()
+		return nil, fmt.Errorf("range %s of cached object: %w", *rangeHeader, ErrRangeNotSatisfiable)
+	}
+	output.ContentLength = aws.Int64(end - start + 1)
+	output.ContentRange = aws.String(fmt.Sprintf("bytes %d-%d/%d", start, end, e.ContentLength))
+	output.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.NewSectionReader(f, start, end-start+1), f}
+	return output, nil
+}
+
+// fill wraps the body of a complete object, the object will be cached once the body has been
+// read to the end.
+func (c *readCache) fill(bucket, key string, output *s3.GetObjectOutput, rc io.ReadCloser) io.ReadCloser {
+	if output.ETag == nil || output.ContentLength == nil || *output.ContentLength < 0 {
+		return rc
+	}
+	f, err := ioutil.TempFile(c.dir, "fill-*.tmp")
+	if err != nil {
+		return rc
+	}
+	return &readCacheFiller{
+		c:      c,
+		bucket: bucket,
+		key:    key,
+		rc:     rc,
+		f:      f,
+		entry: readCacheEntry{
+			ETag:            *output.ETag,
+			ContentLength:   *output.ContentLength,
+			ContentType:     aws.StringValue(output.ContentType),
+			ContentEncoding: aws.StringValue(output.ContentEncoding),
+			LastModified:    aws.TimeValue(output.LastModified),
+			Metadata:        output.Metadata,
+		},
+	}
+}
+
+// readCacheFiller copies the body into the temp file while it's being read.
+type readCacheFiller struct {
+	c           *readCache
+	bucket, key string
+	entry       readCacheEntry
+
+	rc      io.ReadCloser
+	f       *os.File
+	written int64
+	failed  bool
+	done    bool
+}
+
+func (r *readCacheFiller) Read(p []byte) (n int, err error) {
+	n, err = r.rc.Read(p)
+	if n > 0 && !r.failed {
+		if _, werr := r.f.Write(p[:n]); werr != nil {
+			r.failed = true
+		}
+		r.written += int64(n)
+	}
+	if err == io.EOF && !r.failed && r.written == r.entry.ContentLength {
+		r.done = true
+	}
+	return
+}
+
+// Close will commit the object if the body has been read to the end, the temp file is removed
+// otherwise. Failing to cache the object will not fail the read.
+func (r *readCacheFiller) Close() error {
+	err := r.rc.Close()
+	if r.f == nil {
+		return err
+	}
+
+	name := r.f.Name()
+	cerr := r.f.Close()
+	r.f = nil
+	if !r.done || cerr != nil || r.c.commit(r.bucket, r.key, name, r.entry) != nil {
+		_ = os.Remove(name)
+	}
+	return err
+}
+
+func (c *readCache) commit(bucket, key, tmp string, e readCacheEntry) error {
+	h := c.keyHash(bucket, key)
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// The content of the previous version will be removed after the headers are replaced.
+	prev := c.lookup(bucket, key)
+
+	if err = os.Rename(tmp, c.dataPath(h, e.ETag)); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.dir, "fill-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, h+".json"))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if prev != nil && prev.ETag != e.ETag {
+		_ = os.Remove(c.dataPath(h, prev.ETag))
+	}
+	if c.maxSize > 0 {
+		c.evict()
+	}
+	return nil
+}
+
+// evict removes the least recently used content until the total size fits in maxSize, headers
+// without content are treated as cache misses.
+func (c *readCache) evict() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var data []os.FileInfo
+	var total int64
+	for _, fi := range files {
+		if strings.HasSuffix(fi.Name(), ".data") {
+			data = append(data, fi)
+			total += fi.Size()
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].ModTime().Before(data[j].ModTime())
+	})
+	for _, fi := range data {
+		if total <= c.maxSize {
+			return
+		}
+		if os.Remove(filepath.Join(c.dir, fi.Name())) == nil {
+			total -= fi.Size()
+		}
+	}
+}
+
+// isNotModified checks whether the request failed with 304.
+func isNotModified(err error) bool {
+	e, ok := err.(awserr.RequestFailure)
+	return ok && e.StatusCode() == 304
+}
+
+// parseRangeHeader parses the range header like `bytes=0-99`, `bytes=100-` or `bytes=-100`
+// against the size of the object, end is inclusive.
+func parseRangeHeader(v string, size int64) (start, end int64, ok bool) {
+	v = strings.TrimPrefix(v, "bytes=")
+	i := strings.Index(v, "-")
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	var err error
+	switch {
+	case i == 0:
+		// The suffix range.
+		var n int64
+		n, err = strconv.ParseInt(v[1:], 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	case i == len(v)-1:
+		start, err = strconv.ParseInt(v[:i], 10, 64)
+		end = size - 1
+	default:
+		start, err = strconv.ParseInt(v[:i], 10, 64)
+		if err == nil {
+			end, err = strconv.ParseInt(v[i+1:], 10, 64)
+		}
+	}
+	if err != nil || start >= size || start > end {
+		return 0, 0, false
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return start, end, true
+}
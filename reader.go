@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// readAheadSize is the minimum size of a ranged GET sent by ReaderAt, smaller reads will
+// be served from the read-ahead buffer.
+const readAheadSize = 1024 * 1024
+
+// ReaderAt implements io.ReaderAt and io.ReadSeeker over ranged GetObject calls, which
+// makes it possible to read formats like Parquet or zip directly from S3.
+//
+// ReadAt could be called concurrently, while Read and Seek could not.
+type ReaderAt struct {
+	s     *Storage
+	ctx   context.Context
+	path  string
+	size  int64
+	pairs []typ.Pair
+
+	// offset is used by Read and Seek.
+	offset int64
+
+	mu     sync.Mutex
+	buf    []byte
+	bufOff int64
+}
+
+// NewReaderAt will create a ReaderAt for the object at path.
+//
+// The object will be stat once to get its size and etag, and all following reads will be
+// sent with if_match, so ErrPreconditionFailed will be returned if the object has been
+// changed. pairs will be passed to every read.
+func (s *Storage) NewReaderAt(ctx context.Context, path string, pairs ...typ.Pair) (r *ReaderAt, err error) {
+	o, err := s.StatWithContext(ctx, path, filterPairs(pairs,
+		"excepted_bucket_owner",
+		"server_side_encryption_customer_algorithm",
+		"server_side_encryption_customer_key",
+	)...)
+	if err != nil {
+		return
+	}
+
+	r = &ReaderAt{
+		s:    s,
+		ctx:  ctx,
+		path: path,
+		size: o.MustGetContentLength(),
+	}
+	if etag, ok := o.GetEtag(); ok {
+		r.pairs = append(r.pairs, WithIfMatch(etag))
+	}
+	r.pairs = append(r.pairs, pairs...)
+	return r, nil
+}
+
+// Size returns the size of the object.
+func (r *ReaderAt) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *ReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("s3: negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	for n < len(p) && off < r.size {
+		var m int
+		m, err = r.readAt(p[n:], off)
+		n += m
+		off += int64(m)
+		if err != nil {
+			return
+		}
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+func (r *ReaderAt) readAt(p []byte, off int64) (n int, err error) {
+	size := int64(len(p))
+	if off+size > r.size {
+		size = r.size - off
+	}
+
+	// Large reads bypass the buffer so that they could be sent concurrently.
+	if size >= readAheadSize {
+		b, err := r.fetch(off, size)
+		return copy(p, b), err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if off < r.bufOff || off >= r.bufOff+int64(len(r.buf)) {
+		size = readAheadSize
+		if off+size > r.size {
+			size = r.size - off
+		}
+		r.buf, err = r.fetch(off, size)
+		r.bufOff = off
+		if err != nil {
+			r.buf = nil
+			return 0, err
+		}
+	}
+	return copy(p, r.buf[off-r.bufOff:]), nil
+}
+
+func (r *ReaderAt) fetch(off, size int64) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(int(size))
+
+	// Pairs are parsed in order and the first one wins, so offset and size must come first.
+	pairs := append([]typ.Pair{ps.WithOffset(off), ps.WithSize(size)}, r.pairs...)
+	n, err := r.s.ReadWithContext(r.ctx, r.path, &buf, pairs...)
+	if err != nil {
+		return nil, err
+	}
+	if n < size {
+		return buf.Bytes(), io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), nil
+}
+
+// Read implements io.Reader.
+func (r *ReaderAt) Read(p []byte) (n int, err error) {
+	n, err = r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	return
+}
+
+// Seek implements io.Seeker.
+func (r *ReaderAt) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("s3: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// filterPairs returns the pairs whose key is one of keys.
+func filterPairs(pairs []typ.Pair, keys ...string) []typ.Pair {
+	var result []typ.Pair
+	for _, v := range pairs {
+		for _, k := range keys {
+			if v.Key == k {
+				result = append(result, v)
+				break
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package s3
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// redacted replaces secrets in error messages and pairs.
+const redacted = "REDACTED"
+
+var (
+	// redactQueryPattern matches the values of query parameters carrying signatures and credentials
+	// of presigned urls, both SigV4 and SigV2.
+	redactQueryPattern = regexp.MustCompile(`(?i)\b((?:X-Amz-Signature|X-Amz-Credential|X-Amz-Security-Token|Signature|AWSAccessKeyId)=)[^&\s"'<>]+`)
+	// redactHeaderPattern matches the values of headers carrying credentials and SSE-C keys, the
+	// key md5 headers are not secrets so they are not matched.
+	redactHeaderPattern = regexp.MustCompile(`(?i)\b((?:Authorization|X-Amz-Security-Token|X-Amz-(?:Copy-Source-)?Server-Side-Encryption-Customer-Key)\s*:\s*)[^\r\n]+`)
+	// redactFieldPattern matches the SSE-C keys of SDK inputs printed by fmt.
+	redactFieldPattern = regexp.MustCompile(`\b((?:CopySource)?SSECustomerKey:\s*)"[^"]*"`)
+)
+
+// redactString will replace signatures, credentials and SSE-C keys in s.
+func redactString(s string) string {
+	s = redactQueryPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = redactHeaderPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = redactFieldPattern.ReplaceAllString(s, `${1}"`+redacted+`"`)
+	return s
+}
+
+// redactedError redacts the message of the wrapped error, the error itself could still be
+// retrieved via errors.As or errors.Unwrap.
+type redactedError struct {
+	err error
+}
+
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{err: err}
+}
+
+func (e redactedError) Error() string {
+	return redactString(e.err.Error())
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactPairs returns a copy of pairs with secrets replaced, so that they could be carried by
+// errors like services.InitError which print pairs.
+func redactPairs(pairs []typ.Pair) []typ.Pair {
+	result := make([]typ.Pair, len(pairs))
+	for i, p := range pairs {
+		switch p.Key {
+		case "credential":
+			// Keep the protocol like `hmac` for debugging.
+			v, _ := p.Value.(string)
+			if idx := strings.Index(v, ":"); idx >= 0 {
+				v = v[:idx+1] + redacted
+			} else {
+				v = redacted
+			}
+			p.Value = v
+		case "server_side_encryption_customer_key",
+			"copy_source_server_side_encryption_customer_key",
+			"client_side_encryption":
+			p.Value = redacted
+		case "default_service_pairs":
+			if v, ok := p.Value.(DefaultServicePairs); ok {
+				redactOperationPairs(&v)
+				p.Value = v
+			}
+		case "default_storage_pairs":
+			if v, ok := p.Value.(DefaultStoragePairs); ok {
+				redactOperationPairs(&v)
+				p.Value = v
+			}
+		case "prefix_rules":
+			if v, ok := p.Value.([]PrefixRule); ok {
+				rules := make([]PrefixRule, len(v))
+				for j, r := range v {
+					rules[j] = PrefixRule{Prefix: r.Prefix, Pairs: redactPairs(r.Pairs), RequiredPairs: redactPairs(r.RequiredPairs)}
+				}
+				p.Value = rules
+			}
+		}
+		result[i] = p
+	}
+	return result
+}
+
+// redactOperationPairs redacts the pairs of every operation in v, which must be a pointer to
+// DefaultServicePairs or DefaultStoragePairs, as all their fields are pairs of operations.
+func redactOperationPairs(v interface{}) {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if f := rv.Field(i); f.Len() > 0 {
+			f.Set(reflect.ValueOf(redactPairs(f.Interface().([]typ.Pair))))
+		}
+	}
+}
+
+// redactLogger redacts the messages of the SDK logger, as the requests dumped with
+// aws.LogDebugWithHTTPBody or aws.LogDebugWithSigning carry credentials and SSE-C keys.
+type redactLogger struct {
+	logger aws.Logger
+}
+
+func (l redactLogger) Log(args ...interface{}) {
+	// Format like the default logger which uses log.Println.
+	l.logger.Log(redactString(strings.TrimSuffix(fmt.Sprintln(args...), "\n")))
+}
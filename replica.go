@@ -0,0 +1,42 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+// replicaError carries the error returned by read_replica, which has been formatted by the
+// replica itself.
+type replicaError struct {
+	err error
+}
+
+func (e replicaError) Error() string {
+	return "read replica: " + e.err.Error()
+}
+
+func (e replicaError) Unwrap() error {
+	return e.err
+}
+
+// IsInternalError implements services.InternalError, so that the error will be returned as is.
+func (e replicaError) IsInternalError() {}
+
+// readReplica will read the object from read_replica, ok will be false if the object doesn't
+// exist in the replica, which may have not been replicated yet.
+//
+// Pairs of the read are passed to the replica as is, so the replica is expected to be a storage
+// of this service.
+func (s *Storage) readReplica(ctx context.Context, path string, w io.Writer, opt pairStorageRead) (n int64, ok bool, err error) {
+	n, err = s.replica.ReadWithContext(ctx, path, w, opt.pairs...)
+	if errors.Is(err, services.ErrObjectNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return n, true, replicaError{err: err}
+	}
+	return n, true, nil
+}
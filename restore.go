@@ -0,0 +1,73 @@
+package s3
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// All available restore tiers are listed here.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/restoring-objects-retrieval-options.html
+const (
+	RestoreTierExpedited = s3.TierExpedited
+	RestoreTierStandard  = s3.TierStandard
+	RestoreTierBulk      = s3.TierBulk
+)
+
+// Restore will initiate a restore of an archived object at path, the restored copy will be
+// available for days.
+//
+// The restore status could be got via the RestoreXXX fields of ObjectSystemMetadata returned by stat.
+func (s *Storage) Restore(ctx context.Context, path string, days int64, tier string) (err error) {
+	defer func() {
+		err = s.formatError("restore", err, path)
+	}()
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	input := &s3.RestoreObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(rp),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(days),
+			GlacierJobParameters: &s3.GlacierJobParameters{
+				Tier: aws.String(tier),
+			},
+		},
+	}
+
+	_, err = s.service.RestoreObjectWithContext(ctx, input)
+	return
+}
+
+// parseRestore will parse the `x-amz-restore` header, which looks like:
+//
+//	ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+//
+// The expiry date will only be returned after the restore is completed.
+func parseRestore(v string) (ongoing bool, expiry time.Time) {
+	for _, kv := range strings.Split(v, "\", ") {
+		kv = strings.TrimSpace(kv)
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		value := strings.Trim(kv[i+1:], "\"")
+
+		switch kv[:i] {
+		case "ongoing-request":
+			ongoing = value == "true"
+		case "expiry-date":
+			expiry, _ = time.Parse(http.TimeFormat, value)
+		}
+	}
+	return
+}
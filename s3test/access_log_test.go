@@ -0,0 +1,94 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func accessLogLine(key string) string {
+	return fmt.Sprintf(`owner test [06/Feb/2019:00:00:38 +0000] 192.0.2.3 requester 3E57427F3EXAMPLE REST.GET.OBJECT %s "GET /test/%s HTTP/1.1" 200 - 5 5 7 6 "-" "aws-sdk-go" -`, key, key)
+}
+
+func TestReadAccessLogs(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("logging")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	write := func(path string, lines ...string) {
+		content := strings.Join(lines, "\n") + "\n"
+		if _, err := store.Write(path, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("logs/2021-01-01-00-00-00-AAAA", accessLogLine("a"), "malformed", accessLogLine("b"))
+	write("logs/2021-01-02-00-00-00-BBBB", accessLogLine("c"))
+	s := store.(*s3.Storage)
+
+	collect := func(it *s3.AccessLogIterator, n int) (keys []string) {
+		for i := 0; i < n || n < 0; i++ {
+			r, err := it.Next()
+			if err == typ.IterateDone {
+				break
+			}
+			if errors.Is(err, s3.ErrAccessLogMalformed) {
+				keys = append(keys, "!")
+				continue
+			}
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			keys = append(keys, r.Key)
+		}
+		return
+	}
+
+	it, err := s.ReadAccessLogs(context.Background(), "logs/", s3.AccessLogOptions{})
+	if err != nil {
+		t.Fatalf("read access logs: %v", err)
+	}
+	if got := strings.Join(collect(it, -1), ","); got != "a,!,b,c" {
+		t.Errorf("expected records of all logs in order, got %s", got)
+	}
+	if it.Path() != "logs/2021-01-02-00-00-00-BBBB" {
+		t.Errorf("unexpected path %s", it.Path())
+	}
+
+	it, err = s.ReadAccessLogs(context.Background(), "logs/", s3.AccessLogOptions{
+		Since: time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("read access logs: %v", err)
+	}
+	if got := strings.Join(collect(it, -1), ","); got != "c" {
+		t.Errorf("expected records since the time, got %s", got)
+	}
+
+	// New logs are read while following.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	it, err = s.ReadAccessLogs(ctx, "logs/", s3.AccessLogOptions{
+		StartAfter:   "logs/2021-01-01-00-00-00-AAAA",
+		Follow:       true,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("read access logs: %v", err)
+	}
+	if got := strings.Join(collect(it, 1), ","); got != "c" {
+		t.Errorf("expected records after the path, got %s", got)
+	}
+	write("logs/2021-01-03-00-00-00-CCCC", accessLogLine("d"))
+	if got := strings.Join(collect(it, 1), ","); got != "d" {
+		t.Errorf("expected the new record, got %s", got)
+	}
+}
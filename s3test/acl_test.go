@@ -0,0 +1,110 @@
+package s3test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestWriteGrants(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var (
+		mu     sync.Mutex
+		grants = map[string]http.Header{}
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut || r.Method == http.MethodPost {
+			h := http.Header{}
+			for k, v := range r.Header {
+				if strings.HasPrefix(k, "X-Amz-Grant-") {
+					h[k] = v
+				}
+			}
+			mu.Lock()
+			grants[strings.TrimPrefix(r.URL.Path, "/test/")] = h
+			mu.Unlock()
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	owner := "id=0123456789abcdef"
+	grantPairs := []typ.Pair{
+		s3.WithGrantFullControl(owner),
+		s3.WithGrantRead(`uri="http://acs.amazonaws.com/groups/global/AllUsers"`),
+		s3.WithGrantReadAcp(owner),
+		s3.WithGrantWriteAcp(owner),
+	}
+	assertGrants := func(path string) {
+		t.Helper()
+
+		mu.Lock()
+		h := grants[path]
+		mu.Unlock()
+		for k, v := range map[string]string{
+			"X-Amz-Grant-Full-Control": owner,
+			"X-Amz-Grant-Read":         `uri="http://acs.amazonaws.com/groups/global/AllUsers"`,
+			"X-Amz-Grant-Read-Acp":     owner,
+			"X-Amz-Grant-Write-Acp":    owner,
+		} {
+			if got := h.Get(k); got != v {
+				t.Errorf("%s: expected %s %q, got %q", path, k, v, got)
+			}
+		}
+	}
+
+	content := "hello, world"
+
+	t.Run("acl disabled", func(t *testing.T) {
+		store, err := srv.NewStorager("test",
+			ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+			s3.WithDisableACL(),
+		)
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+
+		_, err = store.Write("disabled", strings.NewReader(content), int64(len(content)), grantPairs...)
+		if !errors.As(err, &services.PairUnsupportedError{}) {
+			t.Errorf("expected pair unsupported error, got %v", err)
+		}
+	})
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	t.Run("write", func(t *testing.T) {
+		_, err := store.Write("abc", strings.NewReader(content), int64(len(content)), grantPairs...)
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		assertGrants("abc")
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		if err := store.(typ.Copier).Copy("abc", "copied", grantPairs...); err != nil {
+			t.Fatalf("copy: %v", err)
+		}
+		assertGrants("copied")
+	})
+
+	t.Run("create multipart", func(t *testing.T) {
+		if _, err := store.(typ.Multiparter).CreateMultipart("multipart", grantPairs...); err != nil {
+			t.Fatalf("create multipart: %v", err)
+		}
+		assertGrants("multipart")
+	})
+}
@@ -0,0 +1,96 @@
+package s3test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestArchivePrefix(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	large := strings.Repeat("x", 9*1024*1024)
+	files := map[string]string{
+		"photos/a":      "a",
+		"photos/dir/b":  "b",
+		"photos/large":  large,
+		"photos-2021/c": "c",
+		"other/ignored": "ignored",
+	}
+	for path, content := range files {
+		if _, err = store.Write(path, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	s := store.(*s3.Storage)
+	expected := map[string]string{"a": "a", "dir/b": "b", "large": large}
+
+	t.Run("tar", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := s.ArchivePrefix(context.Background(), "photos/", buf, s3.ArchiveFormatTar); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+
+		got := map[string]string{}
+		var names []string
+		tr := tar.NewReader(buf)
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("tar: %v", err)
+			}
+			content, _ := ioutil.ReadAll(tr)
+			got[h.Name] = string(content)
+			names = append(names, h.Name)
+		}
+		if strings.Join(names, ",") != "a,dir/b,large" {
+			t.Errorf("expected entries in order, got %v", names)
+		}
+		for name, content := range expected {
+			if got[name] != content {
+				t.Errorf("unexpected content of %s", name)
+			}
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		if err := s.ArchivePrefix(context.Background(), "photos/", buf, s3.ArchiveFormatZip); err != nil {
+			t.Fatalf("archive: %v", err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("zip: %v", err)
+		}
+		if len(zr.File) != len(expected) {
+			t.Fatalf("expected %d entries, got %d", len(expected), len(zr.File))
+		}
+		for _, f := range zr.File {
+			r, err := f.Open()
+			if err != nil {
+				t.Fatalf("open %s: %v", f.Name, err)
+			}
+			content, _ := ioutil.ReadAll(r)
+			r.Close()
+			if string(content) != expected[f.Name] {
+				t.Errorf("unexpected content of %s", f.Name)
+			}
+		}
+	})
+}
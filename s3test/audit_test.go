@@ -0,0 +1,53 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestAuditEncryption(t *testing.T) {
+	store := setupStorager(t)
+
+	objects := map[string][]typ.Pair{
+		"a/plain": nil,
+		"a/sse":   {s3.WithServerSideEncryption(s3.ServerSideEncryptionAes256)},
+		"a/kms-a": {s3.WithServerSideEncryption(s3.ServerSideEncryptionAwsKms), s3.WithServerSideEncryptionAwsKmsKeyID("key-a")},
+		"a/kms-b": {s3.WithServerSideEncryption(s3.ServerSideEncryptionAwsKms), s3.WithServerSideEncryptionAwsKmsKeyID("key-b")},
+		"b/plain": nil,
+	}
+	for p, pairs := range objects {
+		if _, err := store.Write(p, strings.NewReader(p), int64(len(p)), pairs...); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+
+	it, err := store.(*s3.Storage).AuditEncryption(context.Background(), "a/", s3.EncryptionAuditOptions{KmsKeyID: "key-b"})
+	if err != nil {
+		t.Fatalf("audit encryption: %v", err)
+	}
+	defer it.Close()
+
+	var findings []string
+	for {
+		e, err := it.Next()
+		if errors.Is(err, typ.IterateDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		findings = append(findings, e.Path+":"+string(e.Finding))
+	}
+	sort.Strings(findings)
+
+	expected := "a/kms-a:wrong_kms_key,a/plain:unencrypted,a/sse:wrong_kms_key"
+	if got := strings.Join(findings, ","); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
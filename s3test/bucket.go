@@ -0,0 +1,369 @@
+package s3test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFormat is the format of timestamps in XML responses.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// maxKeysDefault is the default max number of keys returned in a list response.
+const maxKeysDefault = 1000
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+func (s *Server) listBuckets(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.buckets))
+	for name := range s.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := listAllMyBucketsResult{}
+	for _, name := range names {
+		result.Buckets = append(result.Buckets, bucketInfo{
+			Name:         name,
+			CreationDate: formatTime(s.buckets[name].created),
+		})
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *Server) createBucket(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := s.buckets[name]; ok {
+		writeError(w, errBucketAlreadyOwnedByYou)
+		return
+	}
+	s.buckets[name] = newBucket()
+
+	w.Header().Set("Location", "/"+name)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) deleteBucket(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	if len(b.objects) > 0 {
+		writeError(w, errBucketNotEmpty)
+		return
+	}
+	delete(s.buckets, name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) headBucket(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := s.buckets[name]; !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+func (s *Server) getBucketLocation(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := s.buckets[name]; !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	// Buckets in us-east-1 have an empty location constraint.
+	writeXML(w, http.StatusOK, locationConstraint{})
+}
+
+// getBucketEncryption always reports no default encryption, as s3test doesn't encrypt objects.
+func (s *Server) getBucketEncryption(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := s.buckets[name]; !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	writeError(w, errNoEncryptionConfig)
+}
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// getBucketVersioning always reports versioning never enabled, as s3test doesn't keep versions.
+func (s *Server) getBucketVersioning(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := s.buckets[name]; !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	writeXML(w, http.StatusOK, versioningConfiguration{})
+}
+
+// getBucketPolicy returns the policy as is, the response body is the JSON policy document.
+func (s *Server) getBucketPolicy(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	if b.policy == nil {
+		writeError(w, errNoSuchBucketPolicy)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.policy)
+}
+
+func (s *Server) putBucketPolicy(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil || !json.Valid(data) {
+		writeError(w, errMalformedPolicy)
+		return
+	}
+	b.policy = data
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteBucketPolicy(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	b.policy = nil
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getBucketLifecycle(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	if b.lifecycle == nil {
+		writeError(w, errNoSuchLifecycle)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b.lifecycle)
+}
+
+func (s *Server) putBucketLifecycle(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	var v struct {
+		XMLName xml.Name `xml:"LifecycleConfiguration"`
+	}
+	if err != nil || xml.Unmarshal(data, &v) != nil {
+		writeError(w, errMalformedXML)
+		return
+	}
+	b.lifecycle = data
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) deleteBucketLifecycle(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	b.lifecycle = nil
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectInfo   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectInfo struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (s *Server) listObjectsV2(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+
+	q := r.URL.Query()
+	result := listBucketResult{
+		Name:              name,
+		Prefix:            q.Get("prefix"),
+		Delimiter:         q.Get("delimiter"),
+		StartAfter:        q.Get("start-after"),
+		ContinuationToken: q.Get("continuation-token"),
+		MaxKeys:           maxKeysDefault,
+	}
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, errInvalidArgument)
+			return
+		}
+		result.MaxKeys = n
+	}
+
+	marker := result.StartAfter
+	if result.ContinuationToken != "" {
+		marker = result.ContinuationToken
+	}
+
+	// Objects and common prefixes are returned in the same lexicographical order, and the
+	// continuation token is the last key or common prefix returned.
+	for _, e := range b.listEntries(result.Prefix, result.Delimiter) {
+		if e.name <= marker {
+			continue
+		}
+		if result.KeyCount >= result.MaxKeys {
+			result.IsTruncated = true
+			break
+		}
+		result.KeyCount++
+		result.NextContinuationToken = e.name
+
+		if e.object == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: e.name})
+			continue
+		}
+		result.Contents = append(result.Contents, objectInfo{
+			Key:          e.name,
+			LastModified: formatTime(e.object.modified),
+			ETag:         e.object.etag,
+			Size:         int64(len(e.object.data)),
+			StorageClass: e.object.storageClass(),
+		})
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+// listEntry is an object or a common prefix (if object is nil) in the list response.
+type listEntry struct {
+	name   string
+	object *object
+}
+
+func (b *bucket) listEntries(prefix, delimiter string) []listEntry {
+	keys := make([]string, 0, len(b.objects))
+	for k := range b.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := make([]listEntry, 0, len(keys))
+	for _, k := range keys {
+		if delimiter != "" {
+			if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+				cp := k[:len(prefix)+idx+len(delimiter)]
+				// Keys under the same common prefix are adjacent after sorting.
+				if len(entries) == 0 || entries[len(entries)-1].name != cp {
+					entries = append(entries, listEntry{name: cp})
+				}
+				continue
+			}
+		}
+		entries = append(entries, listEntry{name: k, object: b.objects[k]})
+	}
+	return entries
+}
+
+type deleteRequest struct {
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+	Quiet bool `xml:"Quiet"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name      `xml:"DeleteResult"`
+	Deleted []deletedInfo `xml:"Deleted"`
+}
+
+type deletedInfo struct {
+	Key string `xml:"Key"`
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errIncompleteBody)
+		return
+	}
+	req := deleteRequest{}
+	if err = xml.Unmarshal(body, &req); err != nil {
+		writeError(w, errMalformedXML)
+		return
+	}
+
+	result := deleteResult{}
+	for _, v := range req.Objects {
+		delete(b.objects, v.Key)
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, deletedInfo{Key: v.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+}
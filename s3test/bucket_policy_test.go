@@ -0,0 +1,117 @@
+package s3test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+const (
+	secureTransportPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": {
+    "Sid": "DenyInsecureTransport",
+    "Effect": "Deny",
+    "Principal": "*",
+    "Action": "s3:*",
+    "Resource": ["arn:aws:s3:::test", "arn:aws:s3:::test/*"],
+    "Condition": {"Bool": {"aws:SecureTransport": false}}
+  }
+}`
+	kmsOnlyPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Sid": "DenyNonKmsWrites",
+    "Effect": "Deny",
+    "Principal": "*",
+    "Action": "s3:PutObject",
+    "Resource": "arn:aws:s3:::test/*",
+    "Condition": {"StringNotEquals": {"s3:x-amz-server-side-encryption": "aws:kms"}}
+  }]
+}`
+)
+
+func TestPolicyPreflight(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	kmsPairs := []typ.Pair{s3.WithServerSideEncryption(s3.ServerSideEncryptionAwsKms)}
+	cases := []struct {
+		name        string
+		policy      string
+		pairs       []typ.Pair
+		requirement s3.BucketPolicyRequirement
+	}{
+		{"no policy", "", nil, ""},
+		{"secure transport", secureTransportPolicy, nil, s3.BucketPolicyRequirementSecureTransport},
+		{"kms only", kmsOnlyPolicy, nil, s3.BucketPolicyRequirementServerSideEncryption},
+		{"kms only satisfied", kmsOnlyPolicy, []typ.Pair{s3.WithDefaultStoragePairs(s3.DefaultStoragePairs{
+			Write:           kmsPairs,
+			CreateMultipart: kmsPairs,
+		})}, ""},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.SetBucketPolicy("test", tt.policy)
+
+			_, err := srv.NewStorager("test", append(tt.pairs, s3.WithPolicyPreflight())...)
+			if tt.requirement == "" {
+				if err != nil {
+					t.Errorf("new storager: %v", err)
+				}
+				return
+			}
+
+			var e s3.BucketPolicyError
+			if !errors.Is(err, s3.ErrBucketPolicyUnsatisfied) || !errors.As(err, &e) {
+				t.Fatalf("expected %v, got %v", s3.ErrBucketPolicyUnsatisfied, err)
+			}
+			if e.Requirement != tt.requirement {
+				t.Errorf("expected requirement %s, got %s", tt.requirement, e.Requirement)
+			}
+		})
+	}
+}
+
+func TestPutBucketPolicy(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	client := store.(*s3.Storage).Client()
+	_, err = client.PutBucketPolicy(&awss3.PutBucketPolicyInput{
+		Bucket: aws.String("test"),
+		Policy: aws.String(secureTransportPolicy),
+	})
+	if err != nil {
+		t.Fatalf("put bucket policy: %v", err)
+	}
+	output, err := client.GetBucketPolicy(&awss3.GetBucketPolicyInput{Bucket: aws.String("test")})
+	if err != nil {
+		t.Fatalf("get bucket policy: %v", err)
+	}
+	if aws.StringValue(output.Policy) != secureTransportPolicy {
+		t.Errorf("unexpected policy %s", aws.StringValue(output.Policy))
+	}
+	if _, err = srv.NewStorager("test", s3.WithPolicyPreflight()); !errors.Is(err, s3.ErrBucketPolicyUnsatisfied) {
+		t.Errorf("expected %v, got %v", s3.ErrBucketPolicyUnsatisfied, err)
+	}
+
+	_, err = client.DeleteBucketPolicy(&awss3.DeleteBucketPolicyInput{Bucket: aws.String("test")})
+	if err != nil {
+		t.Fatalf("delete bucket policy: %v", err)
+	}
+	// The bucket is kept while its policy is deleted.
+	if _, err = srv.NewStorager("test", s3.WithPolicyPreflight()); err != nil {
+		t.Errorf("new storager: %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestBatchStat(t *testing.T) {
+	store := setupStorager(t)
+
+	var paths []string
+	for i := 0; i < 20; i++ {
+		p := fmt.Sprintf("obj-%02d", i)
+		if i%5 == 0 {
+			// Leave some paths missing.
+			p += "-missing"
+		} else if _, err := store.Write(p, strings.NewReader(p), int64(len(p))); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+		paths = append(paths, p)
+	}
+
+	results := store.(*s3.Storage).BatchStat(context.Background(), paths, s3.BatchStatOptions{Concurrency: 3})
+	if len(results) != len(paths) {
+		t.Fatalf("expected %d results, got %d", len(paths), len(results))
+	}
+	for i, r := range results {
+		if strings.HasSuffix(paths[i], "-missing") {
+			if !errors.Is(r.Err, services.ErrObjectNotExist) {
+				t.Errorf("%s: expected %v, got %v", paths[i], services.ErrObjectNotExist, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("%s: stat: %v", paths[i], r.Err)
+			continue
+		}
+		if r.Object.Path != paths[i] {
+			t.Errorf("expected %s at %d, got %s", paths[i], i, r.Object.Path)
+		}
+		if size := r.Object.MustGetContentLength(); size != int64(len(paths[i])) {
+			t.Errorf("%s: expected size %d, got %d", paths[i], len(paths[i]), size)
+		}
+	}
+
+	// Paths are not stated after ctx is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range store.(*s3.Storage).BatchStat(ctx, paths, s3.BatchStatOptions{}) {
+		if r.Err == nil {
+			t.Errorf("expected error after cancel, got %v", r.Object.Path)
+		}
+	}
+}
+
+func TestUpdatePrefix(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test", s3.WithEnableObjectLock())
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+	for _, p := range []string{"logs/a", "logs/b", "logs/dir/c", "other/d"} {
+		_, err = s.Write(p, strings.NewReader(p), int64(len(p)), s3.WithUserMetadata(map[string]string{"k": "v"}))
+		if err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+
+	until := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	var (
+		mu       sync.Mutex
+		progress []s3.BulkUpdateProgress
+	)
+	sum, err := s.UpdatePrefix(context.Background(), "logs/", s3.BulkUpdateOptions{
+		Tags:            map[string]string{"team": "infra"},
+		StorageClass:    s3.StorageClassStandardIa,
+		LegalHold:       awss3.ObjectLockLegalHoldStatusOn,
+		RetentionMode:   awss3.ObjectLockRetentionModeGovernance,
+		RetainUntilDate: until,
+		Concurrency:     2,
+		Progress: func(p s3.BulkUpdateProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatalf("update prefix: %v", err)
+	}
+	if sum.Total != 3 || sum.Failed != 0 {
+		t.Errorf("expected 3 objects updated, got %+v", sum)
+	}
+	if len(progress) != 3 {
+		t.Errorf("expected 3 progress reports, got %d", len(progress))
+	}
+	for _, p := range progress {
+		if p.Err != nil {
+			t.Errorf("%s: %v", p.Path, p.Err)
+		}
+	}
+
+	client := s.Client()
+	for _, p := range []string{"logs/a", "logs/b", "logs/dir/c", "other/d"} {
+		updated := strings.HasPrefix(p, "logs/")
+
+		head, err := client.HeadObject(&awss3.HeadObjectInput{Bucket: aws.String("test"), Key: aws.String(p)})
+		if err != nil {
+			t.Fatalf("head %s: %v", p, err)
+		}
+		if (aws.StringValue(head.StorageClass) == s3.StorageClassStandardIa) != updated {
+			t.Errorf("%s: unexpected storage class %q", p, aws.StringValue(head.StorageClass))
+		}
+		if aws.StringValue(head.Metadata["k"]) != "v" {
+			t.Errorf("%s: expected user metadata to be kept, got %v", p, head.Metadata)
+		}
+		if (aws.StringValue(head.ObjectLockLegalHoldStatus) == awss3.ObjectLockLegalHoldStatusOn) != updated {
+			t.Errorf("%s: unexpected legal hold %q", p, aws.StringValue(head.ObjectLockLegalHoldStatus))
+		}
+		if updated && (aws.StringValue(head.ObjectLockMode) != awss3.ObjectLockRetentionModeGovernance ||
+			!aws.TimeValue(head.ObjectLockRetainUntilDate).Equal(until)) {
+			t.Errorf("%s: unexpected retention %q until %v", p, aws.StringValue(head.ObjectLockMode), head.ObjectLockRetainUntilDate)
+		}
+
+		tags, err := client.GetObjectTagging(&awss3.GetObjectTaggingInput{Bucket: aws.String("test"), Key: aws.String(p)})
+		if err != nil {
+			t.Fatalf("get tagging %s: %v", p, err)
+		}
+		if tagged := len(tags.TagSet) == 1 && aws.StringValue(tags.TagSet[0].Value) == "infra"; tagged != updated {
+			t.Errorf("%s: unexpected tags %v", p, tags.TagSet)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		store, err := srv.NewStorager("test", s3.WithDisableTagging())
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		_, err = store.(*s3.Storage).UpdatePrefix(context.Background(), "logs/", s3.BulkUpdateOptions{
+			Tags: map[string]string{"team": "infra"},
+		})
+		if !errors.Is(err, services.ErrCapabilityInsufficient) {
+			t.Errorf("expected capability insufficient for tags, got %v", err)
+		}
+
+		// Object lock must be enabled explicitly.
+		store, err = srv.NewStorager("test")
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		_, err = store.(*s3.Storage).UpdatePrefix(context.Background(), "logs/", s3.BulkUpdateOptions{
+			LegalHold: awss3.ObjectLockLegalHoldStatusOn,
+		})
+		if !errors.Is(err, services.ErrCapabilityInsufficient) {
+			t.Errorf("expected capability insufficient for legal hold, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package s3test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestMetadataCache(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var heads, lists int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			atomic.AddInt64(&heads, 1)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			atomic.AddInt64(&lists, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test",
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		s3.WithMetadataCache(s3.NewMemoryMetadataCache(100)),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("a/b", strings.NewReader("x"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	list := func() (n int) {
+		it, err := store.List("a/", ps.WithListMode(typ.ListModePrefix))
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for {
+			if _, err = it.Next(); err != nil {
+				return n
+			}
+			n++
+		}
+	}
+
+	atomic.StoreInt64(&heads, 0)
+	atomic.StoreInt64(&lists, 0)
+	for i := 0; i < 3; i++ {
+		if _, err = store.Stat("a/b"); err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if n := list(); n != 1 {
+			t.Fatalf("expected 1 object, got %d", n)
+		}
+	}
+	if atomic.LoadInt64(&heads) != 1 || atomic.LoadInt64(&lists) != 1 {
+		t.Errorf("expected results to be cached, got %d heads and %d lists", heads, lists)
+	}
+
+	// Writes through the storage invalidate the cache.
+	if _, err = store.Write("a/c", strings.NewReader("xy"), 2); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n := list(); n != 2 || atomic.LoadInt64(&lists) != 2 {
+		t.Errorf("expected 2 objects listed by a new request, got %d with %d lists", n, lists)
+	}
+	if err = store.Delete("a/b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err = store.Stat("a/b"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected %v after delete, got %v", services.ErrObjectNotExist, err)
+	}
+}
+
+func TestMetadataCacheTTL(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_, err := srv.NewStorager("test", s3.WithMetadataCache(s3.NewMemoryMetadataCache(0)), s3.WithMetadataCacheTTL(-time.Second))
+	if !errors.As(err, &services.PairUnsupportedError{}) {
+		t.Errorf("expected pair unsupported, got %v", err)
+	}
+
+	cache := s3.NewMemoryMetadataCache(1)
+	cache.Set("a", 1, time.Minute)
+	cache.Set("b", 2, time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Errorf("expected a to be evicted")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("expected b to be expired")
+	}
+}
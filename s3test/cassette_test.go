@@ -0,0 +1,147 @@
+package s3test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cassette.json")
+	content := "hello, world"
+
+	srv := NewServer()
+	store, err := srv.NewStorager("test", s3.WithCassette(path), s3.WithCassetteMode(s3.CassetteModeRecord))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err = store.Read("abc", &buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	srv.Close()
+
+	// The server has been closed, all requests must be served by the cassette.
+	store, err = s3.NewStorager(append(srv.Pairs("test"), s3.WithCassette(path))...)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("replay write: %v", err)
+	}
+	buf.Reset()
+	if _, err = store.Read("abc", &buf); err != nil {
+		t.Fatalf("replay read: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("expected %q, got %q", content, buf.String())
+	}
+}
+
+func TestCassetteCustomCABundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Any valid certificate works as the bundle, as the fake server is served over plain HTTP.
+	tls := httptest.NewTLSServer(http.NotFoundHandler())
+	tls.Close()
+	bundle := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tls.Certificate().Raw}), 0644)
+	if err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	os.Setenv("AWS_CA_BUNDLE", bundle)
+	defer os.Unsetenv("AWS_CA_BUNDLE")
+
+	srv := NewServer()
+	defer srv.Close()
+	store, err := srv.NewStorager("test",
+		s3.WithCassette(filepath.Join(dir, "cassette.json")), s3.WithCassetteMode(s3.CassetteModeRecord))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("abc", strings.NewReader("abc"), 3); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestCassetteCustomerKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The SDK refuses to send customer keys over plain HTTP.
+	srv := NewTLSServer()
+	defer srv.Close()
+	bundle := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(bundle, srv.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	os.Setenv("AWS_CA_BUNDLE", bundle)
+	defer os.Unsetenv("AWS_CA_BUNDLE")
+
+	path := filepath.Join(dir, "cassette.json")
+	store, err := srv.NewStorager("test", s3.WithCassette(path), s3.WithCassetteMode(s3.CassetteModeRecord))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	key := bytes.Repeat([]byte{1}, 32)
+	content := "hello, world"
+	_, err = store.Write("abc", strings.NewReader(content), int64(len(content)),
+		s3.WithServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+		s3.WithServerSideEncryptionCustomerKey(key),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	err = store.(typ.Copier).Copy("abc", "copied",
+		s3.WithCopySourceServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+		s3.WithCopySourceServerSideEncryptionCustomerKey(key),
+	)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+
+	recorded, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read cassette: %v", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(key)
+	if bytes.Contains(recorded, []byte(encoded)) {
+		t.Errorf("expected customer keys stripped from the cassette")
+	}
+	for _, v := range []string{"X-Amz-Server-Side-Encryption-Customer-Key\"", "X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key\""} {
+		if bytes.Contains(recorded, []byte(v)) {
+			t.Errorf("expected header %s stripped from the cassette", v)
+		}
+	}
+	// The algorithm and the md5 of keys are kept, as they don't reveal the key.
+	if !bytes.Contains(recorded, []byte("X-Amz-Server-Side-Encryption-Customer-Algorithm")) {
+		t.Errorf("expected the customer algorithm recorded")
+	}
+}
@@ -0,0 +1,146 @@
+package s3test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestCompatibilityModeHeaders(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var lock sync.Mutex
+	var header http.Header
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			lock.Lock()
+			header = r.Header.Clone()
+			lock.Unlock()
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	cases := []struct {
+		mode     string
+		expected bool
+	}{
+		{s3.CompatibilityModeAWS, true},
+		{s3.CompatibilityModeR2, false},
+		{s3.CompatibilityModeB2, false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.mode, func(t *testing.T) {
+			store, err := srv.NewStorager("test",
+				ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+				s3.WithCompatibilityMode(tt.mode),
+			)
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+			// Additional checksums are not sent by the SDK, set one like a request handler of callers.
+			store.(*s3.Storage).Client().Handlers.Build.PushFront(func(r *request.Request) {
+				r.HTTPRequest.Header.Set("X-Amz-Checksum-Crc32", "AAAAAA==")
+			})
+
+			_, err = store.Write("abc", strings.NewReader("abc"), 3,
+				s3.WithGrantRead("id=abc"),
+				s3.WithExceptedBucketOwner("123456789012"),
+			)
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			for _, k := range []string{"X-Amz-Grant-Read", "X-Amz-Expected-Bucket-Owner", "X-Amz-Checksum-Crc32"} {
+				if got := header.Get(k) != ""; got != tt.expected {
+					t.Errorf("expected header %s sent %v, got %v", k, tt.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestListEmptyTruncatedPage(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	// The first page is truncated without any object, which is returned by Cloudflare R2 sometimes.
+	const token = "empty-page"
+	var lock sync.Mutex
+	served := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != http.MethodGet || q.Get("list-type") != "2" {
+			srv.ServeHTTP(w, r)
+			return
+		}
+
+		lock.Lock()
+		first := !served
+		served = true
+		lock.Unlock()
+		if first {
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>test</Name><Prefix></Prefix><KeyCount>0</KeyCount><MaxKeys>200</MaxKeys><IsTruncated>true</IsTruncated><NextContinuationToken>` + token + `</NextContinuationToken></ListBucketResult>`))
+			return
+		}
+		if q.Get("continuation-token") == token {
+			q.Del("continuation-token")
+			r.URL.RawQuery = q.Encode()
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test",
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		s3.WithCompatibilityMode(s3.CompatibilityModeR2),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"a", "b"} {
+		if _, err = store.Write(path, strings.NewReader(path), 1); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	for _, mode := range []typ.ListMode{typ.ListModePrefix, typ.ListModeDir} {
+		lock.Lock()
+		served = false
+		lock.Unlock()
+
+		it, err := store.List("", ps.WithListMode(mode))
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var paths []string
+		for {
+			o, err := it.Next()
+			if err == typ.IterateDone {
+				break
+			}
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			paths = append(paths, o.Path)
+		}
+		if strings.Join(paths, ",") != "a,b" {
+			t.Errorf("list mode %v: expected [a b], got %v", mode, paths)
+		}
+	}
+}
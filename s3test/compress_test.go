@@ -0,0 +1,51 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestWriteCompress(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := strings.Repeat("hello, world\n", 1024)
+	n, err := store.Write("abc", strings.NewReader(content), int64(len(content)), s3.WithCompress(s3.ContentEncodingGzip))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("expected %d bytes written, got %d", len(content), n)
+	}
+
+	var raw bytes.Buffer
+	if _, err = store.Read("abc", &raw); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if raw.Len() >= len(content) {
+		t.Errorf("expected compressed content, got %d bytes", raw.Len())
+	}
+
+	var buf bytes.Buffer
+	if _, err = store.Read("abc", &buf, s3.WithDecompress()); err != nil {
+		t.Fatalf("read decompress: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("unexpected decompressed content")
+	}
+
+	// Content too large to be buffered is rejected before anything is read.
+	_, err = store.Write("large", strings.NewReader(""), 1<<30, s3.WithCompress(s3.ContentEncodingGzip))
+	if !errors.Is(err, services.ErrRestrictionDissatisfied) {
+		t.Errorf("expected ErrRestrictionDissatisfied, got %v", err)
+	}
+}
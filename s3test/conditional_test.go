@@ -0,0 +1,137 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestReadIfModified(t *testing.T) {
+	store := setupStorager(t)
+	if _, err := store.Write("config", strings.NewReader("v1"), 2); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	// The first read has no etag to revalidate.
+	buf := &bytes.Buffer{}
+	result, err := s.ReadIfModified(context.Background(), "config", "", buf)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	etag, ok := result.Object.GetEtag()
+	if result.NotModified || !ok || buf.String() != "v1" || result.N != 2 {
+		t.Fatalf("unexpected result %+v with content %q", result, buf.String())
+	}
+
+	buf.Reset()
+	result, err = s.ReadIfModified(context.Background(), "config", etag, buf)
+	if err != nil {
+		t.Fatalf("revalidate: %v", err)
+	}
+	if !result.NotModified || result.Object != nil || buf.Len() != 0 {
+		t.Errorf("expected not modified, got %+v with content %q", result, buf.String())
+	}
+
+	if _, err = store.Write("config", strings.NewReader("v2"), 2); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	result, err = s.ReadIfModified(context.Background(), "config", etag, buf)
+	if err != nil {
+		t.Fatalf("revalidate: %v", err)
+	}
+	if result.NotModified || buf.String() != "v2" {
+		t.Errorf("expected modified, got %+v with content %q", result, buf.String())
+	}
+	if newEtag, _ := result.Object.GetEtag(); newEtag == etag {
+		t.Errorf("expected a new etag, got %s", newEtag)
+	}
+}
+
+func TestConditionalPairs(t *testing.T) {
+	store := setupStorager(t)
+	if _, err := store.Write("abc", strings.NewReader("v1"), 2); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	o, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	etag := o.MustGetEtag()
+	modified := o.MustGetLastModified()
+
+	cases := []struct {
+		name     string
+		pair     typ.Pair
+		expected error
+	}{
+		{"if match", s3.WithIfMatch(etag), nil},
+		{"if match changed", s3.WithIfMatch(`"changed"`), s3.ErrPreconditionFailed},
+		{"if none match", s3.WithIfNoneMatch(etag), s3.ErrObjectNotModified},
+		{"if none match changed", s3.WithIfNoneMatch(`"changed"`), nil},
+		{"if modified since", s3.WithIfModifiedSince(modified), s3.ErrObjectNotModified},
+		{"if modified since before", s3.WithIfModifiedSince(modified.Add(-time.Hour)), nil},
+		{"if unmodified since", s3.WithIfUnmodifiedSince(modified), nil},
+		{"if unmodified since before", s3.WithIfUnmodifiedSince(modified.Add(-time.Hour)), s3.ErrPreconditionFailed},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := store.Read("abc", &buf, tt.pair)
+			if tt.expected != nil {
+				if !errors.Is(err, tt.expected) {
+					t.Errorf("read: expected %v, got %v", tt.expected, err)
+				}
+				return
+			}
+			if err != nil || buf.String() != "v1" {
+				t.Errorf("read: unexpected %q, %v", buf.String(), err)
+			}
+		})
+	}
+
+	// Objects are created only if absent with if_none_match `*`.
+	_, err = store.Write("abc", strings.NewReader("v2"), 2, s3.WithIfNoneMatch("*"))
+	if !errors.Is(err, s3.ErrPreconditionFailed) {
+		t.Errorf("write existing: expected %v, got %v", s3.ErrPreconditionFailed, err)
+	}
+	if _, err = store.Write("def", strings.NewReader("v1"), 2, s3.WithIfNoneMatch("*")); err != nil {
+		t.Errorf("write absent: %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err = store.Read("abc", &buf); err != nil || buf.String() != "v1" {
+		t.Errorf("expected the object untouched, got %q, %v", buf.String(), err)
+	}
+}
+
+func TestWriteExpectedEtag(t *testing.T) {
+	store := setupStorager(t)
+	if _, err := store.Write("config", strings.NewReader("v1"), 2); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	o, err := store.Stat("config")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	stale := o.MustGetEtag()
+
+	// Etags are accepted with or without quotes.
+	if _, err = store.Write("config", strings.NewReader("v2"), 2, s3.WithExpectedEtag(strings.Trim(stale, `"`))); err != nil {
+		t.Fatalf("write with expected etag: %v", err)
+	}
+	_, err = store.Write("config", strings.NewReader("v3"), 2, s3.WithExpectedEtag(stale))
+	if !errors.Is(err, s3.ErrPreconditionFailed) {
+		t.Errorf("expected %v, got %v", s3.ErrPreconditionFailed, err)
+	}
+
+	var buf bytes.Buffer
+	if _, err = store.Read("config", &buf); err != nil || buf.String() != "v2" {
+		t.Errorf("expected v2, got %q, %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,229 @@
+package s3test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// openGCM opens sealed by AES-GCM as other S3 encryption clients do.
+func openGCM(key, nonce, sealed, additionalData []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+func TestClientSideEncryption(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	masterKey := bytes.Repeat([]byte{1}, 32)
+	store, err := srv.NewStorager("test", s3.WithClientSideEncryption(s3.ClientSideEncryption{MasterKey: masterKey}))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	plain, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := "hello, world"
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Run("read", func(t *testing.T) {
+		o, err := store.Stat("abc")
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if size := o.MustGetContentLength(); size != int64(len(content)) {
+			t.Errorf("expected size %d, got %d", len(content), size)
+		}
+		if metadata, ok := o.GetUserMetadata(); ok && len(metadata) > 0 {
+			t.Errorf("expected no user metadata, got %v", metadata)
+		}
+
+		var buf bytes.Buffer
+		if _, err = store.Read("abc", &buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if buf.String() != content {
+			t.Errorf("expected %q, got %q", content, buf.String())
+		}
+	})
+
+	t.Run("ciphertext", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := plain.Read("abc", &buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if buf.Len() != len(content)+16 || strings.Contains(buf.String(), content) {
+			t.Errorf("expected encrypted content, got %q", buf.String())
+		}
+	})
+
+	// Objects are sealed as one GCM message like other S3 encryption clients.
+	t.Run("compatible", func(t *testing.T) {
+		output, err := plain.(*s3.Storage).Client().GetObject(&awss3.GetObjectInput{
+			Bucket: aws.String("test"),
+			Key:    aws.String("abc"),
+		})
+		if err != nil {
+			t.Fatalf("get object: %v", err)
+		}
+		defer output.Body.Close()
+		sealed, err := ioutil.ReadAll(output.Body)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		metadata := make(map[string]string)
+		for k, v := range output.Metadata {
+			metadata[strings.ToLower(k)] = aws.StringValue(v)
+		}
+		if alg := metadata["x-amz-cek-alg"]; alg != "AES/GCM/NoPadding" {
+			t.Fatalf("expected AES/GCM/NoPadding, got %q", alg)
+		}
+
+		wrapped, _ := base64.StdEncoding.DecodeString(metadata["x-amz-key-v2"])
+		iv, _ := base64.StdEncoding.DecodeString(metadata["x-amz-iv"])
+		key, err := openGCM(masterKey, wrapped[:12], wrapped[12:], []byte("AES/GCM/NoPadding"))
+		if err != nil {
+			t.Fatalf("unwrap data key: %v", err)
+		}
+		got, err := openGCM(key, iv, sealed, nil)
+		if err != nil {
+			t.Fatalf("decrypt: %v", err)
+		}
+		if string(got) != content {
+			t.Errorf("expected %q, got %q", content, got)
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		streaming, err := srv.NewStorager("test",
+			s3.WithClientSideEncryption(s3.ClientSideEncryption{MasterKey: masterKey, Streaming: true}))
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		if _, err = streaming.Write("stream", strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		output, err := streaming.(*s3.Storage).Client().HeadObject(&awss3.HeadObjectInput{
+			Bucket: aws.String("test"),
+			Key:    aws.String("stream"),
+		})
+		if err != nil {
+			t.Fatalf("head object: %v", err)
+		}
+		for k, v := range output.Metadata {
+			if strings.EqualFold(k, "x-amz-cek-alg") && aws.StringValue(v) != "AES/GCM/STREAM" {
+				t.Errorf("expected AES/GCM/STREAM, got %q", aws.StringValue(v))
+			}
+		}
+
+		// Objects are read by the algorithm in the envelope.
+		for _, v := range []typ.Storager{streaming, store} {
+			var buf bytes.Buffer
+			if _, err = v.Read("stream", &buf); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if buf.String() != content {
+				t.Errorf("expected %q, got %q", content, buf.String())
+			}
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		store, err := srv.NewStorager("test",
+			s3.WithClientSideEncryption(s3.ClientSideEncryption{MasterKey: bytes.Repeat([]byte{2}, 32)}))
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err = store.Read("abc", &buf); !errors.Is(err, s3.ErrObjectDecryptionFailed) {
+			t.Errorf("expected %v, got %v", s3.ErrObjectDecryptionFailed, err)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		m := store.(typ.Multiparter)
+		o, err := m.CreateMultipart("def")
+		if err != nil {
+			t.Fatalf("create multipart: %v", err)
+		}
+
+		var parts []*typ.Part
+		for i, v := range []string{"hello, ", "world"} {
+			_, part, err := m.WriteMultipart(o, strings.NewReader(v), int64(len(v)), i)
+			if err != nil {
+				t.Fatalf("write multipart: %v", err)
+			}
+			parts = append(parts, part)
+		}
+		if err = m.CompleteMultipart(o, parts); err != nil {
+			t.Fatalf("complete multipart: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if _, err = store.Read("def", &buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if buf.String() != content {
+			t.Errorf("expected %q, got %q", content, buf.String())
+		}
+	})
+	t.Run("multipart chunks", func(t *testing.T) {
+		m := store.(typ.Multiparter)
+		o, err := m.CreateMultipart("ghi")
+		if err != nil {
+			t.Fatalf("create multipart: %v", err)
+		}
+
+		// Parts are not aligned with the chunks they're sealed in.
+		var expected bytes.Buffer
+		var parts []*typ.Part
+		for i, size := range []int{100000, 70000, 5} {
+			v := bytes.Repeat([]byte{byte('a' + i)}, size)
+			expected.Write(v)
+			_, part, err := m.WriteMultipart(o, bytes.NewReader(v), int64(size), i)
+			if err != nil {
+				t.Fatalf("write multipart: %v", err)
+			}
+			parts = append(parts, part)
+		}
+		if err = m.CompleteMultipart(o, parts); err != nil {
+			t.Fatalf("complete multipart: %v", err)
+		}
+
+		if o, err = store.Stat("ghi"); err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if size := o.MustGetContentLength(); size != int64(expected.Len()) {
+			t.Errorf("expected size %d, got %d", expected.Len(), size)
+		}
+		var buf bytes.Buffer
+		if _, err = store.Read("ghi", &buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected.Bytes()) {
+			t.Errorf("unexpected content of %d bytes", buf.Len())
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestDeletePrefix(t *testing.T) {
+	store := setupStorager(t)
+	s := store.(*s3.Storage)
+
+	// More than a batch of objects.
+	var paths []string
+	for i := 0; i < 1500; i++ {
+		paths = append(paths, fmt.Sprintf("tmp/%04d", i))
+	}
+	paths = append(paths, "tmp/dir/a", "tmp2/b", "keep/c")
+	for _, p := range paths {
+		if _, err := s.Write(p, strings.NewReader(p), int64(len(p))); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	exists := func(p string) bool {
+		_, err := s.Stat(p)
+		return err == nil
+	}
+
+	var progress []s3.DeletePrefixProgress
+	opt := s3.DeletePrefixOptions{
+		DryRun:   true,
+		Progress: func(p s3.DeletePrefixProgress) { progress = append(progress, p) },
+	}
+	sum, err := s.DeletePrefix(context.Background(), "tmp/", opt)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if sum.Deleted != 1501 || sum.Failed != 0 {
+		t.Errorf("expected 1501 objects to be deleted, got %+v", sum)
+	}
+	if len(progress) != 2 || len(progress[0].Paths) != 1000 || progress[1].Deleted != 1501 {
+		t.Errorf("expected progress of 2 batches, got %d", len(progress))
+	}
+	if progress[0].Paths[0] != "tmp/0000" {
+		t.Errorf("expected relative paths, got %s", progress[0].Paths[0])
+	}
+	if !exists("tmp/0000") || !exists("tmp/dir/a") {
+		t.Errorf("expected objects to be kept in dry run")
+	}
+
+	progress = nil
+	opt.DryRun = false
+	if sum, err = s.DeletePrefix(context.Background(), "tmp/", opt); err != nil {
+		t.Fatalf("delete prefix: %v", err)
+	}
+	if sum.Deleted != 1501 || sum.Failed != 0 || len(progress) != 2 {
+		t.Errorf("expected 1501 objects deleted in 2 batches, got %+v in %d", sum, len(progress))
+	}
+	for _, p := range paths {
+		if expected := !strings.HasPrefix(p, "tmp/"); exists(p) != expected {
+			t.Errorf("%s: expected exists %v", p, expected)
+		}
+	}
+
+	if _, err = s.DeletePrefix(context.Background(), "keep/", s3.DeletePrefixOptions{AllVersions: true}); !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected capability insufficient, got %v", err)
+	}
+	if !exists("keep/c") {
+		t.Errorf("expected keep/c to be kept")
+	}
+}
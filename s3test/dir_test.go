@@ -0,0 +1,284 @@
+package s3test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestCreateDirSkipIfExists(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var puts int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			atomic.AddInt64(&puts, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test",
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		s3.WithEnableVirtualDir(),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	d := store.(typ.Direr)
+
+	for i := 0; i < 2; i++ {
+		o, err := d.CreateDir("a", s3.WithSkipIfExists())
+		if err != nil {
+			t.Fatalf("create dir: %v", err)
+		}
+		if !o.Mode.IsDir() {
+			t.Errorf("expected dir, got mode %v", o.Mode)
+		}
+	}
+	if n := atomic.LoadInt64(&puts); n != 1 {
+		t.Errorf("expected the placeholder written once, got %d writes", n)
+	}
+
+	// A non-empty prefix is a dir already.
+	if _, err = store.Write("b/c", strings.NewReader("c"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	atomic.StoreInt64(&puts, 0)
+	if _, err = d.CreateDir("b", s3.WithSkipIfExists()); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if n := atomic.LoadInt64(&puts); n != 0 {
+		t.Errorf("expected no placeholder written for a non-empty prefix, got %d writes", n)
+	}
+
+	// The placeholder is always written without skip_if_exists.
+	if _, err = d.CreateDir("a"); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if n := atomic.LoadInt64(&puts); n != 1 {
+		t.Errorf("expected the placeholder written, got %d writes", n)
+	}
+}
+
+func TestCreateDirParents(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var puts int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			atomic.AddInt64(&puts, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test",
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		s3.WithEnableVirtualDir(),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	d := store.(typ.Direr)
+
+	o, err := d.CreateDir("a/b/c", s3.WithCreateParents())
+	if err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if o.Path != "a/b/c" || !o.Mode.IsDir() {
+		t.Errorf("expected dir a/b/c, got %s with mode %v", o.Path, o.Mode)
+	}
+
+	output, err := store.(*s3.Storage).Client().ListObjectsV2(&awss3.ListObjectsV2Input{Bucket: aws.String("test")})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var keys []string
+	for _, v := range output.Contents {
+		keys = append(keys, aws.StringValue(v.Key))
+	}
+	if expected := []string{"a/", "a/b/", "a/b/c/"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected placeholders %v, got %v", expected, keys)
+	}
+
+	// Existing parents are skipped along with the dir itself.
+	atomic.StoreInt64(&puts, 0)
+	if _, err = d.CreateDir("a/b/d", s3.WithCreateParents(), s3.WithSkipIfExists()); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if n := atomic.LoadInt64(&puts); n != 1 {
+		t.Errorf("expected only a/b/d/ to be written, got %d writes", n)
+	}
+}
+
+func TestDeleteDirRecursive(t *testing.T) {
+	for _, marker := range []string{s3.DirMarkerSlash, s3.DirMarkerFolder} {
+		t.Run(marker, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+
+			store, err := srv.NewStorager("test", s3.WithEnableVirtualDir(), s3.WithDirMarker(marker))
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+			keys := func() (keys []string) {
+				output, err := store.(*s3.Storage).Client().ListObjectsV2(&awss3.ListObjectsV2Input{Bucket: aws.String("test")})
+				if err != nil {
+					t.Fatalf("list: %v", err)
+				}
+				for _, v := range output.Contents {
+					keys = append(keys, aws.StringValue(v.Key))
+				}
+				return
+			}
+
+			if _, err = store.(typ.Direr).CreateDir("a"); err != nil {
+				t.Fatalf("create dir: %v", err)
+			}
+			for _, p := range []string{"a/x", "a/b/y", "ab/z"} {
+				if _, err = store.Write(p, strings.NewReader(p), int64(len(p))); err != nil {
+					t.Fatalf("write %s: %v", p, err)
+				}
+			}
+
+			// Only the placeholder is deleted without recursive.
+			if err = store.Delete("a", ps.WithObjectMode(typ.ModeDir)); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if expected := []string{"a/b/y", "a/x", "ab/z"}; !reflect.DeepEqual(keys(), expected) {
+				t.Errorf("expected %v, got %v", expected, keys())
+			}
+
+			if _, err = store.(typ.Direr).CreateDir("a"); err != nil {
+				t.Fatalf("create dir: %v", err)
+			}
+			if err = store.Delete("a", ps.WithObjectMode(typ.ModeDir), s3.WithRecursive()); err != nil {
+				t.Fatalf("delete recursive: %v", err)
+			}
+			if expected := []string{"ab/z"}; !reflect.DeepEqual(keys(), expected) {
+				t.Errorf("expected %v, got %v", expected, keys())
+			}
+		})
+	}
+}
+
+func TestDirMarker(t *testing.T) {
+	cases := []struct {
+		marker string
+		keys   []string
+	}{
+		{s3.DirMarkerSlash, []string{"a/"}},
+		{s3.DirMarkerFolder, []string{"a_$folder$"}},
+		{s3.DirMarkerNone, nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.marker, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+
+			store, err := srv.NewStorager("test", s3.WithEnableVirtualDir(), s3.WithDirMarker(tt.marker))
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+			keys := func() (keys []string) {
+				output, err := store.(*s3.Storage).Client().ListObjectsV2(&awss3.ListObjectsV2Input{Bucket: aws.String("test")})
+				if err != nil {
+					t.Fatalf("list: %v", err)
+				}
+				for _, v := range output.Contents {
+					keys = append(keys, aws.StringValue(v.Key))
+				}
+				return
+			}
+			dirs := func() (paths []string) {
+				it, err := store.List("", ps.WithListMode(typ.ListModeDir))
+				if err != nil {
+					t.Fatalf("list: %v", err)
+				}
+				for {
+					o, err := it.Next()
+					if err == typ.IterateDone {
+						break
+					}
+					if err != nil {
+						t.Fatalf("next: %v", err)
+					}
+					if !o.Mode.IsDir() {
+						t.Errorf("expected dir, got %s", o.Path)
+					}
+					paths = append(paths, o.Path)
+				}
+				return
+			}
+
+			o, err := store.(typ.Direr).CreateDir("a")
+			if err != nil {
+				t.Fatalf("create dir: %v", err)
+			}
+			if !o.Mode.IsDir() {
+				t.Errorf("expected dir, got mode %v", o.Mode)
+			}
+			if got := keys(); !reflect.DeepEqual(got, tt.keys) {
+				t.Errorf("expected markers %v, got %v", tt.keys, got)
+			}
+
+			_, err = store.Stat("a", ps.WithObjectMode(typ.ModeDir))
+			if tt.marker == s3.DirMarkerNone {
+				// Dirs without markers only exist while there are objects under them.
+				if !errors.Is(err, services.ErrObjectNotExist) {
+					t.Errorf("expected object not exist for an empty dir, got %v", err)
+				}
+				if got := dirs(); len(got) != 0 {
+					t.Errorf("expected no dirs, got %v", got)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("stat dir: %v", err)
+				}
+				if expected := []string{"a/"}; !reflect.DeepEqual(dirs(), expected) {
+					t.Errorf("expected %v, got %v", expected, dirs())
+				}
+			}
+
+			// The dir is listed once even if both the marker and objects under it exist.
+			if _, err = store.Write("a/x", strings.NewReader("x"), 1); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if _, err = store.Stat("a", ps.WithObjectMode(typ.ModeDir)); err != nil {
+				t.Errorf("stat dir: %v", err)
+			}
+			if expected := []string{"a/"}; !reflect.DeepEqual(dirs(), expected) {
+				t.Errorf("expected %v, got %v", expected, dirs())
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		srv := NewServer()
+		defer srv.Close()
+
+		_, err := srv.NewStorager("test", s3.WithEnableVirtualDir(), s3.WithDirMarker("dot"))
+		var e services.PairUnsupportedError
+		if !errors.As(err, &e) {
+			t.Errorf("expected pair unsupported error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+)
+
+func TestDownloadFile(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	content := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	if _, err = s.Write("abc", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	o, err := s.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	etag := o.MustGetEtag()
+
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name string
+		// part and partEtag are the partial download left by a previous attempt.
+		part     []byte
+		partEtag string
+		// ranges are the ranges of GET requests sent.
+		ranges []string
+	}{
+		{"fresh", nil, "", []string{"bytes=0-"}},
+		{"resume", content[:1024], etag, []string{"bytes=1024-"}},
+		{"downloaded", content, etag, nil},
+		{"changed", []byte("stale content"), `"stale"`, []string{"bytes=0-"}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			localPath := filepath.Join(dir, tt.name)
+			if tt.part != nil {
+				if err := ioutil.WriteFile(localPath+".part", tt.part, 0644); err != nil {
+					t.Fatalf("write part: %v", err)
+				}
+				if err := ioutil.WriteFile(localPath+".part.etag", []byte(tt.partEtag), 0644); err != nil {
+					t.Fatalf("write etag: %v", err)
+				}
+			}
+
+			mu.Lock()
+			ranges = nil
+			mu.Unlock()
+			if err := s.DownloadFile(context.Background(), "abc", localPath); err != nil {
+				t.Fatalf("download: %v", err)
+			}
+
+			b, err := ioutil.ReadFile(localPath)
+			if err != nil {
+				t.Fatalf("read file: %v", err)
+			}
+			if !bytes.Equal(b, content) {
+				t.Errorf("content mismatch, got %d bytes", len(b))
+			}
+			for _, p := range []string{localPath + ".part", localPath + ".part.etag"} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed, got %v", p, err)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(ranges, tt.ranges) {
+				t.Errorf("expected GET requests with ranges %q, got %q", tt.ranges, ranges)
+			}
+		})
+	}
+}
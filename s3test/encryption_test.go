@@ -0,0 +1,172 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestRequireEncryption(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	content := "hello, world"
+	cases := []struct {
+		name         string
+		storagePairs []typ.Pair
+		writePairs   []typ.Pair
+		expected     error
+	}{
+		{"unencrypted", nil, nil, s3.ErrEncryptionRequired},
+		{"server-side", nil, []typ.Pair{s3.WithServerSideEncryption(s3.ServerSideEncryptionAes256)}, nil},
+		{"client-side", []typ.Pair{s3.WithClientSideEncryption(s3.ClientSideEncryption{MasterKey: bytes.Repeat([]byte{1}, 32)})}, nil, nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := srv.NewStorager("test", append(tt.storagePairs, s3.WithRequireEncryption())...)
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+
+			_, err = store.Write("abc", strings.NewReader(content), int64(len(content)), tt.writePairs...)
+			if tt.expected == nil && err != nil {
+				t.Errorf("write: %v", err)
+			}
+			if tt.expected != nil && !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestServerSideEncryptionCustomerKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The SDK refuses to send customer keys over plain HTTP.
+	srv := NewTLSServer()
+	defer srv.Close()
+	bundle := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(bundle, srv.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	os.Setenv("AWS_CA_BUNDLE", bundle)
+	defer os.Unsetenv("AWS_CA_BUNDLE")
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	client := srv.Client()
+
+	key := bytes.Repeat([]byte{1}, 32)
+	keyPairs := []typ.Pair{
+		s3.WithServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+		s3.WithServerSideEncryptionCustomerKey(key),
+	}
+
+	content := "hello, world"
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content)), keyPairs...); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Run("read", func(t *testing.T) {
+		if _, err := store.Read("abc", ioutil.Discard); err == nil {
+			t.Errorf("expected read without the key to fail")
+		}
+
+		var buf bytes.Buffer
+		if _, err := store.Read("abc", &buf, keyPairs...); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if buf.String() != content {
+			t.Errorf("expected %q, got %q", content, buf.String())
+		}
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		copier := store.(typ.Copier)
+		if err := copier.Copy("abc", "copied"); err == nil {
+			t.Errorf("expected copy without the source key to fail")
+		}
+
+		err := copier.Copy("abc", "copied",
+			s3.WithCopySourceServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+			s3.WithCopySourceServerSideEncryptionCustomerKey(key),
+		)
+		if err != nil {
+			t.Fatalf("copy: %v", err)
+		}
+
+		// The copy is not encrypted with a customer key, so it could be read without one.
+		var buf bytes.Buffer
+		if _, err := store.Read("copied", &buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if buf.String() != content {
+			t.Errorf("expected %q, got %q", content, buf.String())
+		}
+	})
+
+	t.Run("presigned read", func(t *testing.T) {
+		req, err := store.(typ.StorageHTTPSigner).QuerySignHTTPRead("abc", time.Minute, keyPairs...)
+		if err != nil {
+			t.Fatalf("query sign http read: %v", err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("do: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(body) != content {
+			t.Errorf("expected %q, got %d %q", content, resp.StatusCode, body)
+		}
+	})
+
+	t.Run("presigned write multipart", func(t *testing.T) {
+		o, err := store.(typ.Multiparter).CreateMultipart("def", keyPairs...)
+		if err != nil {
+			t.Fatalf("create multipart: %v", err)
+		}
+		signer := store.(typ.MultipartHTTPSigner)
+
+		for _, tt := range []struct {
+			name   string
+			pairs  []typ.Pair
+			status int
+		}{
+			{"without key", nil, http.StatusBadRequest},
+			{"with key", keyPairs, http.StatusOK},
+		} {
+			req, err := signer.QuerySignHTTPWriteMultipart(o, int64(len(content)), 0, time.Minute, tt.pairs...)
+			if err != nil {
+				t.Fatalf("%s: query sign http write multipart: %v", tt.name, err)
+			}
+			req.Body = ioutil.NopCloser(strings.NewReader(content))
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s: do: %v", tt.name, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, resp.StatusCode)
+			}
+		}
+	})
+}
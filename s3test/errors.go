@@ -0,0 +1,58 @@
+package s3test
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+// apiError is the error returned in the S3 error response.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+var (
+	errAccessDenied            = apiError{http.StatusForbidden, "AccessDenied", "Access Denied"}
+	errBadDigest               = apiError{http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received."}
+	errBucketAlreadyOwnedByYou = apiError{http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it."}
+	errBucketNotEmpty          = apiError{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty."}
+	errIncompleteBody          = apiError{http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header."}
+	errInvalidArgument         = apiError{http.StatusBadRequest, "InvalidArgument", "Invalid Argument."}
+	errInvalidObjectState      = apiError{http.StatusForbidden, "InvalidObjectState", "The operation is not valid for the object's storage class."}
+	errInvalidPart             = apiError{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found."}
+	errInvalidPartOrder        = apiError{http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order."}
+	errInvalidRange            = apiError{http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable."}
+	errInvalidRequest          = apiError{http.StatusBadRequest, "InvalidRequest", "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object."}
+	errMalformedPolicy         = apiError{http.StatusBadRequest, "MalformedPolicy", "Policies must be valid JSON."}
+	errMalformedXML            = apiError{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed."}
+	errNoSuchBucket            = apiError{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist."}
+	errNoSuchBucketPolicy      = apiError{http.StatusNotFound, "NoSuchBucketPolicy", "The bucket policy does not exist."}
+	errNoSuchKey               = apiError{http.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+	errNoSuchLifecycle         = apiError{http.StatusNotFound, "NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist."}
+	errNoSuchUpload            = apiError{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist."}
+	errNotImplemented          = apiError{http.StatusNotImplemented, "NotImplemented", "The requested operation is not implemented by s3test."}
+	errPreconditionFailed      = apiError{http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the preconditions you specified did not hold."}
+	errRestoreInProgress       = apiError{http.StatusConflict, "RestoreAlreadyInProgress", "Object restore is already in progress."}
+	errNoEncryptionConfig      = apiError{http.StatusNotFound, "ServerSideEncryptionConfigurationNotFoundError", "The server side encryption configuration was not found."}
+)
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, e apiError) {
+	writeXML(w, e.status, errorResponse{Code: e.code, Message: e.message})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	// Bodies of HEAD requests will be discarded by the server.
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,84 @@
+package s3test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestExtractArchive(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	large := strings.Repeat("x", 9*1024*1024)
+	files := []struct {
+		name    string
+		content string
+	}{{"a", "a"}, {"dir/b", "b"}, {"../escape", "c"}, {"large", large}}
+	expected := map[string]string{"a": "a", "dir/b": "b", "escape": "c", "large": large}
+
+	tarBuf := &bytes.Buffer{}
+	gw := gzip.NewWriter(tarBuf)
+	tw := tar.NewWriter(gw)
+	_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755})
+	for _, f := range files {
+		_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: f.name, Size: int64(len(f.content)), Mode: 0600})
+		_, _ = tw.Write([]byte(f.content))
+	}
+	tw.Close()
+	gw.Close()
+
+	zipBuf := &bytes.Buffer{}
+	zw := zip.NewWriter(zipBuf)
+	_, _ = zw.Create("dir/")
+	for _, f := range files {
+		w, _ := zw.Create(f.name)
+		_, _ = w.Write([]byte(f.content))
+	}
+	zw.Close()
+
+	for format, archive := range map[string]*bytes.Buffer{"tar.gz": tarBuf, "zip": zipBuf} {
+		prefix := "extracted/" + format + "/"
+		err = s.ExtractArchive(context.Background(), archive, prefix, s3.ExtractArchiveOptions{PreserveMode: true})
+		if err != nil {
+			t.Fatalf("%s: extract: %v", format, err)
+		}
+
+		for path, content := range expected {
+			buf := &bytes.Buffer{}
+			if _, err = store.Read(prefix+path, buf); err != nil || buf.String() != content {
+				t.Errorf("%s: unexpected content of %s: %v", format, path, err)
+			}
+		}
+		if _, err = store.Stat("extracted/escape"); err == nil {
+			t.Errorf("%s: expected entries not to escape the prefix", format)
+		}
+	}
+
+	o, err := store.Stat("extracted/tar.gz/a")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if metadata, _ := o.GetUserMetadata(); metadata["mode"] != "384" {
+		t.Errorf("expected mode 0600 preserved, got %v", metadata)
+	}
+
+	err = s.ExtractArchive(context.Background(), strings.NewReader("not an archive"), "extracted/", s3.ExtractArchiveOptions{})
+	if !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected %v, got %v", services.ErrCapabilityInsufficient, err)
+	}
+}
@@ -0,0 +1,68 @@
+package s3test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+)
+
+func TestFailoverEndpoints(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	// The primary endpoint is down, which could not be connected.
+	down := httptest.NewServer(http.NotFoundHandler())
+	downEndpoint := "http:" + strings.TrimPrefix(down.URL, "http://")
+	down.Close()
+
+	var served int64
+	failover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&served, 1)
+		srv.ServeHTTP(w, r)
+	}))
+	defer failover.Close()
+
+	var retries int64
+	store, err := srv.NewStorager("test",
+		ps.WithEndpoint(downEndpoint),
+		s3.WithFailoverEndpoints([]string{"http:" + strings.TrimPrefix(failover.URL, "http://")}),
+		s3.WithRetryCallback(func(s3.RetryEvent) { atomic.AddInt64(&retries, 1) }),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	if _, err = store.Write("abc", strings.NewReader("hello"), 5); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n := atomic.LoadInt64(&retries); n != 1 {
+		t.Errorf("expected the write retried on the failover endpoint, got %d retries", n)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = store.Read("abc", buf); err != nil || buf.String() != "hello" {
+		t.Fatalf("read: %v", err)
+	}
+	// Unseekable content is spooled, so that it could be sent again to the failover endpoint.
+	if _, err = store.Write("def", io.MultiReader(strings.NewReader("world")), 5); err != nil {
+		t.Fatalf("write unseekable: %v", err)
+	}
+	buf.Reset()
+	if _, err = store.Read("def", buf); err != nil || buf.String() != "world" {
+		t.Fatalf("read: %v", err)
+	}
+	// Requests stay on the failover endpoint while the primary one is down.
+	if n := atomic.LoadInt64(&retries); n != 1 {
+		t.Errorf("expected no requests sent to the primary endpoint again, got %d retries", n)
+	}
+	if atomic.LoadInt64(&served) < 2 {
+		t.Errorf("expected requests to be served by the failover endpoint, got %d", served)
+	}
+}
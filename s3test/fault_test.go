@@ -0,0 +1,57 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestFaultPolicy(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	content := "hello, world"
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Run("error", func(t *testing.T) {
+		retries := 0
+		store, err := srv.NewStorager("test",
+			s3.WithFaultPolicy(s3.FaultPolicy{ErrorRate: 1, Operations: []string{"HeadObject"}}),
+			s3.WithRetryCallback(func(s3.RetryEvent) { retries++ }),
+		)
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+
+		if _, err = store.Stat("abc"); !errors.Is(err, s3.ErrRateLimited) {
+			t.Errorf("expected %v, got %v", s3.ErrRateLimited, err)
+		}
+		if retries == 0 {
+			t.Errorf("expected injected errors to be retried")
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		store, err := srv.NewStorager("test", s3.WithFaultPolicy(s3.FaultPolicy{TruncateRate: 1}))
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+
+		var buf bytes.Buffer
+		if _, err = store.Read("abc", &buf); err == nil {
+			t.Errorf("expected error while reading truncated body")
+		}
+		if buf.Len() >= len(content) {
+			t.Errorf("expected less than %d bytes, got %d", len(content), buf.Len())
+		}
+	})
+}
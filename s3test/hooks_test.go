@@ -0,0 +1,42 @@
+package s3test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestSlowOperationCallback(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	var events []s3.SlowOperationEvent
+	store, err := srv.NewStorager("test",
+		s3.WithSlowOperationThreshold(time.Nanosecond),
+		s3.WithSlowOperationCallback(func(e s3.SlowOperationEvent) { events = append(events, e) }),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := "hello, world"
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err = store.Read("abc", &bytes.Buffer{}); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v", events)
+	}
+	for i, op := range []string{"write", "read"} {
+		e := events[i]
+		if e.Operation != op || e.Path != "abc" || e.Bytes != int64(len(content)) || e.Duration <= 0 {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestContentIntegrityMode(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	content := "hello, world"
+	cse := s3.WithClientSideEncryption(s3.ClientSideEncryption{MasterKey: bytes.Repeat([]byte{1}, 32)})
+	compressed := s3.WithDefaultStoragePairs(s3.DefaultStoragePairs{
+		Write: []typ.Pair{s3.WithCompress(s3.ContentEncodingGzip)},
+		Read:  []typ.Pair{s3.WithDecompress()},
+	})
+	cases := []struct {
+		name     string
+		pairs    []typ.Pair
+		reader   io.Reader
+		expected error
+	}{
+		{"strict seekable", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeStrict)}, strings.NewReader(content), nil},
+		{"strict unseekable", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeStrict)}, struct{ io.Reader }{strings.NewReader(content)}, s3.ErrContentUnverifiable},
+		{"spool unseekable", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeSpool)}, struct{ io.Reader }{strings.NewReader(content)}, nil},
+		// The sealed content of seekable content could be checksummed in advance.
+		{"strict seekable with cse", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeStrict), cse}, strings.NewReader(content), nil},
+		{"strict compressed with cse", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeStrict), cse, compressed}, strings.NewReader(content), nil},
+		{"strict unseekable with cse", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeStrict), cse}, struct{ io.Reader }{strings.NewReader(content)}, s3.ErrContentUnverifiable},
+		{"spool unseekable with cse", []typ.Pair{s3.WithContentIntegrityMode(s3.ContentIntegrityModeSpool), cse}, struct{ io.Reader }{strings.NewReader(content)}, nil},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := srv.NewStorager("test", tt.pairs...)
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+
+			_, err = store.Write("abc", tt.reader, int64(len(content)))
+			if tt.expected != nil {
+				if !errors.Is(err, tt.expected) {
+					t.Errorf("expected %v, got %v", tt.expected, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			var buf bytes.Buffer
+			if _, err = store.Read("abc", &buf); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if buf.String() != content {
+				t.Errorf("expected %q, got %q", content, buf.String())
+			}
+		})
+	}
+}
+
+func TestContentIntegrityModeInvalid(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	_, err := srv.NewStorager("test", s3.WithContentIntegrityMode("lenient"))
+	if err == nil {
+		t.Errorf("expected error for invalid content integrity mode")
+	}
+}
+
+func TestWriteSpoolThreshold(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	// Fail the first PutObject with a retryable error after the body has been drained, and
+	// record the bodies of all attempts.
+	var bodies []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			srv.ServeHTTP(w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	content := "hello, world"
+	cases := []struct {
+		name      string
+		reader    io.Reader
+		threshold int64
+	}{
+		// io.MultiReader hides Seek of the strings.Reader, so it must be spooled.
+		{"spooled", io.MultiReader(strings.NewReader(content)), 1024},
+		{"seekable", strings.NewReader(content), 0},
+		// Only the content to be written is sent from a seekable reader.
+		{"seekable with more content", strings.NewReader(content + "extra"), 0},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies = nil
+			store, err := srv.NewStorager("test",
+				ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+				s3.WithWriteSpoolThreshold(tt.threshold),
+			)
+			if err != nil {
+				t.Fatalf("new storager: %v", err)
+			}
+
+			if _, err = store.Write("abc", tt.reader, int64(len(content))); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if len(bodies) != 2 || bodies[0] != content || bodies[1] != content {
+				t.Fatalf("expected the same content sent twice, got %q", bodies)
+			}
+
+			var buf bytes.Buffer
+			if _, err = store.Read("abc", &buf); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if buf.String() != content {
+				t.Errorf("expected %q, got %q", content, buf.String())
+			}
+		})
+	}
+}
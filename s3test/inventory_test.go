@@ -0,0 +1,106 @@
+package s3test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestInventory(t *testing.T) {
+	store := setupStorager(t)
+	s := store.(*s3.Storage)
+
+	write := func(path string, content []byte) {
+		if _, err := s.Write(path, bytes.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	writeManifest := func(path, format string, files ...string) {
+		var entries []map[string]interface{}
+		for _, v := range files {
+			entries = append(entries, map[string]interface{}{"key": v, "size": 0, "MD5checksum": ""})
+		}
+		b, _ := json.Marshal(map[string]interface{}{
+			"sourceBucket":      "src",
+			"destinationBucket": "arn:aws:s3:::test",
+			"fileFormat":        format,
+			"fileSchema":        "Bucket, Key, Size, LastModifiedDate, ETag, StorageClass",
+			"files":             entries,
+		})
+		write(path, b)
+	}
+	writeData := func(path string, records ...string) {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		for _, v := range records {
+			fmt.Fprintln(gw, v)
+		}
+		_ = gw.Close()
+		write(path, buf.Bytes())
+	}
+
+	// The first file spans more than a page of entries.
+	var records []string
+	for i := 0; i < 1200; i++ {
+		records = append(records, fmt.Sprintf(`"src","obj-%04d","%d","2021-10-01T12:00:00.000Z","etag-%d","STANDARD"`, i, i, i))
+	}
+	writeData("inv/src/cfg/data/1.csv.gz", records...)
+	writeData("inv/src/cfg/data/2.csv.gz", `"src","dir/a%20b","5","2021-10-01T12:00:00.000Z","etag","GLACIER"`)
+	writeManifest("inv/src/cfg/2021-10-01T00-00Z/manifest.json", "CSV", "inv/src/cfg/data/1.csv.gz")
+	writeManifest("inv/src/cfg/2021-10-02T00-00Z/manifest.json", "CSV", "inv/src/cfg/data/1.csv.gz", "inv/src/cfg/data/2.csv.gz")
+	writeManifest("inv/src/orc/2021-10-01T00-00Z/manifest.json", "ORC", "inv/src/orc/data/1.orc")
+	write("inv/src/cfg/hive/dt=2021-10-02-00-00/symlink.txt", []byte("inv/src/cfg/data/2.csv.gz"))
+
+	path, err := s.LatestInventoryManifest(context.Background(), "inv/src/cfg")
+	if err != nil {
+		t.Fatalf("latest inventory manifest: %v", err)
+	}
+	if path != "inv/src/cfg/2021-10-02T00-00Z/manifest.json" {
+		t.Fatalf("unexpected manifest %s", path)
+	}
+
+	it, err := s.ListInventory(context.Background(), path)
+	if err != nil {
+		t.Fatalf("list inventory: %v", err)
+	}
+	var objects []*typ.Object
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		objects = append(objects, o)
+	}
+	if len(objects) != 1201 {
+		t.Fatalf("expected 1201 entries, got %d", len(objects))
+	}
+	if o := objects[1100]; o.Path != "obj-1100" || o.MustGetContentLength() != 1100 || o.MustGetEtag() != "etag-1100" {
+		t.Errorf("unexpected entry %s of %d bytes with etag %s", o.Path, o.MustGetContentLength(), o.MustGetEtag())
+	}
+	o := objects[1200]
+	if o.Path != "dir/a b" || s3.GetObjectSystemMetadata(o).StorageClass != s3.StorageClassGlacier {
+		t.Errorf("unexpected entry %s in %s", o.Path, s3.GetObjectSystemMetadata(o).StorageClass)
+	}
+	if !o.MustGetLastModified().Equal(time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected last modified %v", o.MustGetLastModified())
+	}
+
+	if _, err = s.ListInventory(context.Background(), "inv/src/orc/2021-10-01T00-00Z/manifest.json"); !errors.Is(err, services.ErrCapabilityInsufficient) {
+		t.Errorf("expected capability insufficient for ORC, got %v", err)
+	}
+	if _, err = s.LatestInventoryManifest(context.Background(), "inv/src/missing/"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected object not exist, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestListContinuationToken(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	// More objects than a single page could hold.
+	const total = 250
+	for i := 0; i < total; i++ {
+		if _, err = store.Write(fmt.Sprintf("dir/%03d", i), strings.NewReader("x"), 1); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	seen := make(map[string]struct{})
+	it, err := store.List("dir/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for i := 0; i < 220; i++ {
+		o, err := it.Next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		seen[o.Path] = struct{}{}
+	}
+	token := it.ContinuationToken()
+	if token == "" {
+		t.Fatalf("expected continuation token of the second page")
+	}
+
+	// Resume from the checkpoint with a new iterator.
+	it, err = store.List("dir/", ps.WithContinuationToken(token))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		seen[o.Path] = struct{}{}
+	}
+	if len(seen) != total {
+		t.Errorf("expected %d objects, got %d", total, len(seen))
+	}
+}
+
+func TestListDirOnly(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"a/1", "a/b/2", "a/c/3", "a/c/4"} {
+		if _, err = store.Write(path, strings.NewReader("x"), 1); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	it, err := store.List("a/", s3.WithDirOnly())
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if !o.Mode.IsDir() {
+			t.Errorf("expected dir, got %s", o.Path)
+		}
+		paths = append(paths, o.Path)
+	}
+	if strings.Join(paths, ",") != "a/b/,a/c/" {
+		t.Errorf("expected dirs a/b/ and a/c/, got %v", paths)
+	}
+
+	if _, err = store.List("a/", s3.WithDirOnly(), ps.WithListMode(typ.ListModePrefix)); err == nil {
+		t.Errorf("expected error with prefix list mode")
+	}
+}
+
+func TestListLimit(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for i := 0; i < 250; i++ {
+		if _, err = store.Write(fmt.Sprintf("dir/%03d", i), strings.NewReader("x"), 1); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	// The limit spans two pages, the second page should be shrunk.
+	it, err := store.List("dir/", s3.WithListLimit(210))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	count := 0
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if expected := fmt.Sprintf("dir/%03d", count); o.Path != expected {
+			t.Errorf("expected %s, got %s", expected, o.Path)
+		}
+		count++
+	}
+	if count != 210 {
+		t.Errorf("expected 210 objects, got %d", count)
+	}
+
+	if _, err = store.List("dir/", s3.WithListLimit(0)); err == nil {
+		t.Errorf("expected error for zero limit")
+	}
+}
+
+func TestListDelimiter(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"logs:2021:1", "logs:2021:2", "logs:2022:1", "logs:index", "logs/x"} {
+		if _, err = store.Write(path, strings.NewReader("x"), 1); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	it, err := store.List("logs:", ps.WithListMode(typ.ListModeDir), s3.WithDelimiter(":"))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if o.Mode.IsDir() {
+			paths = append(paths, "dir:"+o.Path)
+		} else {
+			paths = append(paths, o.Path)
+		}
+	}
+	if expected := "dir:logs:2021:,dir:logs:2022:,logs:index"; strings.Join(paths, ",") != expected {
+		t.Errorf("expected %s, got %s", expected, strings.Join(paths, ","))
+	}
+
+	if _, err = store.List("logs:", ps.WithListMode(typ.ListModePrefix), s3.WithDelimiter(":")); err == nil {
+		t.Errorf("expected error with prefix list mode")
+	}
+}
+
+func TestHasPrefix(t *testing.T) {
+	store := setupStorager(t)
+
+	if _, err := store.Write("a/b/c", strings.NewReader("x"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cases := map[string]bool{
+		"a/":   true,
+		"a/b/": true,
+		"a/b":  true,
+		"b/":   false,
+		"a/c/": false,
+	}
+	for prefix, expected := range cases {
+		ok, err := store.(*s3.Storage).HasPrefix(context.Background(), prefix)
+		if err != nil {
+			t.Fatalf("has prefix %s: %v", prefix, err)
+		}
+		if ok != expected {
+			t.Errorf("expected %v for %s, got %v", expected, prefix, ok)
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	store := setupStorager(t)
+
+	if _, err := store.Write("a/b", strings.NewReader("x"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cases := map[string]bool{
+		"a/b": true,
+		"a/c": false,
+		"a":   false,
+	}
+	for path, expected := range cases {
+		ok, err := store.(*s3.Storage).Exists(context.Background(), path)
+		if err != nil {
+			t.Fatalf("exists %s: %v", path, err)
+		}
+		if ok != expected {
+			t.Errorf("expected %v for %s, got %v", expected, path, ok)
+		}
+	}
+
+	// Errors other than not found are returned.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.(*s3.Storage).Exists(ctx, "a/b"); err == nil {
+		t.Errorf("expected error after cancel")
+	}
+}
+
+func TestListMaxPages(t *testing.T) {
+	store := setupStorager(t)
+
+	for i := 0; i < 250; i++ {
+		if _, err := store.Write(fmt.Sprintf("dir/%03d", i), strings.NewReader("x"), 1); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	count := func(pairs ...typ.Pair) (n int, err error) {
+		it, err := store.List("dir/", pairs...)
+		if err != nil {
+			return 0, err
+		}
+		for {
+			_, err = it.Next()
+			if err == typ.IterateDone {
+				return n, nil
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	n, err := count(s3.WithListMaxPages(1))
+	if !errors.Is(err, s3.ErrListPageLimitExceeded) {
+		t.Errorf("expected %v, got %v", s3.ErrListPageLimitExceeded, err)
+	}
+	if n != 200 {
+		t.Errorf("expected objects of the first page, got %d", n)
+	}
+
+	// Zero means unbounded.
+	if n, err = count(s3.WithListMaxPages(0)); err != nil || n != 250 {
+		t.Errorf("expected 250 objects, got %d: %v", n, err)
+	}
+}
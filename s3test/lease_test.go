@@ -0,0 +1,77 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestLease(t *testing.T) {
+	s := setupStorager(t).(*s3.Storage)
+	ctx := context.Background()
+
+	l, err := s.AcquireLease(ctx, "jobs/lock", time.Minute)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if l.Path() != "jobs/lock" || time.Until(l.Expire()) <= 0 {
+		t.Errorf("unexpected lease %s expiring at %s", l.Path(), l.Expire())
+	}
+	if _, err = s.AcquireLease(ctx, "jobs/lock", time.Minute); !errors.Is(err, s3.ErrLeaseHeld) {
+		t.Errorf("expected lease held, got %v", err)
+	}
+
+	expire := l.Expire()
+	if err = l.Renew(ctx, 2*time.Minute); err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	if !l.Expire().After(expire) {
+		t.Errorf("expected expire extended from %s, got %s", expire, l.Expire())
+	}
+	if err = l.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+
+	// The lease could be acquired again after released.
+	l, err = s.AcquireLease(ctx, "jobs/lock", time.Minute)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	if err = l.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+}
+
+func TestLeaseExpired(t *testing.T) {
+	s := setupStorager(t).(*s3.Storage)
+	ctx := context.Background()
+
+	old, err := s.AcquireLease(ctx, "jobs/lock", time.Millisecond)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// The expired lease is taken over by others.
+	l, err := s.AcquireLease(ctx, "jobs/lock", time.Minute)
+	if err != nil {
+		t.Fatalf("take over: %v", err)
+	}
+	if err = old.Renew(ctx, time.Minute); !errors.Is(err, s3.ErrLeaseLost) {
+		t.Errorf("expected lease lost on renew, got %v", err)
+	}
+	if err = old.Release(ctx); !errors.Is(err, s3.ErrLeaseLost) {
+		t.Errorf("expected lease lost on release, got %v", err)
+	}
+
+	// The lease taken over is still held.
+	if _, err = s.AcquireLease(ctx, "jobs/lock", time.Minute); !errors.Is(err, s3.ErrLeaseHeld) {
+		t.Errorf("expected lease held, got %v", err)
+	}
+	if err = l.Release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestAnalyzeLifecycle(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	content := bytes.Repeat([]byte("x"), 128*1024)
+	for _, path := range []string{"logs/a", "logs/b"} {
+		if _, err = store.Write(path, bytes.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err = store.Write("logs/small", bytes.NewReader(content[:1]), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	s := store.(*s3.Storage)
+	ctx := context.Background()
+
+	now := time.Now().Add(100 * 24 * time.Hour)
+	a, err := s.AnalyzeLifecycle(ctx, "logs/", s3.LifecycleAnalysisOptions{Now: now})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if a.Objects != 3 || a.SmallObjects != 1 || a.Ages[2].Objects != 3 {
+		t.Errorf("unexpected analysis %+v", a)
+	}
+	if len(a.Rule.Transitions) != 2 || aws.StringValue(a.Rule.Filter.Prefix) != "logs/" {
+		t.Errorf("expected transitions to STANDARD_IA and GLACIER, got %v", a.Rule)
+	}
+
+	// Objects still accessed are not cold.
+	hot, err := s.AnalyzeLifecycle(ctx, "logs/", s3.LifecycleAnalysisOptions{
+		Now: now,
+		LastAccess: func(path string) (time.Time, bool) {
+			return now, path == "logs/a"
+		},
+	})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if len(hot.Rule.Transitions) != 0 || hot.Rule.AbortIncompleteMultipartUpload == nil {
+		t.Errorf("expected no transitions, got %v", hot.Rule)
+	}
+
+	// Applying the rule again replaces the existing one.
+	if err = s.ApplyLifecycleRule(ctx, a.Rule); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err = s.ApplyLifecycleRule(ctx, hot.Rule); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	rules, err := s.GetBucketLifecycle(ctx)
+	if err != nil {
+		t.Fatalf("get lifecycle: %v", err)
+	}
+	if len(rules) != 1 || len(rules[0].Transitions) != 0 {
+		t.Errorf("expected the replaced rule, got %v", rules)
+	}
+
+	if err = s.PutBucketLifecycle(ctx, nil); err != nil {
+		t.Fatalf("delete lifecycle: %v", err)
+	}
+	if rules, err = s.GetBucketLifecycle(ctx); err != nil || len(rules) != 0 {
+		t.Errorf("expected no rules, got %v, %v", rules, err)
+	}
+	// Only the lifecycle configuration is deleted, not the bucket.
+	if _, err = s.Stat("logs/a"); err != nil {
+		t.Errorf("stat: %v", err)
+	}
+}
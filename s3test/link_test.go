@@ -0,0 +1,419 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestFollowLink(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test", s3.WithEnableVirtualLink())
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	l := store.(typ.Linker)
+
+	content := "hello, world"
+	if _, err = store.Write("target", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// l2 -> l1 -> target, and x <-> y is a loop.
+	for _, v := range [][2]string{{"l1", "target"}, {"l2", "l1"}, {"x", "y"}, {"y", "x"}} {
+		if _, err = l.CreateLink(v[0], v[1]); err != nil {
+			t.Fatalf("create link %s: %v", v[0], err)
+		}
+	}
+	target, err := store.Stat("target")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	o, err := store.Stat("l2")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if !o.Mode.IsLink() || o.MustGetLinkTarget() != "/l1" {
+		t.Errorf("expected link to /l1 without follow_link, got mode %v", o.Mode)
+	}
+
+	o, err = store.Stat("l2", s3.WithFollowLink())
+	if err != nil {
+		t.Fatalf("stat follow link: %v", err)
+	}
+	if o.Path != "l2" || o.Mode.IsLink() || o.MustGetContentLength() != int64(len(content)) || o.MustGetEtag() != target.MustGetEtag() {
+		t.Errorf("expected the metadata of target at l2, got %s of %d bytes with mode %v", o.Path, o.MustGetContentLength(), o.Mode)
+	}
+
+	var buf bytes.Buffer
+	if _, err = store.Read("l2", &buf, s3.WithFollowLink()); err != nil {
+		t.Fatalf("read follow link: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("expected %q, got %q", content, buf.String())
+	}
+
+	cases := []struct {
+		name  string
+		path  string
+		pairs []typ.Pair
+	}{
+		{"too deep", "l2", []typ.Pair{s3.WithFollowLink(), s3.WithFollowLinkDepth(1)}},
+		{"loop", "x", []typ.Pair{s3.WithFollowLink()}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := store.Stat(tt.path, tt.pairs...); !errors.Is(err, s3.ErrLinkLoop) {
+				t.Errorf("stat: expected link loop, got %v", err)
+			}
+			if _, err := store.Read(tt.path, &bytes.Buffer{}, tt.pairs...); !errors.Is(err, s3.ErrLinkLoop) {
+				t.Errorf("read: expected link loop, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFollowLinkCustomerKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test-link")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The SDK refuses to send customer keys over plain HTTP.
+	srv := NewTLSServer()
+	defer srv.Close()
+	bundle := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(bundle, srv.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	os.Setenv("AWS_CA_BUNDLE", bundle)
+	defer os.Unsetenv("AWS_CA_BUNDLE")
+
+	// Count HEADs sent without the pairs of the read.
+	var heads, missing int64
+	handlers := s3.RequestHandlers{
+		Send: []request.NamedHandler{{
+			Name: "s3test.CheckHead",
+			Fn: func(r *request.Request) {
+				if r.Operation.Name != "HeadObject" {
+					return
+				}
+				atomic.AddInt64(&heads, 1)
+				h := r.HTTPRequest.Header
+				if h.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") == "" || h.Get("X-Amz-Expected-Bucket-Owner") == "" {
+					atomic.AddInt64(&missing, 1)
+				}
+			},
+		}},
+	}
+	store, err := srv.NewStorager("test", s3.WithEnableVirtualLink(), s3.WithRequestHandlers(handlers))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	pairs := []typ.Pair{
+		s3.WithServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+		s3.WithServerSideEncryptionCustomerKey(bytes.Repeat([]byte{1}, 32)),
+		s3.WithExceptedBucketOwner("123456789012"),
+	}
+	content := "hello, world"
+	if _, err = store.Write("target", strings.NewReader(content), int64(len(content)), pairs...); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err = store.(typ.Linker).CreateLink("link", "target"); err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+
+	followPairs := append([]typ.Pair{s3.WithFollowLink()}, pairs...)
+	o, err := store.Stat("link", followPairs...)
+	if err != nil {
+		t.Fatalf("stat follow link: %v", err)
+	}
+	if o.MustGetContentLength() != int64(len(content)) {
+		t.Errorf("expected the metadata of target, got %d bytes", o.MustGetContentLength())
+	}
+	var buf bytes.Buffer
+	if _, err = store.Read("link", &buf, followPairs...); err != nil {
+		t.Fatalf("read follow link: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("expected %q, got %q", content, buf.String())
+	}
+
+	if heads == 0 || missing != 0 {
+		t.Errorf("expected every HEAD sent with the pairs, %d of %d are not", missing, heads)
+	}
+}
+
+func TestReadLink(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var heads int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test",
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		s3.WithEnableVirtualLink(),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	for p, content := range map[string]string{"dir/target": "hello, world", "dir/empty": ""} {
+		if _, err = s.Write(p, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	if _, err = s.CreateLink("dir/link", "dir/target"); err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+
+	target, err := s.ReadLink(context.Background(), "dir/link")
+	if err != nil {
+		t.Fatalf("read link: %v", err)
+	}
+	if target != "/dir/target" {
+		t.Errorf("expected target /dir/target, got %s", target)
+	}
+	if _, err = s.ReadLink(context.Background(), "dir/empty"); !errors.Is(err, s3.ErrNotLink) {
+		t.Errorf("expected not link, got %v", err)
+	}
+
+	list := func(pairs ...typ.Pair) map[string]*typ.Object {
+		it, err := s.List("dir/", pairs...)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		objects := map[string]*typ.Object{}
+		for {
+			o, err := it.Next()
+			if err == typ.IterateDone {
+				return objects
+			}
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			objects[o.Path] = o
+		}
+	}
+
+	// Links are indistinguishable from files without detect_link.
+	atomic.StoreInt64(&heads, 0)
+	if o := list()["dir/link"]; o == nil || o.Mode.IsLink() {
+		t.Errorf("expected dir/link listed as a file")
+	}
+	if n := atomic.LoadInt64(&heads); n != 0 {
+		t.Errorf("expected no HEAD requests, got %d", n)
+	}
+
+	for _, mode := range []typ.ListMode{typ.ListModePrefix, typ.ListModeDir} {
+		atomic.StoreInt64(&heads, 0)
+		objects := list(ps.WithListMode(mode), s3.WithDetectLink())
+		if o := objects["dir/link"]; o == nil || !o.Mode.IsLink() || o.MustGetLinkTarget() != "/dir/target" {
+			t.Errorf("list mode %v: expected dir/link listed as a link", mode)
+		}
+		for _, p := range []string{"dir/target", "dir/empty"} {
+			if o := objects[p]; o == nil || o.Mode.IsLink() || !o.Mode.IsRead() {
+				t.Errorf("list mode %v: expected %s listed as a file", mode, p)
+			}
+		}
+		// Only zero-byte objects are checked.
+		if n := atomic.LoadInt64(&heads); n != 2 {
+			t.Errorf("list mode %v: expected 2 HEAD requests, got %d", mode, n)
+		}
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		store, err := srv.NewStorager("test")
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		if _, err = store.(*s3.Storage).ReadLink(context.Background(), "dir/link"); !errors.Is(err, services.ErrCapabilityInsufficient) {
+			t.Errorf("expected capability insufficient, got %v", err)
+		}
+	})
+}
+
+func TestLinkReference(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test", s3.WithEnableVirtualLink(), s3.WithLinkReference())
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	content := "hello, world"
+	if _, err = s.Write("a/data", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	target, err := s.Stat("a/data")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	for _, p := range []string{"cat/l1", "cat/l2"} {
+		if _, err = s.CreateLink(p, "a/data"); err != nil {
+			t.Fatalf("create link %s: %v", p, err)
+		}
+	}
+	if _, err = s.CreateLink("cat/missing", "a/missing"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected object not exist for a missing target, got %v", err)
+	}
+
+	assertLink := func(path, target, etag string) {
+		t.Helper()
+
+		o, err := s.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if !o.Mode.IsLink() || o.MustGetLinkTarget() != target {
+			t.Errorf("%s: expected link to %s, got mode %v", path, target, o.Mode)
+		}
+		if v := s3.GetObjectSystemMetadata(o).LinkTargetEtag; v != etag {
+			t.Errorf("%s: expected target etag %s, got %s", path, etag, v)
+		}
+		// Links are zero-byte objects, the content is never duplicated.
+		if n := o.MustGetContentLength(); n != 0 {
+			t.Errorf("%s: expected zero-byte link, got %d bytes", path, n)
+		}
+	}
+	assertLink("cat/l1", "/a/data", target.MustGetEtag())
+
+	// Move the target and rewrite the links.
+	if err = s.Copy("a/data", "moved/data"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if err = s.Delete("a/data"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	n, err := s.RewriteLinks(context.Background(), "cat/", "a/data", "moved/data")
+	if err != nil {
+		t.Fatalf("rewrite links: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 links rewritten, got %d", n)
+	}
+	for _, p := range []string{"cat/l1", "cat/l2"} {
+		assertLink(p, "/moved/data", target.MustGetEtag())
+	}
+	var buf bytes.Buffer
+	if _, err = s.Read("cat/l1", &buf, s3.WithFollowLink()); err != nil || buf.String() != content {
+		t.Errorf("expected %q via the rewritten link, got %q: %v", content, buf.String(), err)
+	}
+
+	// Links can't be pointed to different content.
+	changed := "changed"
+	if _, err = s.Write("changed", strings.NewReader(changed), int64(len(changed))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err = s.RewriteLinks(context.Background(), "cat/", "moved/data", "changed"); !errors.Is(err, s3.ErrPreconditionFailed) {
+		t.Errorf("expected precondition failed, got %v", err)
+	}
+	assertLink("cat/l1", "/moved/data", target.MustGetEtag())
+}
+
+func TestLinkReferenceCustomerKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test-link")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The SDK refuses to send customer keys over plain HTTP.
+	srv := NewTLSServer()
+	defer srv.Close()
+	bundle := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(bundle, srv.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	os.Setenv("AWS_CA_BUNDLE", bundle)
+	defer os.Unsetenv("AWS_CA_BUNDLE")
+
+	// Count requests sent without the pairs of the link.
+	var missing int64
+	handlers := s3.RequestHandlers{
+		Send: []request.NamedHandler{{
+			Name: "s3test.CheckPairs",
+			Fn: func(r *request.Request) {
+				h := r.HTTPRequest.Header
+				if h.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") == "" || h.Get("X-Amz-Expected-Bucket-Owner") == "" {
+					atomic.AddInt64(&missing, 1)
+				}
+			},
+		}},
+	}
+	store, err := srv.NewStorager("test",
+		s3.WithEnableVirtualLink(),
+		s3.WithLinkReference(),
+		s3.WithRequestHandlers(handlers),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	pairs := []typ.Pair{
+		s3.WithServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+		s3.WithServerSideEncryptionCustomerKey(bytes.Repeat([]byte{1}, 32)),
+		s3.WithExceptedBucketOwner("123456789012"),
+	}
+	content := "hello, world"
+	if _, err = store.Write("target", strings.NewReader(content), int64(len(content)), pairs...); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	target, err := store.Stat("target", pairs...)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	o, err := store.(typ.Linker).CreateLink("link", "target", pairs...)
+	if err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	if v := s3.GetObjectSystemMetadata(o).LinkTargetEtag; v != target.MustGetEtag() {
+		t.Errorf("expected target etag %s, got %s", target.MustGetEtag(), v)
+	}
+
+	// The link is encrypted with the same key, and followed with the pairs of the target.
+	var buf bytes.Buffer
+	if _, err = store.Read("link", &buf, append([]typ.Pair{s3.WithFollowLink()}, pairs...)...); err != nil {
+		t.Fatalf("read follow link: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("expected %q, got %q", content, buf.String())
+	}
+	if missing != 0 {
+		t.Errorf("expected every request sent with the pairs, %d are not", missing)
+	}
+
+	if _, err = store.Stat("link"); err == nil {
+		t.Errorf("expected the link encrypted with the customer key")
+	}
+}
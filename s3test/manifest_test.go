@@ -0,0 +1,85 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestReadManifest(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"data/a", "data/b c"} {
+		if _, err = store.Write(path, strings.NewReader(path), int64(len(path))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	s := store.(*s3.Storage)
+
+	cases := []struct {
+		format   s3.ManifestFormat
+		manifest string
+	}{
+		{s3.ManifestFormatCSV, "test,data/a\ntest,data/b%20c,version\ntest,data/missing\nother,data/a\n"},
+		{s3.ManifestFormatJSON, `{"key":"data/a"}` + "\n" + `{"key":"data/b c","bucket":"test"}` + "\n" + `{"key":"data/missing"}` + "\n" + `{"key":"data/a","bucket":"other"}`},
+	}
+	for _, tc := range cases {
+		it, err := s.ReadManifest(context.Background(), strings.NewReader(tc.manifest), s3.ManifestReadOptions{
+			Format:  tc.format,
+			Content: true,
+		})
+		if err != nil {
+			t.Fatalf("read manifest: %v", err)
+		}
+
+		entries := map[int]*s3.ManifestEntry{}
+		for {
+			e, err := it.Next()
+			if err == typ.IterateDone {
+				break
+			}
+			if err != nil {
+				t.Fatalf("next: %v", err)
+			}
+			entries[e.Line] = e
+		}
+		if len(entries) != 4 {
+			t.Fatalf("%s: expected 4 entries, got %d", tc.format, len(entries))
+		}
+		for _, line := range []int{1, 2} {
+			e := entries[line]
+			if e.Err != nil {
+				t.Fatalf("%s: line %d: %v", tc.format, line, e.Err)
+			}
+			content, _ := ioutil.ReadAll(e.Content)
+			if string(content) != e.Path || e.Object.Path != e.Path {
+				t.Errorf("%s: unexpected entry %+v with content %q", tc.format, e, content)
+			}
+		}
+		if !errors.Is(entries[3].Err, services.ErrObjectNotExist) {
+			t.Errorf("%s: expected %v, got %v", tc.format, services.ErrObjectNotExist, entries[3].Err)
+		}
+		if !errors.Is(entries[4].Err, services.ErrRestrictionDissatisfied) {
+			t.Errorf("%s: expected %v, got %v", tc.format, services.ErrRestrictionDissatisfied, entries[4].Err)
+		}
+	}
+
+	it, err := s.ReadManifest(context.Background(), strings.NewReader("test\n"), s3.ManifestReadOptions{Format: s3.ManifestFormatCSV})
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	if _, err = it.Next(); err == nil || err == typ.IterateDone {
+		t.Errorf("expected error for malformed manifest, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package s3test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestMergeList(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	shards := map[string][]string{
+		"/shard-0/": {"a", "c", "e"},
+		"/shard-1/": {"b", "c", "d"},
+	}
+	var sources []s3.MergeSource
+	for _, workDir := range []string{"/shard-0/", "/shard-1/"} {
+		store, err := srv.NewStorager("test", ps.WithWorkDir(workDir))
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		for _, path := range shards[workDir] {
+			if _, err = store.Write(path, strings.NewReader(workDir), int64(len(workDir))); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		}
+		sources = append(sources, s3.MergeSource{Storager: store})
+	}
+
+	it, err := s3.MergeList(context.Background(), sources)
+	if err != nil {
+		t.Fatalf("merge list: %v", err)
+	}
+	var paths, ids []string
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		paths = append(paths, o.Path)
+		ids = append(ids, o.ID)
+	}
+
+	if got := strings.Join(paths, ","); got != "a,b,c,d,e" {
+		t.Errorf("expected sorted and deduplicated paths, got %s", got)
+	}
+	// The duplicated path is returned from the first source.
+	if ids[2] != "shard-0/c" {
+		t.Errorf("expected c from the first source, got %s", ids[2])
+	}
+}
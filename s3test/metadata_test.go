@@ -0,0 +1,69 @@
+package s3test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+)
+
+func TestMetadataBucketInfo(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	before := time.Now().Add(-time.Second)
+	srv.CreateBucket("test")
+
+	var requests int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	// Bucket info is only fetched on demand.
+	meta := store.Metadata()
+	if sm := s3.GetStorageSystemMetadata(meta); sm.BucketRegion != "" || !sm.BucketCreationDate.IsZero() {
+		t.Errorf("expected no bucket info, got %+v", sm)
+	}
+	if n := atomic.LoadInt64(&requests); n != 0 {
+		t.Errorf("expected no requests, got %d", n)
+	}
+
+	meta = store.Metadata(s3.WithFetchBucketInfo())
+	sm := s3.GetStorageSystemMetadata(meta)
+	if sm.BucketRegion != Location {
+		t.Errorf("expected region %s, got %s", Location, sm.BucketRegion)
+	}
+	if sm.BucketCreationDate.Before(before) || sm.BucketCreationDate.After(time.Now()) {
+		t.Errorf("unexpected creation date %s", sm.BucketCreationDate)
+	}
+	if sm.VersioningStatus != "" {
+		t.Errorf("expected versioning never enabled, got %s", sm.VersioningStatus)
+	}
+	if sm.DefaultServerSideEncryption != "" {
+		t.Errorf("expected no default encryption, got %s", sm.DefaultServerSideEncryption)
+	}
+	if meta.Name != "test" {
+		t.Errorf("expected name test, got %s", meta.Name)
+	}
+
+	// The info is cached after fetched.
+	n := atomic.LoadInt64(&requests)
+	if cached := s3.GetStorageSystemMetadata(store.Metadata(s3.WithFetchBucketInfo())); cached != sm {
+		t.Errorf("expected cached %+v, got %+v", sm, cached)
+	}
+	if m := atomic.LoadInt64(&requests); m != n {
+		t.Errorf("expected no more requests, got %d", m-n)
+	}
+}
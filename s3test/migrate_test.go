@@ -0,0 +1,203 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+)
+
+// denyingHandler forwards requests to srv, while PUT requests to paths with the suffix `deny` are
+// rejected with 403 as long as `denied` is set.
+type denyingHandler struct {
+	srv    *Server
+	deny   string
+	denied int32
+}
+
+func (h *denyingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, h.deny) && atomic.LoadInt32(&h.denied) == 1 {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+		return
+	}
+	h.srv.ServeHTTP(w, r)
+}
+
+func TestMigrate(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	src, err := srv.NewStorager("src")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"data/a", "data/b", "data/c", "other"} {
+		if _, err = src.Write(path, strings.NewReader(path), int64(len(path))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	srv.CreateBucket("dst")
+	h := &denyingHandler{srv: srv, deny: "/data/b", denied: 1}
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+	dst, err := srv.NewStorager("dst", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	stateFile := filepath.Join(dir, "state.json")
+	opt := s3.MigrateOptions{
+		Prefix:         "data/",
+		BytesPerSecond: 1 << 20,
+		MaxAttempts:    2,
+		RetryBackoff:   time.Millisecond,
+		StateFile:      stateFile,
+	}
+
+	var attempts int64
+	opt.Progress = func(e s3.MigrateEvent) {
+		if e.Path == "data/b" {
+			atomic.StoreInt64(&attempts, int64(e.Attempts))
+		}
+	}
+	sum, err := dst.(*s3.Storage).Migrate(context.Background(), src, opt)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if sum.Migrated != 2 || sum.Failed != 1 || sum.Bytes != 12 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	if attempts != 2 {
+		t.Errorf("expected the failed object to be attempted twice, got %d", attempts)
+	}
+
+	content, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	var state struct {
+		Watermark string
+		Failed    []string
+	}
+	if err = json.Unmarshal(content, &state); err != nil {
+		t.Fatalf("parse state file: %v", err)
+	}
+	if state.Watermark != "data/c" || len(state.Failed) != 1 || state.Failed[0] != "data/b" {
+		t.Errorf("unexpected state %s", content)
+	}
+
+	// The resumed migration only retries the failed object.
+	atomic.StoreInt32(&h.denied, 0)
+	opt.Progress = nil
+	sum, err = dst.(*s3.Storage).Migrate(context.Background(), src, opt)
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if sum.Migrated != 1 || sum.Skipped != 2 || sum.Failed != 0 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = dst.Read("data/b", buf); err != nil || buf.String() != "data/b" {
+		t.Errorf("expected data/b migrated, got %q, %v", buf.String(), err)
+	}
+	if _, err = dst.Stat("other"); err == nil {
+		t.Errorf("expected objects out of prefix not migrated")
+	}
+
+	// The prefix of the state file must match.
+	opt.Prefix = "other"
+	if _, err = dst.(*s3.Storage).Migrate(context.Background(), src, opt); err == nil {
+		t.Errorf("expected error for mismatched state file")
+	}
+}
+
+func TestMigrateServerSideCopy(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("src")
+	srv.CreateBucket("dst")
+
+	var copies, gets, heads int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			atomic.AddInt64(&copies, 1)
+		case r.Method == http.MethodGet && strings.Count(strings.Trim(r.URL.Path, "/"), "/") > 0:
+			atomic.AddInt64(&gets, 1)
+		case r.Method == http.MethodHead:
+			atomic.AddInt64(&heads, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	endpoint := "http:" + strings.TrimPrefix(proxy.URL, "http://")
+
+	servicer, err := s3.NewServicer(
+		ps.WithCredential("hmac:s3test:s3test"),
+		ps.WithEndpoint(endpoint),
+		ps.WithLocation(Location),
+		s3.WithForcePathStyle(),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	src, err := servicer.Get("src")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	dst, err := servicer.Get("dst")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	for _, path := range []string{"data/a", "data/b"} {
+		if _, err = src.Write(path, strings.NewReader(path), int64(len(path))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	// Storages of the same servicer copy on server side.
+	sum, err := dst.(*s3.Storage).Migrate(context.Background(), src, s3.MigrateOptions{Prefix: "data/"})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if sum.Migrated != 2 || sum.Bytes != 12 || copies != 2 || gets != 0 || heads != 0 {
+		t.Errorf("expected 2 server side copies without reads or stats, got %+v with %d copies, %d reads and %d stats", sum, copies, gets, heads)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = dst.Read("data/a", buf); err != nil || buf.String() != "data/a" {
+		t.Errorf("expected data/a copied, got %q, %v", buf.String(), err)
+	}
+
+	// Storages with other credentials stream the content through the client.
+	other, err := srv.NewStorager("other", ps.WithEndpoint(endpoint), ps.WithCredential("hmac:other:other"))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	gets = 0
+	sum, err = other.(*s3.Storage).Migrate(context.Background(), src, s3.MigrateOptions{Prefix: "data/"})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if sum.Migrated != 2 || copies != 2 || gets != 2 {
+		t.Errorf("expected content streamed, got %+v with %d copies and %d reads", sum, copies, gets)
+	}
+}
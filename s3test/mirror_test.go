@@ -0,0 +1,84 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestWriteMirrors(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	var mirrors []typ.Storager
+	for _, name := range []string{"mirror-a", "mirror-b"} {
+		m, err := srv.NewStorager(name)
+		if err != nil {
+			t.Fatalf("new mirror: %v", err)
+		}
+		mirrors = append(mirrors, m)
+	}
+	store, err := srv.NewStorager("primary", s3.WithWriteMirrors(mirrors))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := "hello, world"
+	cases := map[string]io.Reader{
+		"seekable": strings.NewReader(content),
+		// io.MultiReader hides Seek of the strings.Reader.
+		"unseekable": io.MultiReader(strings.NewReader(content)),
+	}
+	for path, r := range cases {
+		var result s3.WriteResult
+		if _, err = store.Write(path, r, int64(len(content)), s3.WithWriteResult(&result)); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+		if result.Etag == "" {
+			t.Errorf("%s: expected the write result of the storage", path)
+		}
+		for i, m := range append([]typ.Storager{store}, mirrors...) {
+			buf := &bytes.Buffer{}
+			if _, err = m.Read(path, buf); err != nil {
+				t.Fatalf("read %s from %d: %v", path, i, err)
+			}
+			if buf.String() != content {
+				t.Errorf("%s: expected %q in %d, got %q", path, content, i, buf.String())
+			}
+		}
+	}
+
+	// The bucket of the mirror doesn't exist.
+	missing, err := s3.NewStorager(srv.Pairs("missing")...)
+	if err != nil {
+		t.Fatalf("new mirror: %v", err)
+	}
+	store, err = srv.NewStorager("primary", s3.WithWriteMirrors([]typ.Storager{mirrors[0], missing}))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for path, r := range map[string]io.Reader{
+		"partial-seekable":   strings.NewReader(content),
+		"partial-unseekable": io.MultiReader(strings.NewReader(content)),
+	} {
+		_, err = store.Write(path, r, int64(len(content)))
+		var me s3.MirrorWriteError
+		if !errors.As(err, &me) || !errors.Is(err, s3.ErrMirrorWriteFailed) {
+			t.Fatalf("%s: expected %v, got %v", path, s3.ErrMirrorWriteFailed, err)
+		}
+		if len(me.Errs) != 2 || me.Errs[0] != nil || !errors.Is(me.Errs[1], s3.ErrBucketNotExist) {
+			t.Errorf("%s: unexpected errors %v", path, me.Errs)
+		}
+		// The storage and other mirrors have been written.
+		for _, m := range []typ.Storager{store, mirrors[0]} {
+			if _, err = m.Stat(path); err != nil {
+				t.Errorf("%s: stat: %v", path, err)
+			}
+		}
+	}
+}
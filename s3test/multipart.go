@@ -0,0 +1,321 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partNumberMaximum is the max part number of a multipart upload.
+const partNumberMaximum = 10000
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, name, key string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+
+	s.uploadID++
+	id := fmt.Sprintf("%016x", s.uploadID)
+	b.uploads[id] = &upload{
+		key:       key,
+		initiated: time.Now(),
+		header:    formatStoredHeader(r.Header),
+		tags:      parseTaggingHeader(r.Header),
+		parts:     make(map[int]*object),
+	}
+
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{
+		Bucket:   name,
+		Key:      key,
+		UploadID: id,
+	})
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, name, key string) {
+	u, e, ok := s.lookupUpload(name, key, r.URL.Query().Get("uploadId"))
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	if e, ok := checkCustomerKey(r, u.header, "X-Amz-"); !ok {
+		writeError(w, e)
+		return
+	}
+	number, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || number < 1 || number > partNumberMaximum {
+		writeError(w, errInvalidArgument)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errIncompleteBody)
+		return
+	}
+	if !checkContentMD5(r, data) {
+		writeError(w, errBadDigest)
+		return
+	}
+	p := newObject(data, nil)
+	u.parts[number] = p
+
+	w.Header().Set("ETag", p.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+type listPartsResult struct {
+	XMLName              xml.Name   `xml:"ListPartsResult"`
+	Bucket               string     `xml:"Bucket"`
+	Key                  string     `xml:"Key"`
+	UploadID             string     `xml:"UploadId"`
+	PartNumberMarker     int        `xml:"PartNumberMarker"`
+	NextPartNumberMarker int        `xml:"NextPartNumberMarker"`
+	MaxParts             int        `xml:"MaxParts"`
+	IsTruncated          bool       `xml:"IsTruncated"`
+	Parts                []partInfo `xml:"Part"`
+}
+
+type partInfo struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+}
+
+func (s *Server) listParts(w http.ResponseWriter, r *http.Request, name, key string) {
+	q := r.URL.Query()
+	u, e, ok := s.lookupUpload(name, key, q.Get("uploadId"))
+	if !ok {
+		writeError(w, e)
+		return
+	}
+
+	result := listPartsResult{
+		Bucket:   name,
+		Key:      key,
+		UploadID: q.Get("uploadId"),
+		MaxParts: maxKeysDefault,
+	}
+	if v := q.Get("part-number-marker"); v != "" {
+		result.PartNumberMarker, _ = strconv.Atoi(v)
+	}
+	if v := q.Get("max-parts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, errInvalidArgument)
+			return
+		}
+		result.MaxParts = n
+	}
+
+	for _, number := range u.partNumbers() {
+		if number <= result.PartNumberMarker {
+			continue
+		}
+		if len(result.Parts) >= result.MaxParts {
+			result.IsTruncated = true
+			break
+		}
+		p := u.parts[number]
+		result.Parts = append(result.Parts, partInfo{
+			PartNumber:   number,
+			LastModified: formatTime(p.modified),
+			ETag:         p.etag,
+			Size:         int64(len(p.data)),
+		})
+		result.NextPartNumberMarker = number
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+type listMultipartUploadsResult struct {
+	XMLName     xml.Name     `xml:"ListMultipartUploadsResult"`
+	Bucket      string       `xml:"Bucket"`
+	Prefix      string       `xml:"Prefix"`
+	MaxUploads  int          `xml:"MaxUploads"`
+	IsTruncated bool         `xml:"IsTruncated"`
+	Uploads     []uploadInfo `xml:"Upload"`
+}
+
+type uploadInfo struct {
+	Key          string    `xml:"Key"`
+	UploadID     string    `xml:"UploadId"`
+	Initiated    string    `xml:"Initiated"`
+	Initiator    initiator `xml:"Initiator"`
+	StorageClass string    `xml:"StorageClass"`
+}
+
+// initiator is always the access key of Pairs, as requests are not authenticated.
+type initiator struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+// listMultipartUploads returns all uploads under the prefix at once, the markers are ignored.
+func (s *Server) listMultipartUploads(w http.ResponseWriter, r *http.Request, name string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	ids := make([]string, 0, len(b.uploads))
+	for id, u := range b.uploads {
+		if strings.HasPrefix(u.key, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	// Uploads are sorted by key, and uploads of the same key are sorted by initiated time.
+	sort.Slice(ids, func(i, j int) bool {
+		ui, uj := b.uploads[ids[i]], b.uploads[ids[j]]
+		if ui.key != uj.key {
+			return ui.key < uj.key
+		}
+		return ids[i] < ids[j]
+	})
+
+	result := listMultipartUploadsResult{
+		Bucket:     name,
+		Prefix:     prefix,
+		MaxUploads: maxKeysDefault,
+	}
+	for _, id := range ids {
+		u := b.uploads[id]
+		storageClass := u.header.Get("X-Amz-Storage-Class")
+		if storageClass == "" {
+			storageClass = "STANDARD"
+		}
+		result.Uploads = append(result.Uploads, uploadInfo{
+			Key:          u.key,
+			UploadID:     id,
+			Initiated:    formatTime(u.initiated),
+			Initiator:    initiator{ID: "s3test", DisplayName: "s3test"},
+			StorageClass: storageClass,
+		})
+	}
+
+	writeXML(w, http.StatusOK, result)
+}
+
+type completeMultipartUploadRequest struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// completeMultipartUpload will assemble the parts into the object, the minimum part size is not
+// enforced so that tests could use small parts.
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, name, key string) {
+	id := r.URL.Query().Get("uploadId")
+	u, e, ok := s.lookupUpload(name, key, id)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errIncompleteBody)
+		return
+	}
+	req := completeMultipartUploadRequest{}
+	if err = xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		writeError(w, errMalformedXML)
+		return
+	}
+
+	var data []byte
+	// The etag of a multipart object is the md5 of the concatenated md5 of parts, followed by
+	// the number of parts.
+	sums := md5.New()
+	last := 0
+	for _, v := range req.Parts {
+		if v.PartNumber <= last {
+			writeError(w, errInvalidPartOrder)
+			return
+		}
+		last = v.PartNumber
+
+		p, ok := u.parts[v.PartNumber]
+		if !ok || !matchEtag(v.ETag, p.etag) {
+			writeError(w, errInvalidPart)
+			return
+		}
+		data = append(data, p.data...)
+
+		sum, _ := hex.DecodeString(strings.Trim(p.etag, `"`))
+		sums.Write(sum)
+	}
+
+	o := newObject(data, u.header)
+	o.tags = u.tags
+	o.etag = fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sums.Sum(nil)), len(req.Parts))
+	s.buckets[name].objects[key] = o
+	delete(s.buckets[name].uploads, id)
+
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Location: "/" + name + "/" + key,
+		Bucket:   name,
+		Key:      key,
+		ETag:     o.etag,
+	})
+}
+
+func (s *Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, name, key string) {
+	id := r.URL.Query().Get("uploadId")
+	if _, e, ok := s.lookupUpload(name, key, id); !ok {
+		writeError(w, e)
+		return
+	}
+	delete(s.buckets[name].uploads, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) lookupUpload(name, key, id string) (*upload, apiError, bool) {
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errNoSuchBucket, false
+	}
+	u, ok := b.uploads[id]
+	if !ok || u.key != key {
+		return nil, errNoSuchUpload, false
+	}
+	return u, apiError{}, true
+}
+
+func (u *upload) partNumbers() []int {
+	numbers := make([]int, 0, len(u.parts))
+	for number := range u.parts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
@@ -0,0 +1,440 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// storedHeaders are the headers stored along with the object and returned while reading,
+// user-defined metadata (x-amz-meta-*) will be stored as well.
+var storedHeaders = []string{
+	"Cache-Control",
+	"Content-Disposition",
+	"Content-Encoding",
+	"Content-Language",
+	"Content-Type",
+	"Expires",
+	"X-Amz-Server-Side-Encryption",
+	"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id",
+	"X-Amz-Server-Side-Encryption-Bucket-Key-Enabled",
+	"X-Amz-Server-Side-Encryption-Customer-Algorithm",
+	"X-Amz-Server-Side-Encryption-Customer-Key-Md5",
+	"X-Amz-Storage-Class",
+	"X-Amz-Website-Redirect-Location",
+}
+
+func newObject(data []byte, header http.Header) *object {
+	sum := md5.Sum(data)
+	return &object{
+		data: data,
+		etag: `"` + hex.EncodeToString(sum[:]) + `"`,
+		// S3 only keeps the last modified time in seconds.
+		modified: time.Now().UTC().Truncate(time.Second),
+		header:   header,
+	}
+}
+
+// checkContentMD5 checks the body against the Content-MD5 header if it's sent.
+func checkContentMD5(r *http.Request, data []byte) bool {
+	v := r.Header.Get("Content-MD5")
+	if v == "" {
+		return true
+	}
+	sum := md5.Sum(data)
+	return v == base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// storageClass returns the storage class in list responses, which is STANDARD by default.
+func (o *object) storageClass() string {
+	if v := o.header.Get("X-Amz-Storage-Class"); v != "" {
+		return v
+	}
+	return "STANDARD"
+}
+
+// archived returns whether the object is in an archived storage class and not restored, which
+// could not be read.
+func (o *object) archived() bool {
+	switch o.storageClass() {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return !strings.Contains(o.header.Get("X-Amz-Restore"), `ongoing-request="false"`)
+	default:
+		return false
+	}
+}
+
+func formatStoredHeader(h http.Header) http.Header {
+	m := make(http.Header)
+	for _, k := range storedHeaders {
+		if v := h.Get(k); v != "" {
+			m.Set(k, v)
+		}
+	}
+	for k, v := range h {
+		if strings.HasPrefix(http.CanonicalHeaderKey(k), "X-Amz-Meta-") {
+			m[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	if m.Get("Content-Type") == "" {
+		m.Set("Content-Type", "binary/octet-stream")
+	}
+	return m
+}
+
+func (o *object) writeHeader(w http.ResponseWriter) {
+	for k, v := range o.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", o.etag)
+	w.Header().Set("Last-Modified", o.modified.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+}
+
+func matchEtag(cond, etag string) bool {
+	if cond == "*" {
+		return true
+	}
+	for _, v := range strings.Split(cond, ",") {
+		if strings.Trim(strings.TrimSpace(v), `"`) == strings.Trim(etag, `"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkReadConditions checks the conditional headers of GET and HEAD requests, and returns the
+// status to respond with if any condition fails, or 0 if all conditions hold.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
+func checkReadConditions(r *http.Request, o *object) int {
+	if v := r.Header.Get("If-Match"); v != "" {
+		if !matchEtag(v, o.etag) {
+			return http.StatusPreconditionFailed
+		}
+	} else if v := r.Header.Get("If-Unmodified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && o.modified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		if matchEtag(v, o.etag) {
+			return http.StatusNotModified
+		}
+	} else if v := r.Header.Get("If-Modified-Since"); v != "" {
+		if t, err := http.ParseTime(v); err == nil && !o.modified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// checkWriteConditions checks the conditional headers of PUT and DELETE requests, o is nil if the
+// object doesn't exist.
+func checkWriteConditions(r *http.Request, o *object) (apiError, bool) {
+	if v := r.Header.Get("If-None-Match"); v == "*" && o != nil {
+		return errPreconditionFailed, false
+	}
+	if v := r.Header.Get("If-Match"); v != "" {
+		if o == nil {
+			return errNoSuchKey, false
+		}
+		if !matchEtag(v, o.etag) {
+			return errPreconditionFailed, false
+		}
+	}
+	return apiError{}, true
+}
+
+// checkCustomerKey checks the customer-provided key sent with headers prefixed by prefix against the
+// one the object (or upload) was encrypted with, which must be sent for every access as S3 does.
+func checkCustomerKey(r *http.Request, stored http.Header, prefix string) (apiError, bool) {
+	keyMd5 := stored.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5")
+	if keyMd5 == "" {
+		return apiError{}, true
+	}
+	v := r.Header.Get(prefix + "Server-Side-Encryption-Customer-Key-Md5")
+	if v == "" {
+		return errInvalidRequest, false
+	}
+	if v != keyMd5 {
+		return errAccessDenied, false
+	}
+	return apiError{}, true
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, name, key string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	if e, ok := checkWriteConditions(r, b.objects[key]); !ok {
+		writeError(w, e)
+		return
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errIncompleteBody)
+		return
+	}
+	if !checkContentMD5(r, data) {
+		writeError(w, errBadDigest)
+		return
+	}
+	o := newObject(data, formatStoredHeader(r.Header))
+	o.tags = parseTaggingHeader(r.Header)
+	b.objects[key] = o
+
+	// The encryption applied is returned as S3 does.
+	for k, v := range o.header {
+		if strings.HasPrefix(k, "X-Amz-Server-Side-Encryption") {
+			w.Header()[k] = v
+		}
+	}
+	w.Header().Set("ETag", o.etag)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	if o.archived() {
+		writeError(w, errInvalidObjectState)
+		return
+	}
+	if e, ok := checkCustomerKey(r, o.header, "X-Amz-"); !ok {
+		writeError(w, e)
+		return
+	}
+	if status := checkReadConditions(r, o); status != 0 {
+		writeConditionFailed(w, o, status)
+		return
+	}
+
+	size := int64(len(o.data))
+	start, end, partial, ok := parseRange(r.Header.Get("Range"), size)
+	if !ok {
+		writeError(w, errInvalidRange)
+		return
+	}
+
+	o.writeHeader(w)
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start, 10))
+	if partial {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, size))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	_, _ = w.Write(o.data[start:end])
+}
+
+func (s *Server) headObject(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	if e, ok := checkCustomerKey(r, o.header, "X-Amz-"); !ok {
+		writeError(w, e)
+		return
+	}
+	if status := checkReadConditions(r, o); status != 0 {
+		writeConditionFailed(w, o, status)
+		return
+	}
+
+	o.writeHeader(w)
+	w.Header().Set("Content-Length", strconv.Itoa(len(o.data)))
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeConditionFailed(w http.ResponseWriter, o *object, status int) {
+	if status == http.StatusNotModified {
+		w.Header().Set("ETag", o.etag)
+		w.Header().Set("Last-Modified", o.modified.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeError(w, errPreconditionFailed)
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+}
+
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, name, key string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+
+	// The copy source is in the form of `bucket/key?versionId=xxx`, and URL-encoded.
+	source := r.Header.Get("X-Amz-Copy-Source")
+	if idx := strings.Index(source, "?"); idx >= 0 {
+		source = source[:idx]
+	}
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		writeError(w, errInvalidArgument)
+		return
+	}
+	src, e, ok := s.lookupObject(splitPath(source))
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	if e, ok := checkCustomerKey(r, src.header, "X-Amz-Copy-Source-"); !ok {
+		writeError(w, e)
+		return
+	}
+
+	header := formatStoredHeader(r.Header)
+	if !strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		// Encryption is never copied from the source object, but specified in the request as S3 does.
+		replaced := header
+		header = src.header.Clone()
+		for k := range header {
+			if strings.HasPrefix(k, "X-Amz-Server-Side-Encryption") {
+				delete(header, k)
+			}
+		}
+		for k, v := range replaced {
+			if strings.HasPrefix(k, "X-Amz-Server-Side-Encryption") {
+				header[k] = v
+			}
+		}
+		if v := r.Header.Get("X-Amz-Storage-Class"); v != "" {
+			header.Set("X-Amz-Storage-Class", v)
+		}
+		header.Del("X-Amz-Restore")
+	}
+	// Content of objects will never be modified in place, so it's safe to share.
+	o := newObject(src.data, header)
+	o.tags = src.tags
+	if strings.EqualFold(r.Header.Get("X-Amz-Tagging-Directive"), "REPLACE") {
+		o.tags = parseTaggingHeader(r.Header)
+	}
+	b.objects[key] = o
+
+	writeXML(w, http.StatusOK, copyObjectResult{
+		ETag:         o.etag,
+		LastModified: formatTime(o.modified),
+	})
+}
+
+// restoreObject marks the archived object as being restored, the restore will only be completed by
+// Server.CompleteRestore.
+func (s *Server) restoreObject(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	if !o.archived() {
+		writeError(w, errInvalidObjectState)
+		return
+	}
+	if o.header.Get("X-Amz-Restore") != "" {
+		writeError(w, errRestoreInProgress)
+		return
+	}
+	o.header.Set("X-Amz-Restore", `ongoing-request="true"`)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, name, key string) {
+	b, ok := s.buckets[name]
+	if !ok {
+		writeError(w, errNoSuchBucket)
+		return
+	}
+	if r.Header.Get("If-Match") != "" {
+		if e, ok := checkWriteConditions(r, b.objects[key]); !ok {
+			writeError(w, e)
+			return
+		}
+	}
+	delete(b.objects, key)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) lookupObject(name, key string) (*object, apiError, bool) {
+	b, ok := s.buckets[name]
+	if !ok {
+		return nil, errNoSuchBucket, false
+	}
+	o, ok := b.objects[key]
+	if !ok {
+		return nil, errNoSuchKey, false
+	}
+	return o, apiError{}, true
+}
+
+// parseRange parses the Range header into [start, end), partial is false if the header is absent or
+// malformed, in which case the whole content will be returned as S3 does. ok is false if the range
+// is not satisfiable.
+func parseRange(h string, size int64) (start, end int64, partial, ok bool) {
+	if !strings.HasPrefix(h, "bytes=") || strings.Contains(h, ",") {
+		return 0, size, false, true
+	}
+	spec := strings.TrimPrefix(h, "bytes=")
+	idx := strings.Index(spec, "-")
+	if idx < 0 {
+		return 0, size, false, true
+	}
+	first, last := spec[:idx], spec[idx+1:]
+
+	if first == "" {
+		// bytes=-n means the last n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return 0, size, false, true
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, false, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size, true, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, size, false, true
+	}
+	end = size
+	if last != "" {
+		v, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || v < start {
+			return 0, size, false, true
+		}
+		if v+1 < size {
+			end = v + 1
+		}
+	}
+	if start >= size {
+		return 0, 0, false, false
+	}
+	return start, end, true, true
+}
@@ -0,0 +1,246 @@
+package s3test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestListPartDetails(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	contents := []string{"hello, ", "world"}
+	for i, v := range contents {
+		if _, _, err = m.WriteMultipart(o, strings.NewReader(v), int64(len(v)), i); err != nil {
+			t.Fatalf("write multipart: %v", err)
+		}
+	}
+
+	it := store.(*s3.Storage).ListPartDetails(context.Background(), o)
+	for i, v := range contents {
+		p, err := it.Next()
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if p.Index != i || p.Size != int64(len(v)) {
+			t.Errorf("expected part %d with size %d, got part %d with size %d", i, len(v), p.Index, p.Size)
+		}
+		sum := md5.Sum([]byte(v))
+		if p.ContentMD5 != hex.EncodeToString(sum[:]) {
+			t.Errorf("expected md5 %x, got %s", sum, p.ContentMD5)
+		}
+		if time.Since(p.LastModified) > time.Minute {
+			t.Errorf("unexpected last modified %v", p.LastModified)
+		}
+	}
+	if _, err = it.Next(); err != typ.IterateDone {
+		t.Errorf("expected %v, got %v", typ.IterateDone, err)
+	}
+}
+
+// cancelReader cancels the context once read, like a job canceled mid-upload.
+type cancelReader struct {
+	cancel context.CancelFunc
+}
+
+func (r cancelReader) Read(p []byte) (int, error) {
+	r.cancel()
+	return 0, context.Canceled
+}
+
+func TestWriteMultipartAbortOnCancel(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	if _, _, err = m.WriteMultipart(o, strings.NewReader("hello"), 5, 0); err != nil {
+		t.Fatalf("write multipart: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, err = m.WriteMultipartWithContext(ctx, o, cancelReader{cancel: cancel}, 5, 1, s3.WithAbortOnCancel())
+	if err == nil {
+		t.Fatalf("expected error of canceled upload")
+	}
+
+	it, err := store.List("", ps.WithListMode(typ.ListModePart))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if _, err = it.Next(); err != typ.IterateDone {
+		t.Errorf("expected upload to be aborted, got %v", err)
+	}
+}
+
+func TestWriteMultipartContentMD5(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+
+	content := "hello"
+	sum := md5.Sum([]byte(content))
+	if _, _, err = m.WriteMultipart(o, strings.NewReader(content), int64(len(content)), 0,
+		ps.WithContentMd5(base64.StdEncoding.EncodeToString(sum[:]))); err != nil {
+		t.Errorf("write multipart: %v", err)
+	}
+
+	sum = md5.Sum([]byte("world"))
+	if _, _, err = m.WriteMultipart(o, strings.NewReader(content), int64(len(content)), 1,
+		ps.WithContentMd5(base64.StdEncoding.EncodeToString(sum[:]))); err == nil {
+		t.Errorf("expected error of mismatched md5")
+	}
+}
+
+func TestListMultipartUploadInfo(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	if _, err := m.CreateMultipart("abc"); err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+
+	it, err := store.List("", ps.WithListMode(typ.ListModePart))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	o, err := it.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	sm := s3.GetObjectSystemMetadata(o)
+	if time.Since(sm.MultipartInitiated) > time.Minute {
+		t.Errorf("unexpected initiated time %v", sm.MultipartInitiated)
+	}
+	if sm.MultipartInitiatorID != "s3test" || sm.StorageClass != "STANDARD" {
+		t.Errorf("unexpected system metadata %+v", sm)
+	}
+}
+
+func TestCompleteMultipartInvalidPart(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	var parts []*typ.Part
+	for i, v := range []string{"hello, ", "world", "!"} {
+		_, part, err := m.WriteMultipart(o, strings.NewReader(v), int64(len(v)), i)
+		if err != nil {
+			t.Fatalf("write multipart: %v", err)
+		}
+		parts = append(parts, part)
+	}
+	parts[1] = &typ.Part{Index: 1, Size: parts[1].Size, ETag: `"stale"`}
+
+	err = m.CompleteMultipart(o, parts)
+	var pe s3.MultipartPartError
+	if !errors.As(err, &pe) || !errors.Is(err, s3.ErrMultipartPartInvalid) {
+		t.Fatalf("expected %v, got %v", s3.ErrMultipartPartInvalid, err)
+	}
+	if pe.Code != "InvalidPart" || len(pe.Indexes) != 1 || pe.Indexes[0] != 1 {
+		t.Errorf("expected part 1 to be invalid, got %v", pe.Indexes)
+	}
+}
+
+func TestCompleteMultipartWithoutResult(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	// The first complete responds 200 with a body of other formats, without completing the upload.
+	responded := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Query().Get("uploadId") != "" && !responded {
+			responded = true
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("<html><body>processing</body></html>"))
+			return
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	_, part, err := m.WriteMultipart(o, strings.NewReader("hello"), 5, 0)
+	if err != nil {
+		t.Fatalf("write multipart: %v", err)
+	}
+	if err = m.CompleteMultipart(o, []*typ.Part{part}); err != nil {
+		t.Fatalf("complete multipart: %v", err)
+	}
+	if !responded {
+		t.Fatalf("expected the first complete to be intercepted")
+	}
+
+	// The upload must have been completed by the retry.
+	if _, err = store.Stat("abc"); err != nil {
+		t.Errorf("stat: %v", err)
+	}
+}
+
+func TestCompleteMultipartResult(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+	_, part, err := m.WriteMultipart(o, strings.NewReader("hello"), 5, 0)
+	if err != nil {
+		t.Fatalf("write multipart: %v", err)
+	}
+	var result s3.WriteResult
+	if err = m.CompleteMultipart(o, []*typ.Part{part}, s3.WithWriteResult(&result)); err != nil {
+		t.Fatalf("complete multipart: %v", err)
+	}
+
+	stat, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	etag, _ := o.GetEtag()
+	if want, _ := stat.GetEtag(); etag != want || result.Etag != want {
+		t.Errorf("expected etag %s, got %s and %s", want, etag, result.Etag)
+	}
+	if size, _ := o.GetContentLength(); size != 5 {
+		t.Errorf("expected content length 5, got %d", size)
+	}
+	if loc := s3.GetObjectSystemMetadata(o).Location; loc == "" || loc != result.Location {
+		t.Errorf("expected location %q, got %q", result.Location, loc)
+	}
+}
@@ -0,0 +1,52 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestPathEscape(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test", ps.WithWorkDir("/data/"))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := "hello, world"
+	if _, err = store.Write("a/../b", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if o := store.Create("a/../b"); o.ID != "data/b" {
+		t.Errorf("expected id data/b, got %s", o.ID)
+	}
+
+	_, err = store.Write("../escape", strings.NewReader(content), int64(len(content)))
+	if !errors.Is(err, s3.ErrPathInvalid) {
+		t.Errorf("expected %v, got %v", s3.ErrPathInvalid, err)
+	}
+
+	o := store.Create("../escape")
+	if o == nil || o.Path != "../escape" {
+		t.Fatalf("expected object with the path, got %v", o)
+	}
+	if _, err = store.Read(o.Path, &bytes.Buffer{}); !errors.Is(err, s3.ErrPathInvalid) {
+		t.Errorf("expected %v, got %v", s3.ErrPathInvalid, err)
+	}
+
+	m := store.(typ.Multiparter)
+	o = store.Create("../escape", ps.WithMultipartID("id"))
+	if _, _, err = m.WriteMultipart(o, strings.NewReader(content), int64(len(content)), 0); !errors.Is(err, s3.ErrPathInvalid) {
+		t.Errorf("expected %v, got %v", s3.ErrPathInvalid, err)
+	}
+	if err = m.CompleteMultipart(o, nil); !errors.Is(err, s3.ErrPathInvalid) {
+		t.Errorf("expected %v, got %v", s3.ErrPathInvalid, err)
+	}
+}
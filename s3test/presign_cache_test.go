@@ -0,0 +1,78 @@
+package s3test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestPresignCache(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	signed := 0
+	handlers := s3.RequestHandlers{
+		Sign: []request.NamedHandler{{
+			Name: "s3test.CountSign",
+			Fn: func(r *request.Request) {
+				if r.Operation.Name == "GetObject" {
+					signed++
+				}
+			},
+		}},
+	}
+	store, err := srv.NewStorager("test",
+		s3.WithRequestHandlers(handlers),
+		s3.WithPresignCacheSize(10),
+		s3.WithPresignCacheReuseFraction(0.1),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	signer := store.(*s3.Storage)
+
+	first, err := signer.QuerySignHTTPRead("abc", time.Second)
+	if err != nil {
+		t.Fatalf("query sign: %v", err)
+	}
+	second, err := signer.QuerySignHTTPRead("abc", time.Second)
+	if err != nil {
+		t.Fatalf("query sign: %v", err)
+	}
+	if signed != 1 || first.URL.String() != second.URL.String() {
+		t.Errorf("expected the request to be reused, signed %d times", signed)
+	}
+	if first == second {
+		t.Errorf("expected a new request to be returned")
+	}
+
+	// Different objects and expires are signed separately.
+	if _, err = signer.QuerySignHTTPRead("def", time.Second); err != nil {
+		t.Fatalf("query sign: %v", err)
+	}
+	if _, err = signer.QuerySignHTTPRead("abc", time.Minute); err != nil {
+		t.Fatalf("query sign: %v", err)
+	}
+	if signed != 3 {
+		t.Errorf("expected 3 signs, got %d", signed)
+	}
+
+	// Requests are signed again after the fraction of the lifetime.
+	time.Sleep(150 * time.Millisecond)
+	if _, err = signer.QuerySignHTTPRead("abc", time.Second); err != nil {
+		t.Fatalf("query sign: %v", err)
+	}
+	if signed != 4 {
+		t.Errorf("expected the request to be renewed, signed %d times", signed)
+	}
+
+	_, err = srv.NewStorager("test", s3.WithPresignCacheSize(10), s3.WithPresignCacheReuseFraction(1.5))
+	if !errors.As(err, &services.PairUnsupportedError{}) {
+		t.Errorf("expected pair unsupported, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestProgressCallback(t *testing.T) {
+	store := setupStorager(t)
+
+	content := bytes.Repeat([]byte("x"), 64*1024)
+	size := int64(len(content))
+
+	var reports []s3.Progress
+	progress := s3.WithProgressCallback(func(p s3.Progress) {
+		reports = append(reports, p)
+	})
+	check := func(name string, total int64) {
+		if len(reports) == 0 {
+			t.Fatalf("%s: no progress reported", name)
+		}
+		last := reports[len(reports)-1]
+		if last.Done != total || last.Total != total || last.ETA != 0 {
+			t.Errorf("%s: unexpected final progress %+v", name, last)
+		}
+		reports = nil
+	}
+
+	if _, err := store.Write("abc", bytes.NewReader(content), size, progress); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	check("write", size)
+
+	var buf bytes.Buffer
+	if _, err := store.Read("abc", &buf, progress); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	check("read", size)
+
+	dir, err := ioutil.TempDir("", "s3test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Resume from a partial download, the progress should cover the whole object.
+	localPath := filepath.Join(dir, "abc")
+	if err = ioutil.WriteFile(localPath+".part", content[:1024], 0644); err != nil {
+		t.Fatalf("write part: %v", err)
+	}
+	o, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	etag, _ := o.GetEtag()
+	if err = ioutil.WriteFile(localPath+".part.etag", []byte(etag), 0644); err != nil {
+		t.Fatalf("write etag: %v", err)
+	}
+	if err = store.(*s3.Storage).DownloadFile(context.Background(), "abc", localPath, progress); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if reports[0].Done < 1024 {
+		t.Errorf("expected progress to start from the partial file, got %+v", reports[0])
+	}
+	check("download", size)
+
+	w := store.(*s3.Storage).NewWriter(context.Background(), "def", progress)
+	if _, err = w.Write(content); err != nil {
+		t.Fatalf("writer write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("writer close: %v", err)
+	}
+	check("writer", size)
+}
@@ -0,0 +1,210 @@
+package s3test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// statusRecorder records the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func newReadCacheStorager(t *testing.T, pairs ...typ.Pair) (typ.Storager, *int64) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	srv.CreateBucket("test")
+
+	var notModified int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		srv.ServeHTTP(rec, r)
+		if rec.status == http.StatusNotModified {
+			atomic.AddInt64(&notModified, 1)
+		}
+	}))
+	t.Cleanup(proxy.Close)
+
+	dir, err := ioutil.TempDir("", "s3test-read-cache")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	pairs = append(pairs,
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		s3.WithReadCacheDir(dir),
+	)
+	store, err := srv.NewStorager("test", pairs...)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	return store, &notModified
+}
+
+func TestReadCache(t *testing.T) {
+	store, notModified := newReadCacheStorager(t)
+
+	read := func(pairs ...typ.Pair) string {
+		buf := &bytes.Buffer{}
+		if _, err := store.Read("abc", buf, pairs...); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return buf.String()
+	}
+
+	if _, err := store.Write("abc", strings.NewReader("hello, world"), 12); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if v := read(); v != "hello, world" || atomic.LoadInt64(notModified) != 0 {
+		t.Fatalf("unexpected first read %q", v)
+	}
+	if v := read(); v != "hello, world" || atomic.LoadInt64(notModified) != 1 {
+		t.Errorf("expected the read to be served from cache, got %q", v)
+	}
+	if v := read(ps.WithOffset(7), ps.WithSize(3)); v != "wor" || atomic.LoadInt64(notModified) != 2 {
+		t.Errorf("expected the range to be served from cache, got %q", v)
+	}
+	if v := read(s3.WithSuffixSize(5)); v != "world" || atomic.LoadInt64(notModified) != 3 {
+		t.Errorf("expected the suffix to be served from cache, got %q", v)
+	}
+
+	// Modified objects are fetched again.
+	if _, err := store.Write("abc", strings.NewReader("bye"), 3); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if v := read(); v != "bye" || atomic.LoadInt64(notModified) != 3 {
+		t.Errorf("expected the modified object, got %q", v)
+	}
+	if v := read(); v != "bye" || atomic.LoadInt64(notModified) != 4 {
+		t.Errorf("expected the modified object to be cached, got %q", v)
+	}
+}
+
+func TestReadCacheMaxSize(t *testing.T) {
+	store, notModified := newReadCacheStorager(t, s3.WithReadCacheMaxSize(15))
+
+	for _, p := range []string{"a", "b"} {
+		if _, err := store.Write(p, strings.NewReader(strings.Repeat(p, 10)), 10); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err := store.Read(p, ioutil.Discard); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+
+	// a has been evicted as it's least recently used.
+	if _, err := store.Read("a", ioutil.Discard); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if atomic.LoadInt64(notModified) != 0 {
+		t.Errorf("expected a to be evicted")
+	}
+}
+
+// assertNoPlaintextCached fails if any file in dir contains content.
+func assertNoPlaintextCached(t *testing.T, dir, content string) (files int) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	for _, fi := range fis {
+		b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatalf("read file: %v", err)
+		}
+		if strings.Contains(string(b), content) {
+			t.Errorf("expected no plaintext cached, found in %s", fi.Name())
+		}
+	}
+	return len(fis)
+}
+
+func TestReadCacheEncrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3test-read-cache")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The SDK refuses to send customer keys over plain HTTP.
+	srv := NewTLSServer()
+	defer srv.Close()
+	bundle := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(bundle, srv.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	os.Setenv("AWS_CA_BUNDLE", bundle)
+	defer os.Unsetenv("AWS_CA_BUNDLE")
+
+	content := "hello, world"
+	read := func(store typ.Storager, pairs ...typ.Pair) {
+		buf := &bytes.Buffer{}
+		if _, err := store.Read("abc", buf, pairs...); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if buf.String() != content {
+			t.Errorf("expected %q, got %q", content, buf.String())
+		}
+	}
+
+	t.Run("customer key", func(t *testing.T) {
+		cacheDir := filepath.Join(dir, "sse-c")
+		store, err := srv.NewStorager("sse-c", s3.WithReadCacheDir(cacheDir))
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		keyPairs := []typ.Pair{
+			s3.WithServerSideEncryptionCustomerAlgorithm(s3.ServerSideEncryptionAes256),
+			s3.WithServerSideEncryptionCustomerKey(bytes.Repeat([]byte{1}, 32)),
+		}
+		if _, err = store.Write("abc", strings.NewReader(content), int64(len(content)), keyPairs...); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		read(store, keyPairs...)
+		read(store, keyPairs...)
+
+		// Objects returned in plaintext are not cached at all.
+		if n := assertNoPlaintextCached(t, cacheDir, content); n != 0 {
+			t.Errorf("expected nothing cached, got %d files", n)
+		}
+	})
+
+	t.Run("client side encryption", func(t *testing.T) {
+		cacheDir := filepath.Join(dir, "cse")
+		store, err := srv.NewStorager("cse",
+			s3.WithReadCacheDir(cacheDir),
+			s3.WithClientSideEncryption(s3.ClientSideEncryption{MasterKey: bytes.Repeat([]byte{1}, 32)}),
+		)
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		read(store)
+		read(store)
+
+		// The ciphertext is cached and decrypted on every read.
+		if n := assertNoPlaintextCached(t, cacheDir, content); n == 0 {
+			t.Errorf("expected the ciphertext cached")
+		}
+	})
+}
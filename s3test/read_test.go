@@ -0,0 +1,324 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// truncatingHandler forwards requests to srv, while the body of the next `truncate` GET responses
+// are cut in the middle with the original `Content-Length`.
+type truncatingHandler struct {
+	srv      *Server
+	truncate int32
+}
+
+func (h *truncatingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || atomic.AddInt32(&h.truncate, -1) < 0 {
+		h.srv.ServeHTTP(w, r)
+		return
+	}
+
+	rec := httptest.NewRecorder()
+	h.srv.ServeHTTP(rec, r)
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	body := rec.Body.Bytes()
+	w.WriteHeader(rec.Code)
+	// The server will close the connection as fewer bytes than Content-Length are written.
+	_, _ = w.Write(body[:len(body)/2])
+}
+
+// newTruncatingStorager returns a storager of bucket `test` with abc written, whose reads are
+// truncated by the returned handler.
+func newTruncatingStorager(t *testing.T, content string) (typ.Storager, *truncatingHandler) {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	srv.CreateBucket("test")
+
+	h := &truncatingHandler{srv: srv}
+	proxy := httptest.NewServer(h)
+	t.Cleanup(proxy.Close)
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return store, h
+}
+
+func TestReadTruncatedBody(t *testing.T) {
+	content := strings.Repeat("x", 1024)
+	store, h := newTruncatingStorager(t, content)
+	atomic.StoreInt32(&h.truncate, 1)
+
+	buf := &bytes.Buffer{}
+	_, err := store.Read("abc", buf)
+	var te s3.TruncatedBodyError
+	if !errors.As(err, &te) || !errors.Is(err, s3.ErrTruncatedBody) {
+		t.Fatalf("expected %v, got %v", s3.ErrTruncatedBody, err)
+	}
+	if te.Expected != 1024 || te.Received != int64(buf.Len()) || te.Received >= 1024 {
+		t.Errorf("unexpected error %+v with %d bytes read", te, buf.Len())
+	}
+
+	// The following reads are not truncated.
+	buf.Reset()
+	if _, err = store.Read("abc", buf); err != nil || buf.String() != content {
+		t.Errorf("read: %v", err)
+	}
+}
+
+func TestReadResumeAttempts(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	cases := []struct {
+		name     string
+		truncate int32
+		pairs    []typ.Pair
+		expected string
+		err      error
+	}{
+		{"resumed", 2, []typ.Pair{s3.WithReadResumeAttempts(2)}, content, nil},
+		{"resumed range", 2, []typ.Pair{s3.WithReadResumeAttempts(3), ps.WithOffset(100), ps.WithSize(500)}, content[100:600], nil},
+		{"attempts exhausted", 3, []typ.Pair{s3.WithReadResumeAttempts(2)}, "", s3.ErrTruncatedBody},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			store, h := newTruncatingStorager(t, content)
+			atomic.StoreInt32(&h.truncate, tt.truncate)
+
+			buf := &bytes.Buffer{}
+			n, err := store.Read("abc", buf, tt.pairs...)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if n != int64(len(tt.expected)) || buf.String() != tt.expected {
+				t.Errorf("expected %d bytes, got %d", len(tt.expected), n)
+			}
+		})
+	}
+}
+
+// upperReadCloser is a transform which upper-cases the content and records whether it's closed.
+type upperReadCloser struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (r *upperReadCloser) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	copy(p[:n], bytes.ToUpper(p[:n]))
+	return
+}
+
+func (r *upperReadCloser) Close() error {
+	r.closed = true
+	return r.ReadCloser.Close()
+}
+
+func TestReadTransform(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var transformed []*upperReadCloser
+	transform := s3.ReadTransform(func(rc io.ReadCloser) io.ReadCloser {
+		r := &upperReadCloser{ReadCloser: rc}
+		transformed = append(transformed, r)
+		return r
+	})
+
+	// The transform is applied to all reads via default pairs.
+	store, err := srv.NewStorager("test", s3.WithDefaultStoragePairs(s3.DefaultStoragePairs{
+		Read: []typ.Pair{s3.WithReadTransform(transform)},
+	}))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = store.Write("abc", strings.NewReader("hello"), 5); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err = store.Read("abc", buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != "HELLO" {
+		t.Errorf("expected transformed content, got %q", buf.String())
+	}
+	if len(transformed) != 1 || !transformed[0].closed {
+		t.Errorf("expected the transformed body to be closed")
+	}
+
+	// The transform of the read overrides the default one.
+	buf.Reset()
+	_, err = store.Read("abc", buf, s3.WithReadTransform(func(rc io.ReadCloser) io.ReadCloser {
+		return ioutil.NopCloser(io.LimitReader(rc, 2))
+	}))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != "he" || len(transformed) != 1 {
+		t.Errorf("expected the transform of the read, got %q", buf.String())
+	}
+
+	// Errors of the transformed body are returned by read.
+	_, err = store.Read("abc", ioutil.Discard, s3.WithReadTransform(func(rc io.ReadCloser) io.ReadCloser {
+		return ioutil.NopCloser(errReader{})
+	}))
+	if !errors.Is(err, services.ErrUnexpected) {
+		t.Errorf("expected %v, got %v", services.ErrUnexpected, err)
+	}
+}
+
+// errReader always fails.
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("transform failed")
+}
+
+func TestReadRange(t *testing.T) {
+	store := setupStorager(t)
+
+	content := "hello, world"
+	if _, err := store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cases := []struct {
+		name     string
+		pairs    []typ.Pair
+		expected string
+		err      error
+	}{
+		{"offset and size", []typ.Pair{ps.WithOffset(7), ps.WithSize(3)}, "wor", nil},
+		{"offset", []typ.Pair{ps.WithOffset(7)}, "world", nil},
+		{"size", []typ.Pair{ps.WithSize(5)}, "hello", nil},
+		{"suffix", []typ.Pair{s3.WithSuffixSize(5)}, "world", nil},
+		{"suffix larger than object", []typ.Pair{s3.WithSuffixSize(100)}, content, nil},
+		{"offset past the end", []typ.Pair{ps.WithOffset(100)}, "", s3.ErrRangeNotSatisfiable},
+		{"empty suffix", []typ.Pair{s3.WithSuffixSize(0)}, "", s3.ErrRangeNotSatisfiable},
+		{"suffix with offset", []typ.Pair{s3.WithSuffixSize(5), ps.WithOffset(1)}, "", services.ErrRestrictionDissatisfied},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := store.Read("abc", &buf, tt.pairs...)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if buf.String() != tt.expected || n != int64(len(tt.expected)) {
+				t.Errorf("expected %q, got %d bytes %q", tt.expected, n, buf.String())
+			}
+		})
+	}
+}
+
+func TestReadObjectCallback(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var heads int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := "hello, world"
+	_, err = store.Write("abc", strings.NewReader(content), int64(len(content)),
+		ps.WithContentType("text/plain"),
+		s3.WithCacheControl("no-cache"),
+		s3.WithStorageClass(s3.StorageClassStandardIa),
+		s3.WithUserMetadata(map[string]string{"k": "v"}),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expected, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	atomic.StoreInt64(&heads, 0)
+
+	cases := []struct {
+		name  string
+		pairs []typ.Pair
+	}{
+		{"whole", nil},
+		// The size of the whole object is returned for range reads.
+		{"range", []typ.Pair{ps.WithOffset(7), ps.WithSize(3)}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			var o *typ.Object
+			pairs := append(tt.pairs, s3.WithObjectCallback(func(v *typ.Object) { o = v }))
+			if _, err := store.Read("abc", ioutil.Discard, pairs...); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if o == nil {
+				t.Fatalf("expected object callback to be called")
+			}
+
+			if o.Path != "abc" || o.MustGetContentLength() != int64(len(content)) {
+				t.Errorf("expected abc of %d bytes, got %s of %d bytes", len(content), o.Path, o.MustGetContentLength())
+			}
+			if o.MustGetEtag() != expected.MustGetEtag() {
+				t.Errorf("expected etag %s, got %s", expected.MustGetEtag(), o.MustGetEtag())
+			}
+			if !o.MustGetLastModified().Equal(expected.MustGetLastModified()) {
+				t.Errorf("expected last modified %v, got %v", expected.MustGetLastModified(), o.MustGetLastModified())
+			}
+			if o.MustGetContentType() != "text/plain" {
+				t.Errorf("expected content type text/plain, got %s", o.MustGetContentType())
+			}
+			if m := o.MustGetUserMetadata(); m["k"] != "v" {
+				t.Errorf("expected user metadata, got %v", m)
+			}
+			sm := s3.GetObjectSystemMetadata(o)
+			if sm.CacheControl != "no-cache" || sm.StorageClass != s3.StorageClassStandardIa {
+				t.Errorf("unexpected system metadata %+v", sm)
+			}
+		})
+	}
+
+	if n := atomic.LoadInt64(&heads); n != 0 {
+		t.Errorf("expected no HEAD requests, got %d", n)
+	}
+}
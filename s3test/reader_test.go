@@ -0,0 +1,157 @@
+package s3test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+)
+
+func TestReaderAt(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var gets int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt64(&gets, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	content := make([]byte, 3*1024*1024+17)
+	rand.New(rand.NewSource(1)).Read(content)
+	if _, err = s.Write("abc", bytes.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	t.Run("read at", func(t *testing.T) {
+		r, err := s.NewReaderAt(context.Background(), "abc")
+		if err != nil {
+			t.Fatalf("new reader at: %v", err)
+		}
+		if r.Size() != int64(len(content)) {
+			t.Errorf("expected size %d, got %d", len(content), r.Size())
+		}
+
+		atomic.StoreInt64(&gets, 0)
+		// Small reads following each other are served from the read-ahead buffer.
+		for _, off := range []int64{0, 100, 4096, 1000} {
+			p := make([]byte, 512)
+			if _, err = r.ReadAt(p, off); err != nil {
+				t.Fatalf("read at %d: %v", off, err)
+			}
+			if !bytes.Equal(p, content[off:off+512]) {
+				t.Errorf("read at %d: content mismatch", off)
+			}
+		}
+		if n := atomic.LoadInt64(&gets); n != 1 {
+			t.Errorf("expected 1 GET request, got %d", n)
+		}
+
+		// Reading past the end returns the rest of the content with io.EOF.
+		p := make([]byte, 100)
+		n, err := r.ReadAt(p, int64(len(content)-10))
+		if n != 10 || err != io.EOF || !bytes.Equal(p[:n], content[len(content)-10:]) {
+			t.Errorf("expected the last 10 bytes with EOF, got %d, %v", n, err)
+		}
+
+		// Large reads are sent directly.
+		p = make([]byte, 2*1024*1024)
+		if _, err = r.ReadAt(p, 12345); err != nil {
+			t.Fatalf("read at: %v", err)
+		}
+		if !bytes.Equal(p, content[12345:12345+len(p)]) {
+			t.Errorf("large read at: content mismatch")
+		}
+	})
+
+	t.Run("read seek", func(t *testing.T) {
+		r, err := s.NewReaderAt(context.Background(), "abc")
+		if err != nil {
+			t.Fatalf("new reader at: %v", err)
+		}
+		if _, err = r.Seek(-1024, io.SeekEnd); err != nil {
+			t.Fatalf("seek: %v", err)
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if !bytes.Equal(b, content[len(content)-1024:]) {
+			t.Errorf("read after seek: content mismatch")
+		}
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, name := range []string{"a.txt", "b.txt"} {
+			w, err := zw.Create(name)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			_, _ = w.Write([]byte("content of " + name))
+		}
+		if err = zw.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		if _, err = s.Write("archive.zip", &buf, int64(buf.Len())); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+
+		r, err := s.NewReaderAt(context.Background(), "archive.zip")
+		if err != nil {
+			t.Fatalf("new reader at: %v", err)
+		}
+		zr, err := zip.NewReader(r, r.Size())
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		if len(zr.File) != 2 {
+			t.Fatalf("expected 2 files, got %d", len(zr.File))
+		}
+		f, err := zr.File[1].Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", zr.File[1].Name, err)
+		}
+		defer f.Close()
+		b, _ := ioutil.ReadAll(f)
+		if string(b) != "content of b.txt" {
+			t.Errorf("unexpected content %q", b)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		r, err := s.NewReaderAt(context.Background(), "abc")
+		if err != nil {
+			t.Fatalf("new reader at: %v", err)
+		}
+		changed := "changed"
+		if _, err = s.Write("abc", strings.NewReader(changed), int64(len(changed))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if _, err = r.ReadAt(make([]byte, 10), 0); !errors.Is(err, s3.ErrPreconditionFailed) {
+			t.Errorf("expected precondition failed, got %v", err)
+		}
+	})
+}
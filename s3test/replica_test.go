@@ -0,0 +1,62 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestReadReplica(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	replica, err := srv.NewStorager("replica")
+	if err != nil {
+		t.Fatalf("new replica: %v", err)
+	}
+	store, err := srv.NewStorager("primary", s3.WithReadReplica(replica))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	for _, v := range []struct {
+		path    string
+		content string
+		replica bool
+	}{
+		{"replicated", "from primary", true},
+		{"lagging", "from primary", false},
+	} {
+		if _, err = store.Write(v.path, strings.NewReader(v.content), int64(len(v.content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if v.replica {
+			if _, err = replica.Write(v.path, strings.NewReader("from replica"), 12); err != nil {
+				t.Fatalf("write replica: %v", err)
+			}
+		}
+	}
+
+	cases := map[string]string{
+		"replicated": "from replica",
+		// Objects not replicated yet are read from the primary.
+		"lagging": "from primary",
+	}
+	for path, expected := range cases {
+		buf := &bytes.Buffer{}
+		if _, err = store.Read(path, buf); err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if buf.String() != expected {
+			t.Errorf("%s: expected %q, got %q", path, expected, buf.String())
+		}
+	}
+
+	if _, err = store.Read("missing", &bytes.Buffer{}); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected %v, got %v", services.ErrObjectNotExist, err)
+	}
+}
@@ -0,0 +1,73 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+)
+
+func TestReadArchived(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	content := "hello, world"
+	if _, err = s.Write("abc", strings.NewReader(content), int64(len(content)), s3.WithStorageClass(s3.StorageClassGlacier)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	read := func() (string, error) {
+		var buf bytes.Buffer
+		_, err := s.Read("abc", &buf)
+		return buf.String(), err
+	}
+
+	_, err = read()
+	var e s3.ObjectArchivedError
+	if !errors.Is(err, s3.ErrObjectArchived) || !errors.As(err, &e) {
+		t.Fatalf("expected object archived, got %v", err)
+	}
+	if e.StorageClass != s3.StorageClassGlacier || e.RestoreOngoing {
+		t.Errorf("expected %s without restore, got %+v", s3.StorageClassGlacier, e)
+	}
+
+	if err = s.Restore(context.Background(), "abc", 1, "Standard"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err = read(); !errors.As(err, &e) || !e.RestoreOngoing {
+		t.Errorf("expected object archived with restore ongoing, got %v", err)
+	}
+	o, err := s.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if sm := s3.GetObjectSystemMetadata(o); !sm.RestoreOngoing {
+		t.Errorf("expected restore ongoing, got %+v", sm)
+	}
+
+	expiry := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	srv.CompleteRestore("test", "abc", expiry)
+	v, err := read()
+	if err != nil {
+		t.Fatalf("read restored: %v", err)
+	}
+	if v != content {
+		t.Errorf("expected %q, got %q", content, v)
+	}
+	if o, err = s.Stat("abc"); err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if sm := s3.GetObjectSystemMetadata(o); sm.RestoreOngoing || !sm.RestoreExpiryDate.Equal(expiry) {
+		t.Errorf("expected restore completed until %v, got %+v", expiry, sm)
+	}
+}
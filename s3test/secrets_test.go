@@ -0,0 +1,107 @@
+package s3test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestCredentialProvider(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var lock sync.Mutex
+	calls := 0
+	provider := func(ctx context.Context) (s3.CredentialValue, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		calls++
+		return s3.CredentialValue{AccessKeyID: "s3test", SecretAccessKey: "s3test"}, nil
+	}
+
+	// The credential pair is not required with a provider.
+	store, err := s3.NewStorager(
+		s3.WithCredentialProvider(provider),
+		ps.WithEndpoint(srv.Endpoint()),
+		ps.WithLocation(Location),
+		ps.WithName("test"),
+		s3.WithForcePathStyle(),
+	)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		content := "hello, world"
+		if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the provider to be called once, got %d", calls)
+	}
+}
+
+func TestCustomerKeyProvider(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	key := bytes.Repeat([]byte{1}, 32)
+	var lock sync.Mutex
+	var requested []string
+	provider := func(ctx context.Context, k string) ([]byte, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		requested = append(requested, k)
+		if strings.HasPrefix(k, "plain/") {
+			return nil, nil
+		}
+		return key, nil
+	}
+
+	store, err := srv.NewStorager("test", s3.WithServerSideEncryptionCustomerKeyProvider(provider))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	sum := md5.Sum(key)
+	cases := []struct {
+		path   string
+		keyMd5 string
+	}{
+		{"secret/abc", base64.StdEncoding.EncodeToString(sum[:])},
+		{"plain/abc", ""},
+	}
+	for _, tt := range cases {
+		t.Run(tt.path, func(t *testing.T) {
+			content := "hello, world"
+			if _, err := store.Write(tt.path, strings.NewReader(content), int64(len(content))); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			var o *typ.Object
+			if o, err = store.Stat(tt.path); err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			sm := s3.GetObjectSystemMetadata(o)
+			if sm.ServerSideEncryptionCustomerKeyMd5 != tt.keyMd5 {
+				t.Errorf("expected key md5 %q, got %q", tt.keyMd5, sm.ServerSideEncryptionCustomerKeyMd5)
+			}
+		})
+	}
+
+	if len(requested) != 4 || requested[0] != "secret/abc" || requested[2] != "plain/abc" {
+		t.Errorf("unexpected keys requested: %v", requested)
+	}
+}
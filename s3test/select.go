@@ -0,0 +1,104 @@
+package s3test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/private/protocol/eventstream"
+)
+
+type selectObjectContentRequest struct {
+	XMLName            xml.Name `xml:"SelectObjectContentRequest"`
+	Expression         string   `xml:"Expression"`
+	ExpressionType     string   `xml:"ExpressionType"`
+	InputSerialization struct {
+		CSV *struct {
+			FileHeaderInfo string `xml:"FileHeaderInfo"`
+		} `xml:"CSV"`
+		JSON *struct {
+			Type string `xml:"Type"`
+		} `xml:"JSON"`
+	} `xml:"InputSerialization"`
+}
+
+type selectStats struct {
+	XMLName        xml.Name `xml:"Stats"`
+	BytesScanned   int64    `xml:"BytesScanned"`
+	BytesProcessed int64    `xml:"BytesProcessed"`
+	BytesReturned  int64    `xml:"BytesReturned"`
+}
+
+// selectExpression is the only form of SQL supported by s3test, which returns every record as is.
+var selectExpression = regexp.MustCompile(`(?i)^\s*SELECT\s+\*\s+FROM\s+S3Object(?:\s+LIMIT\s+(\d+))?\s*$`)
+
+// selectObjectContent runs `SELECT * FROM S3Object [LIMIT n]` over objects with a record per line,
+// which is CSV or JSON LINES, and returns the records in the input format as is.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/API/API_SelectObjectContent.html
+func (s *Server) selectObjectContent(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	if e, ok := checkCustomerKey(r, o.header, "X-Amz-"); !ok {
+		writeError(w, e)
+		return
+	}
+
+	var req selectObjectContentRequest
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errMalformedXML)
+		return
+	}
+	m := selectExpression.FindStringSubmatch(req.Expression)
+	in := req.InputSerialization
+	if m == nil || !strings.EqualFold(req.ExpressionType, "SQL") ||
+		(in.CSV == nil && (in.JSON == nil || !strings.EqualFold(in.JSON.Type, "LINES"))) {
+		writeError(w, errNotImplemented)
+		return
+	}
+
+	records := strings.SplitAfter(string(o.data), "\n")
+	if records[len(records)-1] == "" {
+		records = records[:len(records)-1]
+	}
+	if in.CSV != nil && len(records) > 0 {
+		switch strings.ToUpper(in.CSV.FileHeaderInfo) {
+		case "USE", "IGNORE":
+			records = records[1:]
+		}
+	}
+	if m[1] != "" {
+		if limit, err := strconv.Atoi(m[1]); err == nil && limit < len(records) {
+			records = records[:limit]
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	enc := eventstream.NewEncoder(w)
+	stats := selectStats{BytesScanned: int64(len(o.data)), BytesProcessed: int64(len(o.data))}
+	// Every record is sent in its own event, so that clients must join them.
+	for _, v := range records {
+		stats.BytesReturned += int64(len(v))
+		_ = enc.Encode(newSelectEvent("Records", "application/octet-stream", []byte(v)))
+	}
+	var buf bytes.Buffer
+	_ = xml.NewEncoder(&buf).Encode(stats)
+	_ = enc.Encode(newSelectEvent("Stats", "text/xml", buf.Bytes()))
+	_ = enc.Encode(newSelectEvent("End", "", nil))
+}
+
+func newSelectEvent(typ, contentType string, payload []byte) eventstream.Message {
+	msg := eventstream.Message{Payload: payload}
+	msg.Headers.Set(":message-type", eventstream.StringValue("event"))
+	msg.Headers.Set(":event-type", eventstream.StringValue(typ))
+	if contentType != "" {
+		msg.Headers.Set(":content-type", eventstream.StringValue(contentType))
+	}
+	return msg
+}
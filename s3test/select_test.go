@@ -0,0 +1,77 @@
+package s3test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestSelect(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	store, err := srv.NewStorager("test", s3.WithEnableSelect())
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	s := store.(*s3.Storage)
+
+	content := "name,age\nalice,20\nbob,30\ncarol,40\n"
+	if _, err = s.Write("people.csv", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	query := s3.SelectQuery{
+		Expression: "SELECT * FROM S3Object LIMIT 2",
+		Input: &awss3.InputSerialization{
+			CSV: &awss3.CSVInput{FileHeaderInfo: aws.String(awss3.FileHeaderInfoUse)},
+		},
+		Output: &awss3.OutputSerialization{CSV: &awss3.CSVOutput{}},
+	}
+
+	t.Run("records", func(t *testing.T) {
+		r, err := s.Select(context.Background(), "people.csv", query)
+		if err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		defer r.Close()
+
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		expected := "alice,20\nbob,30\n"
+		if string(b) != expected {
+			t.Errorf("expected %q, got %q", expected, b)
+		}
+		stats := r.Stats()
+		if stats.BytesScanned != int64(len(content)) || stats.BytesReturned != int64(len(expected)) {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		_, err := s.Select(context.Background(), "missing.csv", query)
+		if !errors.Is(err, services.ErrObjectNotExist) {
+			t.Errorf("expected object not exist, got %v", err)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		store, err := srv.NewStorager("test")
+		if err != nil {
+			t.Fatalf("new storager: %v", err)
+		}
+		_, err = store.(*s3.Storage).Select(context.Background(), "people.csv", query)
+		if !errors.Is(err, services.ErrCapabilityInsufficient) {
+			t.Errorf("expected capability insufficient, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,304 @@
+// Package s3test provides an in-memory S3 server, so that code built on go-service-s3 could be
+// unit tested without Docker or real AWS.
+//
+// The server speaks the S3 REST API over HTTP (or HTTPS, see NewTLSServer) and is accessed by the
+// real SDK client, only the subset of the API used by Storage is implemented:
+//
+//   - buckets: create, delete, head, list, get location, encryption and versioning and
+//     get/put/delete policy and lifecycle
+//   - objects: put, get (with range and conditional headers), head, copy, delete, restore, select
+//     (only `SELECT * FROM S3Object [LIMIT n]`), list (v2), get/put/delete tagging and put legal
+//     hold and retention
+//   - multipart uploads: create, upload part, list parts, list uploads, complete and abort
+//
+// Requests are not authenticated, and features like versioning and ACL are ignored, while legal
+// holds and retention are stored but not enforced. Objects encrypted with customer-provided keys
+// (SSE-C) must be accessed with the same key as S3 requires.
+package s3test
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// Location is the location of the buckets served by Server.
+const Location = "us-east-1"
+
+// Server is an in-memory S3 server, which is safe for concurrent use.
+type Server struct {
+	srv *httptest.Server
+
+	lock     sync.Mutex
+	buckets  map[string]*bucket
+	uploadID int64
+}
+
+type bucket struct {
+	created time.Time
+	objects map[string]*object
+	uploads map[string]*upload
+	// policy is the bucket policy, which is stored but not enforced.
+	policy []byte
+	// lifecycle is the lifecycle configuration, which is stored but not enforced.
+	lifecycle []byte
+}
+
+type object struct {
+	data     []byte
+	etag     string
+	modified time.Time
+	// header contains the headers stored along with the object, like Content-Type and x-amz-meta-*.
+	header http.Header
+	tags   map[string]string
+}
+
+type upload struct {
+	key       string
+	initiated time.Time
+	header    http.Header
+	tags      map[string]string
+	parts     map[int]*object
+}
+
+// NewServer starts a new Server, which should be closed after use.
+func NewServer() *Server {
+	s := &Server{
+		buckets: make(map[string]*bucket),
+	}
+	s.srv = httptest.NewServer(s)
+	return s
+}
+
+// NewTLSServer starts a new Server serving over HTTPS, which is required by the SDK to send
+// customer-provided encryption keys. The certificate is self-signed, so clients must be told to
+// trust it, e.g. by pointing AWS_CA_BUNDLE to a file with the content of CertificatePEM.
+func NewTLSServer() *Server {
+	s := &Server{
+		buckets: make(map[string]*bucket),
+	}
+	s.srv = httptest.NewTLSServer(s)
+	return s
+}
+
+// URL returns the base url of the server, like `http://127.0.0.1:12345`.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Endpoint returns the endpoint pair value of the server, like `http:127.0.0.1:12345`.
+func (s *Server) Endpoint() string {
+	return strings.Replace(s.srv.URL, "://", ":", 1)
+}
+
+// Client returns an HTTP client configured for making requests to the server, which trusts the
+// certificate of a server started by NewTLSServer.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// CertificatePEM returns the PEM encoded certificate of a server started by NewTLSServer.
+func (s *Server) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.srv.Certificate().Raw})
+}
+
+// Close shuts down the server and blocks until all outstanding requests have completed.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// CreateBucket will create the bucket if it doesn't exist.
+func (s *Server) CreateBucket(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.buckets[name]; ok {
+		return
+	}
+	s.buckets[name] = newBucket()
+}
+
+// SetBucketPolicy will create the bucket if it doesn't exist, and set the bucket policy, which
+// is stored but not enforced. An empty policy will delete it.
+func (s *Server) SetBucketPolicy(name, policy string) {
+	s.CreateBucket(name)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	b := s.buckets[name]
+	b.policy = nil
+	if policy != "" {
+		b.policy = []byte(policy)
+	}
+}
+
+// CompleteRestore will complete the ongoing restore of the archived object, which makes it readable
+// until expiry. Restores are never completed by the server itself, as they take hours in S3.
+func (s *Server) CompleteRestore(bucket, key string, expiry time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	o, _, ok := s.lookupObject(bucket, key)
+	if !ok {
+		return
+	}
+	o.header.Set("X-Amz-Restore", fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, expiry.UTC().Format(http.TimeFormat)))
+}
+
+// Pairs returns the pairs used to connect to the bucket on the server.
+func (s *Server) Pairs(bucket string) []typ.Pair {
+	return []typ.Pair{
+		ps.WithCredential("hmac:s3test:s3test"),
+		ps.WithEndpoint(s.Endpoint()),
+		ps.WithLocation(Location),
+		ps.WithName(bucket),
+		s3.WithForcePathStyle(),
+	}
+}
+
+// NewStorager will create the bucket if it doesn't exist, and return a Storager connected to it.
+//
+// Pairs passed in will win over the ones returned by Pairs.
+func (s *Server) NewStorager(bucket string, pairs ...typ.Pair) (typ.Storager, error) {
+	s.CreateBucket(bucket)
+	return s3.NewStorager(append(pairs, s.Pairs(bucket)...)...)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, key := splitPath(r.URL.Path)
+	q := r.URL.Query()
+
+	// Bodies are read before locking the server, so that a request streaming the response of
+	// another request as its body will not block the server.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, errIncompleteBody)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if name == "" {
+		if r.Method == http.MethodGet {
+			s.listBuckets(w, r)
+			return
+		}
+		writeError(w, errNotImplemented)
+		return
+	}
+
+	if key == "" {
+		// Requests to subresources must be matched before the ones to the bucket itself.
+		switch {
+		case r.Method == http.MethodGet && has(q, "location"):
+			s.getBucketLocation(w, r, name)
+		case r.Method == http.MethodGet && has(q, "encryption"):
+			s.getBucketEncryption(w, r, name)
+		case r.Method == http.MethodGet && has(q, "versioning"):
+			s.getBucketVersioning(w, r, name)
+		case r.Method == http.MethodGet && has(q, "policy"):
+			s.getBucketPolicy(w, r, name)
+		case r.Method == http.MethodPut && has(q, "policy"):
+			s.putBucketPolicy(w, r, name)
+		case r.Method == http.MethodDelete && has(q, "policy"):
+			s.deleteBucketPolicy(w, r, name)
+		case r.Method == http.MethodGet && has(q, "lifecycle"):
+			s.getBucketLifecycle(w, r, name)
+		case r.Method == http.MethodPut && has(q, "lifecycle"):
+			s.putBucketLifecycle(w, r, name)
+		case r.Method == http.MethodDelete && has(q, "lifecycle"):
+			s.deleteBucketLifecycle(w, r, name)
+		case r.Method == http.MethodGet && has(q, "uploads"):
+			s.listMultipartUploads(w, r, name)
+		case r.Method == http.MethodPost && has(q, "delete"):
+			s.deleteObjects(w, r, name)
+		case r.Method == http.MethodGet:
+			s.listObjectsV2(w, r, name)
+		case r.Method == http.MethodPut:
+			s.createBucket(w, r, name)
+		case r.Method == http.MethodDelete:
+			s.deleteBucket(w, r, name)
+		case r.Method == http.MethodHead:
+			s.headBucket(w, r, name)
+		default:
+			writeError(w, errNotImplemented)
+		}
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && has(q, "tagging"):
+		s.getObjectTagging(w, r, name, key)
+	case r.Method == http.MethodPut && has(q, "tagging"):
+		s.putObjectTagging(w, r, name, key)
+	case r.Method == http.MethodDelete && has(q, "tagging"):
+		s.deleteObjectTagging(w, r, name, key)
+	case r.Method == http.MethodPut && has(q, "legal-hold"):
+		s.putObjectLegalHold(w, r, name, key)
+	case r.Method == http.MethodPut && has(q, "retention"):
+		s.putObjectRetention(w, r, name, key)
+	case r.Method == http.MethodPut && has(q, "uploadId"):
+		s.uploadPart(w, r, name, key)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, name, key)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, name, key)
+	case r.Method == http.MethodGet && has(q, "uploadId"):
+		s.listParts(w, r, name, key)
+	case r.Method == http.MethodGet:
+		s.getObject(w, r, name, key)
+	case r.Method == http.MethodHead:
+		s.headObject(w, r, name, key)
+	case r.Method == http.MethodDelete && has(q, "uploadId"):
+		s.abortMultipartUpload(w, r, name, key)
+	case r.Method == http.MethodDelete:
+		s.deleteObject(w, r, name, key)
+	case r.Method == http.MethodPost && has(q, "uploads"):
+		s.createMultipartUpload(w, r, name, key)
+	case r.Method == http.MethodPost && has(q, "restore"):
+		s.restoreObject(w, r, name, key)
+	case r.Method == http.MethodPost && has(q, "select"):
+		s.selectObjectContent(w, r, name, key)
+	case r.Method == http.MethodPost && has(q, "uploadId"):
+		s.completeMultipartUpload(w, r, name, key)
+	default:
+		writeError(w, errNotImplemented)
+	}
+}
+
+func newBucket() *bucket {
+	return &bucket{
+		created: time.Now(),
+		objects: make(map[string]*object),
+		uploads: make(map[string]*upload),
+	}
+}
+
+// splitPath splits the path style request path into the bucket name and the object key.
+func splitPath(p string) (name, key string) {
+	p = strings.TrimPrefix(p, "/")
+	idx := strings.Index(p, "/")
+	if idx < 0 {
+		return p, ""
+	}
+	return p[:idx], p[idx+1:]
+}
+
+func has(q map[string][]string, key string) bool {
+	_, ok := q[key]
+	return ok
+}
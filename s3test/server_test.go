@@ -0,0 +1,144 @@
+package s3test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func setupStorager(t *testing.T) typ.Storager {
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+
+	store, err := srv.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	return store
+}
+
+func TestObject(t *testing.T) {
+	store := setupStorager(t)
+
+	content := "hello, world"
+	if _, err := store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	o, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if size := o.MustGetContentLength(); size != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), size)
+	}
+
+	var buf bytes.Buffer
+	if _, err = store.Read("abc", &buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("expected %q, got %q", content, buf.String())
+	}
+
+	if err = store.Delete("abc"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err = store.Stat("abc"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected %v, got %v", services.ErrObjectNotExist, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	store := setupStorager(t)
+
+	for _, p := range []string{"a/1", "a/2", "a/b/3", "c"} {
+		if _, err := store.Write(p, strings.NewReader(p), int64(len(p))); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+
+	it, err := store.List("a/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if errors.Is(err, typ.IterateDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		paths = append(paths, o.Path)
+	}
+
+	expected := "a/1,a/2,a/b/3"
+	if got := strings.Join(paths, ","); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	store := setupStorager(t)
+	m := store.(typ.Multiparter)
+
+	o, err := m.CreateMultipart("abc")
+	if err != nil {
+		t.Fatalf("create multipart: %v", err)
+	}
+
+	var parts []*typ.Part
+	for i, v := range []string{"hello, ", "world"} {
+		_, part, err := m.WriteMultipart(o, strings.NewReader(v), int64(len(v)), i+1)
+		if err != nil {
+			t.Fatalf("write multipart: %v", err)
+		}
+		parts = append(parts, part)
+	}
+	if err = m.CompleteMultipart(o, parts); err != nil {
+		t.Fatalf("complete multipart: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err = store.Read("abc", &buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != "hello, world" {
+		t.Errorf("expected %q, got %q", "hello, world", buf.String())
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header  string
+		start   int64
+		end     int64
+		partial bool
+		ok      bool
+	}{
+		{"", 0, 10, false, true},
+		{"bytes=2-4", 2, 5, true, true},
+		{"bytes=2-", 2, 10, true, true},
+		{"bytes=5-100", 5, 10, true, true},
+		{"bytes=-3", 7, 10, true, true},
+		{"bytes=-100", 0, 10, true, true},
+		{"bytes=10-", 0, 0, false, false},
+		{"bytes=4-2", 0, 10, false, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.header, func(t *testing.T) {
+			start, end, partial, ok := parseRange(tt.header, 10)
+			if start != tt.start || end != tt.end || partial != tt.partial || ok != tt.ok {
+				t.Errorf("expected (%d, %d, %v, %v), got (%d, %d, %v, %v)",
+					tt.start, tt.end, tt.partial, tt.ok, start, end, partial, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package s3test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestServiceDefaultPairs(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	servicer, err := s3.NewServicer(
+		ps.WithCredential("hmac:s3test:s3test"),
+		ps.WithEndpoint(srv.Endpoint()),
+		ps.WithLocation(Location),
+		s3.WithForcePathStyle(),
+		s3.WithDefaultStoragePairs(s3.DefaultStoragePairs{
+			Write: []typ.Pair{s3.WithStorageClass(s3.StorageClassStandardIa)},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	store, err := servicer.Get("test")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	content := "hello, world"
+	if _, err = store.Write("default", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// Pairs passed to the operation win over the defaults.
+	_, err = store.Write("override", strings.NewReader(content), int64(len(content)), s3.WithStorageClass(s3.StorageClassOnezoneIa))
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	it, err := store.List("")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	expected := map[string]string{"default": s3.StorageClassStandardIa, "override": s3.StorageClassOnezoneIa}
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		if sc := s3.GetObjectSystemMetadata(o).StorageClass; sc != expected[o.Path] {
+			t.Errorf("expected storage class %s of %s, got %s", expected[o.Path], o.Path, sc)
+		}
+	}
+}
+
+func TestRawClient(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	servicer, err := s3.NewServicer(
+		ps.WithCredential("hmac:s3test:s3test"),
+		ps.WithEndpoint(srv.Endpoint()),
+		ps.WithLocation(Location),
+		s3.WithForcePathStyle(),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	service := servicer.(*s3.Service)
+
+	// Clients share the configuration of the service, the region is set per storage.
+	if endpoint := service.Client().Endpoint; endpoint != srv.URL() {
+		t.Errorf("expected endpoint %s, got %s", srv.URL(), endpoint)
+	}
+	client := awss3.New(service.Session(), aws.NewConfig().WithRegion(Location))
+	if _, err = client.HeadBucket(&awss3.HeadBucketInput{Bucket: aws.String("test")}); err != nil {
+		t.Errorf("head bucket: %v", err)
+	}
+
+	store, err := srv.NewStorager("test", ps.WithWorkDir("/work/"))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	// Keys passed to the storage client are absolute.
+	_, err = store.(*s3.Storage).Client().PutObject(&awss3.PutObjectInput{
+		Bucket: aws.String("test"),
+		Key:    aws.String("work/raw"),
+		Body:   strings.NewReader("raw"),
+	})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	r, err := store.(*s3.Storage).Client().GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String("test"),
+		Key:    aws.String("work/raw"),
+	})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer r.Body.Close()
+	if b, _ := ioutil.ReadAll(r.Body); string(b) != "raw" {
+		t.Errorf("expected raw, got %q", b)
+	}
+	o, err := store.Stat("raw")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if n := o.MustGetContentLength(); n != 3 {
+		t.Errorf("expected 3 bytes, got %d", n)
+	}
+}
+
+func TestStorageOverrides(t *testing.T) {
+	primary, secondary := NewServer(), NewServer()
+	defer primary.Close()
+	defer secondary.Close()
+	primary.CreateBucket("test")
+	secondary.CreateBucket("test")
+
+	var auth, path string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			auth, path = r.Header.Get("Authorization"), r.URL.Path
+		}
+		secondary.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	servicer, err := s3.NewServicer(
+		ps.WithCredential("hmac:s3test:s3test"),
+		ps.WithEndpoint(primary.Endpoint()),
+		ps.WithLocation(Location),
+		s3.WithForcePathStyle(),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+
+	// The storage talks to another provider with its own credential.
+	store, err := servicer.Get("test",
+		ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")),
+		ps.WithCredential("hmac:other:other"),
+		s3.WithForcePathStyle(),
+	)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err = store.Write("x", strings.NewReader("x"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if !strings.Contains(auth, "Credential=other/") {
+		t.Errorf("expected the overridden credential, got %q", auth)
+	}
+	if path != "/test/x" {
+		t.Errorf("expected path-style request, got %s", path)
+	}
+
+	// Storages without overrides keep the config of the service.
+	primaryStore, err := servicer.Get("test")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err = primaryStore.Stat("x"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected object not exist in the primary, got %v", err)
+	}
+	secondaryStore, err := secondary.NewStorager("test")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	if _, err = secondaryStore.Stat("x"); err != nil {
+		t.Errorf("stat in the secondary: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package s3test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestStatFast(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var heads int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"abc", "abcd", "abd"} {
+		if _, err = store.Write(path, strings.NewReader(path), int64(len(path))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	expected, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	atomic.StoreInt64(&heads, 0)
+
+	o, err := store.Stat("abc", s3.WithStatFast())
+	if err != nil {
+		t.Fatalf("stat fast: %v", err)
+	}
+	if o.MustGetContentLength() != expected.MustGetContentLength() {
+		t.Errorf("expected size %d, got %d", expected.MustGetContentLength(), o.MustGetContentLength())
+	}
+	if o.MustGetEtag() != expected.MustGetEtag() {
+		t.Errorf("expected etag %s, got %s", expected.MustGetEtag(), o.MustGetEtag())
+	}
+	if !o.MustGetLastModified().Equal(expected.MustGetLastModified()) {
+		t.Errorf("expected last modified %v, got %v", expected.MustGetLastModified(), o.MustGetLastModified())
+	}
+
+	// A key which is only the prefix of other keys doesn't exist.
+	if _, err = store.Stat("ab", s3.WithStatFast()); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected object not exist, got %v", err)
+	}
+
+	if n := atomic.LoadInt64(&heads); n != 0 {
+		t.Errorf("expected no HEAD requests, got %d", n)
+	}
+}
@@ -0,0 +1,209 @@
+package s3test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestSyncKeyMapper(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	src, err := srv.NewStorager("src")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	dst, err := srv.NewStorager("dst")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"logs/2021-01-02.log", "logs/2021-02-03.log"} {
+		if _, err = src.Write(path, strings.NewReader(path), int64(len(path))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err = dst.Write("logs/stale", strings.NewReader("stale"), 5); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// Re-partition logs by date.
+	opt := s3.SyncOptions{
+		Prefix: "logs/",
+		Delete: true,
+		KeyMapper: func(path string) string {
+			return strings.Replace(strings.Replace(path, "-", "/", 2), ".log", "", 1)
+		},
+	}
+	sum, err := s3.Sync(context.Background(), src, dst, opt)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if sum.Copied != 2 || sum.Deleted != 1 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+
+	it, err := dst.List("", ps.WithListMode(typ.ListModePrefix))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		paths = append(paths, o.Path)
+	}
+	sort.Strings(paths)
+	if got := strings.Join(paths, ","); got != "logs/2021/01/02,logs/2021/02/03" {
+		t.Errorf("unexpected paths in dst: %s", got)
+	}
+
+	// Mapped objects are compared with their paths in dst.
+	sum, err = s3.Sync(context.Background(), src, dst, opt)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if sum.Skipped != 2 || sum.Copied != 0 || sum.Deleted != 0 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+}
+
+func TestSync(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("src")
+	srv.CreateBucket("dst")
+
+	var heads int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	endpoint := ps.WithEndpoint("http:" + strings.TrimPrefix(proxy.URL, "http://"))
+	src, err := srv.NewStorager("src", endpoint)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	dst, err := srv.NewStorager("dst", endpoint)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	for _, path := range []string{"a", "b", "c"} {
+		if _, err = src.Write(path, strings.NewReader(path), int64(len(path))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err = dst.Write("a", strings.NewReader("a"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err = dst.Write("b", strings.NewReader("stale"), 5); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err = dst.Write("d", strings.NewReader("d"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	atomic.StoreInt64(&heads, 0)
+	sum, err := s3.Sync(context.Background(), src, dst, s3.SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if sum.Skipped != 1 || sum.Copied != 2 || sum.Deleted != 1 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	// Listed objects are compared without sending a HEAD request for every key.
+	if n := atomic.LoadInt64(&heads); n != 0 {
+		t.Errorf("expected no HEAD requests, got %d", n)
+	}
+
+	var buf strings.Builder
+	if _, err = dst.Read("b", &buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != "b" {
+		t.Errorf("expected b to be copied, got %q", buf.String())
+	}
+	if _, err = dst.Stat("d"); err == nil {
+		t.Errorf("expected d to be deleted")
+	}
+}
+
+func TestSyncServerSideCopy(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("src")
+	srv.CreateBucket("dst")
+
+	var copies, gets int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			atomic.AddInt64(&copies, 1)
+		case r.Method == http.MethodGet && strings.Count(strings.Trim(r.URL.Path, "/"), "/") > 0:
+			atomic.AddInt64(&gets, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+	endpoint := "http:" + strings.TrimPrefix(proxy.URL, "http://")
+
+	servicer, err := s3.NewServicer(
+		ps.WithCredential("hmac:s3test:s3test"),
+		ps.WithEndpoint(endpoint),
+		ps.WithLocation(Location),
+		s3.WithForcePathStyle(),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	src, err := servicer.Get("src")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	dst, err := servicer.Get("dst")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, err = src.Write("data/a", strings.NewReader("a"), 1); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// Storages of the same servicer copy on server side.
+	sum, err := s3.Sync(context.Background(), src, dst, s3.SyncOptions{Prefix: "data/"})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if sum.Copied != 1 || copies != 1 || gets != 0 {
+		t.Errorf("expected 1 server side copy and no reads, got %+v with %d copies and %d reads", sum, copies, gets)
+	}
+
+	// Storages with other credentials stream the content through the client.
+	other, err := srv.NewStorager("other", ps.WithEndpoint(endpoint), ps.WithCredential("hmac:other:other"))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	sum, err = s3.Sync(context.Background(), src, other, s3.SyncOptions{Prefix: "data/"})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if sum.Copied != 1 || copies != 1 || gets != 1 {
+		t.Errorf("expected content streamed, got %+v with %d copies and %d reads", sum, copies, gets)
+	}
+}
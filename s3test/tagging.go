@@ -0,0 +1,128 @@
+package s3test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// parseTaggingHeader parses the `x-amz-tagging` header, which is URL query encoded.
+func parseTaggingHeader(h http.Header) map[string]string {
+	v := h.Get("X-Amz-Tagging")
+	if v == "" {
+		return nil
+	}
+	q, err := url.ParseQuery(v)
+	if err != nil {
+		return nil
+	}
+	tags := make(map[string]string, len(q))
+	for k := range q {
+		tags[k] = q.Get(k)
+	}
+	return tags
+}
+
+func (s *Server) getObjectTagging(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+
+	var resp tagging
+	for k, v := range o.tags {
+		resp.TagSet = append(resp.TagSet, tag{Key: k, Value: v})
+	}
+	writeXML(w, http.StatusOK, resp)
+}
+
+func (s *Server) putObjectTagging(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+
+	var req tagging
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errMalformedXML)
+		return
+	}
+	o.tags = make(map[string]string, len(req.TagSet))
+	for _, v := range req.TagSet {
+		o.tags[v.Key] = v.Value
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) deleteObjectTagging(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+	o.tags = nil
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type legalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+	Status  string   `xml:"Status"`
+}
+
+// putObjectLegalHold stores the legal hold status, which will be returned by HEAD but not enforced.
+func (s *Server) putObjectLegalHold(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+
+	var req legalHold
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errMalformedXML)
+		return
+	}
+	o.header.Set("X-Amz-Object-Lock-Legal-Hold", req.Status)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+type retention struct {
+	XMLName         xml.Name  `xml:"Retention"`
+	Mode            string    `xml:"Mode"`
+	RetainUntilDate time.Time `xml:"RetainUntilDate"`
+}
+
+// putObjectRetention stores the retention, which will be returned by HEAD but not enforced.
+func (s *Server) putObjectRetention(w http.ResponseWriter, r *http.Request, name, key string) {
+	o, e, ok := s.lookupObject(name, key)
+	if !ok {
+		writeError(w, e)
+		return
+	}
+
+	var req retention
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, errMalformedXML)
+		return
+	}
+	o.header.Set("X-Amz-Object-Lock-Mode", req.Mode)
+	o.header.Set("X-Amz-Object-Lock-Retain-Until-Date", req.RetainUntilDate.UTC().Format(time.RFC3339))
+
+	w.WriteHeader(http.StatusOK)
+}
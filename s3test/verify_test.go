@@ -0,0 +1,60 @@
+package s3test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestVerify(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	src, err := srv.NewStorager("src")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	dst, err := srv.NewStorager("dst")
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	write := func(store typ.Storager, path, content string) {
+		if _, err := store.Write(path, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write(src, "data/a", "same")
+	write(dst, "data/a", "same")
+	write(src, "data/b", "missing")
+	write(src, "data/c", "short")
+	write(dst, "data/c", "longer")
+	write(src, "data/d", "aaaa")
+	write(dst, "data/d", "bbbb")
+	write(dst, "data/e", "extra")
+	write(src, "other", "ignored")
+
+	it, err := s3.Verify(context.Background(), src, dst, "data/")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	var got []string
+	for {
+		e, err := it.Next()
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		got = append(got, e.Path+":"+string(e.Finding))
+	}
+
+	expected := "data/b:missing,data/c:size_mismatch,data/d:etag_mismatch,data/e:extra"
+	if s := strings.Join(got, ","); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+}
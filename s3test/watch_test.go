@@ -0,0 +1,78 @@
+package s3test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+)
+
+func TestWatch(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var heads int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt64(&heads, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+	write := func(path, content string) {
+		if _, err := store.Write(path, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write("conf/a", "a")
+	write("conf/b", "b")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ch, err := store.(*s3.Storage).Watch(ctx, "conf/", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	write("conf/a", "updated")
+	write("conf/c", "c")
+	write("other", "ignored")
+	if err = store.Delete("conf/b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	got := map[string]s3.WatchEventType{}
+	for len(got) < 3 {
+		e, ok := <-ch
+		if !ok {
+			t.Fatalf("channel closed with events %v", got)
+		}
+		if e.Err != nil {
+			t.Fatalf("watch: %v", e.Err)
+		}
+		got[e.Path] = e.Type
+	}
+	if got["conf/a"] != s3.WatchEventUpdated || got["conf/b"] != s3.WatchEventDeleted || got["conf/c"] != s3.WatchEventCreated {
+		t.Errorf("unexpected events %v", got)
+	}
+	// Listed objects are compared without sending a HEAD request for every key.
+	if n := atomic.LoadInt64(&heads); n != 0 {
+		t.Errorf("expected no HEAD requests, got %d", n)
+	}
+
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,104 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestWriteResult(t *testing.T) {
+	store := setupStorager(t)
+
+	content := "hello, world"
+	var result s3.WriteResult
+	_, err := store.Write("abc", strings.NewReader(content), int64(len(content)),
+		s3.WithWriteResult(&result),
+		s3.WithServerSideEncryption(s3.ServerSideEncryptionAes256),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	sum := md5.Sum([]byte(content))
+	if result.Etag != `"`+hex.EncodeToString(sum[:])+`"` {
+		t.Errorf("expected the md5 of the content as etag, got %s", result.Etag)
+	}
+	if result.ServerSideEncryption != s3.ServerSideEncryptionAes256 {
+		t.Errorf("expected server side encryption %s, got %s", s3.ServerSideEncryptionAes256, result.ServerSideEncryption)
+	}
+
+	o, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if etag := o.MustGetEtag(); etag != result.Etag {
+		t.Errorf("expected etag %s, got %s", etag, result.Etag)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	store := setupStorager(t)
+
+	content := "hello, world"
+	_, err := store.Write("abc", strings.NewReader(content), int64(len(content)),
+		ps.WithContentType("text/plain"),
+		s3.WithCacheControl("max-age=60"),
+		s3.WithContentDisposition(`attachment; filename="abc.txt"`),
+		s3.WithContentEncoding("identity"),
+		s3.WithContentLanguage("en"),
+	)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	assertHeaders := func(path, contentType, cacheControl, disposition, encoding, language string) {
+		t.Helper()
+
+		o, err := store.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if v := o.MustGetContentType(); v != contentType {
+			t.Errorf("%s: expected content type %q, got %q", path, contentType, v)
+		}
+		sm := s3.GetObjectSystemMetadata(o)
+		if sm.CacheControl != cacheControl {
+			t.Errorf("%s: expected cache control %q, got %q", path, cacheControl, sm.CacheControl)
+		}
+		if sm.ContentDisposition != disposition {
+			t.Errorf("%s: expected content disposition %q, got %q", path, disposition, sm.ContentDisposition)
+		}
+		if sm.ContentEncoding != encoding {
+			t.Errorf("%s: expected content encoding %q, got %q", path, encoding, sm.ContentEncoding)
+		}
+		if sm.ContentLanguage != language {
+			t.Errorf("%s: expected content language %q, got %q", path, language, sm.ContentLanguage)
+		}
+	}
+	assertHeaders("abc", "text/plain", "max-age=60", `attachment; filename="abc.txt"`, "identity", "en")
+
+	copier := store.(typ.Copier)
+
+	// Headers are copied from the source object by default.
+	if err := copier.Copy("abc", "copied", ps.WithContentType("text/html")); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	assertHeaders("copied", "text/plain", "max-age=60", `attachment; filename="abc.txt"`, "identity", "en")
+
+	// Headers are replaced with the provided ones with the REPLACE directive.
+	err = copier.Copy("abc", "replaced",
+		s3.WithMetadataDirective("REPLACE"),
+		ps.WithContentType("text/html"),
+		s3.WithCacheControl("no-cache"),
+		s3.WithContentLanguage("fr"),
+	)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	assertHeaders("replaced", "text/html", "no-cache", "", "", "fr")
+}
@@ -0,0 +1,165 @@
+package s3test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+func TestWriter(t *testing.T) {
+	store := setupStorager(t)
+	s := store.(*s3.Storage)
+
+	t.Run("multipart", func(t *testing.T) {
+		// Two full parts and a partial one.
+		content := bytes.Repeat([]byte("0123456789abcdef"), 1024*1024+10)
+		var called int
+		w := s.NewWriter(context.Background(), "large",
+			s3.WithUserMetadata(map[string]string{"k": "v"}),
+			ps.WithIoCallback(func(b []byte) { called += len(b) }),
+		)
+		// Write in small chunks to cross part boundaries.
+		for p := content; len(p) > 0; {
+			n := 1000
+			if n > len(p) {
+				n = len(p)
+			}
+			if _, err := w.Write(p[:n]); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			p = p[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		buf := &bytes.Buffer{}
+		if _, err := store.Read("large", buf); err != nil || !bytes.Equal(buf.Bytes(), content) {
+			t.Fatalf("unexpected content of %d bytes: %v", buf.Len(), err)
+		}
+		o, err := store.Stat("large")
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if etag, _ := o.GetEtag(); !strings.HasSuffix(etag, `-3"`) {
+			t.Errorf("expected 3 parts, got etag %s", etag)
+		}
+		if m, _ := o.GetUserMetadata(); m["k"] != "v" {
+			t.Errorf("expected user metadata, got %v", m)
+		}
+		if called != len(content) {
+			t.Errorf("expected io callback with %d bytes, got %d", len(content), called)
+		}
+	})
+
+	t.Run("single", func(t *testing.T) {
+		w := s.NewWriter(context.Background(), "small", ps.WithContentType("text/plain"))
+		if _, err := w.Write([]byte("hello")); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+		o, err := store.Stat("small")
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if ct, _ := o.GetContentType(); ct != "text/plain" {
+			t.Errorf("expected content type text/plain, got %s", ct)
+		}
+	})
+
+	t.Run("encoder", func(t *testing.T) {
+		w := s.NewWriter(context.Background(), "encoded.gz")
+		gw := gzip.NewWriter(w)
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(gw, "line %d\n", i)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatalf("close gzip: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+
+		buf := &bytes.Buffer{}
+		if _, err := store.Read("encoded.gz", buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		gr, err := gzip.NewReader(buf)
+		if err != nil {
+			t.Fatalf("open gzip: %v", err)
+		}
+		b, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatalf("read gzip: %v", err)
+		}
+		if lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"); len(lines) != 1000 || lines[999] != "line 999" {
+			t.Errorf("unexpected content of %d lines", len(lines))
+		}
+	})
+
+	t.Run("unsupported pairs", func(t *testing.T) {
+		w := s.NewWriter(context.Background(), "small", ps.WithContentMd5("XrY7u+Ae7tCTyyK7j1rNww=="))
+		_, err := w.Write([]byte("hello"))
+		var e services.PairUnsupportedError
+		if !errors.As(err, &e) || e.Pair.Key != "content_md5" {
+			t.Errorf("expected unsupported content_md5, got %v", err)
+		}
+		if err = w.Close(); !errors.As(err, &e) {
+			t.Errorf("expected unsupported content_md5, got %v", err)
+		}
+	})
+}
+
+func TestWriterAbort(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	srv.CreateBucket("test")
+
+	var parts, aborts int64
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method == http.MethodPut && q.Get("partNumber") != "" && atomic.AddInt64(&parts, 1) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodDelete && q.Get("uploadId") != "" {
+			atomic.AddInt64(&aborts, 1)
+		}
+		srv.ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	store, err := srv.NewStorager("test", ps.WithEndpoint("http:"+strings.TrimPrefix(proxy.URL, "http://")))
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	w := store.(*s3.Storage).NewWriter(context.Background(), "abc")
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1024*1024+10)
+	// Write may or may not see the failure of the part uploaded in the background.
+	_, _ = w.Write(content)
+	if err = w.Close(); err == nil {
+		t.Fatalf("expected close to fail")
+	}
+
+	if n := atomic.LoadInt64(&aborts); n != 1 {
+		t.Errorf("expected the upload to be aborted, got %d aborts", n)
+	}
+	if _, err = store.Stat("abc"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected object not exist, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// CredentialValue is the static credential returned by CredentialProvider.
+type CredentialValue struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is the time when the credential expires, the provider will be called again after
+	// that. The credential never expires if it's zero.
+	Expires time.Time
+}
+
+// CredentialProvider fetches the credential at call time, for example, from Vault or a secret
+// manager, so that secrets are not embedded in pairs which may be logged.
+//
+// The provider will be called before the first request is sent and after the credential expires.
+type CredentialProvider func(ctx context.Context) (CredentialValue, error)
+
+// credentialProvider adapts CredentialProvider to the provider of the SDK.
+type credentialProvider struct {
+	fn CredentialProvider
+
+	lock    sync.Mutex
+	expires time.Time
+}
+
+func newProviderCredentials(fn CredentialProvider) *credentials.Credentials {
+	return credentials.NewCredentials(&credentialProvider{fn: fn})
+}
+
+func (p *credentialProvider) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithContext(context.Background())
+}
+
+func (p *credentialProvider) RetrieveWithContext(ctx credentials.Context) (credentials.Value, error) {
+	v, err := p.fn(ctx)
+	if err != nil {
+		return credentials.Value{ProviderName: "CredentialProvider"}, err
+	}
+	p.lock.Lock()
+	p.expires = v.Expires
+	p.lock.Unlock()
+	return credentials.Value{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: v.SecretAccessKey,
+		SessionToken:    v.SessionToken,
+		ProviderName:    "CredentialProvider",
+	}, nil
+}
+
+// IsExpired implements credentials.Provider, the SDK will retrieve the credential before the
+// first request and after it returns true.
+func (p *credentialProvider) IsExpired() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return !p.expires.IsZero() && !time.Now().Before(p.expires)
+}
+
+// CustomerKeyProvider fetches the SSE-C key of the object at call time, for example, from Vault
+// or a secret manager, so that keys are not embedded in pairs which may be logged.
+//
+// key is the absolute key of the object in the bucket. The provider should return a 32 bytes
+// AES256 key, or nil if the object is not encrypted by SSE-C, as S3 will reject requests with a
+// key for objects without SSE-C.
+type CustomerKeyProvider func(ctx context.Context, key string) ([]byte, error)
+
+// customerKeyHandler will fill SSE-C headers by the provider for requests without them, it's
+// added as a request handler so that all requests (including presigned ones) are covered.
+func (s *Storage) customerKeyHandler(r *request.Request) {
+	if r.Error != nil {
+		return
+	}
+
+	var key, algorithm, keyBase64, keyMD5 **string
+	var copySource, copyAlgorithm, copyKeyBase64, copyKeyMD5 **string
+	switch v := r.Params.(type) {
+	case *s3.PutObjectInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+	case *s3.GetObjectInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+	case *s3.HeadObjectInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+	case *s3.SelectObjectContentInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+	case *s3.CreateMultipartUploadInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+	case *s3.UploadPartInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+	case *s3.CopyObjectInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+		copySource, copyAlgorithm, copyKeyBase64, copyKeyMD5 = &v.CopySource, &v.CopySourceSSECustomerAlgorithm, &v.CopySourceSSECustomerKey, &v.CopySourceSSECustomerKeyMD5
+	case *s3.UploadPartCopyInput:
+		key, algorithm, keyBase64, keyMD5 = &v.Key, &v.SSECustomerAlgorithm, &v.SSECustomerKey, &v.SSECustomerKeyMD5
+		copySource, copyAlgorithm, copyKeyBase64, copyKeyMD5 = &v.CopySource, &v.CopySourceSSECustomerAlgorithm, &v.CopySourceSSECustomerKey, &v.CopySourceSSECustomerKeyMD5
+	default:
+		return
+	}
+
+	if *keyBase64 == nil {
+		r.Error = s.fillCustomerKey(r.Context(), aws.StringValue(*key), algorithm, keyBase64, keyMD5)
+		if r.Error != nil {
+			return
+		}
+	}
+	if copySource != nil && *copyKeyBase64 == nil {
+		// Only copies from the same bucket are covered, as the provider is bound to the storage.
+		src, ok := s.parseCopySourceKey(aws.StringValue(*copySource))
+		if !ok {
+			return
+		}
+		r.Error = s.fillCustomerKey(r.Context(), src, copyAlgorithm, copyKeyBase64, copyKeyMD5)
+	}
+}
+
+func (s *Storage) fillCustomerKey(ctx context.Context, key string, algorithm, keyBase64, keyMD5 **string) error {
+	customerKey, err := s.customerKeyProvider(ctx, key)
+	if err != nil || customerKey == nil {
+		return err
+	}
+	*algorithm, *keyBase64, *keyMD5, err = calculateEncryptionHeaders(ServerSideEncryptionAes256, customerKey)
+	return err
+}
+
+// parseCopySourceKey returns the object key of the copy source in the format of
+// `bucket/key?versionId=id`, ok will be false if it's not in the bucket of the storage.
+func (s *Storage) parseCopySourceKey(source string) (key string, ok bool) {
+	if idx := strings.Index(source, "?"); idx >= 0 {
+		source = source[:idx]
+	}
+	source, err := url.PathUnescape(strings.TrimPrefix(source, "/"))
+	if err != nil {
+		return "", false
+	}
+	if !strings.HasPrefix(source, s.name+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(source, s.name+"/"), true
+}
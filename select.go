@@ -0,0 +1,126 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minhjh/go-storage/v4/services"
+)
+
+// SelectQuery is the SQL query run by Select.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/selecting-content-from-objects.html
+type SelectQuery struct {
+	// Expression is the SQL expression, for example `SELECT * FROM S3Object s WHERE s.age > 18`.
+	Expression string
+	// Input describes the format of the object, CSV, JSON and Parquet are supported.
+	Input *s3.InputSerialization
+	// Output describes the format of the returned records, CSV and JSON are supported.
+	Output *s3.OutputSerialization
+}
+
+// SelectStats carries the stats of a select query.
+type SelectStats struct {
+	BytesScanned   int64
+	BytesProcessed int64
+	BytesReturned  int64
+}
+
+// SelectReader reads the records returned by Select.
+type SelectReader struct {
+	s    *Storage
+	path string
+
+	stream *s3.SelectObjectContentEventStream
+	buf    []byte
+	ended  bool
+	stats  SelectStats
+}
+
+// Select will run the SQL query over the object at path on server side, only the matching
+// records will be returned. The returned SelectReader must be closed after use.
+//
+// Select requires enable_select, ErrCapabilityInsufficient will be returned otherwise.
+func (s *Storage) Select(ctx context.Context, path string, query SelectQuery) (r *SelectReader, err error) {
+	defer func() {
+		err = s.formatError("select", err, path)
+	}()
+
+	if !s.selectEnabled {
+		return nil, fmt.Errorf("select not enabled: %w", services.ErrCapabilityInsufficient)
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	input := &s3.SelectObjectContentInput{
+		Bucket:              aws.String(s.name),
+		Key:                 aws.String(rp),
+		Expression:          aws.String(query.Expression),
+		ExpressionType:      aws.String(s3.ExpressionTypeSql),
+		InputSerialization:  query.Input,
+		OutputSerialization: query.Output,
+	}
+
+	output, err := s.service.SelectObjectContentWithContext(ctx, input)
+	if err != nil {
+		return
+	}
+
+	return &SelectReader{
+		s:      s,
+		path:   path,
+		stream: output.EventStream,
+	}, nil
+}
+
+// Read implements io.Reader, records will be returned in the output format.
+func (r *SelectReader) Read(p []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		ev, ok := <-r.stream.Events()
+		if !ok {
+			if err = r.stream.Err(); err != nil {
+				return 0, r.s.formatError("select", err, r.path)
+			}
+			// S3 may close the stream without an end event while the query failed halfway.
+			if !r.ended {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, io.EOF
+		}
+
+		switch e := ev.(type) {
+		case *s3.RecordsEvent:
+			r.buf = e.Payload
+		case *s3.StatsEvent:
+			if e.Details != nil {
+				r.stats = SelectStats{
+					BytesScanned:   aws.Int64Value(e.Details.BytesScanned),
+					BytesProcessed: aws.Int64Value(e.Details.BytesProcessed),
+					BytesReturned:  aws.Int64Value(e.Details.BytesReturned),
+				}
+			}
+		case *s3.EndEvent:
+			r.ended = true
+		}
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Stats returns the stats of the query, which will only be available after all records are read.
+func (r *SelectReader) Stats() SelectStats {
+	return r.stats
+}
+
+// Close implements io.Closer.
+func (r *SelectReader) Close() error {
+	return r.stream.Close()
+}
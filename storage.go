@@ -3,13 +3,21 @@ package s3
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/private/protocol/xml/xmlutil"
 	"github.com/aws/aws-sdk-go/service/s3"
 
@@ -20,19 +28,73 @@ import (
 )
 
 func (s *Storage) completeMultipart(ctx context.Context, o *Object, parts []*Part, opt pairStorageCompleteMultipart) (err error) {
-	input := s.formatCompleteMultipartUploadInput(o, parts, opt)
-	_, err = s.service.CompleteMultipartUploadWithContext(ctx, input)
-	if err != nil {
+	if err = s.checkObjectPath(o); err != nil {
 		return
 	}
 
+	var output *s3.CompleteMultipartUploadOutput
+	if s.cse != nil {
+		output, err = s.completeEncryptedMultipart(ctx, o, parts, opt)
+	} else {
+		input := s.formatCompleteMultipartUploadInput(o, parts, opt)
+		output, err = s.service.CompleteMultipartUploadWithContext(ctx, input)
+	}
+	if err != nil {
+		return s.formatMultipartPartError(ctx, o, parts, opt, err)
+	}
+
 	o.Mode.Del(ModePart)
 	o.Mode.Add(ModeRead)
+
+	// Fill the object with the response, so that callers don't need to stat it again.
+	var size int64
+	for _, p := range parts {
+		size += p.Size
+	}
+	o.SetContentLength(size)
+	o.SetEtag(aws.StringValue(output.ETag))
+
+	sm := GetObjectSystemMetadata(o)
+	sm.VersionID = aws.StringValue(output.VersionId)
+	sm.Location = aws.StringValue(output.Location)
+	sm.ServerSideEncryption = aws.StringValue(output.ServerSideEncryption)
+	sm.ServerSideEncryptionAwsKmsKeyID = aws.StringValue(output.SSEKMSKeyId)
+	sm.ServerSideEncryptionBucketKeyEnabled = aws.BoolValue(output.BucketKeyEnabled)
+	o.SetSystemMetadata(sm)
+
+	if opt.HasWriteResult && opt.WriteResult != nil {
+		*opt.WriteResult = WriteResult{
+			Etag:                                 aws.StringValue(output.ETag),
+			VersionID:                            aws.StringValue(output.VersionId),
+			Expiration:                           aws.StringValue(output.Expiration),
+			Location:                             aws.StringValue(output.Location),
+			ServerSideEncryption:                 aws.StringValue(output.ServerSideEncryption),
+			ServerSideEncryptionAwsKmsKeyID:      aws.StringValue(output.SSEKMSKeyId),
+			ServerSideEncryptionBucketKeyEnabled: aws.BoolValue(output.BucketKeyEnabled),
+		}
+	}
 	return
 }
 
+// partNumberPattern matches the part numbers in messages like `Part number 3 is invalid`
+// returned by some S3 compatible services.
+var partNumberPattern = regexp.MustCompile(`(?i)\bpart\s*(?:number)?\s*[:#]?\s*(\d+)`)
+
+func (s *Storage) copy(ctx context.Context, src string, dst string, opt pairStorageCopy) (err error) {
+	input, err := s.formatCopyObjectInput(src, dst, opt)
+	if err != nil {
+		return
+	}
+
+	_, err = s.service.CopyObjectWithContext(ctx, input)
+	return err
+}
+
 func (s *Storage) create(path string, opt pairStorageCreate) (o *Object) {
-	rp := s.getAbsPath(path)
+	// Create could not return errors, objects with invalid paths carry no ID, and they will be
+	// rejected by following operations with ErrPathInvalid.
+	rp, err := s.getAbsPath(path)
+	valid := err == nil
 
 	// Handle create multipart object separately.
 	if opt.HasMultipartID {
@@ -45,15 +107,17 @@ func (s *Storage) create(path string, opt pairStorageCreate) (o *Object) {
 				return
 			}
 
-			rp += "/"
+			rp = s.formatDirMarker(rp)
 			o = s.newObject(true)
 			o.Mode = ModeDir
 		} else {
-			o = s.newObject(false)
+			o = s.newObject(valid)
 			o.Mode = ModeRead
 		}
 	}
-	o.ID = rp
+	if valid {
+		o.ID = rp
+	}
 	o.Path = path
 	return o
 }
@@ -64,15 +128,48 @@ func (s *Storage) createDir(ctx context.Context, path string, opt pairStorageCre
 		return
 	}
 
-	rp := s.getAbsPath(path)
+	if opt.HasCreateParents {
+		// Create all parents like `a/` and `a/b/` for `a/b/c` before the dir itself.
+		parentOpt := opt
+		parentOpt.HasCreateParents = false
+
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		for i := 1; i < len(segments); i++ {
+			_, err = s.createDir(ctx, strings.Join(segments[:i], "/"), parentOpt)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	if s.dirMarker == DirMarkerNone {
+		// Dirs only exist while there are objects under them, nothing to create.
+		o = s.newObject(true)
+		o.Mode = ModeDir
+		o.ID = rp + "/"
+		o.Path = path
+		return o, nil
+	}
+
+	if opt.HasSkipIfExists {
+		o, err = s.statExistingDir(ctx, path, rp, opt)
+		if err != nil || o != nil {
+			return
+		}
+	}
 
-	// Add `/` at the end of `path` to simulate a directory.
+	// Add `/` (or other suffix according to the dir marker strategy) at the end of `path` to simulate a directory.
 	//ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/using-folders.html
-	rp += "/"
+	key := s.formatDirMarker(rp)
 
 	input := &s3.PutObjectInput{
 		Bucket:        aws.String(s.name),
-		Key:           aws.String(rp),
+		Key:           aws.String(key),
 		ContentLength: aws.Int64(0),
 	}
 	if opt.HasStorageClass {
@@ -89,7 +186,7 @@ func (s *Storage) createDir(ctx context.Context, path string, opt pairStorageCre
 
 	o = s.newObject(true)
 	o.Mode = ModeDir
-	o.ID = rp
+	o.ID = key
 	o.Path = path
 	o.SetEtag(aws.StringValue(output.ETag))
 
@@ -117,12 +214,98 @@ func (s *Storage) createDir(ctx context.Context, path string, opt pairStorageCre
 	return o, nil
 }
 
+// detectInvalidParts returns the indexes of parts not uploaded or with mismatched etags for
+// `InvalidPart`, and the indexes of parts smaller than the minimum size except the last one
+// for `EntityTooSmall`. Encrypted parts are not checked, as their sizes and etags are changed.
+func (s *Storage) detectInvalidParts(ctx context.Context, o *Object, parts []*Part, opt pairStorageCompleteMultipart, code string) []int {
+	if s.cse != nil {
+		return nil
+	}
+
+	input := &partPageStatus{
+		maxParts: 1000,
+		key:      o.ID,
+		uploadId: o.MustGetMultipartID(),
+	}
+	if opt.HasExceptedBucketOwner {
+		input.expectedBucketOwner = opt.ExceptedBucketOwner
+	}
+	uploaded := make(map[int]*s3.Part)
+	for {
+		listed, done, err := s.listParts(ctx, input)
+		if err != nil {
+			return nil
+		}
+		for _, v := range listed {
+			uploaded[int(aws.Int64Value(v.PartNumber))-1] = v
+		}
+		if done {
+			break
+		}
+	}
+
+	last := -1
+	for _, p := range parts {
+		if p.Index > last {
+			last = p.Index
+		}
+	}
+	var indexes []int
+	for _, p := range parts {
+		v, ok := uploaded[p.Index]
+		switch code {
+		case "InvalidPart":
+			if !ok || strings.Trim(aws.StringValue(v.ETag), `"`) != strings.Trim(p.ETag, `"`) {
+				indexes = append(indexes, p.Index)
+			}
+		case "EntityTooSmall":
+			if ok && p.Index != last && aws.Int64Value(v.Size) < multipartSizeMinimum {
+				indexes = append(indexes, p.Index)
+			}
+		}
+	}
+	return indexes
+}
+
+// formatMultipartPartError will detect the rejected parts if err is caused by invalid parts,
+// err will be returned as is otherwise.
+//
+// The part numbers will be extracted from the error message, or detected by comparing parts
+// with the ones listed by ListParts, as AWS S3 doesn't put them into the message.
+func (s *Storage) formatMultipartPartError(ctx context.Context, o *Object, parts []*Part, opt pairStorageCompleteMultipart, err error) error {
+	e, ok := err.(awserr.RequestFailure)
+	if !ok || (e.Code() != "InvalidPart" && e.Code() != "EntityTooSmall") {
+		return err
+	}
+
+	var indexes []int
+	for _, m := range partNumberPattern.FindAllStringSubmatch(e.Message(), -1) {
+		if n, perr := strconv.Atoi(m[1]); perr == nil && n > 0 {
+			indexes = append(indexes, n-1)
+		}
+	}
+	if len(indexes) == 0 {
+		indexes = s.detectInvalidParts(ctx, o, parts, opt, e.Code())
+	}
+	return multipartPartError{RequestFailure: e, indexes: indexes}
+}
+
 // metadataLinkTargetHeader is the name of the user-defined metadata name used to store the link target.
 const metadataLinkTargetHeader = "x-amz-meta-bs-link-target"
 
+// metadataLinkTargetEtagHeader is the name of the user-defined metadata name used to store the etag
+// of the link target, which is only recorded while link_reference is enabled.
+const metadataLinkTargetEtagHeader = "x-amz-meta-bs-link-target-etag"
+
 func (s *Storage) createLink(ctx context.Context, path string, target string, opt pairStorageCreateLink) (o *Object, err error) {
-	rt := s.getAbsPath(target)
-	rp := s.getAbsPath(path)
+	rt, err := s.getAbsPath(target)
+	if err != nil {
+		return
+	}
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
 
 	input := &s3.PutObjectInput{
 		Bucket: aws.String(s.name),
@@ -133,6 +316,33 @@ func (s *Storage) createLink(ctx context.Context, path string, target string, op
 			metadataLinkTargetHeader: &rt,
 		},
 	}
+	if opt.HasExceptedBucketOwner {
+		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+	// The link is encrypted with the same key as the target, so that it could be followed
+	// with the pairs used to read the target.
+	if opt.HasServerSideEncryptionCustomerAlgorithm {
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5, err = calculateEncryptionHeaders(opt.ServerSideEncryptionCustomerAlgorithm, opt.ServerSideEncryptionCustomerKey)
+		if err != nil {
+			return
+		}
+	}
+	if s.linkReference {
+		// Record the etag of the target, so that the link could be verified to still
+		// refer to the same content after the target moves.
+		head, err := s.service.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket:               aws.String(s.name),
+			Key:                  aws.String(rt),
+			ExpectedBucketOwner:  input.ExpectedBucketOwner,
+			SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+			SSECustomerKey:       input.SSECustomerKey,
+			SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+		})
+		if err != nil {
+			return nil, err
+		}
+		input.Metadata[metadataLinkTargetEtagHeader] = head.ETag
+	}
 
 	output, err := s.service.PutObjectWithContext(ctx, input)
 	if err != nil {
@@ -172,23 +382,59 @@ func (s *Storage) createLink(ctx context.Context, path string, target string, op
 	if output.BucketKeyEnabled != nil {
 		sm.ServerSideEncryptionBucketKeyEnabled = aws.BoolValue(output.BucketKeyEnabled)
 	}
+	sm.LinkTargetEtag = aws.StringValue(input.Metadata[metadataLinkTargetEtagHeader])
 	o.SetSystemMetadata(sm)
 
 	return
 }
 
 func (s *Storage) createMultipart(ctx context.Context, path string, opt pairStorageCreateMultipart) (o *Object, err error) {
-	rp := s.getAbsPath(path)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	if len(s.prefixRules) > 0 {
+		var pairs []Pair
+		pairs, err = s.applyPrefixRules(path, opt.pairs, s.defaultPairs.CreateMultipart, true)
+		if err != nil {
+			return
+		}
+		opt, err = s.parsePairStorageCreateMultipart(pairs)
+		if err != nil {
+			return
+		}
+	}
 
 	input, err := s.formatCreateMultipartUploadInput(path, opt)
 	if err != nil {
 		return nil, err
 	}
 
+	var key, iv []byte
+	if s.cse != nil {
+		var envelope map[string]*string
+		key, iv, envelope, err = s.cse.newEnvelope(ctx)
+		if err != nil {
+			return
+		}
+		if input.Metadata == nil {
+			input.Metadata = make(map[string]*string, len(envelope))
+		}
+		for k, v := range envelope {
+			input.Metadata[k] = v
+		}
+	}
+
 	output, err := s.service.CreateMultipartUpload(input)
 	if err != nil {
 		return
 	}
+	if s.cse != nil {
+		if err = s.cse.addMultipart(aws.StringValue(output.UploadId), key, iv); err != nil {
+			return
+		}
+	}
 
 	o = s.newObject(true)
 	o.ID = rp
@@ -223,7 +469,10 @@ func (s *Storage) createMultipart(ctx context.Context, path string, opt pairStor
 
 func (s *Storage) delete(ctx context.Context, path string, opt pairStorageDelete) (err error) {
 	if opt.HasMultipartID {
-		abortInput := s.formatAbortMultipartUploadInput(path, opt)
+		abortInput, err := s.formatAbortMultipartUploadInput(path, opt)
+		if err != nil {
+			return err
+		}
 
 		// S3 AbortMultipartUpload is idempotent, so we don't need to check NoSuchUpload error.
 		//
@@ -232,8 +481,38 @@ func (s *Storage) delete(ctx context.Context, path string, opt pairStorageDelete
 		// - https://docs.aws.amazon.com/AmazonS3/latest/API/API_AbortMultipartUpload.html
 		_, err = s.service.AbortMultipartUpload(abortInput)
 		if err != nil {
-			return
+			return err
+		}
+		if s.cse != nil {
+			s.cse.removeMultipart(opt.MultipartID)
+		}
+	}
+
+	if opt.HasRecursive && opt.HasObjectMode && opt.ObjectMode.IsDir() {
+		if !s.features.VirtualDir {
+			return services.PairUnsupportedError{Pair: ps.WithObjectMode(opt.ObjectMode)}
+		}
+
+		rp, err := s.getAbsPath(path)
+		if err != nil {
+			return err
+		}
+
+		// The dir placeholder will also be deleted as it's under the prefix.
+		sum, err := s.deletePrefix(ctx, rp+"/", DeletePrefixOptions{})
+		if err != nil {
+			return err
+		}
+		if sum.Failed > 0 {
+			return fmt.Errorf("%d objects under dir failed to delete", sum.Failed)
 		}
+		// Only markers like `dir_$folder$` are outside the prefix and need to be deleted separately.
+		if s.dirMarker != DirMarkerFolder {
+			return nil
+		}
+	}
+	if opt.HasObjectMode && opt.ObjectMode.IsDir() && s.dirMarker == DirMarkerNone {
+		return nil
 	}
 
 	input, err := s.formatDeleteObjectInput(path, opt)
@@ -254,15 +533,31 @@ func (s *Storage) delete(ctx context.Context, path string, opt pairStorageDelete
 }
 
 func (s *Storage) list(ctx context.Context, path string, opt pairStorageList) (oi *ObjectIterator, err error) {
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
 	input := &objectPageStatus{
 		maxKeys: 200,
-		prefix:  s.getAbsPath(path),
+		prefix:  rp,
 	}
 
 	if opt.HasExceptedBucketOwner {
 		input.expectedBucketOwner = opt.ExceptedBucketOwner
 	}
+	if opt.HasDetectLink && s.features.VirtualLink {
+		input.detectLink = true
+	}
 
+	if opt.HasDirOnly && opt.DirOnly {
+		if opt.HasListMode && !opt.ListMode.IsDir() {
+			return nil, services.PairUnsupportedError{Pair: WithDirOnly()}
+		}
+		opt.HasListMode = true
+		opt.ListMode = ListModeDir
+		input.dirOnly = true
+	}
 	if !opt.HasListMode {
 		// Support `ListModePrefix` as the default `ListMode`.
 		// ref: [GSP-46](https://github.com/minhjh/go-storage/blob/master/docs/rfcs/654-unify-list-behavior.md)
@@ -273,20 +568,63 @@ func (s *Storage) list(ctx context.Context, path string, opt pairStorageList) (o
 
 	switch {
 	case opt.ListMode.IsPart():
+		input.part = true
 		nextFn = s.nextPartObjectPageByPrefix
 	case opt.ListMode.IsDir():
 		input.delimiter = "/"
+		if opt.HasDelimiter {
+			if opt.Delimiter == "" {
+				return nil, services.PairUnsupportedError{Pair: WithDelimiter(opt.Delimiter)}
+			}
+			input.delimiter = opt.Delimiter
+		}
 		nextFn = s.nextObjectPageByDir
 	case opt.ListMode.IsPrefix():
 		nextFn = s.nextObjectPageByPrefix
 	default:
 		return nil, services.ListModeInvalidError{Actual: opt.ListMode}
 	}
+	if opt.HasListMaxPages && opt.ListMaxPages > 0 {
+		// Pages are counted before filtering, as every page is a request.
+		input.maxPages = opt.ListMaxPages
+		nextFn = input.guardObjectPages(nextFn)
+	}
+	if !opt.ListMode.IsPart() {
+		nextFn = skipEmptyObjectPages(nextFn)
+	}
+	if opt.HasDelimiter && !opt.ListMode.IsDir() {
+		// Keys are only grouped in dir list mode.
+		return nil, services.PairUnsupportedError{Pair: WithDelimiter(opt.Delimiter)}
+	}
+
+	if f, startAfter := newObjectFilter(rp, opt); f != nil {
+		if opt.ListMode.IsPrefix() {
+			input.startAfter = startAfter
+		}
+		nextFn = f.filterObjectPages(nextFn, opt.ListMode.IsPrefix())
+	}
+	if opt.HasListLimit {
+		if opt.ListLimit <= 0 {
+			return nil, services.PairUnsupportedError{Pair: WithListLimit(opt.ListLimit)}
+		}
+		input.limit = opt.ListLimit
+		nextFn = input.limitObjectPages(nextFn)
+	}
+
+	if opt.HasContinuationToken {
+		if err = input.setContinuationToken(opt.ContinuationToken); err != nil {
+			return nil, services.PairUnsupportedError{Pair: ps.WithContinuationToken(opt.ContinuationToken)}
+		}
+	}
 
 	return NewObjectIterator(ctx, nextFn, input), nil
 }
 
 func (s *Storage) listMultipart(ctx context.Context, o *Object, opt pairStorageListMultipart) (pi *PartIterator, err error) {
+	if err = s.checkObjectPath(o); err != nil {
+		return
+	}
+
 	input := &partPageStatus{
 		maxParts: 200,
 		key:      o.ID,
@@ -299,26 +637,61 @@ func (s *Storage) listMultipart(ctx context.Context, o *Object, opt pairStorageL
 	return NewPartIterator(ctx, s.nextPartPage, input), nil
 }
 
+// listParts will request the next page of parts and move the marker forward, done will be true
+// if it's the last page.
+func (s *Storage) listParts(ctx context.Context, input *partPageStatus) (parts []*s3.Part, done bool, err error) {
+	listInput := &s3.ListPartsInput{
+		Bucket:           &s.name,
+		Key:              &input.key,
+		MaxParts:         &input.maxParts,
+		PartNumberMarker: &input.partNumberMarker,
+		UploadId:         &input.uploadId,
+	}
+	if input.expectedBucketOwner != "" {
+		listInput.ExpectedBucketOwner = &input.expectedBucketOwner
+	}
+
+	output, err := s.service.ListPartsWithContext(ctx, listInput)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !aws.BoolValue(output.IsTruncated) {
+		return output.Parts, true, nil
+	}
+	input.partNumberMarker = aws.Int64Value(output.NextPartNumberMarker)
+	return output.Parts, false, nil
+}
+
 func (s *Storage) metadata(opt pairStorageMetadata) (meta *StorageMeta) {
 	meta = NewStorageMeta()
 	meta.Name = s.name
 	meta.WorkDir = s.workDir
 	// set write restriction
-	meta.SetWriteSizeMaximum(writeSizeMaximum)
+	meta.SetWriteSizeMaximum(s.compat.writeSizeMaximum)
 	// set multipart restrictions
 	meta.SetMultipartNumberMaximum(multipartNumberMaximum)
 	meta.SetMultipartSizeMaximum(multipartSizeMaximum)
 	meta.SetMultipartSizeMinimum(multipartSizeMinimum)
+
+	if opt.HasFetchBucketInfo && opt.FetchBucketInfo {
+		// Metadata doesn't return errors, the bucket info will be absent if it can't be fetched.
+		sm, err := s.getBucketInfo(context.Background())
+		if err == nil {
+			setStorageSystemMetadata(meta, sm)
+		}
+	}
 	return meta
 }
 
 func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) error {
 	input := page.Status.(*objectPageStatus)
+	input.startPage()
 
 	listInput := &s3.ListObjectsV2Input{
 		Bucket:            &s.name,
 		Delimiter:         &input.delimiter,
-		MaxKeys:           &input.maxKeys,
+		MaxKeys:           input.getServiceMaxKeys(),
 		ContinuationToken: input.getServiceContinuationToken(),
 		Prefix:            &input.prefix,
 	}
@@ -326,29 +699,57 @@ func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) err
 		listInput.ExpectedBucketOwner = &input.expectedBucketOwner
 	}
 
-	output, err := s.service.ListObjectsV2WithContext(ctx, listInput)
+	output, err := s.listObjectsV2(ctx, listInput)
 	if err != nil {
 		return err
 	}
 
+	var candidates []int
+	dirs := make(map[string]struct{}, len(output.CommonPrefixes))
 	for _, v := range output.CommonPrefixes {
 		o := s.newObject(true)
 		o.ID = *v.Prefix
+		// The path keeps the trailing delimiter, so that it could be listed again as is.
 		o.Path = s.getRelPath(*v.Prefix)
 		o.Mode |= ModeDir
 
+		dirs[o.Path] = struct{}{}
 		page.Data = append(page.Data, o)
 	}
 
 	for _, v := range output.Contents {
-		o, err := s.formatFileObject(v)
+		// Dir markers are folders separated by `/`, which are not dirs of other delimiters.
+		if input.delimiter == "/" {
+			if o := s.formatDirMarkerObject(v); o != nil {
+				// Skip markers of dirs which have been returned as common prefixes.
+				if _, ok := dirs[o.Path]; !ok {
+					page.Data = append(page.Data, o)
+				}
+				continue
+			}
+		}
+		if input.dirOnly {
+			continue
+		}
+
+		o, err := s.formatFileObject(v, input.listedOnly)
 		if err != nil {
 			return err
 		}
 
+		// Links are always zero-byte objects, so only those are checked.
+		if aws.Int64Value(v.Size) == 0 {
+			candidates = append(candidates, len(page.Data))
+		}
 		page.Data = append(page.Data, o)
 	}
 
+	if input.detectLink && !input.dirOnly {
+		if err := s.detectLinks(ctx, page.Data, candidates, input.expectedBucketOwner); err != nil {
+			return err
+		}
+	}
+
 	if !aws.BoolValue(output.IsTruncated) {
 		return IterateDone
 	}
@@ -359,31 +760,46 @@ func (s *Storage) nextObjectPageByDir(ctx context.Context, page *ObjectPage) err
 
 func (s *Storage) nextObjectPageByPrefix(ctx context.Context, page *ObjectPage) error {
 	input := page.Status.(*objectPageStatus)
+	input.startPage()
 
 	listInput := &s3.ListObjectsV2Input{
 		Bucket:            &s.name,
-		MaxKeys:           &input.maxKeys,
+		MaxKeys:           input.getServiceMaxKeys(),
 		ContinuationToken: input.getServiceContinuationToken(),
 		Prefix:            &input.prefix,
 	}
+	if input.continuationToken == "" && input.startAfter != "" {
+		listInput.StartAfter = &input.startAfter
+	}
 	if input.expectedBucketOwner != "" {
 		listInput.ExpectedBucketOwner = &input.expectedBucketOwner
 	}
 
-	output, err := s.service.ListObjectsV2WithContext(ctx, listInput)
+	output, err := s.listObjectsV2(ctx, listInput)
 	if err != nil {
 		return err
 	}
 
+	var candidates []int
 	for _, v := range output.Contents {
-		o, err := s.formatFileObject(v)
+		o, err := s.formatFileObject(v, input.listedOnly)
 		if err != nil {
 			return err
 		}
 
+		// Links are always zero-byte objects, so only those are checked.
+		if aws.Int64Value(v.Size) == 0 {
+			candidates = append(candidates, len(page.Data))
+		}
 		page.Data = append(page.Data, o)
 	}
 
+	if input.detectLink {
+		if err := s.detectLinks(ctx, page.Data, candidates, input.expectedBucketOwner); err != nil {
+			return err
+		}
+	}
+
 	if !aws.BoolValue(output.IsTruncated) {
 		return IterateDone
 	}
@@ -394,11 +810,12 @@ func (s *Storage) nextObjectPageByPrefix(ctx context.Context, page *ObjectPage)
 
 func (s *Storage) nextPartObjectPageByPrefix(ctx context.Context, page *ObjectPage) error {
 	input := page.Status.(*objectPageStatus)
+	input.startPage()
 
 	listInput := &s3.ListMultipartUploadsInput{
 		Bucket:         &s.name,
 		KeyMarker:      &input.keyMarker,
-		MaxUploads:     &input.maxKeys,
+		MaxUploads:     input.getServiceMaxKeys(),
 		Prefix:         &input.prefix,
 		UploadIdMarker: &input.uploadIdMarker,
 	}
@@ -418,6 +835,15 @@ func (s *Storage) nextPartObjectPageByPrefix(ctx context.Context, page *ObjectPa
 		o.Mode |= ModePart
 		o.SetMultipartID(*v.UploadId)
 
+		var sm ObjectSystemMetadata
+		sm.MultipartInitiated = aws.TimeValue(v.Initiated)
+		if v.Initiator != nil {
+			sm.MultipartInitiatorID = aws.StringValue(v.Initiator.ID)
+			sm.MultipartInitiatorDisplayName = aws.StringValue(v.Initiator.DisplayName)
+		}
+		sm.StorageClass = aws.StringValue(v.StorageClass)
+		o.SetSystemMetadata(sm)
+
 		page.Data = append(page.Data, o)
 	}
 
@@ -425,51 +851,34 @@ func (s *Storage) nextPartObjectPageByPrefix(ctx context.Context, page *ObjectPa
 		return IterateDone
 	}
 
-	input.keyMarker = aws.StringValue(output.KeyMarker)
-	input.uploadIdMarker = aws.StringValue(output.UploadIdMarker)
+	input.keyMarker = aws.StringValue(output.NextKeyMarker)
+	input.uploadIdMarker = aws.StringValue(output.NextUploadIdMarker)
 	return nil
 }
 
 func (s *Storage) nextPartPage(ctx context.Context, page *PartPage) error {
 	input := page.Status.(*partPageStatus)
 
-	listInput := &s3.ListPartsInput{
-		Bucket:           &s.name,
-		Key:              &input.key,
-		MaxParts:         &input.maxParts,
-		PartNumberMarker: &input.partNumberMarker,
-		UploadId:         &input.uploadId,
-	}
-	if input.expectedBucketOwner != "" {
-		listInput.ExpectedBucketOwner = &input.expectedBucketOwner
-	}
-
-	output, err := s.service.ListPartsWithContext(ctx, listInput)
+	parts, done, err := s.listParts(ctx, input)
 	if err != nil {
 		return err
 	}
 
-	for _, v := range output.Parts {
-		p := &Part{
-			// The returned `PartNumber` is [1, 10000].
-			// Set Index=*v.PartNumber-1 here to make the `PartNumber` zero-based for user.
-			Index: int(*v.PartNumber) - 1,
-			Size:  *v.Size,
-			ETag:  aws.StringValue(v.ETag),
-		}
-
-		page.Data = append(page.Data, p)
+	for _, v := range parts {
+		page.Data = append(page.Data, formatPart(v))
 	}
 
-	if !aws.BoolValue(output.IsTruncated) {
+	if done {
 		return IterateDone
 	}
-
-	input.partNumberMarker = aws.Int64Value(output.NextPartNumberMarker)
 	return nil
 }
 
 func (s *Storage) querySignHTTPCompleteMultipart(ctx context.Context, o *Object, parts []*Part, expire time.Duration, opt pairStorageQuerySignHTTPCompleteMultipart) (req *http.Request, err error) {
+	if err = s.checkObjectPath(o); err != nil {
+		return
+	}
+
 	pairs, err := s.parsePairStorageCompleteMultipart(opt.pairs)
 	if err != nil {
 		return nil, err
@@ -499,7 +908,11 @@ func (s *Storage) querySignHTTPCompleteMultipart(ctx context.Context, o *Object,
 }
 
 func (s *Storage) querySignHTTPCreateMultipart(ctx context.Context, path string, expire time.Duration, opt pairStorageQuerySignHTTPCreateMultipart) (req *http.Request, err error) {
-	pairs, err := s.parsePairStorageCreateMultipart(opt.pairs)
+	rulePairs, err := s.applyPrefixRules(path, opt.pairs, s.defaultPairs.QuerySignHTTPCreateMultipart, true)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := s.parsePairStorageCreateMultipart(rulePairs)
 	if err != nil {
 		return nil, err
 	}
@@ -531,7 +944,10 @@ func (s *Storage) querySignHTTPDelete(ctx context.Context, path string, expire t
 	}
 
 	if pairs.HasMultipartID {
-		abortInput := s.formatAbortMultipartUploadInput(path, pairs)
+		abortInput, err := s.formatAbortMultipartUploadInput(path, pairs)
+		if err != nil {
+			return nil, err
+		}
 
 		abortReq, _ := s.service.AbortMultipartUploadRequest(abortInput)
 		url, headers, err := abortReq.PresignRequest(expire)
@@ -569,6 +985,10 @@ func (s *Storage) querySignHTTPDelete(ctx context.Context, path string, expire t
 }
 
 func (s *Storage) querySignHTTPListMultipart(ctx context.Context, o *Object, expire time.Duration, opt pairStorageQuerySignHTTPListMultipart) (req *http.Request, err error) {
+	if err = s.checkObjectPath(o); err != nil {
+		return
+	}
+
 	pairs, err := s.parsePairStorageListMultipart(opt.pairs)
 	if err != nil {
 		return nil, err
@@ -615,6 +1035,13 @@ func (s *Storage) querySignHTTPRead(ctx context.Context, path string, expire tim
 		return
 	}
 
+	if s.presignCache != nil {
+		return s.presignCache.presign(input, expire, func() (string, http.Header, error) {
+			getReq, _ := s.service.GetObjectRequest(input)
+			return getReq.PresignRequest(expire)
+		})
+	}
+
 	getReq, _ := s.service.GetObjectRequest(input)
 	url, headers, err := getReq.PresignRequest(expire)
 	if err != nil {
@@ -631,7 +1058,11 @@ func (s *Storage) querySignHTTPRead(ctx context.Context, path string, expire tim
 }
 
 func (s *Storage) querySignHTTPWrite(ctx context.Context, path string, size int64, expire time.Duration, opt pairStorageQuerySignHTTPWrite) (req *http.Request, err error) {
-	pairs, err := s.parsePairStorageWrite(opt.pairs)
+	rulePairs, err := s.applyPrefixRules(path, opt.pairs, s.defaultPairs.QuerySignHTTPWrite, false)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := s.parsePairStorageWrite(rulePairs)
 	if err != nil {
 		return nil, err
 	}
@@ -658,6 +1089,10 @@ func (s *Storage) querySignHTTPWrite(ctx context.Context, path string, size int6
 }
 
 func (s *Storage) querySignHTTPWriteMultipart(ctx context.Context, o *Object, size int64, index int, expire time.Duration, opt pairStorageQuerySignHTTPWriteMultipart) (req *http.Request, err error) {
+	if err = s.checkObjectPath(o); err != nil {
+		return
+	}
+
 	pairs, err := s.parsePairStorageWriteMultipart(opt.pairs)
 	if err != nil {
 		return nil, err
@@ -685,83 +1120,169 @@ func (s *Storage) querySignHTTPWriteMultipart(ctx context.Context, o *Object, si
 }
 
 func (s *Storage) read(ctx context.Context, path string, w io.Writer, opt pairStorageRead) (n int64, err error) {
+	start := time.Now()
+	defer func() {
+		s.reportSlowOperation("read", path, n, start)
+	}()
+
+	if s.replica != nil {
+		var ok bool
+		n, ok, err = s.readReplica(ctx, path, w, opt)
+		if ok {
+			return
+		}
+	}
+
 	input, err := s.formatGetObjectInput(path, opt)
 	if err != nil {
 		return
 	}
 
+	if opt.HasFollowLink && s.features.VirtualLink {
+		var rp string
+		rp, err = s.resolveLink(ctx, aws.StringValue(input.Key), opt.FollowLinkDepth, &s3.HeadObjectInput{
+			ExpectedBucketOwner:  input.ExpectedBucketOwner,
+			SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+			SSECustomerKey:       input.SSECustomerKey,
+			SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+		})
+		if err != nil {
+			return
+		}
+		input.Key = aws.String(rp)
+	}
+
+	// Objects encrypted with customer-provided keys are never cached, as they are returned in
+	// plaintext which would be stored on disk unencrypted.
+	useCache := s.readCache != nil && input.SSECustomerKey == nil && s.customerKeyProvider == nil
+
+	// Cached objects are validated by the etag, unless the caller has its own condition.
+	var cached *readCacheEntry
+	if useCache && input.IfNoneMatch == nil {
+		cached = s.readCache.lookup(s.name, aws.StringValue(input.Key))
+		if cached != nil {
+			input.IfNoneMatch = aws.String(cached.ETag)
+		}
+	}
+
 	output, err := s.service.GetObjectWithContext(ctx, input)
+	hit := false
+	if cached != nil {
+		// The input is kept to resume reads, which must not carry the condition.
+		input.IfNoneMatch = nil
+		if isNotModified(err) {
+			output, err = s.readCache.open(s.name, aws.StringValue(input.Key), cached, input.Range)
+			if err != nil {
+				return
+			}
+			hit = true
+		}
+	}
 	if err != nil {
-		return
+		return 0, s.formatArchivedError(ctx, input, err)
 	}
 	defer output.Body.Close()
 
-	rc := output.Body
-	if opt.HasIoCallback {
-		rc = iowrap.CallbackReadCloser(rc, opt.IoCallback)
+	// Content is authenticated in order from the start, so ranges are not supported.
+	encrypted := s.cse != nil && isClientSideEncrypted(output.Metadata)
+	if encrypted && input.Range != nil {
+		return 0, fmt.Errorf("range read of client-side encrypted object: %w", services.ErrCapabilityInsufficient)
 	}
 
-	return io.Copy(w, rc)
-}
-
-func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o *Object, err error) {
-	rp := s.getAbsPath(path)
+	if opt.HasObjectCallback {
+		o := s.formatGetObjectOutput(path, aws.StringValue(input.Key), output)
+		opt.ObjectCallback(o)
+	}
 
-	if opt.HasMultipartID {
-		listInput := &s3.ListPartsInput{
-			Bucket:   aws.String(s.name),
-			Key:      aws.String(rp),
-			UploadId: aws.String(opt.MultipartID),
+	var rc io.ReadCloser
+	if opt.HasReadResumeAttempts && opt.ReadResumeAttempts > 0 {
+		rc = s.newResumableReader(ctx, input, output, opt.ReadResumeAttempts)
+	} else {
+		rc = newTruncationReader(output.Body, output.ContentLength)
+	}
+	// Only complete objects fetched from S3 are cached.
+	if useCache && input.Range == nil && !hit && output.SSECustomerAlgorithm == nil {
+		rc = s.readCache.fill(s.name, aws.StringValue(input.Key), output, rc)
+	}
+	defer rc.Close()
+	if opt.HasIoCallback {
+		rc = iowrap.CallbackReadCloser(rc, opt.IoCallback)
+	}
+	if opt.HasProgressCallback {
+		total := int64(-1)
+		if output.ContentLength != nil {
+			total = *output.ContentLength
 		}
-		if opt.HasExceptedBucketOwner {
-			listInput.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+		rc = progressReadCloser{progressReader: newProgressReader(rc, opt.ProgressCallback, total), c: rc}
+	}
+	if opt.HasReadTransform {
+		rc = opt.ReadTransform(rc)
+		defer rc.Close()
+	}
+	if encrypted {
+		var key, iv []byte
+		var alg string
+		key, iv, alg, err = s.cse.openEnvelope(ctx, output.Metadata)
+		if err != nil {
+			return
 		}
-
-		_, err = s.service.ListPartsWithContext(ctx, listInput)
+		var r io.Reader
+		r, err = newClientSideDecryptReader(rc, alg, key, iv)
 		if err != nil {
-			return nil, err
+			return
 		}
-
-		o = s.newObject(true)
-		o.ID = rp
-		o.Path = path
-		o.Mode.Add(ModePart)
-		o.SetMultipartID(opt.MultipartID)
-		return o, nil
+		rc = ioutil.NopCloser(r)
 	}
-
-	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
-		if !s.features.VirtualDir {
-			err = services.PairUnsupportedError{Pair: ps.WithObjectMode(opt.ObjectMode)}
+	if opt.HasDecompress {
+		rc, err = newDecompressReader(aws.StringValue(output.ContentEncoding), rc)
+		if err != nil {
 			return
 		}
-
-		rp += "/"
+		defer rc.Close()
 	}
 
-	input := &s3.HeadObjectInput{
-		Bucket: aws.String(s.name),
-		Key:    aws.String(rp),
-	}
-	if opt.HasExceptedBucketOwner {
-		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	return io.Copy(w, rc)
+}
+
+// followLinkDepthDefault is the max number of links followed in a chain by default, like MAXSYMLINKS.
+const followLinkDepthDefault = 8
+
+// formatArchivedError will fetch the archive status of the object if err is caused by reading an
+// archived object, err will be returned as is otherwise.
+func (s *Storage) formatArchivedError(ctx context.Context, input *s3.GetObjectInput, err error) error {
+	e, ok := err.(awserr.RequestFailure)
+	if !ok || e.Code() != "InvalidObjectState" {
+		return err
 	}
-	if opt.HasServerSideEncryptionCustomerAlgorithm {
-		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5, err = calculateEncryptionHeaders(opt.ServerSideEncryptionCustomerAlgorithm, opt.ServerSideEncryptionCustomerKey)
-		if err != nil {
-			return
-		}
+
+	output, herr := s.service.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket:               input.Bucket,
+		Key:                  input.Key,
+		ExpectedBucketOwner:  input.ExpectedBucketOwner,
+		SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+		SSECustomerKey:       input.SSECustomerKey,
+		SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+	})
+	if herr != nil {
+		return err
 	}
 
-	output, err := s.service.HeadObject(input)
-	if err != nil {
-		return nil, err
+	ongoing, _ := parseRestore(aws.StringValue(output.Restore))
+	return objectArchivedError{
+		RequestFailure: e,
+		storageClass:   aws.StringValue(output.StorageClass),
+		restoreOngoing: ongoing,
 	}
+}
 
+// formatHeadObject will build the object from the output of HeadObject, which is marked as done
+// as all fields are returned. dir is set if the object is stat as a dir.
+func (s *Storage) formatHeadObject(rp, path string, output *s3.HeadObjectOutput, dir bool) (o *Object) {
 	o = s.newObject(true)
 	o.ID = rp
 	o.Path = path
 
+	var linkTargetEtag string
 	if output.Metadata != nil {
 		metadata := output.Metadata
 		if target, ok := metadata[metadataLinkTargetHeader]; ok {
@@ -776,17 +1297,27 @@ func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o
 				o.SetLinkTarget("/" + *target)
 			}
 		}
+		if v, ok := metadata[metadataLinkTargetEtagHeader]; ok {
+			linkTargetEtag = aws.StringValue(v)
+		}
+		if metadata := parseUserMetadata(output.Metadata); len(metadata) > 0 {
+			o.SetUserMetadata(metadata)
+		}
 	}
 
 	if o.Mode&ModeLink == 0 && o.Mode&ModeRead == 0 {
-		if opt.HasObjectMode && opt.ObjectMode.IsDir() {
+		if dir {
 			o.Mode |= ModeDir
 		} else {
 			o.Mode |= ModeRead
 		}
 	}
 
-	o.SetContentLength(aws.Int64Value(output.ContentLength))
+	size := aws.Int64Value(output.ContentLength)
+	if s.cse != nil && isClientSideEncrypted(output.Metadata) {
+		size = parseClientSideEncryptedSize(output.Metadata, size)
+	}
+	o.SetContentLength(size)
 	o.SetLastModified(aws.TimeValue(output.LastModified))
 
 	if output.ContentType != nil {
@@ -797,6 +1328,18 @@ func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o
 	}
 
 	var sm ObjectSystemMetadata
+	if v := aws.StringValue(output.CacheControl); v != "" {
+		sm.CacheControl = v
+	}
+	if v := aws.StringValue(output.ContentDisposition); v != "" {
+		sm.ContentDisposition = v
+	}
+	if v := aws.StringValue(output.ContentEncoding); v != "" {
+		sm.ContentEncoding = v
+	}
+	if v := aws.StringValue(output.ContentLanguage); v != "" {
+		sm.ContentLanguage = v
+	}
 	if v := aws.StringValue(output.StorageClass); v != "" {
 		sm.StorageClass = v
 	}
@@ -815,45 +1358,416 @@ func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o
 	if output.BucketKeyEnabled != nil {
 		sm.ServerSideEncryptionBucketKeyEnabled = aws.BoolValue(output.BucketKeyEnabled)
 	}
+	if v := aws.StringValue(output.Restore); v != "" {
+		sm.RestoreRequested = true
+		sm.RestoreOngoing, sm.RestoreExpiryDate = parseRestore(v)
+	}
+	sm.LinkTargetEtag = linkTargetEtag
 	o.SetSystemMetadata(sm)
+	return o
+}
+
+// resolveLink will follow the chain of links starting at rp, and return the key of the first
+// object which is not a link. ErrLinkLoop will be returned if the chain loops or is deeper than depth.
+//
+// The expected bucket owner and SSE-C key in head are sent with every HEAD in the chain, so that
+// links are resolved with the same pairs as the target is accessed, links to objects encrypted
+// by SSE-C should be created with the same key.
+func (s *Storage) resolveLink(ctx context.Context, rp string, depth int, head *s3.HeadObjectInput) (string, error) {
+	if depth <= 0 {
+		depth = followLinkDepthDefault
+	}
+
+	visited := make(map[string]struct{})
+	for {
+		input := *head
+		input.Bucket = aws.String(s.name)
+		input.Key = aws.String(rp)
+		output, err := s.service.HeadObjectWithContext(ctx, &input)
+		if err != nil {
+			return "", err
+		}
+
+		target, ok := output.Metadata[metadataLinkTargetHeader]
+		if !ok {
+			return rp, nil
+		}
+
+		visited[rp] = struct{}{}
+		if _, ok := visited[*target]; ok || len(visited) > depth {
+			return "", fmt.Errorf("follow link %s: %w", rp, ErrLinkLoop)
+		}
+		rp = *target
+	}
+}
+
+func (s *Storage) stat(ctx context.Context, path string, opt pairStorageStat) (o *Object, err error) {
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return
+	}
+
+	if opt.HasMultipartID {
+		listInput := &s3.ListPartsInput{
+			Bucket:   aws.String(s.name),
+			Key:      aws.String(rp),
+			UploadId: aws.String(opt.MultipartID),
+		}
+		if opt.HasExceptedBucketOwner {
+			listInput.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+		}
+
+		_, err = s.service.ListPartsWithContext(ctx, listInput)
+		if err != nil {
+			return nil, err
+		}
 
+		o = s.newObject(true)
+		o.ID = rp
+		o.Path = path
+		o.Mode.Add(ModePart)
+		o.SetMultipartID(opt.MultipartID)
+		return o, nil
+	}
+
+	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
+		if !s.features.VirtualDir {
+			err = services.PairUnsupportedError{Pair: ps.WithObjectMode(opt.ObjectMode)}
+			return
+		}
+
+		if s.dirMarker == DirMarkerNone {
+			return s.statDirPrefix(ctx, path, rp, opt)
+		}
+		rp = s.formatDirMarker(rp)
+	}
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(rp),
+	}
+	if opt.HasExceptedBucketOwner {
+		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+	if opt.HasServerSideEncryptionCustomerAlgorithm {
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5, err = calculateEncryptionHeaders(opt.ServerSideEncryptionCustomerAlgorithm, opt.ServerSideEncryptionCustomerKey)
+		if err != nil {
+			return
+		}
+	}
+
+	if opt.HasFollowLink && s.features.VirtualLink {
+		rp, err = s.resolveLink(ctx, rp, opt.FollowLinkDepth, &s3.HeadObjectInput{
+			ExpectedBucketOwner:  input.ExpectedBucketOwner,
+			SSECustomerAlgorithm: input.SSECustomerAlgorithm,
+			SSECustomerKey:       input.SSECustomerKey,
+			SSECustomerKeyMD5:    input.SSECustomerKeyMD5,
+		})
+		if err != nil {
+			return
+		}
+		input.Key = aws.String(rp)
+	}
+
+	if opt.HasStatFast {
+		return s.statFast(ctx, path, rp, opt)
+	}
+
+	if opt.HasIfMatch {
+		input.IfMatch = &opt.IfMatch
+	}
+	if opt.HasIfNoneMatch {
+		input.IfNoneMatch = &opt.IfNoneMatch
+	}
+	if opt.HasIfModifiedSince {
+		input.IfModifiedSince = &opt.IfModifiedSince
+	}
+	if opt.HasIfUnmodifiedSince {
+		input.IfUnmodifiedSince = &opt.IfUnmodifiedSince
+	}
+
+	output, err := s.headObject(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.formatHeadObject(rp, path, output, opt.HasObjectMode && opt.ObjectMode.IsDir()), nil
+}
+
+// statExistingDir will return the dir object if the placeholder object or any object
+// under the prefix exists, and (nil, nil) if the dir doesn't exist yet.
+func (s *Storage) statExistingDir(ctx context.Context, path, rp string, opt pairStorageCreateDir) (o *Object, err error) {
+	key := s.formatDirMarker(rp)
+
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(s.name),
+		Key:    aws.String(key),
+	}
+	if opt.HasExceptedBucketOwner {
+		headInput.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+
+	output, err := s.service.HeadObjectWithContext(ctx, headInput)
+	if err == nil {
+		o = s.newObject(true)
+		o.Mode = ModeDir
+		o.ID = key
+		o.Path = path
+		o.SetEtag(aws.StringValue(output.ETag))
+		return o, nil
+	}
+	if e, ok := err.(awserr.Error); !ok || (e.Code() != "NotFound" && e.Code() != "NoSuchKey") {
+		return nil, err
+	}
+
+	// The placeholder doesn't exist, but a real prefix makes the dir visible already.
+	listInput := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.name),
+		Prefix:  aws.String(rp + "/"),
+		MaxKeys: aws.Int64(1),
+	}
+	if opt.HasExceptedBucketOwner {
+		listInput.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+
+	listOutput, err := s.service.ListObjectsV2WithContext(ctx, listInput)
+	if err != nil {
+		return nil, err
+	}
+	if len(listOutput.Contents) == 0 {
+		return nil, nil
+	}
+
+	o = s.newObject(true)
+	o.Mode = ModeDir
+	o.ID = key
+	o.Path = path
+	return o, nil
+}
+
+// statFast will stat the object via ListObjectsV2 instead of HeadObject.
+//
+// Only the fields returned by listing (size, etag, last modified and storage class) are available,
+// user metadata and link target will not be returned.
+func (s *Storage) statFast(ctx context.Context, path, rp string, opt pairStorageStat) (o *Object, err error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.name),
+		Prefix:  aws.String(rp),
+		MaxKeys: aws.Int64(1),
+	}
+	if opt.HasExceptedBucketOwner {
+		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+
+	output, err := s.listObjectsV2(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	// Keys are returned in lexicographical order, so the object must be the first one if exists.
+	if len(output.Contents) == 0 || aws.StringValue(output.Contents[0].Key) != rp {
+		return nil, services.ErrObjectNotExist
+	}
+
+	o, err = s.formatFileObject(output.Contents[0], true)
+	if err != nil {
+		return nil, err
+	}
+	o.Path = path
+	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
+		o.Mode = ModeDir
+	}
 	return o, nil
 }
 
 func (s *Storage) write(ctx context.Context, path string, r io.Reader, size int64, opt pairStorageWrite) (n int64, err error) {
-	if size > writeSizeMaximum {
+	start := time.Now()
+	defer func() {
+		s.reportSlowOperation("write", path, n, start)
+	}()
+
+	if size > s.compat.writeSizeMaximum {
 		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
 		return
 	}
 
+	if len(s.prefixRules) > 0 {
+		var pairs []Pair
+		pairs, err = s.applyPrefixRules(path, opt.pairs, s.defaultPairs.Write, false)
+		if err != nil {
+			return
+		}
+		opt, err = s.parsePairStorageWrite(pairs)
+		if err != nil {
+			return
+		}
+	}
+	if opt.HasCompress && size > compressSizeMaximum {
+		err = fmt.Errorf("compress size limit exceeded: %w", services.ErrRestrictionDissatisfied)
+		return
+	}
+
+	if len(s.mirrors) > 0 {
+		var wait func(error) error
+		r, wait = s.startMirrors(ctx, path, r, size, opt)
+		defer func() {
+			err = wait(err)
+		}()
+	}
+
+	// The checksum of content sent as is could be calculated before it's wrapped, content
+	// transformed by compression or client-side encryption will be checked after.
+	if s.contentIntegrityMode != "" && !opt.HasContentMd5 && !opt.HasCompress && s.cse == nil && r != nil && size > 0 {
+		var cleanup func()
+		r, opt.ContentMd5, cleanup, err = s.checksumReader(r, size)
+		if err != nil {
+			return
+		}
+		defer cleanup()
+		opt.HasContentMd5 = true
+	}
+
 	// According to GSP-751, we should allow the user to pass in a nil io.Reader.
 	// ref: https://github.com/minhjh/go-storage/blob/master/docs/rfcs/751-write-empty-file-behavior.md
 	if (r == nil && size == 0) || (r != nil && size == 0) {
 		r = bytes.NewReader([]byte{})
 	} else if r == nil && size != 0 {
 		return 0, fmt.Errorf("reader is nil but size is not 0")
-	} else {
-		r = io.LimitReader(r, size)
+	} else if r, err = limitReader(r, size); err != nil {
+		return
+	}
+
+	// Content type set explicitly (including via default_content_type) always takes precedence.
+	if opt.HasAutoContentType && !opt.HasContentType {
+		opt.HasContentType = true
+		opt.ContentType, r, err = detectContentType(path, r)
+		if err != nil {
+			return
+		}
 	}
 
 	if opt.HasIoCallback {
 		r = iowrap.CallbackReader(r, opt.IoCallback)
 	}
+	if opt.HasProgressCallback {
+		r = newProgressReader(r, opt.ProgressCallback, size)
+	}
+
+	// The size of the original content will be returned, while the compressed one is sent.
+	sentSize := size
+	if opt.HasCompress {
+		var buf *bytes.Buffer
+		buf, err = compress(opt.Compress, r)
+		if err != nil {
+			return
+		}
+		r, sentSize = buf, int64(buf.Len())
+
+		opt.HasContentEncoding = true
+		opt.ContentEncoding = opt.Compress
+		// The md5 of the original content doesn't match the compressed one.
+		opt.HasContentMd5 = false
+		if s.contentIntegrityMode != "" {
+			sum := md5.Sum(buf.Bytes())
+			opt.HasContentMd5 = true
+			opt.ContentMd5 = base64.StdEncoding.EncodeToString(sum[:])
+		}
+	}
+
+	var envelope map[string]*string
+	if s.cse != nil {
+		var key, iv []byte
+		key, iv, envelope, err = s.cse.newEnvelope(ctx)
+		if err != nil {
+			return
+		}
+		// Keep the compressed content seekable, so that the sealed one could be checksummed.
+		if buf, ok := r.(*bytes.Buffer); ok {
+			r = bytes.NewReader(buf.Bytes())
+		}
+		r, err = newGCMEncryptReadSeeker(r, sentSize, s.cse.cekAlg, key, iv)
+		if err != nil {
+			return
+		}
+		envelope[metadataCseUnencryptedContentLengthHeader] = aws.String(strconv.FormatInt(sentSize, 10))
+		sentSize = cseSealedSize(s.cse.cekAlg, sentSize)
+		opt.HasContentMd5 = false
+		if s.contentIntegrityMode != "" {
+			var cleanup func()
+			r, opt.ContentMd5, cleanup, err = s.checksumReader(r, sentSize)
+			if err != nil {
+				return
+			}
+			defer cleanup()
+			opt.HasContentMd5 = true
+		}
+	}
 
-	input, err := s.formatPutObjectInput(path, size, opt)
+	input, err := s.formatPutObjectInput(path, sentSize, opt)
 	if err != nil {
 		return
 	}
+	if envelope != nil {
+		if input.Metadata == nil {
+			input.Metadata = make(map[string]*string, len(envelope))
+		}
+		for k, v := range envelope {
+			input.Metadata[k] = v
+		}
+	}
 
+	// PutObjectInput doesn't support conditional write in current SDK, set the headers directly.
+	// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/conditional-writes.html
+	headers := make(map[string]string)
+	if opt.HasIfNoneMatch {
+		headers["If-None-Match"] = opt.IfNoneMatch
+	}
+	if opt.HasExpectedEtag {
+		headers["If-Match"] = quoteEtag(opt.ExpectedEtag)
+	}
+	var reqOpts []request.Option
+	if len(headers) > 0 {
+		reqOpts = append(reqOpts, request.WithSetRequestHeaders(headers))
+	}
+
+	if r, err = s.spoolReader(r, sentSize); err != nil {
+		return
+	}
 	input.Body = aws.ReadSeekCloser(r)
-	_, err = s.service.PutObjectWithContext(ctx, input)
+	output, err := s.service.PutObjectWithContext(ctx, input, reqOpts...)
 	if err != nil {
 		return
 	}
+
+	if opt.HasWriteResult && opt.WriteResult != nil {
+		*opt.WriteResult = WriteResult{
+			Etag:                                 aws.StringValue(output.ETag),
+			VersionID:                            aws.StringValue(output.VersionId),
+			Expiration:                           aws.StringValue(output.Expiration),
+			ServerSideEncryption:                 aws.StringValue(output.ServerSideEncryption),
+			ServerSideEncryptionAwsKmsKeyID:      aws.StringValue(output.SSEKMSKeyId),
+			ServerSideEncryptionContext:          aws.StringValue(output.SSEKMSEncryptionContext),
+			ServerSideEncryptionBucketKeyEnabled: aws.BoolValue(output.BucketKeyEnabled),
+		}
+	}
 	return size, nil
 }
 
 func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, size int64, index int, opt pairStorageWriteMultipart) (n int64, part *Part, err error) {
+	if err = s.checkObjectPath(o); err != nil {
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		s.reportSlowOperation("write_multipart", o.Path, n, start)
+	}()
+	if opt.HasAbortOnCancel && opt.AbortOnCancel {
+		defer func() {
+			if err != nil && ctx.Err() != nil {
+				s.abortCanceledMultipart(o, opt.ExceptedBucketOwner)
+			}
+		}()
+	}
+
 	if size > multipartSizeMaximum {
 		err = fmt.Errorf("size limit exceeded: %w", services.ErrRestrictionDissatisfied)
 		return
@@ -866,6 +1780,31 @@ func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, si
 	if opt.HasIoCallback {
 		r = iowrap.CallbackReader(r, opt.IoCallback)
 	}
+	if opt.HasProgressCallback {
+		r = newProgressReader(r, opt.ProgressCallback, size)
+	}
+
+	if s.cse != nil {
+		return s.writeEncryptedMultipart(ctx, o, r, size, index, opt)
+	}
+
+	var contentMd5 *string
+	if opt.HasContentMd5 {
+		// Buckets with Object Lock enabled require Content-MD5 on every part.
+		contentMd5 = &opt.ContentMd5
+	} else if s.contentIntegrityMode != "" && size > 0 {
+		var sum string
+		var cleanup func()
+		r, sum, cleanup, err = s.checksumReader(r, size)
+		if err != nil {
+			return
+		}
+		defer cleanup()
+		contentMd5 = &sum
+	}
+	if r, err = s.spoolReader(r, size); err != nil {
+		return
+	}
 
 	input := &s3.UploadPartInput{
 		Bucket: &s.name,
@@ -876,6 +1815,7 @@ func (s *Storage) writeMultipart(ctx context.Context, o *Object, r io.Reader, si
 		Key:           aws.String(o.ID),
 		UploadId:      aws.String(o.MustGetMultipartID()),
 		ContentLength: &size,
+		ContentMD5:    contentMd5,
 		Body:          iowrap.SizedReadSeekCloser(r, size),
 	}
 	if opt.HasExceptedBucketOwner {
@@ -0,0 +1,322 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go/aws"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// SyncAction is the action taken on an object during Sync.
+type SyncAction string
+
+// All available sync actions are listed here.
+const (
+	SyncActionCopy   SyncAction = "copy"
+	SyncActionSkip   SyncAction = "skip"
+	SyncActionDelete SyncAction = "delete"
+)
+
+// KeyMapper maps the path of an object in src to its path in dst during Sync and Migrate, for
+// example, to re-partition date paths, lowercase keys or add hash prefixes, so that data layouts
+// could be restructured without a separate pass.
+//
+// Paths are relative to the work dirs, mapping multiple paths to the same path is not supported.
+type KeyMapper func(path string) string
+
+// SyncOptions controls the behavior of Sync.
+type SyncOptions struct {
+	// Prefix is the path under which objects will be synced, in both src and dst.
+	Prefix string
+	// KeyMapper maps paths of src to dst, paths are kept as is if it's nil.
+	KeyMapper KeyMapper
+	// DstPrefix is the path under which objects in dst are listed for comparison and deletion,
+	// which should cover all mapped paths. It's Prefix by default.
+	DstPrefix string
+	// Delete will delete objects in dst which don't exist in src.
+	Delete bool
+	// DryRun will only report the actions without taking them.
+	DryRun bool
+	// Concurrency is the number of objects synced concurrently, 8 by default.
+	Concurrency int
+	// Progress will be called after each action, it could be called concurrently.
+	Progress func(SyncEvent)
+}
+
+// SyncEvent carries the action taken on an object during Sync.
+type SyncEvent struct {
+	Path string
+	// DstPath is the path in dst, which differs from Path only if KeyMapper is set.
+	DstPath string
+	Action  SyncAction
+	Bytes   int64
+	Err     error
+}
+
+// SyncSummary is the result of Sync.
+type SyncSummary struct {
+	Copied  int64
+	Skipped int64
+	Deleted int64
+	Failed  int64
+	// Bytes is the total size of copied objects.
+	Bytes int64
+}
+
+// Sync will make objects under opt.Prefix in dst the same as src, like rsync.
+//
+// Objects are considered unchanged if they have the same size, and either the same etag or
+// a dst last modified not before src. Objects will be copied on server side if both src and
+// dst are s3 storagers sharing the same service, otherwise content will be streamed through.
+//
+// Failing to sync an object will not stop the sync, the error will be reported via Progress
+// and counted in the summary. Only listing errors will be returned.
+func Sync(ctx context.Context, src, dst typ.Storager, opt SyncOptions) (sum SyncSummary, err error) {
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+
+	dstPrefix := opt.DstPrefix
+	if dstPrefix == "" {
+		dstPrefix = opt.Prefix
+	}
+	mapKey := opt.KeyMapper
+	if mapKey == nil {
+		mapKey = func(path string) string { return path }
+	}
+
+	dstObjects, err := listFiles(ctx, dst, dstPrefix)
+	if err != nil {
+		return
+	}
+	srcObjects, err := listFiles(ctx, src, opt.Prefix)
+	if err != nil {
+		return
+	}
+
+	report := func(e SyncEvent) {
+		switch {
+		case e.Err != nil:
+			atomic.AddInt64(&sum.Failed, 1)
+		case e.Action == SyncActionCopy:
+			atomic.AddInt64(&sum.Copied, 1)
+			atomic.AddInt64(&sum.Bytes, e.Bytes)
+		case e.Action == SyncActionSkip:
+			atomic.AddInt64(&sum.Skipped, 1)
+		case e.Action == SyncActionDelete:
+			atomic.AddInt64(&sum.Deleted, 1)
+		}
+		if opt.Progress != nil {
+			opt.Progress(e)
+		}
+	}
+
+	ch := make(chan *typ.Object)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for so := range ch {
+				size := so.MustGetContentLength()
+				e := SyncEvent{Path: so.Path, DstPath: mapKey(so.Path), Action: SyncActionCopy, Bytes: size}
+				if do, ok := dstObjects[e.DstPath]; ok && !objectChanged(so, do) {
+					e.Action = SyncActionSkip
+				} else if !opt.DryRun {
+					e.Err = syncObject(ctx, src, dst, so, e.DstPath)
+				}
+				report(e)
+			}
+		}()
+	}
+
+	for _, so := range srcObjects {
+		select {
+		case ch <- so:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(ch)
+	wg.Wait()
+	if err != nil || !opt.Delete {
+		return
+	}
+
+	mappedPaths := make(map[string]struct{}, len(srcObjects))
+	for _, so := range srcObjects {
+		mappedPaths[mapKey(so.Path)] = struct{}{}
+	}
+	for p := range dstObjects {
+		if _, ok := mappedPaths[p]; ok {
+			continue
+		}
+
+		e := SyncEvent{Path: p, DstPath: p, Action: SyncActionDelete}
+		if !opt.DryRun {
+			e.Err = dst.DeleteWithContext(ctx, p)
+		}
+		report(e)
+	}
+	return
+}
+
+// listFiles will list all files under prefix, dirs are skipped.
+//
+// Only size, etag and last modified of the objects are read, which are returned in the listing
+// of s3 storagers without a stat for every object.
+func listFiles(ctx context.Context, store typ.Storager, prefix string) (m map[string]*typ.Object, err error) {
+	var it *typ.ObjectIterator
+	if s, ok := store.(*Storage); ok {
+		it, err = s.listFiles(ctx, prefix)
+	} else {
+		it, err = store.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix))
+	}
+	if err != nil {
+		return
+	}
+
+	m = make(map[string]*typ.Object)
+	for {
+		o, err := it.Next()
+		if err == typ.IterateDone {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if o.Mode.IsDir() {
+			continue
+		}
+		m[o.Path] = o
+	}
+}
+
+// listFiles lists objects under prefix like List in prefix mode, while objects are marked as
+// done with only the fields in the listing, so that they could be compared without a HEAD
+// request for every key.
+func (s *Storage) listFiles(ctx context.Context, prefix string) (*typ.ObjectIterator, error) {
+	rp, err := s.getAbsPath(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &objectPageStatus{
+		maxKeys:    200,
+		prefix:     rp,
+		listedOnly: true,
+	}
+	return typ.NewObjectIterator(ctx, skipEmptyObjectPages(s.nextObjectPageByPrefix), input), nil
+}
+
+// objectChanged will compare src and dst by size, etag and last modified.
+func objectChanged(src, dst *typ.Object) bool {
+	ss, _ := src.GetContentLength()
+	ds, _ := dst.GetContentLength()
+	if ss != ds {
+		return true
+	}
+
+	// Etags of multipart uploads (with a `-N` suffix) depend on the part size, which can't be compared.
+	se, sok := src.GetEtag()
+	de, dok := dst.GetEtag()
+	if sok && dok && !strings.Contains(se, "-") && !strings.Contains(de, "-") {
+		return se != de
+	}
+
+	st, sok := src.GetLastModified()
+	dt, dok := dst.GetLastModified()
+	if !sok || !dok {
+		return true
+	}
+	return st.After(dt)
+}
+
+// syncObject copies the object o in src to dstPath in dst.
+func syncObject(ctx context.Context, src, dst typ.Storager, o *typ.Object, dstPath string) (err error) {
+	ss, sok := src.(*Storage)
+	ds, dok := dst.(*Storage)
+	if sok && dok && ds.canCopyFrom(ss) {
+		return ds.copyFrom(ctx, ss, o.Path, dstPath)
+	}
+
+	size := o.MustGetContentLength()
+	r, w := io.Pipe()
+	go func() {
+		_, err := src.ReadWithContext(ctx, o.Path, w)
+		w.CloseWithError(err)
+	}()
+	_, err = dst.WriteWithContext(ctx, dstPath, r, size)
+	r.CloseWithError(err)
+	return err
+}
+
+// canCopyFrom checks whether objects in from could be copied to s on server side, which requires
+// both storages to talk to the same endpoint in the same region with the same access key.
+//
+// Every storage has its own client, so clients can't be compared directly. Storages with
+// client-side encryption or SSE-C key providers are excluded, as their content must be
+// decrypted and encrypted again by the client.
+func (s *Storage) canCopyFrom(from *Storage) bool {
+	if s.cse != nil || from.cse != nil || s.customerKeyProvider != nil || from.customerKeyProvider != nil {
+		return false
+	}
+	if s.service.Endpoint != from.service.Endpoint ||
+		aws.StringValue(s.service.Config.Region) != aws.StringValue(from.service.Config.Region) {
+		return false
+	}
+
+	sc, fc := s.service.Config.Credentials, from.service.Config.Credentials
+	if sc == fc {
+		return true
+	}
+	if sc == nil || fc == nil {
+		return false
+	}
+	sv, err := sc.Get()
+	if err != nil {
+		return false
+	}
+	fv, err := fc.Get()
+	if err != nil {
+		return false
+	}
+	return sv.AccessKeyID == fv.AccessKeyID
+}
+
+// copyFrom will copy the object at src in the bucket of from to dst on server side.
+func (s *Storage) copyFrom(ctx context.Context, from *Storage, src, dst string) (err error) {
+	defer func() {
+		err = s.formatError("copy", err, src, dst)
+	}()
+
+	// Default pairs like server side encryption of dst should still be applied.
+	opt, err := s.parsePairStorageCopy(s.defaultPairs.Copy)
+	if err != nil {
+		return
+	}
+	input, err := s.formatCopyObjectInput(src, dst, opt)
+	if err != nil {
+		return
+	}
+
+	rs, err := from.getAbsPath(src)
+	if err != nil {
+		return
+	}
+	input.CopySource = aws.String((&url.URL{Path: from.name + "/" + rs}).EscapedPath())
+
+	_, err = s.service.CopyObjectWithContext(ctx, input)
+	return
+}
@@ -2,10 +2,18 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	tests "github.com/minhjh/go-integration-test/v4"
+
+	s3 "github.com/minhjh/go-service-s3/v2"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/services"
+	"github.com/minhjh/go-storage/v4/types"
 )
 
 func TestStorage(t *testing.T) {
@@ -22,6 +30,13 @@ func TestMultiparter(t *testing.T) {
 	tests.TestMultiparter(t, setupTest(t))
 }
 
+func TestCopier(t *testing.T) {
+	if os.Getenv("STORAGE_S3_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_S3_INTEGRATION_TEST is not 'on', skipped")
+	}
+	tests.TestCopier(t, setupTest(t))
+}
+
 func TestDirer(t *testing.T) {
 	if os.Getenv("STORAGE_S3_INTEGRATION_TEST") != "on" {
 		t.Skipf("STORAGE_S3_INTEGRATION_TEST is not 'on', skipped")
@@ -46,6 +61,18 @@ func TestHTTPSigner(t *testing.T) {
 	tests.TestMultipartHTTPSigner(t, setupTest(t))
 }
 
+func TestPing(t *testing.T) {
+	if os.Getenv("STORAGE_S3_INTEGRATION_TEST") != "on" {
+		t.Skipf("STORAGE_S3_INTEGRATION_TEST is not 'on', skipped")
+	}
+	store := setupTest(t)
+
+	_, err := store.(*s3.Storage).Ping(context.Background())
+	if err != nil {
+		t.Errorf("ping: %v", err)
+	}
+}
+
 // https://github.com/minhjh/go-storage/issues/741
 func TestIssue741(t *testing.T) {
 	if os.Getenv("STORAGE_S3_INTEGRATION_TEST") != "on" {
@@ -63,3 +90,76 @@ func TestIssue741(t *testing.T) {
 	}
 	return
 }
+
+// cassettePairs are the pairs of the storage which tests/testdata/cassette.json is recorded with.
+func cassettePairs() []types.Pair {
+	mode := s3.CassetteModeReplay
+	if v := os.Getenv("STORAGE_S3_CASSETTE_MODE"); v != "" {
+		mode = v
+	}
+	return []types.Pair{
+		ps.WithCredential("hmac:cassette:cassette"),
+		ps.WithEndpoint("http:127.0.0.1:9000"),
+		ps.WithLocation("us-east-1"),
+		ps.WithName("cassette"),
+		ps.WithWorkDir("/TestCassette/"),
+		s3.WithForcePathStyle(),
+		s3.WithCassette("testdata/cassette.json"),
+		s3.WithCassetteMode(mode),
+	}
+}
+
+// TestCassette replays the recorded interactions without network, so that it always runs.
+func TestCassette(t *testing.T) {
+	store, err := s3.NewStorager(cassettePairs()...)
+	if err != nil {
+		t.Fatalf("new storager: %v", err)
+	}
+
+	content := "Hello, World!"
+	if _, err = store.Write("abc", strings.NewReader(content), int64(len(content))); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	o, err := store.Stat("abc")
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if size := o.MustGetContentLength(); size != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), size)
+	}
+
+	var buf bytes.Buffer
+	if _, err = store.Read("abc", &buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if buf.String() != content {
+		t.Errorf("expected %q, got %q", content, buf.String())
+	}
+
+	it, err := store.List("", ps.WithListMode(types.ListModePrefix))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var paths []string
+	for {
+		o, err := it.Next()
+		if err == types.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		paths = append(paths, o.Path)
+	}
+	if len(paths) != 1 || paths[0] != "abc" {
+		t.Errorf("expected [abc], got %v", paths)
+	}
+
+	if err = store.Delete("abc"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err = store.Stat("abc"); !errors.Is(err, services.ErrObjectNotExist) {
+		t.Errorf("expected %v, got %v", services.ErrObjectNotExist, err)
+	}
+}
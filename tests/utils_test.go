@@ -14,16 +14,39 @@ import (
 func setupTest(t *testing.T) types.Storager {
 	t.Log("Setup test for s3")
 
-	store, err := s3.NewStorager(
+	// Paths must be the same while recording and replaying a cassette, so the test name is used
+	// as the work dir instead of a random one.
+	workDir := "/" + uuid.New().String() + "/"
+	if os.Getenv("STORAGE_S3_CASSETTE") != "" {
+		workDir = "/" + t.Name() + "/"
+	}
+
+	pairs := []types.Pair{
 		ps.WithCredential(os.Getenv("STORAGE_S3_CREDENTIAL")),
 		ps.WithName(os.Getenv("STORAGE_S3_NAME")),
 		ps.WithLocation(os.Getenv("STORAGE_S3_LOCATION")),
-		ps.WithWorkDir("/"+uuid.New().String()+"/"),
+		ps.WithWorkDir(workDir),
 		s3.WithStorageFeatures(s3.StorageFeatures{
 			VirtualDir:  true,
 			VirtualLink: true,
 		}),
-	)
+	}
+	// Tests could be run against S3 compatible services like Cloudflare R2 and Backblaze B2.
+	if v := os.Getenv("STORAGE_S3_ENDPOINT"); v != "" {
+		pairs = append(pairs, ps.WithEndpoint(v))
+	}
+	if v := os.Getenv("STORAGE_S3_COMPATIBILITY_MODE"); v != "" {
+		pairs = append(pairs, s3.WithCompatibilityMode(v))
+	}
+	// HTTP interactions could be recorded into a cassette and replayed offline.
+	if v := os.Getenv("STORAGE_S3_CASSETTE"); v != "" {
+		pairs = append(pairs, s3.WithCassette(v))
+	}
+	if v := os.Getenv("STORAGE_S3_CASSETTE_MODE"); v != "" {
+		pairs = append(pairs, s3.WithCassetteMode(v))
+	}
+
+	store, err := s3.NewStorager(pairs...)
 	if err != nil {
 		t.Errorf("new storager: %v", err)
 	}
@@ -1,14 +1,28 @@
 package s3
 
 import (
+	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -26,6 +40,16 @@ type Service struct {
 	sess    *session.Session
 	service *s3.S3
 
+	// storagePairs are the storage scoped pairs passed while creating the service, which will be
+	// inherited by all storagers created by the service.
+	storagePairs []typ.Pair
+	// endpoint and compatibilityMode are the ones of the session, which storagers inherit unless
+	// they're overridden.
+	endpoint          string
+	compatibilityMode string
+	// cassette will wrap the transports of http clients used by the service and its storagers.
+	cassette *cassetteTape
+
 	defaultPairs DefaultServicePairs
 	features     ServiceFeatures
 
@@ -37,6 +61,18 @@ func (s *Service) String() string {
 	return fmt.Sprintf("Servicer s3")
 }
 
+// Client returns the underlying S3 client, which could be used to call APIs not wrapped
+// by this package with the same configuration.
+func (s *Service) Client() *s3.S3 {
+	return s.service
+}
+
+// Session returns the underlying session, which could be used to create clients of other
+// AWS services with the same credential and handlers.
+func (s *Service) Session() *session.Session {
+	return s.sess
+}
+
 // Storage is the s3 object storage service.
 type Storage struct {
 	service *s3.S3
@@ -47,7 +83,46 @@ type Storage struct {
 	defaultPairs DefaultStoragePairs
 	features     StorageFeatures
 
+	slowOperationThreshold time.Duration
+	slowOperationCallback  func(SlowOperationEvent)
+
+	linkReference bool
+	dirMarker     string
+	compat        compatibility
+	prefixRules   []prefixRule
+
+	// Capabilities not supported by every bucket or service, tagging and ACL are supported by
+	// default while the others must be enabled explicitly.
+	aclDisabled       bool
+	taggingDisabled   bool
+	objectLockEnabled bool
+	selectEnabled     bool
+	versioningEnabled bool
+
+	contentIntegrityMode string
+	customerKeyProvider  CustomerKeyProvider
+	writeSpoolThreshold  int64
+
+	bucketInfoLock sync.Mutex
+	bucketInfo     *StorageSystemMetadata
+
+	defaultEncryptionLock sync.Mutex
+	defaultEncryption     *string
+
+	// cse is nil if client-side encryption is not enabled.
+	cse *clientSideEncryption
+	// metadataCache is nil if metadata_cache is not enabled.
+	metadataCache *metadataCache
+	// readCache is nil if read_cache_dir is not set.
+	readCache *readCache
+	// presignCache is nil if presign_cache_size is not set.
+	presignCache *presignCache
+	// replica is nil if read_replica is not set.
+	replica typ.Storager
+	mirrors []typ.Storager
+
 	typ.UnimplementedStorager
+	typ.UnimplementedCopier
 	typ.UnimplementedDirer
 	typ.UnimplementedMultiparter
 	typ.UnimplementedLinker
@@ -63,6 +138,35 @@ func (s *Storage) String() string {
 	)
 }
 
+// Client returns the underlying S3 client, which has been configured with the region of the bucket.
+//
+// Keys passed to the client are absolute, the work dir will not be applied.
+func (s *Storage) Client() *s3.S3 {
+	return s.service
+}
+
+// WriteResult carries the metadata returned by S3 after an object has been written.
+//
+// Pass a pointer via WithWriteResult to get them without an extra stat.
+type WriteResult struct {
+	// Etag is the entity tag of the written object.
+	//
+	// For objects uploaded by a single PUT without SSE-C or SSE-KMS, it's the hex encoded MD5
+	// digest of the content, which could be used as a checksum.
+	Etag string
+	// VersionID is the version of the written object, only available for versioned buckets.
+	VersionID string
+	// Expiration is the raw `x-amz-expiration` header if a lifecycle rule applies.
+	Expiration string
+	// Location is the URI of the object, only returned while completing a multipart upload.
+	Location string
+
+	ServerSideEncryption                 string
+	ServerSideEncryptionAwsKmsKeyID      string
+	ServerSideEncryptionContext          string
+	ServerSideEncryptionBucketKeyEnabled bool
+}
+
 // New will create both Servicer and Storager.
 func New(pairs ...typ.Pair) (typ.Servicer, typ.Storager, error) {
 	return newServicerAndStorager(pairs...)
@@ -82,7 +186,7 @@ func NewStorager(pairs ...typ.Pair) (typ.Storager, error) {
 func newServicer(pairs ...typ.Pair) (srv *Service, err error) {
 	defer func() {
 		if err != nil {
-			err = services.InitError{Op: "new_servicer", Type: Type, Err: formatError(err), Pairs: pairs}
+			err = services.InitError{Op: "new_servicer", Type: Type, Err: formatError(err), Pairs: redactPairs(pairs)}
 		}
 	}()
 
@@ -95,6 +199,17 @@ func newServicer(pairs ...typ.Pair) (srv *Service, err error) {
 
 	// Set s3 config's http client
 	cfg.HTTPClient = httpclient.New(opt.HTTPClientOptions)
+	var tape *cassetteTape
+	if opt.HasCassette {
+		mode := CassetteModeReplay
+		if opt.HasCassetteMode {
+			mode = opt.CassetteMode
+		}
+		tape, err = newCassetteTape(opt.Cassette, mode)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// S3 SDK will compute content MD5 by default. But we will let users calculate content MD5 and pass into as a pair `Content-MD5` in our design.
 	// So we need to disable the auto content MD5 validation here.
@@ -103,60 +218,97 @@ func newServicer(pairs ...typ.Pair) (srv *Service, err error) {
 	// We need to make all letters lowercase,
 	// so we need to set the API response header mapping here to decrypt to normalised lowercase mapping keys.
 	cfg.LowerCaseHeaderMaps = aws.Bool(true)
+	// Requests dumped by the SDK at debug levels carry credentials and SSE-C keys.
+	cfg.Logger = redactLogger{logger: aws.NewDefaultLogger()}
 
+	mode := CompatibilityModeAWS
 	if opt.HasEndpoint {
-		ep, err := endpoint.Parse(opt.Endpoint)
+		url, err := parseEndpoint(opt.Endpoint)
 		if err != nil {
 			return nil, err
 		}
-
-		var url string
-		switch ep.Protocol() {
-		case endpoint.ProtocolHTTP:
-			url, _, _ = ep.HTTP()
-		case endpoint.ProtocolHTTPS:
-			url, _, _ = ep.HTTPS()
-		default:
-			return nil, services.PairUnsupportedError{Pair: ps.WithEndpoint(opt.Endpoint)}
-		}
 		cfg = cfg.WithEndpoint(url)
+		mode = detectCompatibilityMode(url)
 	}
+	if opt.HasCompatibilityMode {
+		mode = opt.CompatibilityMode
+	}
+	compat, err := parseCompatibilityMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	// Defaults of the backend could still be overridden by pairs below.
+	cfg = cfg.WithS3ForcePathStyle(compat.forcePathStyle).WithS3Disable100Continue(compat.disable100Continue)
+
 	if opt.HasForcePathStyle {
 		cfg = cfg.WithS3ForcePathStyle(opt.ForcePathStyle)
 	}
 	if opt.HasDisable100Continue {
 		cfg = cfg.WithS3Disable100Continue(opt.Disable100Continue)
 	}
-	if opt.HasUseAccelerate {
-		cfg = cfg.WithS3Disable100Continue(opt.UseAccelerate)
+	if opt.HasUseAccelerate && compat.accelerate {
+		cfg = cfg.WithS3UseAccelerate(opt.UseAccelerate)
+	}
+	if opt.HasUseDualStack {
+		cfg = cfg.WithUseDualStack(opt.UseDualStack)
 	}
 	if opt.HasUseArnRegion {
 		cfg = cfg.WithS3UseARNRegion(opt.UseArnRegion)
 	}
-
-	cp, err := credential.Parse(opt.Credential)
-	if err != nil {
-		return nil, err
+	if opt.HasRetryCallback {
+		cfg = request.WithRetryer(cfg, newCallbackRetryer(cfg, opt.RetryCallback))
 	}
-	switch cp.Protocol() {
-	case credential.ProtocolHmac:
-		ak, sk := cp.Hmac()
 
-		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(ak, sk, ""))
-	case credential.ProtocolEnv:
-		cfg = cfg.WithCredentials(credentials.NewEnvCredentials())
+	switch {
+	case opt.HasCredentialProvider:
+		cfg = cfg.WithCredentials(newProviderCredentials(opt.CredentialProvider))
+	case opt.HasCredential:
+		cred, err := parseCredential(opt.Credential)
+		if err != nil {
+			return nil, err
+		}
+		cfg = cfg.WithCredentials(cred)
 	default:
-		return nil, services.PairUnsupportedError{Pair: ps.WithCredential(opt.Credential)}
+		return nil, services.PairRequiredError{Keys: []string{"credential"}}
 	}
 
 	sess, err := session.NewSession(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if tape != nil {
+		// The transport is wrapped after the session is created, so that the custom CA bundle
+		// could still be loaded into it.
+		sess.Config.HTTPClient.Transport = tape.wrap(sess.Config.HTTPClient.Transport)
+	}
+	if opt.HasRequestHandlers {
+		// Handlers added into session will be inherited by all clients created from it.
+		opt.RequestHandlers.apply(&sess.Handlers)
+	}
+	if opt.HasRequestCostCallback {
+		sess.Handlers.Complete.PushBackNamed(newRequestCostHandler(opt.RequestCostCallback))
+	}
+	if opt.HasFaultPolicy {
+		opt.FaultPolicy.apply(&sess.Handlers)
+	}
 
 	srv = &Service{
-		sess:    sess,
-		service: newS3Service(sess),
+		sess:     sess,
+		service:  newS3Service(sess),
+		cassette: tape,
+	}
+	compat.apply(&srv.service.Handlers)
+
+	if opt.HasEndpoint {
+		srv.endpoint = aws.StringValue(cfg.Endpoint)
+	}
+	srv.compatibilityMode = mode
+	for _, v := range pairs {
+		// name and work_dir are specific to the storager created along with the service.
+		if v.Key == "name" || v.Key == "work_dir" || isSessionPair(v) {
+			continue
+		}
+		srv.storagePairs = append(srv.storagePairs, v)
 	}
 
 	if opt.HasDefaultServicePairs {
@@ -168,6 +320,124 @@ func newServicer(pairs ...typ.Pair) (srv *Service, err error) {
 	return
 }
 
+// isSessionPair checks whether the pair configures the session of the service, which is shared by
+// storagers created by the service. Passing them to a storager will copy the session.
+func isSessionPair(p typ.Pair) bool {
+	switch p.Key {
+	case "credential", "credential_provider", "endpoint", "force_path_style", "http_client_options", "compatibility_mode":
+		return true
+	default:
+		return false
+	}
+}
+
+// isObjectLambdaAccessPoint checks whether name is the ARN or alias of an Object Lambda access point,
+// which could be used as the storage name to read transformed objects. Only read, stat and list are
+// supported by Object Lambda.
+//
+// ref: https://docs.aws.amazon.com/AmazonS3/latest/userguide/olap-use.html
+func isObjectLambdaAccessPoint(name string) bool {
+	if strings.HasSuffix(name, objectLambdaAliasSuffix) {
+		return true
+	}
+	a, err := arn.Parse(name)
+	return err == nil && a.Service == objectLambdaSigningName
+}
+
+const (
+	// objectLambdaSigningName is the service name of Object Lambda used in ARNs and signing.
+	objectLambdaSigningName = "s3-object-lambda"
+	// objectLambdaAliasSuffix is the suffix of Object Lambda access point aliases.
+	objectLambdaAliasSuffix = "--ol-s3"
+)
+
+// partitionOf returns the partition of region like `aws`, `aws-cn` and `aws-us-gov`, regions
+// unknown to the SDK are treated as in the `aws` partition.
+func partitionOf(region string) string {
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return p.ID()
+	}
+	return endpoints.AwsPartitionID
+}
+
+// parseKmsKeyID will trim and validate the AWS KMS key id, which could be a key id, an alias
+// like `alias/name`, or the ARN of either. The region in the ARN will be returned if present.
+func parseKmsKeyID(id string) (keyID string, a *arn.ARN, ok bool) {
+	keyID = strings.TrimSpace(id)
+	if !strings.HasPrefix(keyID, "arn:") {
+		if strings.HasPrefix(keyID, "alias/") {
+			return keyID, nil, len(keyID) > len("alias/")
+		}
+		return keyID, nil, keyID != "" && !strings.ContainsAny(keyID, "/: ")
+	}
+
+	v, err := arn.Parse(keyID)
+	if err != nil || v.Service != "kms" || v.Region == "" {
+		return "", nil, false
+	}
+	if !strings.HasPrefix(v.Resource, "key/") && !strings.HasPrefix(v.Resource, "alias/") {
+		return "", nil, false
+	}
+	return keyID, &v, true
+}
+
+// formatKmsKeyID will normalize the AWS KMS key id used for server-side encryption.
+//
+// S3 only uses KMS keys in the same region as the bucket. Multi-Region keys (`mrk-` prefixed)
+// share the same id in all regions, so the ARN of a replica in another region will be rewritten
+// to the region of the bucket, while other keys in another region will be rejected before sending.
+func (s *Storage) formatKmsKeyID(id string) (*string, error) {
+	keyID, a, ok := parseKmsKeyID(id)
+	if !ok {
+		return nil, services.PairUnsupportedError{Pair: WithServerSideEncryptionAwsKmsKeyID(id)}
+	}
+	if region := aws.StringValue(s.service.Config.Region); a != nil && a.Region != region {
+		if !strings.HasPrefix(a.Resource, "key/mrk-") {
+			return nil, services.PairUnsupportedError{Pair: WithServerSideEncryptionAwsKmsKeyID(id)}
+		}
+		a.Region = region
+		keyID = a.String()
+	}
+	return &keyID, nil
+}
+
+// parseEndpoint will parse the endpoint pair into the url used by the SDK.
+func parseEndpoint(v string) (string, error) {
+	ep, err := endpoint.Parse(v)
+	if err != nil {
+		return "", err
+	}
+
+	var url string
+	switch ep.Protocol() {
+	case endpoint.ProtocolHTTP:
+		url, _, _ = ep.HTTP()
+	case endpoint.ProtocolHTTPS:
+		url, _, _ = ep.HTTPS()
+	default:
+		return "", services.PairUnsupportedError{Pair: ps.WithEndpoint(v)}
+	}
+	return url, nil
+}
+
+// parseCredential will parse the credential pair into the credentials used by the SDK.
+func parseCredential(v string) (*credentials.Credentials, error) {
+	cp, err := credential.Parse(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cp.Protocol() {
+	case credential.ProtocolHmac:
+		ak, sk := cp.Hmac()
+		return credentials.NewStaticCredentials(ak, sk, ""), nil
+	case credential.ProtocolEnv:
+		return credentials.NewEnvCredentials(), nil
+	default:
+		return nil, services.PairUnsupportedError{Pair: ps.WithCredential(v)}
+	}
+}
+
 // New will create a new s3 service.
 func newServicerAndStorager(pairs ...typ.Pair) (srv *Service, store *Storage, err error) {
 	srv, err = newServicer(pairs...)
@@ -175,9 +445,16 @@ func newServicerAndStorager(pairs ...typ.Pair) (srv *Service, store *Storage, er
 		return
 	}
 
-	store, err = srv.newStorage(pairs...)
+	// Pairs of the session have been applied to the service.
+	storagePairs := make([]typ.Pair, 0, len(pairs))
+	for _, v := range pairs {
+		if !isSessionPair(v) {
+			storagePairs = append(storagePairs, v)
+		}
+	}
+	store, err = srv.newStorage(storagePairs...)
 	if err != nil {
-		err = services.InitError{Op: "new_storager", Type: Type, Err: formatError(err), Pairs: pairs}
+		err = services.InitError{Op: "new_storager", Type: Type, Err: formatError(err), Pairs: redactPairs(pairs)}
 		return
 	}
 	return
@@ -195,23 +472,73 @@ const (
 )
 
 func formatError(err error) error {
-	if _, ok := err.(services.InternalError); ok {
+	// Errors of this package like ErrPathInvalid may be wrapped with details.
+	var ie services.InternalError
+	if errors.As(err, &ie) {
 		return err
 	}
 
+	// Messages of errors may carry signed urls, which must not be leaked into logs.
+	re := redactError(err)
+
 	e, ok := err.(awserr.RequestFailure)
 	if !ok {
-		return fmt.Errorf("%w: %v", services.ErrUnexpected, err)
+		return fmt.Errorf("%w: %v", services.ErrUnexpected, re)
+	}
+
+	// Errors returned by KMS will be forwarded by S3 with a `KMS.` prefix, for example `KMS.DisabledException`.
+	if _, ok := err.(kmsRequestError); ok || strings.HasPrefix(e.Code(), "KMS.") {
+		switch strings.TrimPrefix(e.Code(), "KMS.") {
+		case "AccessDeniedException", "AccessDenied":
+			return fmt.Errorf("%w: %v", ErrKmsAccessDenied, re)
+		default:
+			return fmt.Errorf("%w: %v", ErrKmsRequestFailed, re)
+		}
 	}
 
 	switch e.Code() {
 	// AWS SDK will use status code to generate awserr.Error, so "NotFound" should also be supported.
 	case "NoSuchKey", "NotFound":
-		return fmt.Errorf("%w: %v", services.ErrObjectNotExist, err)
+		return fmt.Errorf("%w: %v", services.ErrObjectNotExist, re)
 	case "AccessDenied":
-		return fmt.Errorf("%w: %v", services.ErrPermissionDenied, err)
+		return fmt.Errorf("%w: %v", services.ErrPermissionDenied, re)
+	case "NoSuchBucket":
+		return fmt.Errorf("%w: %v", ErrBucketNotExist, re)
+	case "BucketAlreadyExists", "BucketAlreadyOwnedByYou":
+		return fmt.Errorf("%w: %v", ErrBucketAlreadyExists, re)
+	case "NoSuchUpload":
+		return fmt.Errorf("%w: %v", ErrMultipartNotExist, re)
+	case "InvalidRange":
+		return fmt.Errorf("%w: %v", ErrRangeNotSatisfiable, re)
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return fmt.Errorf("%w: %v", ErrPreconditionFailed, re)
+	case "NotModified":
+		return fmt.Errorf("%w: %v", ErrObjectNotModified, re)
+	case "SlowDown", "ServiceUnavailable", "TooManyRequests":
+		re := RateLimitedError{Err: fmt.Errorf("%w: %v", ErrRateLimited, re)}
+		if v, ok := err.(retryAfterError); ok {
+			re.RetryAfter = v.retryAfter
+		}
+		return re
+	case "InvalidObjectState":
+		ae := ObjectArchivedError{Err: fmt.Errorf("%w: %v", ErrObjectArchived, re)}
+		if v, ok := err.(objectArchivedError); ok {
+			ae.StorageClass = v.storageClass
+			ae.RestoreOngoing = v.restoreOngoing
+		}
+		return ae
+	case "InvalidPart", "EntityTooSmall":
+		pe := MultipartPartError{Code: e.Code(), Err: fmt.Errorf("%w: %v", ErrMultipartPartInvalid, re)}
+		if v, ok := err.(multipartPartError); ok {
+			pe.Indexes = v.indexes
+		}
+		return pe
+	case "RequestTimeout":
+		return fmt.Errorf("%w: %v", ErrRequestTimeout, re)
+	case "EntityTooLarge":
+		return fmt.Errorf("%w: %v", services.ErrRestrictionDissatisfied, re)
 	default:
-		return fmt.Errorf("%w: %v", services.ErrUnexpected, err)
+		return fmt.Errorf("%w: %v", services.ErrUnexpected, re)
 	}
 }
 
@@ -226,21 +553,118 @@ func newS3Service(sess *session.Session, cfgs ...*aws.Config) (srv *s3.S3) {
 		// With UnsignedPayload set to true, signer will set "X-Amz-Content-Sha256" to "UNSIGNED-PAYLOAD"
 		s.UnsignedPayload = true
 	}))
+	srv.Handlers.UnmarshalError.PushBackNamed(retryAfterHandler)
+	srv.Handlers.Unmarshal.PushBackNamed(completeMultipartResultHandler)
 	return
 }
 
 // newStorage will create a new client.
+//
+// Storage scoped pairs passed while creating the service will be appended, so that storagers
+// created by the service share the same defaults and features. Pairs passed in will win.
 func (s *Service) newStorage(pairs ...typ.Pair) (st *Storage, err error) {
-	opt, err := parsePairStorageNew(pairs)
+	// Copy pairs so that the backing array of the caller is not written.
+	merged := make([]typ.Pair, 0, len(pairs)+len(s.storagePairs))
+	merged = append(merged, pairs...)
+	merged = append(merged, s.storagePairs...)
+
+	opt, err := parsePairStorageNew(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := s.compatibilityMode
+	endpointURL := s.endpoint
+	if opt.HasEndpoint {
+		endpointURL, err = parseEndpoint(opt.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		mode = detectCompatibilityMode(endpointURL)
+	}
+	if opt.HasCompatibilityMode {
+		mode = opt.CompatibilityMode
+	}
+	compat, err := parseCompatibilityMode(mode)
 	if err != nil {
 		return nil, err
 	}
 
+	// Buckets could be served by different providers, the config of the service could be
+	// overridden per storage while handlers of the service are kept.
+	sess := s.sess
+	if isObjectLambdaAccessPoint(opt.Name) {
+		// Requests to Object Lambda access points are routed and signed with the `s3-object-lambda`
+		// service name by the SDK, and the region must be the one in the ARN.
+		sess = sess.Copy(aws.NewConfig().WithS3UseARNRegion(true))
+	}
+	if opt.HasCredential || opt.HasCredentialProvider || opt.HasEndpoint || opt.HasForcePathStyle || opt.HasHTTPClientOptions || opt.HasCompatibilityMode {
+		cfg := aws.NewConfig().
+			WithS3ForcePathStyle(compat.forcePathStyle).
+			WithS3Disable100Continue(compat.disable100Continue)
+		if opt.HasCredentialProvider {
+			cfg = cfg.WithCredentials(newProviderCredentials(opt.CredentialProvider))
+		} else if opt.HasCredential {
+			cred, err := parseCredential(opt.Credential)
+			if err != nil {
+				return nil, err
+			}
+			cfg = cfg.WithCredentials(cred)
+		}
+		if opt.HasEndpoint {
+			cfg = cfg.WithEndpoint(endpointURL)
+		}
+		if opt.HasForcePathStyle {
+			cfg = cfg.WithS3ForcePathStyle(opt.ForcePathStyle)
+		}
+		if opt.HasHTTPClientOptions {
+			cfg.HTTPClient = httpclient.New(opt.HTTPClientOptions)
+			if s.cassette != nil {
+				cfg.HTTPClient.Transport = s.cassette.wrap(cfg.HTTPClient.Transport)
+			}
+		}
+		sess = sess.Copy(cfg)
+	}
+
+	// Endpoints and signing regions of partitions like `aws-cn` and `aws-us-gov` will be resolved
+	// by the SDK from the region, but transfer acceleration is only available in the `aws` partition.
+	cfg := aws.NewConfig().WithRegion(opt.Location)
+	if partitionOf(opt.Location) != endpoints.AwsPartitionID {
+		cfg = cfg.WithS3UseAccelerate(false)
+	}
+
 	st = &Storage{
-		service: newS3Service(s.sess, aws.NewConfig().WithRegion(opt.Location)),
+		service: newS3Service(sess, cfg),
 
-		name:    opt.Name,
-		workDir: "/",
+		name:      opt.Name,
+		workDir:   "/",
+		dirMarker: DirMarkerSlash,
+		compat:    compat,
+	}
+	compat.apply(&st.service.Handlers)
+	if opt.HasFailoverEndpoints && len(opt.FailoverEndpoints) > 0 {
+		pool, err := parseFailoverEndpoints(endpointURL, opt.FailoverEndpoints)
+		if err != nil {
+			return nil, err
+		}
+		pool.apply(&st.service.Handlers)
+	}
+	if opt.HasOperationPolicy {
+		opt.OperationPolicy.apply(&st.service.Handlers)
+	}
+	if opt.HasServerSideEncryptionCustomerKeyProvider {
+		st.customerKeyProvider = opt.ServerSideEncryptionCustomerKeyProvider
+		// The handler must run before require_encryption, as SSE-C keys filled by it count.
+		st.service.Handlers.Validate.PushBackNamed(request.NamedHandler{
+			Name: "s3.CustomerKeyHandler",
+			Fn:   st.customerKeyHandler,
+		})
+	}
+	if opt.HasRequireEncryption && opt.RequireEncryption {
+		st.service.Handlers.Validate.PushBackNamed(request.NamedHandler{
+			Name: "s3.RequireEncryptionHandler",
+			Fn:   st.requireEncryptionHandler,
+		})
 	}
 
 	if opt.HasDefaultStoragePairs {
@@ -250,7 +674,110 @@ func (s *Service) newStorage(pairs ...typ.Pair) (st *Storage, err error) {
 		st.features = opt.StorageFeatures
 	}
 	if opt.HasWorkDir {
-		st.workDir = opt.WorkDir
+		st.workDir = normalizeWorkDir(opt.WorkDir)
+	}
+	if opt.HasSlowOperationThreshold {
+		st.slowOperationThreshold = opt.SlowOperationThreshold
+	}
+	if opt.HasSlowOperationCallback {
+		st.slowOperationCallback = opt.SlowOperationCallback
+	}
+	if opt.HasLinkReference {
+		st.linkReference = opt.LinkReference
+	}
+	if opt.HasDisableACL {
+		st.aclDisabled = opt.DisableACL
+	}
+	if opt.HasDisableTagging {
+		st.taggingDisabled = opt.DisableTagging
+	}
+	if opt.HasEnableObjectLock {
+		st.objectLockEnabled = opt.EnableObjectLock
+	}
+	if opt.HasEnableSelect {
+		st.selectEnabled = opt.EnableSelect
+	}
+	if opt.HasEnableVersioning {
+		st.versioningEnabled = opt.EnableVersioning
+	}
+	if opt.HasDirMarker {
+		switch opt.DirMarker {
+		case DirMarkerSlash, DirMarkerFolder, DirMarkerNone:
+			st.dirMarker = opt.DirMarker
+		default:
+			return nil, services.PairUnsupportedError{Pair: WithDirMarker(opt.DirMarker)}
+		}
+	}
+	if opt.HasContentIntegrityMode {
+		switch opt.ContentIntegrityMode {
+		case ContentIntegrityModeStrict, ContentIntegrityModeSpool:
+			st.contentIntegrityMode = opt.ContentIntegrityMode
+		default:
+			return nil, services.PairUnsupportedError{Pair: WithContentIntegrityMode(opt.ContentIntegrityMode)}
+		}
+	}
+	if opt.HasWriteSpoolThreshold {
+		if opt.WriteSpoolThreshold < 0 {
+			return nil, services.PairUnsupportedError{Pair: WithWriteSpoolThreshold(opt.WriteSpoolThreshold)}
+		}
+		st.writeSpoolThreshold = opt.WriteSpoolThreshold
+	} else if opt.HasFailoverEndpoints && len(opt.FailoverEndpoints) > 0 {
+		st.writeSpoolThreshold = failoverWriteSpoolThreshold
+	}
+	if opt.HasMetadataCache {
+		st.metadataCache = &metadataCache{backend: opt.MetadataCache, ttl: defaultMetadataCacheTTL}
+		if opt.HasMetadataCacheTTL {
+			if opt.MetadataCacheTTL <= 0 {
+				return nil, services.PairUnsupportedError{Pair: WithMetadataCacheTTL(opt.MetadataCacheTTL)}
+			}
+			st.metadataCache.ttl = opt.MetadataCacheTTL
+		}
+		st.service.Handlers.Complete.PushBackNamed(request.NamedHandler{
+			Name: "s3.MetadataCacheInvalidateHandler",
+			Fn:   st.metadataCache.invalidateHandler,
+		})
+	}
+	if opt.HasReadCacheDir {
+		if opt.HasReadCacheMaxSize && opt.ReadCacheMaxSize < 0 {
+			return nil, services.PairUnsupportedError{Pair: WithReadCacheMaxSize(opt.ReadCacheMaxSize)}
+		}
+		st.readCache, err = newReadCache(opt.ReadCacheDir, opt.ReadCacheMaxSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.HasPresignCacheSize && opt.PresignCacheSize > 0 {
+		fraction := defaultPresignCacheReuseFraction
+		if opt.HasPresignCacheReuseFraction {
+			if opt.PresignCacheReuseFraction <= 0 || opt.PresignCacheReuseFraction > 1 {
+				return nil, services.PairUnsupportedError{Pair: WithPresignCacheReuseFraction(opt.PresignCacheReuseFraction)}
+			}
+			fraction = opt.PresignCacheReuseFraction
+		}
+		st.presignCache = newPresignCache(opt.PresignCacheSize, fraction)
+	}
+	if opt.HasReadReplica {
+		st.replica = opt.ReadReplica
+	}
+	if opt.HasWriteMirrors {
+		st.mirrors = opt.WriteMirrors
+	}
+	if opt.HasPrefixRules {
+		st.prefixRules, err = st.parsePrefixRules(opt.PrefixRules)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.HasClientSideEncryption {
+		st.cse, err = newClientSideEncryption(sess, opt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.HasPolicyPreflight && opt.PolicyPreflight {
+		if err = st.preflightBucketPolicy(context.Background()); err != nil {
+			return nil, err
+		}
 	}
 	return st, nil
 }
@@ -268,10 +795,44 @@ func (s *Service) formatError(op string, err error, name string) error {
 	}
 }
 
-// getAbsPath will calculate object storage's abs path
-func (s *Storage) getAbsPath(path string) string {
+// normalizeWorkDir will make sure work dir is cleaned, starts and ends with `/`.
+func normalizeWorkDir(workDir string) string {
+	workDir = path.Clean("/" + workDir)
+	if workDir == "/" {
+		return workDir
+	}
+	return workDir + "/"
+}
+
+// getAbsPath will calculate object storage's abs path.
+//
+// The path will be cleaned, `.` and `..` elements will be resolved and duplicate `/` will be
+// removed, while the trailing `/` will be kept as it's meaningful for object keys.
+// Path which escapes the work dir will be rejected.
+func (s *Storage) getAbsPath(p string) (string, error) {
 	prefix := strings.TrimPrefix(s.workDir, "/")
-	return prefix + path
+	if p == "" {
+		return prefix, nil
+	}
+
+	cleaned := path.Clean(strings.TrimLeft(p, "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: %s escapes work dir %s", ErrPathInvalid, p, s.workDir)
+	}
+	if cleaned == "." {
+		return prefix, nil
+	}
+	if strings.HasSuffix(p, "/") {
+		cleaned += "/"
+	}
+	return prefix + cleaned, nil
+}
+
+// checkObjectPath checks the path of the object passed to operations, which may escape the work
+// dir while the object is created by Create.
+func (s *Storage) checkObjectPath(o *typ.Object) error {
+	_, err := s.getAbsPath(o.Path)
+	return err
 }
 
 // getRelPath will get object storage's rel path.
@@ -285,16 +846,24 @@ func (s *Storage) formatError(op string, err error, path ...string) error {
 		return nil
 	}
 
+	if s.compat.relaxedErrors {
+		err = formatRelaxedError(err)
+	} else {
+		err = formatError(err)
+	}
 	return services.StorageError{
 		Op:       op,
-		Err:      formatError(err),
+		Err:      err,
 		Storager: s,
 		Path:     path,
 	}
 }
 
-func (s *Storage) formatFileObject(v *s3.Object) (o *typ.Object, err error) {
-	o = s.newObject(false)
+// formatFileObject will build the object from the listing. Objects should only be marked as done
+// if the caller needs no fields other than the ones in the listing, getters of objects not done
+// will stat the object for fields like content type and user metadata.
+func (s *Storage) formatFileObject(v *s3.Object, done bool) (o *typ.Object, err error) {
+	o = s.newObject(done)
 	o.ID = *v.Key
 	o.Path = s.getRelPath(*v.Key)
 	// If you have enabled virtual link, you will not get the accurate object type.
@@ -317,6 +886,55 @@ func (s *Storage) formatFileObject(v *s3.Object) (o *typ.Object, err error) {
 	return
 }
 
+// formatGetObjectOutput will build the object from the headers returned by GetObject.
+func (s *Storage) formatGetObjectOutput(path, rp string, output *s3.GetObjectOutput) (o *typ.Object) {
+	o = s.newObject(true)
+	o.ID = rp
+	o.Path = path
+	o.Mode |= typ.ModeRead
+
+	// Content-Length is the size of the range for range reads, take the complete size
+	// from Content-Range instead.
+	size := aws.Int64Value(output.ContentLength)
+	if v := aws.StringValue(output.ContentRange); v != "" {
+		if i := strings.LastIndex(v, "/"); i >= 0 {
+			if total, err := strconv.ParseInt(v[i+1:], 10, 64); err == nil {
+				size = total
+			}
+		}
+	}
+	if s.cse != nil && isClientSideEncrypted(output.Metadata) {
+		size = parseClientSideEncryptedSize(output.Metadata, size)
+	}
+	o.SetContentLength(size)
+	o.SetLastModified(aws.TimeValue(output.LastModified))
+
+	if output.ContentType != nil {
+		o.SetContentType(*output.ContentType)
+	}
+	if output.ETag != nil {
+		o.SetEtag(*output.ETag)
+	}
+	if metadata := parseUserMetadata(output.Metadata); len(metadata) > 0 {
+		o.SetUserMetadata(metadata)
+	}
+
+	var sm ObjectSystemMetadata
+	sm.CacheControl = aws.StringValue(output.CacheControl)
+	sm.ContentDisposition = aws.StringValue(output.ContentDisposition)
+	sm.ContentEncoding = aws.StringValue(output.ContentEncoding)
+	sm.ContentLanguage = aws.StringValue(output.ContentLanguage)
+	sm.StorageClass = aws.StringValue(output.StorageClass)
+	sm.ServerSideEncryption = aws.StringValue(output.ServerSideEncryption)
+	sm.ServerSideEncryptionAwsKmsKeyID = aws.StringValue(output.SSEKMSKeyId)
+	sm.ServerSideEncryptionCustomerAlgorithm = aws.StringValue(output.SSECustomerAlgorithm)
+	sm.ServerSideEncryptionCustomerKeyMd5 = aws.StringValue(output.SSECustomerKeyMD5)
+	sm.ServerSideEncryptionBucketKeyEnabled = aws.BoolValue(output.BucketKeyEnabled)
+	o.SetSystemMetadata(sm)
+
+	return o
+}
+
 func (s *Storage) newObject(done bool) *typ.Object {
 	return typ.NewObject(s, done)
 }
@@ -338,6 +956,70 @@ func calculateEncryptionHeaders(algo string, key []byte) (algorithm, keyBase64,
 	return &algo, &kB64, &kMD5B64, nil
 }
 
+// userMetadataPrefix is the header prefix of user-defined metadata.
+const userMetadataPrefix = "x-amz-meta-"
+
+// formatUserMetadata will normalize user-defined metadata keys into lower case without the
+// `x-amz-meta-` prefix, which will be added by the SDK.
+func formatUserMetadata(m map[string]string) map[string]*string {
+	metadata := make(map[string]*string, len(m))
+	for k, v := range m {
+		k = strings.TrimPrefix(strings.ToLower(k), userMetadataPrefix)
+		metadata[k] = aws.String(v)
+	}
+	return metadata
+}
+
+// parseUserMetadata will convert the metadata returned by S3 into user metadata, metadata used
+// internally (like the link target and the envelope of client-side encryption) will be excluded.
+func parseUserMetadata(m map[string]*string) map[string]string {
+	metadata := make(map[string]string, len(m))
+	for k, v := range m {
+		if k == metadataLinkTargetHeader || k == metadataLinkTargetEtagHeader || metadataCseHeaders[k] {
+			continue
+		}
+		metadata[k] = aws.StringValue(v)
+	}
+	return metadata
+}
+
+// formatTagging will encode the tag set as URL query parameters, which is required by the
+// `x-amz-tagging` header.
+func formatTagging(m map[string]string) string {
+	tags := make(url.Values, len(m))
+	for k, v := range m {
+		tags.Set(k, v)
+	}
+	return tags.Encode()
+}
+
+// sniffLen is the max length of data used by http.DetectContentType.
+const sniffLen = 512
+
+// detectContentType will detect the content type by the path's extension, and fall back to
+// sniffing the first 512 bytes of r. The returned reader must be used instead of r.
+func detectContentType(p string, r io.Reader) (string, io.Reader, error) {
+	if ct := mime.TypeByExtension(path.Ext(p)); ct != "" {
+		return ct, r, nil
+	}
+
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	buf = buf[:n]
+	return http.DetectContentType(buf), io.MultiReader(bytes.NewReader(buf), r), nil
+}
+
+// quoteEtag will make sure the etag is quoted, as required by conditional headers.
+func quoteEtag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
 // multipartXXX are multipart upload restriction in S3, see more details at:
 // https://docs.aws.amazon.com/AmazonS3/latest/userguide/qfacts.html
 const (
@@ -356,14 +1038,24 @@ const (
 )
 
 func (s *Storage) formatGetObjectInput(path string, opt pairStorageRead) (input *s3.GetObjectInput, err error) {
-	rp := s.getAbsPath(path)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	input = &s3.GetObjectInput{
 		Bucket: aws.String(s.name),
 		Key:    aws.String(rp),
 	}
 
-	if opt.HasOffset && opt.HasSize {
+	if opt.HasSuffixSize {
+		if opt.HasOffset || opt.HasSize {
+			return nil, fmt.Errorf("suffix size conflicts with offset and size: %w", services.ErrRestrictionDissatisfied)
+		}
+		// Suffix range reads the last N bytes of the object.
+		// ref: https://www.rfc-editor.org/rfc/rfc9110#section-14.1.2
+		input.Range = aws.String(fmt.Sprintf("bytes=-%d", opt.SuffixSize))
+	} else if opt.HasOffset && opt.HasSize {
 		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", opt.Offset, opt.Offset+opt.Size-1))
 	} else if opt.HasOffset && !opt.HasSize {
 		input.Range = aws.String(fmt.Sprintf("bytes=%d-", opt.Offset))
@@ -374,6 +1066,18 @@ func (s *Storage) formatGetObjectInput(path string, opt pairStorageRead) (input
 	if opt.HasResponseContentDisposition {
 		input.ResponseContentDisposition = &opt.ResponseContentDisposition
 	}
+	if opt.HasIfMatch {
+		input.IfMatch = &opt.IfMatch
+	}
+	if opt.HasIfNoneMatch {
+		input.IfNoneMatch = &opt.IfNoneMatch
+	}
+	if opt.HasIfModifiedSince {
+		input.IfModifiedSince = &opt.IfModifiedSince
+	}
+	if opt.HasIfUnmodifiedSince {
+		input.IfUnmodifiedSince = &opt.IfUnmodifiedSince
+	}
 
 	if opt.HasExceptedBucketOwner {
 		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
@@ -389,7 +1093,10 @@ func (s *Storage) formatGetObjectInput(path string, opt pairStorageRead) (input
 }
 
 func (s *Storage) formatPutObjectInput(path string, size int64, opt pairStorageWrite) (input *s3.PutObjectInput, err error) {
-	rp := s.getAbsPath(path)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	input = &s3.PutObjectInput{
 		Bucket:        aws.String(s.name),
@@ -409,6 +1116,127 @@ func (s *Storage) formatPutObjectInput(path string, size int64, opt pairStorageW
 	if opt.HasContentEncoding {
 		input.ContentEncoding = &opt.ContentEncoding
 	}
+	if opt.HasContentDisposition {
+		input.ContentDisposition = &opt.ContentDisposition
+	}
+	if opt.HasContentLanguage {
+		input.ContentLanguage = &opt.ContentLanguage
+	}
+	if opt.HasStorageClass {
+		input.StorageClass = &opt.StorageClass
+	}
+	if opt.HasExceptedBucketOwner {
+		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
+	}
+	if opt.HasServerSideEncryptionBucketKeyEnabled {
+		input.BucketKeyEnabled = &opt.ServerSideEncryptionBucketKeyEnabled
+	}
+	if opt.HasServerSideEncryptionCustomerAlgorithm {
+		input.SSECustomerAlgorithm, input.SSECustomerKey, input.SSECustomerKeyMD5, err = calculateEncryptionHeaders(opt.ServerSideEncryptionCustomerAlgorithm, opt.ServerSideEncryptionCustomerKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.HasServerSideEncryptionAwsKmsKeyID {
+		input.SSEKMSKeyId, err = s.formatKmsKeyID(opt.ServerSideEncryptionAwsKmsKeyID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opt.HasServerSideEncryptionContext {
+		encodedKMSEncryptionContext := base64.StdEncoding.EncodeToString([]byte(opt.ServerSideEncryptionContext))
+		input.SSEKMSEncryptionContext = &encodedKMSEncryptionContext
+	}
+	if opt.HasServerSideEncryption {
+		input.ServerSideEncryption = &opt.ServerSideEncryption
+	}
+	if opt.HasUserMetadata {
+		input.Metadata = formatUserMetadata(opt.UserMetadata)
+	}
+	if opt.HasTagging {
+		if s.taggingDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithTagging(opt.Tagging)}
+		}
+		input.Tagging = aws.String(formatTagging(opt.Tagging))
+	}
+	if opt.HasGrantFullControl {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantFullControl(opt.GrantFullControl)}
+		}
+		input.GrantFullControl = &opt.GrantFullControl
+	}
+	if opt.HasGrantRead {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantRead(opt.GrantRead)}
+		}
+		input.GrantRead = &opt.GrantRead
+	}
+	if opt.HasGrantReadAcp {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantReadAcp(opt.GrantReadAcp)}
+		}
+		input.GrantReadACP = &opt.GrantReadAcp
+	}
+	if opt.HasGrantWriteAcp {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantWriteAcp(opt.GrantWriteAcp)}
+		}
+		input.GrantWriteACP = &opt.GrantWriteAcp
+	}
+
+	return
+}
+
+func (s *Storage) formatCopyObjectInput(src, dst string, opt pairStorageCopy) (input *s3.CopyObjectInput, err error) {
+	rs, err := s.getAbsPath(src)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := s.getAbsPath(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	input = &s3.CopyObjectInput{
+		Bucket: aws.String(s.name),
+		// The copy source must be URL-encoded.
+		CopySource: aws.String((&url.URL{Path: s.name + "/" + rs}).EscapedPath()),
+		Key:        aws.String(rd),
+	}
+
+	if opt.HasMetadataDirective {
+		input.MetadataDirective = &opt.MetadataDirective
+	}
+	if opt.HasTaggingDirective {
+		if s.taggingDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithTaggingDirective(opt.TaggingDirective)}
+		}
+		input.TaggingDirective = &opt.TaggingDirective
+	}
+	if opt.HasContentType {
+		input.ContentType = &opt.ContentType
+	}
+	if opt.HasCacheControl {
+		input.CacheControl = &opt.CacheControl
+	}
+	if opt.HasContentEncoding {
+		input.ContentEncoding = &opt.ContentEncoding
+	}
+	if opt.HasContentDisposition {
+		input.ContentDisposition = &opt.ContentDisposition
+	}
+	if opt.HasContentLanguage {
+		input.ContentLanguage = &opt.ContentLanguage
+	}
+	if opt.HasUserMetadata {
+		input.Metadata = formatUserMetadata(opt.UserMetadata)
+	}
+	if opt.HasTagging {
+		if s.taggingDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithTagging(opt.Tagging)}
+		}
+		input.Tagging = aws.String(formatTagging(opt.Tagging))
+	}
 	if opt.HasStorageClass {
 		input.StorageClass = &opt.StorageClass
 	}
@@ -424,8 +1252,17 @@ func (s *Storage) formatPutObjectInput(path string, size int64, opt pairStorageW
 			return nil, err
 		}
 	}
+	if opt.HasCopySourceServerSideEncryptionCustomerAlgorithm {
+		input.CopySourceSSECustomerAlgorithm, input.CopySourceSSECustomerKey, input.CopySourceSSECustomerKeyMD5, err = calculateEncryptionHeaders(opt.CopySourceServerSideEncryptionCustomerAlgorithm, opt.CopySourceServerSideEncryptionCustomerKey)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if opt.HasServerSideEncryptionAwsKmsKeyID {
-		input.SSEKMSKeyId = &opt.ServerSideEncryptionAwsKmsKeyID
+		input.SSEKMSKeyId, err = s.formatKmsKeyID(opt.ServerSideEncryptionAwsKmsKeyID)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if opt.HasServerSideEncryptionContext {
 		encodedKMSEncryptionContext := base64.StdEncoding.EncodeToString([]byte(opt.ServerSideEncryptionContext))
@@ -434,12 +1271,39 @@ func (s *Storage) formatPutObjectInput(path string, size int64, opt pairStorageW
 	if opt.HasServerSideEncryption {
 		input.ServerSideEncryption = &opt.ServerSideEncryption
 	}
+	if opt.HasGrantFullControl {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantFullControl(opt.GrantFullControl)}
+		}
+		input.GrantFullControl = &opt.GrantFullControl
+	}
+	if opt.HasGrantRead {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantRead(opt.GrantRead)}
+		}
+		input.GrantRead = &opt.GrantRead
+	}
+	if opt.HasGrantReadAcp {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantReadAcp(opt.GrantReadAcp)}
+		}
+		input.GrantReadACP = &opt.GrantReadAcp
+	}
+	if opt.HasGrantWriteAcp {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantWriteAcp(opt.GrantWriteAcp)}
+		}
+		input.GrantWriteACP = &opt.GrantWriteAcp
+	}
 
 	return
 }
 
-func (s *Storage) formatAbortMultipartUploadInput(path string, opt pairStorageDelete) (input *s3.AbortMultipartUploadInput) {
-	rp := s.getAbsPath(path)
+func (s *Storage) formatAbortMultipartUploadInput(path string, opt pairStorageDelete) (input *s3.AbortMultipartUploadInput, err error) {
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	input = &s3.AbortMultipartUploadInput{
 		Bucket:   aws.String(s.name),
@@ -454,8 +1318,36 @@ func (s *Storage) formatAbortMultipartUploadInput(path string, opt pairStorageDe
 	return
 }
 
+// multipartAbortTimeout is the timeout to abort the multipart upload whose context is canceled.
+const multipartAbortTimeout = 30 * time.Second
+
+// abortCanceledMultipart will abort the multipart upload with a new context, as the context of
+// the upload has been canceled. Errors are ignored, as the upload may have been aborted.
+func (s *Storage) abortCanceledMultipart(o *typ.Object, expectedBucketOwner string) {
+	ctx, cancel := context.WithTimeout(context.Background(), multipartAbortTimeout)
+	defer cancel()
+
+	input := &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.name),
+		Key:      aws.String(o.ID),
+		UploadId: aws.String(o.MustGetMultipartID()),
+	}
+	if expectedBucketOwner != "" {
+		input.ExpectedBucketOwner = &expectedBucketOwner
+	}
+	if _, err := s.service.AbortMultipartUploadWithContext(ctx, input); err != nil {
+		return
+	}
+	if s.cse != nil {
+		s.cse.removeMultipart(o.MustGetMultipartID())
+	}
+}
+
 func (s *Storage) formatDeleteObjectInput(path string, opt pairStorageDelete) (input *s3.DeleteObjectInput, err error) {
-	rp := s.getAbsPath(path)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	if opt.HasObjectMode && opt.ObjectMode.IsDir() {
 		if !s.features.VirtualDir {
@@ -463,7 +1355,7 @@ func (s *Storage) formatDeleteObjectInput(path string, opt pairStorageDelete) (i
 			return nil, err
 		}
 
-		rp += "/"
+		rp = s.formatDirMarker(rp)
 	}
 
 	input = &s3.DeleteObjectInput{
@@ -479,7 +1371,10 @@ func (s *Storage) formatDeleteObjectInput(path string, opt pairStorageDelete) (i
 }
 
 func (s *Storage) formatCreateMultipartUploadInput(path string, opt pairStorageCreateMultipart) (input *s3.CreateMultipartUploadInput, err error) {
-	rp := s.getAbsPath(path)
+	rp, err := s.getAbsPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	input = &s3.CreateMultipartUploadInput{
 		Bucket: aws.String(s.name),
@@ -499,7 +1394,10 @@ func (s *Storage) formatCreateMultipartUploadInput(path string, opt pairStorageC
 		}
 	}
 	if opt.HasServerSideEncryptionAwsKmsKeyID {
-		input.SSEKMSKeyId = &opt.ServerSideEncryptionAwsKmsKeyID
+		input.SSEKMSKeyId, err = s.formatKmsKeyID(opt.ServerSideEncryptionAwsKmsKeyID)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if opt.HasServerSideEncryptionContext {
 		encodedKMSEncryptionContext := base64.StdEncoding.EncodeToString([]byte(opt.ServerSideEncryptionContext))
@@ -511,6 +1409,49 @@ func (s *Storage) formatCreateMultipartUploadInput(path string, opt pairStorageC
 	if opt.HasContentType {
 		input.ContentType = &opt.ContentType
 	}
+	if opt.HasCacheControl {
+		input.CacheControl = &opt.CacheControl
+	}
+	if opt.HasContentEncoding {
+		input.ContentEncoding = &opt.ContentEncoding
+	}
+	if opt.HasContentDisposition {
+		input.ContentDisposition = &opt.ContentDisposition
+	}
+	if opt.HasContentLanguage {
+		input.ContentLanguage = &opt.ContentLanguage
+	}
+	if opt.HasStorageClass {
+		input.StorageClass = &opt.StorageClass
+	}
+	if opt.HasUserMetadata {
+		input.Metadata = formatUserMetadata(opt.UserMetadata)
+	}
+	if opt.HasGrantFullControl {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantFullControl(opt.GrantFullControl)}
+		}
+		input.GrantFullControl = &opt.GrantFullControl
+	}
+	if opt.HasGrantRead {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantRead(opt.GrantRead)}
+		}
+		input.GrantRead = &opt.GrantRead
+	}
+	if opt.HasGrantReadAcp {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantReadAcp(opt.GrantReadAcp)}
+		}
+		input.GrantReadACP = &opt.GrantReadAcp
+	}
+	if opt.HasGrantWriteAcp {
+		if s.aclDisabled {
+			return nil, services.PairUnsupportedError{Pair: WithGrantWriteAcp(opt.GrantWriteAcp)}
+		}
+		input.GrantWriteACP = &opt.GrantWriteAcp
+	}
+
 	return
 }
 
@@ -550,6 +1491,9 @@ func (s *Storage) formatUploadPartInput(o *typ.Object, size int64, index int, op
 		UploadId:      aws.String(o.MustGetMultipartID()),
 		ContentLength: &size,
 	}
+	if opt.HasContentMd5 {
+		input.ContentMD5 = &opt.ContentMd5
+	}
 	if opt.HasExceptedBucketOwner {
 		input.ExpectedBucketOwner = &opt.ExceptedBucketOwner
 	}
@@ -0,0 +1,1082 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	"github.com/minhjh/go-storage/v4/pkg/httpclient"
+	"github.com/minhjh/go-storage/v4/services"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+func TestNormalizeWorkDir(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"abc", "/abc/"},
+		{"/abc", "/abc/"},
+		{"/abc/", "/abc/"},
+		{"/abc//def/./", "/abc/def/"},
+		{"/abc/../def", "/def/"},
+		{"/../abc", "/abc/"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := normalizeWorkDir(tt.input); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetAbsPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		workDir  string
+		path     string
+		expected string
+		err      error
+	}{
+		{"empty path", "/abc/", "", "abc/", nil},
+		{"root work dir", "/", "def", "def", nil},
+		{"simple path", "/abc/", "def", "abc/def", nil},
+		{"leading slash", "/abc/", "/def", "abc/def", nil},
+		{"trailing slash", "/abc/", "def/", "abc/def/", nil},
+		{"current dir", "/abc/", ".", "abc/", nil},
+		{"dot segments", "/abc/", "./def/./ghi", "abc/def/ghi", nil},
+		{"duplicate slashes", "/abc/", "def//ghi", "abc/def/ghi", nil},
+		{"parent inside work dir", "/abc/", "def/../ghi", "abc/ghi", nil},
+		{"escape work dir", "/abc/", "../def", "", ErrPathInvalid},
+		{"escape root", "/", "..", "", ErrPathInvalid},
+		{"escape after descend", "/abc/", "def/../../ghi", "", ErrPathInvalid},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Storage{workDir: tt.workDir}
+
+			got, err := s.getAbsPath(tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatUserMetadata(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"key", "key"},
+		{"Content-Owner", "content-owner"},
+		{"x-amz-meta-key", "key"},
+		{"X-Amz-Meta-Key", "key"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			m := formatUserMetadata(map[string]string{tt.input: "value"})
+			v, ok := m[tt.expected]
+			if !ok || *v != "value" {
+				t.Errorf("expected key %q in %v", tt.expected, m)
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{"extension", "abc.json", "{}", "application/json"},
+		{"sniff html", "abc", "<html><body></body></html>", "text/html; charset=utf-8"},
+		{"sniff binary", "abc", "\x00\x01\x02", "application/octet-stream"},
+		{"empty", "abc", "", "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			ct, r, err := detectContentType(tt.path, strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("detect content type: %v", err)
+			}
+			if ct != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, ct)
+			}
+
+			content, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if string(content) != tt.content {
+				t.Errorf("expected content %q, got %q", tt.content, content)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	content := "Hello, World!"
+
+	buf, err := compress(ContentEncodingGzip, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("compress: %v", err)
+	}
+
+	rc, err := newDecompressReader(ContentEncodingGzip, buf)
+	if err != nil {
+		t.Fatalf("new decompress reader: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("expected %q, got %q", content, got)
+	}
+
+	if _, err = compress("zstd", strings.NewReader(content)); !errors.Is(err, ErrContentEncodingUnsupported) {
+		t.Errorf("expected %v, got %v", ErrContentEncodingUnsupported, err)
+	}
+}
+
+func TestGCMChunkStream(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	iv := bytes.Repeat([]byte{2}, gcmNonceSize)
+
+	for _, size := range []int{0, 1, gcmChunkSize - 1, gcmChunkSize, gcmChunkSize + 1, 3*gcmChunkSize + 5} {
+		content := bytes.Repeat([]byte("a"), size)
+
+		er, err := newGCMEncryptReader(bytes.NewReader(content), cseCekAlgAESGCMStream, key, iv)
+		if err != nil {
+			t.Fatalf("new encrypt reader: %v", err)
+		}
+		sealed, err := ioutil.ReadAll(er)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		if int64(len(sealed)) != gcmSealedSize(int64(size)) {
+			t.Errorf("size %d: expected sealed size %d, got %d", size, gcmSealedSize(int64(size)), len(sealed))
+		}
+		if n, ok := gcmOpenedSize(int64(len(sealed))); !ok || n != int64(size) {
+			t.Errorf("size %d: expected opened size %d, got %d", size, size, n)
+		}
+
+		dr, err := newGCMDecryptReader(bytes.NewReader(sealed), key, iv)
+		if err != nil {
+			t.Fatalf("new decrypt reader: %v", err)
+		}
+		got, err := ioutil.ReadAll(dr)
+		if err != nil {
+			t.Fatalf("decrypt: %v", err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("size %d: plaintext doesn't match", size)
+		}
+
+		// The stream must be ended by the last chunk.
+		if size >= gcmChunkSize {
+			dr, err = newGCMDecryptReader(bytes.NewReader(sealed[:gcmChunkSize+gcmTagSize]), key, iv)
+			if err != nil {
+				t.Fatalf("new decrypt reader: %v", err)
+			}
+			if _, err = ioutil.ReadAll(dr); !errors.Is(err, ErrObjectDecryptionFailed) {
+				t.Errorf("size %d: expected %v for truncated content, got %v", size, ErrObjectDecryptionFailed, err)
+			}
+		}
+
+		sealed[len(sealed)-1] ^= 1
+		dr, err = newGCMDecryptReader(bytes.NewReader(sealed), key, iv)
+		if err != nil {
+			t.Fatalf("new decrypt reader: %v", err)
+		}
+		got, err = ioutil.ReadAll(dr)
+		if !errors.Is(err, ErrObjectDecryptionFailed) {
+			t.Errorf("size %d: expected %v, got %v", size, ErrObjectDecryptionFailed, err)
+		}
+		// Only chunks authenticated are released.
+		if expected := size / gcmChunkSize * gcmChunkSize; len(got) != expected {
+			t.Errorf("size %d: expected %d bytes released, got %d", size, expected, len(got))
+		}
+	}
+}
+
+func TestGCMMessage(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	iv := bytes.Repeat([]byte{2}, gcmNonceSize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("new gcm: %v", err)
+	}
+
+	for _, size := range []int{0, 1, 15, 16, 17, gcmChunkSize - 1, gcmChunkSize, 3*gcmChunkSize + 5} {
+		content := make([]byte, size)
+		for i := range content {
+			content[i] = byte(i)
+		}
+		expected := aead.Seal(nil, iv, content, nil)
+
+		// The content is read in chunks, which are not aligned with blocks.
+		er, err := newGCMEncryptReader(iotest.OneByteReader(bytes.NewReader(content)), cseCekAlgAESGCM, key, iv)
+		if err != nil {
+			t.Fatalf("new encrypt reader: %v", err)
+		}
+		sealed, err := ioutil.ReadAll(er)
+		if err != nil {
+			t.Fatalf("encrypt: %v", err)
+		}
+		if !bytes.Equal(sealed, expected) {
+			t.Errorf("size %d: sealed content doesn't match cipher.AEAD", size)
+		}
+		if int64(len(sealed)) != cseSealedSize(cseCekAlgAESGCM, int64(size)) {
+			t.Errorf("size %d: expected sealed size %d, got %d", size, cseSealedSize(cseCekAlgAESGCM, int64(size)), len(sealed))
+		}
+
+		dr, err := newGCMMessageDecryptReader(iotest.HalfReader(bytes.NewReader(sealed)), key, iv)
+		if err != nil {
+			t.Fatalf("new decrypt reader: %v", err)
+		}
+		got, err := ioutil.ReadAll(dr)
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Errorf("size %d: plaintext doesn't match", size)
+		}
+
+		sealed[len(sealed)-1] ^= 1
+		dr, err = newGCMMessageDecryptReader(bytes.NewReader(sealed), key, iv)
+		if err != nil {
+			t.Fatalf("new decrypt reader: %v", err)
+		}
+		if _, err = ioutil.ReadAll(dr); !errors.Is(err, ErrObjectDecryptionFailed) {
+			t.Errorf("size %d: expected %v, got %v", size, ErrObjectDecryptionFailed, err)
+		}
+	}
+}
+
+func TestClientSideDecryptReaderMessage(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	iv := bytes.Repeat([]byte{2}, gcmNonceSize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("new gcm: %v", err)
+	}
+
+	content := []byte("hello, world")
+	sealed := aead.Seal(nil, iv, content, nil)
+	r, err := newClientSideDecryptReader(bytes.NewReader(sealed), cseCekAlgAESGCM, key, iv)
+	if err != nil {
+		t.Fatalf("new decrypt reader: %v", err)
+	}
+	if got, _ := ioutil.ReadAll(r); !bytes.Equal(got, content) {
+		t.Errorf("expected %q, got %q", content, got)
+	}
+
+	// Content not larger than cseBufferedSizeMaximum is authenticated before released.
+	sealed[0] ^= 1
+	if _, err = newClientSideDecryptReader(bytes.NewReader(sealed), cseCekAlgAESGCM, key, iv); !errors.Is(err, ErrObjectDecryptionFailed) {
+		t.Errorf("expected %v, got %v", ErrObjectDecryptionFailed, err)
+	}
+}
+
+func TestParseRestore(t *testing.T) {
+	cases := []struct {
+		input   string
+		ongoing bool
+		expiry  time.Time
+	}{
+		{`ongoing-request="true"`, true, time.Time{}},
+		{`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`, false, time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			ongoing, expiry := parseRestore(tt.input)
+			if ongoing != tt.ongoing {
+				t.Errorf("expected ongoing %v, got %v", tt.ongoing, ongoing)
+			}
+			if !expiry.Equal(tt.expiry) {
+				t.Errorf("expected expiry %v, got %v", tt.expiry, expiry)
+			}
+		})
+	}
+}
+
+func TestIsObjectLambdaAccessPoint(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected bool
+	}{
+		{"bucket-name", false},
+		{"arn:aws:s3:us-east-1:123456789012:accesspoint/test", false},
+		{"arn:aws:s3-object-lambda:us-east-1:123456789012:accesspoint/test", true},
+		{"my-olap-1a4n8yjrb3kda96f67zwrwiiuse1a--ol-s3", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isObjectLambdaAccessPoint(tt.name); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatRelaxedError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"known code", awserr.NewRequestFailure(awserr.New("NoSuchKey", "", nil), 404, ""), services.ErrObjectNotExist},
+		{"unknown code not found", awserr.NewRequestFailure(awserr.New("XMinioNotFound", "", nil), 404, ""), services.ErrObjectNotExist},
+		{"unknown code forbidden", awserr.NewRequestFailure(awserr.New("XAccessDenied", "", nil), 403, ""), services.ErrPermissionDenied},
+		{"unknown code precondition", awserr.NewRequestFailure(awserr.New("XPrecondition", "", nil), 412, ""), ErrPreconditionFailed},
+		{"unknown status", awserr.NewRequestFailure(awserr.New("XInternal", "", nil), 500, ""), services.ErrUnexpected},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatRelaxedError(tt.err); !errors.Is(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseKmsKeyID(t *testing.T) {
+	cases := []struct {
+		id       string
+		expected string
+		region   string
+		ok       bool
+	}{
+		{" 1234abcd-12ab-34cd-56ef-1234567890ab ", "1234abcd-12ab-34cd-56ef-1234567890ab", "", true},
+		{"alias/test", "alias/test", "", true},
+		{"alias/", "", "", false},
+		{"arn:aws:kms:us-east-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab", "arn:aws:kms:us-east-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab", "us-east-2", true},
+		{"arn:aws:kms:us-east-2:111122223333:alias/test", "arn:aws:kms:us-east-2:111122223333:alias/test", "us-east-2", true},
+		{"arn:aws:s3:us-east-2:111122223333:key/test", "", "", false},
+		{"arn:aws:kms:us-east-2:111122223333:grant/test", "", "", false},
+		{"key/test", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.id, func(t *testing.T) {
+			keyID, a, ok := parseKmsKeyID(tt.id)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if keyID != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, keyID)
+			}
+			region := ""
+			if a != nil {
+				region = a.Region
+			}
+			if region != tt.region {
+				t.Errorf("expected region %q, got %q", tt.region, region)
+			}
+		})
+	}
+}
+
+func TestFormatKmsError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"forwarded by s3", awserr.NewRequestFailure(awserr.New("KMS.DisabledException", "", nil), 400, ""), ErrKmsRequestFailed},
+		{"access denied forwarded by s3", awserr.NewRequestFailure(awserr.New("KMS.AccessDeniedException", "", nil), 403, ""), ErrKmsAccessDenied},
+		{"access denied by kms", formatKmsError(awserr.NewRequestFailure(awserr.New("AccessDeniedException", "", nil), 400, "")), ErrKmsAccessDenied},
+		{"returned by kms", formatKmsError(awserr.NewRequestFailure(awserr.New("NotFoundException", "", nil), 400, "")), ErrKmsRequestFailed},
+		{"access denied by s3", awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), 403, ""), services.ErrPermissionDenied},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatError(tt.err); !errors.Is(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDetectCompatibilityMode(t *testing.T) {
+	cases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://s3.amazonaws.com:443", CompatibilityModeAWS},
+		{"https://abc.r2.cloudflarestorage.com:443", CompatibilityModeR2},
+		{"https://s3.us-west-004.backblazeb2.com:443", CompatibilityModeB2},
+		{"http://127.0.0.1:9000", CompatibilityModeAWS},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := detectCompatibilityMode(tt.url); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPartitionOf(t *testing.T) {
+	cases := []struct {
+		region   string
+		expected string
+	}{
+		{"us-east-1", "aws"},
+		{"cn-north-1", "aws-cn"},
+		{"us-gov-west-1", "aws-us-gov"},
+		{"unknown", "aws"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.region, func(t *testing.T) {
+			if got := partitionOf(tt.region); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatPutObjectInputCapabilities(t *testing.T) {
+	cases := []struct {
+		name        string
+		storage     *Storage
+		pairs       []typ.Pair
+		unsupported bool
+	}{
+		{"tagging", &Storage{}, []typ.Pair{WithTagging(map[string]string{"a": "b"})}, false},
+		{"tagging disabled", &Storage{taggingDisabled: true}, []typ.Pair{WithTagging(map[string]string{"a": "b"})}, true},
+		{"acl", &Storage{}, []typ.Pair{WithGrantRead("id=abc")}, false},
+		{"acl disabled", &Storage{aclDisabled: true}, []typ.Pair{WithGrantRead("id=abc")}, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.storage
+			s.workDir = "/"
+
+			opt, err := s.parsePairStorageWrite(tt.pairs)
+			if err != nil {
+				t.Fatalf("parse pairs: %v", err)
+			}
+			_, err = s.formatPutObjectInput("abc", 0, opt)
+			if got := errors.As(err, &services.PairUnsupportedError{}); got != tt.unsupported {
+				t.Errorf("expected unsupported %v, got %v", tt.unsupported, err)
+			}
+		})
+	}
+}
+
+func TestApplyPrefixRules(t *testing.T) {
+	s := &Storage{workDir: "/"}
+	rules, err := s.parsePrefixRules([]PrefixRule{
+		{Prefix: "logs/", Pairs: []typ.Pair{WithStorageClass(StorageClassStandardIa)}},
+		{Prefix: "logs/secure/", RequiredPairs: []typ.Pair{WithServerSideEncryption(ServerSideEncryptionAwsKms)}},
+	})
+	if err != nil {
+		t.Fatalf("parse prefix rules: %v", err)
+	}
+	s.prefixRules = rules
+
+	cases := []struct {
+		name         string
+		path         string
+		pairs        []typ.Pair
+		defaults     []typ.Pair
+		storageClass string
+		sse          string
+	}{
+		{"no match", "data/a", nil, []typ.Pair{WithStorageClass(StorageClassStandard)}, StorageClassStandard, ""},
+		{"over defaults", "logs/a", nil, []typ.Pair{WithStorageClass(StorageClassStandard)}, StorageClassStandardIa, ""},
+		{"pairs passed in", "logs/a", []typ.Pair{WithStorageClass(StorageClassGlacier)}, nil, StorageClassGlacier, ""},
+		{"required", "logs/secure/a", []typ.Pair{WithServerSideEncryption(ServerSideEncryptionAes256)}, nil, StorageClassStandardIa, ServerSideEncryptionAwsKms},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			pairs, err := s.applyPrefixRules(tt.path, append(tt.pairs, tt.defaults...), tt.defaults, false)
+			if err != nil {
+				t.Fatalf("apply prefix rules: %v", err)
+			}
+			opt, err := s.parsePairStorageWrite(pairs)
+			if err != nil {
+				t.Fatalf("parse pairs: %v", err)
+			}
+			if opt.StorageClass != tt.storageClass {
+				t.Errorf("expected storage class %q, got %q", tt.storageClass, opt.StorageClass)
+			}
+			if opt.ServerSideEncryption != tt.sse {
+				t.Errorf("expected server-side encryption %q, got %q", tt.sse, opt.ServerSideEncryption)
+			}
+		})
+	}
+
+	if _, err = s.parsePrefixRules([]PrefixRule{{Prefix: "a/", Pairs: []typ.Pair{WithStatFast()}}}); err == nil {
+		t.Errorf("expected pairs unsupported by writes to be rejected")
+	}
+}
+
+func TestOperationPolicyAllowed(t *testing.T) {
+	cases := []struct {
+		name      string
+		policy    OperationPolicy
+		operation string
+		expected  bool
+	}{
+		{"empty", OperationPolicy{}, "DeleteObject", true},
+		{"read only get", OperationPolicy{ReadOnly: true}, "GetObject", true},
+		{"read only list", OperationPolicy{ReadOnly: true}, "ListObjectsV2", true},
+		{"read only put", OperationPolicy{ReadOnly: true}, "PutObject", false},
+		{"read only delete", OperationPolicy{ReadOnly: true}, "DeleteObjects", false},
+		{"deny", OperationPolicy{Deny: []string{"DeleteObject"}}, "DeleteObject", false},
+		{"deny wins", OperationPolicy{Allow: []string{"DeleteObject"}, Deny: []string{"DeleteObject"}}, "DeleteObject", false},
+		{"allow", OperationPolicy{Allow: []string{"GetObject"}}, "GetObject", true},
+		{"not allowed", OperationPolicy{Allow: []string{"GetObject"}}, "HeadObject", false},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Allowed(tt.operation); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"presigned url",
+			`Put "https://bucket.s3.amazonaws.com/abc?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKID%2F20210101%2Fus-east-1%2Fs3%2Faws4_request&X-Amz-Signature=abcdef": EOF`,
+			`Put "https://bucket.s3.amazonaws.com/abc?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=REDACTED&X-Amz-Signature=REDACTED": EOF`,
+		},
+		{
+			"sigv2 url",
+			"https://bucket.s3.amazonaws.com/abc?AWSAccessKeyId=AKID&Expires=1&Signature=abc%2B",
+			"https://bucket.s3.amazonaws.com/abc?AWSAccessKeyId=REDACTED&Expires=1&Signature=REDACTED",
+		},
+		{
+			"headers",
+			"Authorization: AWS4-HMAC-SHA256 Credential=AKID/20210101, Signature=abc\r\nX-Amz-Server-Side-Encryption-Customer-Key: c2VjcmV0\r\nX-Amz-Server-Side-Encryption-Customer-Key-Md5: bWQ1\r\n",
+			"Authorization: REDACTED\r\nX-Amz-Server-Side-Encryption-Customer-Key: REDACTED\r\nX-Amz-Server-Side-Encryption-Customer-Key-Md5: bWQ1\r\n",
+		},
+		{
+			"sdk input",
+			`{ Bucket: "test", SSECustomerKey: "secret", SSECustomerKeyMD5: "bWQ1" }`,
+			`{ Bucket: "test", SSECustomerKey: "REDACTED", SSECustomerKeyMD5: "bWQ1" }`,
+		},
+		{"nothing", "NoSuchKey: The specified key does not exist.", "NoSuchKey: The specified key does not exist."},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactString(tt.input); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedactLogger(t *testing.T) {
+	var got string
+	l := redactLogger{logger: aws.LoggerFunc(func(args ...interface{}) {
+		got = fmt.Sprint(args...)
+	})}
+
+	l.Log("DEBUG: Request s3/PutObject Details:\n---[ REQUEST POST-SIGN ]-----------------------------\n" +
+		"Authorization: AWS4-HMAC-SHA256 Credential=AKID/20210101, Signature=abc\r\n" +
+		"X-Amz-Server-Side-Encryption-Customer-Key: c2VjcmV0\r\n")
+	for _, v := range []string{"AKID", "c2VjcmV0"} {
+		if strings.Contains(got, v) {
+			t.Errorf("secret %s leaked: %s", v, got)
+		}
+	}
+	if !strings.Contains(got, "PutObject") {
+		t.Errorf("expected message kept, got %s", got)
+	}
+
+	srv, err := newServicer(ps.WithCredential("hmac:a:b"), ps.WithEndpoint("http:127.0.0.1:9000"))
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	if _, ok := srv.sess.Config.Logger.(redactLogger); !ok {
+		t.Errorf("expected the logger of the service redacted, got %T", srv.sess.Config.Logger)
+	}
+}
+
+func TestFormatErrorRedacted(t *testing.T) {
+	orig := awserr.New("RequestError", "send request failed",
+		errors.New(`Get "https://bucket.s3.amazonaws.com/abc?X-Amz-Signature=abcdef": EOF`))
+
+	err := formatError(orig)
+	if strings.Contains(err.Error(), "abcdef") {
+		t.Errorf("signature leaked: %v", err)
+	}
+	if !errors.Is(err, services.ErrUnexpected) {
+		t.Errorf("expected %v, got %v", services.ErrUnexpected, err)
+	}
+}
+
+func TestRedactPairs(t *testing.T) {
+	pairs := []typ.Pair{
+		{Key: "credential", Value: "hmac:ak:sk"},
+		{Key: "server_side_encryption_customer_key", Value: []byte("secret")},
+		{Key: "default_storage_pairs", Value: DefaultStoragePairs{
+			Write: []typ.Pair{WithServerSideEncryptionCustomerKey([]byte("secret"))},
+		}},
+		{Key: "name", Value: "test"},
+		{Key: "default_service_pairs", Value: DefaultServicePairs{
+			Create: []typ.Pair{{Key: "credential", Value: "hmac:ak:sk"}},
+		}},
+	}
+
+	got := redactPairs(pairs)
+	if v := got[0].Value; v != "hmac:"+redacted {
+		t.Errorf("expected credential redacted, got %v", v)
+	}
+	if v := got[1].Value; v != redacted {
+		t.Errorf("expected customer key redacted, got %v", v)
+	}
+	if v := got[2].Value.(DefaultStoragePairs).Write[0].Value; v != redacted {
+		t.Errorf("expected default customer key redacted, got %v", v)
+	}
+	if v := got[3].Value; v != "test" {
+		t.Errorf("expected name kept, got %v", v)
+	}
+	if v := got[4].Value.(DefaultServicePairs).Create[0].Value; v != "hmac:"+redacted {
+		t.Errorf("expected default credential redacted, got %v", v)
+	}
+	// Pairs passed in must not be modified.
+	if v := pairs[0].Value; v != "hmac:ak:sk" {
+		t.Errorf("expected pairs kept, got %v", v)
+	}
+}
+
+func TestFilterObjectPages(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2021, 1, d, 12, 0, 0, 0, time.UTC)
+	}
+	newObject := func(key string, modified time.Time) *typ.Object {
+		o := typ.NewObject(nil, true)
+		o.ID = key
+		o.Path = key
+		o.SetLastModified(modified)
+		return o
+	}
+
+	// The second page has no matched object, which must not stop the listing.
+	pages := [][]*typ.Object{
+		{newObject("logs/2021/01/01/a", day(1)), newObject("logs/2021/01/02/a", day(2))},
+		{newObject("logs/2021/01/02/b", day(1))},
+		{newObject("logs/2021/01/03/a", day(3)), newObject("logs/2021/01/04/a", day(4))},
+		{newObject("logs/2021/01/05/a", day(5))},
+	}
+	fetched := 0
+	next := func(ctx context.Context, page *typ.ObjectPage) error {
+		page.Data = append(page.Data, pages[fetched]...)
+		fetched++
+		if fetched == len(pages) {
+			return typ.IterateDone
+		}
+		return nil
+	}
+
+	f, startAfter := newObjectFilter("logs/", pairStorageList{
+		HasModifiedAfter:  true,
+		ModifiedAfter:     day(1),
+		HasModifiedBefore: true,
+		ModifiedBefore:    day(3).Add(time.Hour),
+		HasKeyTimeLayout:  true,
+		KeyTimeLayout:     "2006/01/02/",
+	})
+	if startAfter != "logs/2021/01/01/" {
+		t.Errorf("expected start after %q, got %q", "logs/2021/01/01/", startAfter)
+	}
+
+	fn := f.filterObjectPages(next, true)
+	var keys []string
+	for {
+		page := &typ.ObjectPage{}
+		err := fn(context.Background(), page)
+		for _, o := range page.Data {
+			keys = append(keys, o.ID)
+		}
+		if err == typ.IterateDone {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+	}
+
+	if got := strings.Join(keys, ","); got != "logs/2021/01/02/a,logs/2021/01/03/a" {
+		t.Errorf("unexpected keys: %s", got)
+	}
+	// The listing stops at logs/2021/01/04/a without fetching the last page.
+	if fetched != 3 {
+		t.Errorf("expected 3 pages fetched, got %d", fetched)
+	}
+}
+
+func TestEndpointPool(t *testing.T) {
+	if _, err := parseFailoverEndpoints("", []string{"http:127.0.0.1:9000"}); !errors.As(err, &services.PairUnsupportedError{}) {
+		t.Errorf("expected pair unsupported without the primary endpoint, got %v", err)
+	}
+
+	p, err := newEndpointPool("https://a.example.com", []string{"http://b.example.com:9000"})
+	if err != nil {
+		t.Fatalf("new endpoint pool: %v", err)
+	}
+
+	cases := []struct {
+		host   string
+		idx    int
+		prefix string
+		ok     bool
+	}{
+		{"a.example.com", 0, "", true},
+		{"bucket.a.example.com", 0, "bucket.", true},
+		{"b.example.com:9000", 1, "", true},
+		{"c.example.com", 0, "", false},
+	}
+	for _, tt := range cases {
+		idx, prefix, ok := p.match(tt.host)
+		if idx != tt.idx || prefix != tt.prefix || ok != tt.ok {
+			t.Errorf("%s: expected (%d, %q, %v), got (%d, %q, %v)", tt.host, tt.idx, tt.prefix, tt.ok, idx, prefix, ok)
+		}
+	}
+
+	if idx := p.pick(); idx != 0 {
+		t.Errorf("expected the primary endpoint, got %d", idx)
+	}
+	p.markDown(0)
+	if idx := p.pick(); idx != 1 {
+		t.Errorf("expected the failover endpoint, got %d", idx)
+	}
+	// The endpoint which is checked first is picked if all of them are down.
+	p.markDown(1)
+	if idx := p.pick(); idx != 0 {
+		t.Errorf("expected the primary endpoint, got %d", idx)
+	}
+}
+
+func TestEndpointPoolHealthCheck(t *testing.T) {
+	p, err := newEndpointPool("http://a.example.com", []string{"http://b.example.com"})
+	if err != nil {
+		t.Fatalf("new endpoint pool: %v", err)
+	}
+	probed := make(chan string, 1)
+	var probeErr error
+	p.probe = func(u *url.URL) error {
+		probed <- u.Host
+		return probeErr
+	}
+	// endCooldown lets the health check of the primary start on the next pick.
+	endCooldown := func() {
+		p.lock.Lock()
+		p.health[0].checkAt = time.Now()
+		p.lock.Unlock()
+	}
+	// waitCheck waits for the health check of the primary to finish.
+	waitCheck := func() {
+		if host := <-probed; host != "a.example.com" {
+			t.Errorf("expected the primary probed, got %s", host)
+		}
+		for {
+			p.lock.Lock()
+			checking := p.health[0].checking
+			p.lock.Unlock()
+			if !checking {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	p.markDown(0)
+	if idx := p.pick(); idx != 1 {
+		t.Errorf("expected the failover endpoint, got %d", idx)
+	}
+
+	// Requests stay on the failover endpoint while the health check fails, and the cooldown grows.
+	probeErr = errors.New("connection refused")
+	endCooldown()
+	if idx := p.pick(); idx != 1 {
+		t.Errorf("expected the failover endpoint while checking, got %d", idx)
+	}
+	waitCheck()
+	if idx := p.pick(); idx != 1 {
+		t.Errorf("expected the failover endpoint after a failed check, got %d", idx)
+	}
+	if d := time.Until(p.health[0].checkAt); d <= failoverCooldown {
+		t.Errorf("expected the cooldown to grow, got %v", d)
+	}
+
+	// The primary endpoint is used again once the health check passes.
+	probeErr = nil
+	endCooldown()
+	p.pick()
+	waitCheck()
+	if idx := p.pick(); idx != 0 {
+		t.Errorf("expected the primary endpoint after a passed check, got %d", idx)
+	}
+
+	if d := failoverBackoff(10); d != failoverCooldownMaximum {
+		t.Errorf("expected the maximum cooldown, got %v", d)
+	}
+}
+
+func TestParseAccessLogLine(t *testing.T) {
+	line := `79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be awsexamplebucket1 [06/Feb/2019:00:00:38 +0000] 192.0.2.3 79a59df900b949e55d96a1e698fbacedfd6e09d98eacf8f8d5218e7cd47ef2be 3E57427F3EXAMPLE REST.GET.VERSIONING photos/2019%2B08.jpg "GET /awsexamplebucket1?versioning HTTP/1.1" 200 - 113 - 7 - "-" "S3Console/0.4" - s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader awsexamplebucket1.s3.us-west-1.amazonaws.com TLSV1.2 - -`
+	r, err := ParseAccessLogLine(line)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if r.Bucket != "awsexamplebucket1" || r.Operation != "REST.GET.VERSIONING" || r.Key != "photos/2019+08.jpg" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if !r.Time.Equal(time.Date(2019, 2, 6, 0, 0, 38, 0, time.UTC)) || r.HTTPStatus != 200 || r.BytesSent != 113 || r.ObjectSize != 0 {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r.TotalTime != 7*time.Millisecond || r.UserAgent != "S3Console/0.4" || r.Referer != "" || r.TLSVersion != "TLSV1.2" {
+		t.Errorf("unexpected record %+v", r)
+	}
+
+	// Fields added later are optional.
+	r, err = ParseAccessLogLine(line[:strings.Index(line, " s9lz")])
+	if err != nil || r.HostID != "" {
+		t.Errorf("expected the earliest format parsed, got %+v, %v", r, err)
+	}
+
+	for _, v := range []string{line[:100], strings.Replace(line, "200 -", "OK -", 1), `owner bucket [06/Feb/2019:00:00:38 +0000`} {
+		if _, err = ParseAccessLogLine(v); !errors.Is(err, ErrAccessLogMalformed) {
+			t.Errorf("expected %v for %q, got %v", ErrAccessLogMalformed, v, err)
+		}
+	}
+}
+
+// fakeSQS returns the messages once, and records the receipt handles of deleted ones.
+type fakeSQS struct {
+	sqsiface.SQSAPI
+	messages []*sqs.Message
+	deleted  []string
+}
+
+func (f *fakeSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, _ ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	if len(f.messages) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	output := &sqs.ReceiveMessageOutput{Messages: f.messages}
+	f.messages = nil
+	return output, nil
+}
+
+func (f *fakeSQS) DeleteMessageWithContext(_ aws.Context, input *sqs.DeleteMessageInput, _ ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	f.deleted = append(f.deleted, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestPollNotifications(t *testing.T) {
+	s := &Storage{name: "bucket", workDir: "/data/"}
+
+	direct := `{"Records":[{"eventTime":"2021-01-01T00:00:00.000Z","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"bucket"},"object":{"key":"data/a+b%2Bc","size":5,"eTag":"abc","versionId":"v1","sequencer":"01"}}},` +
+		`{"eventTime":"2021-01-01T00:00:00.000Z","eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"other"},"object":{"key":"data/x"}}}]}`
+	sns, _ := json.Marshal(map[string]string{
+		"Type":    "Notification",
+		"Message": `{"Records":[{"eventName":"LifecycleExpiration:Delete","s3":{"bucket":{"name":"bucket"},"object":{"key":"data/b"}}}]}`,
+	})
+	eventBridge := `{"version":"0","detail-type":"Object Created","source":"aws.s3","time":"2021-01-01T00:00:00Z","detail":{"bucket":{"name":"bucket"},"object":{"key":"data/c","size":1,"etag":"def","version-id":"v2"}}}`
+	test := `{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"bucket"}`
+
+	client := &fakeSQS{}
+	for i, body := range []string{direct, string(sns), eventBridge, test} {
+		client.messages = append(client.messages, &sqs.Message{
+			Body:          aws.String(body),
+			ReceiptHandle: aws.String(fmt.Sprint(i)),
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var events []NotificationEvent
+	err := s.PollNotifications(ctx, NotificationOptions{Client: client}, func(e NotificationEvent) error {
+		events = append(events, e)
+		if len(events) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if len(events) != 3 || strings.Join(client.deleted, ",") != "0,1,2,3" {
+		t.Fatalf("unexpected events %+v with %v deleted", events, client.deleted)
+	}
+
+	if e := events[0]; e.Type != NotificationEventCreated || e.Object.Path != "a b+c" || e.Object.MustGetContentLength() != 5 ||
+		e.Object.MustGetEtag() != `"abc"` || GetObjectSystemMetadata(e.Object).VersionID != "v1" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := events[1]; e.Type != NotificationEventRemoved || e.Object.Path != "b" {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e := events[2]; e.Type != NotificationEventCreated || e.Object.Path != "c" || GetObjectSystemMetadata(e.Object).VersionID != "v2" {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	if _, err = s.ParseNotification([]byte("{")); !errors.Is(err, ErrNotificationMalformed) {
+		t.Errorf("expected %v, got %v", ErrNotificationMalformed, err)
+	}
+}
+
+func TestNewCallbackRetryer(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      *aws.Config
+		expected int
+	}{
+		{"default", aws.NewConfig(), 3},
+		{"service default", aws.NewConfig().WithMaxRetries(aws.UseServiceDefaultRetries), 3},
+		{"max retries", aws.NewConfig().WithMaxRetries(7), 7},
+		{"retryer", request.WithRetryer(aws.NewConfig().WithMaxRetries(7), noRetryer{}), 0},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newCallbackRetryer(tt.cfg, func(RetryEvent) {})
+			if got := r.MaxRetries(); got != tt.expected {
+				t.Errorf("expected %d max retries, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+type noRetryer struct {
+	request.Retryer
+}
+
+func (noRetryer) MaxRetries() int { return 0 }
+
+func TestNewStoragePairs(t *testing.T) {
+	srv, err := newServicer(
+		ps.WithCredential("hmac:a:b"),
+		ps.WithEndpoint("http:127.0.0.1:9000"),
+		WithCompatibilityMode(CompatibilityModeMinio),
+		WithDefaultStorageClass(StorageClassStandardIa),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	if len(srv.storagePairs) != 1 || srv.storagePairs[0].Key != "default_storage_class" {
+		t.Errorf("expected only storage scoped pairs, got %v", srv.storagePairs)
+	}
+
+	pairs := make([]typ.Pair, 2, 4)
+	pairs[0], pairs[1] = ps.WithName("test"), ps.WithLocation("us-east-1")
+	st, err := srv.newStorage(pairs...)
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	if extra := pairs[:cap(pairs)]; extra[2].Key != "" {
+		t.Errorf("expected pairs of the caller untouched, got %v", extra)
+	}
+	if st.service.Config.Credentials != srv.sess.Config.Credentials {
+		t.Errorf("expected the session of the service shared")
+	}
+	if !st.compat.relaxedErrors {
+		t.Errorf("expected the compatibility mode of the service")
+	}
+	if len(st.defaultPairs.Write) != 1 {
+		t.Errorf("expected default pairs of the service, got %v", st.defaultPairs)
+	}
+}
+
+func TestNewStorageCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "s3")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, err := newServicer(
+		ps.WithCredential("hmac:a:b"),
+		ps.WithEndpoint("http:127.0.0.1:9000"),
+		WithCassette(filepath.Join(dir, "cassette.json")),
+		WithCassetteMode(CassetteModeRecord),
+	)
+	if err != nil {
+		t.Fatalf("new servicer: %v", err)
+	}
+	if _, ok := srv.sess.Config.HTTPClient.Transport.(*cassetteTransport); !ok {
+		t.Errorf("expected the transport of the service wrapped by the cassette")
+	}
+
+	// The http client rebuilt for the storage must be wrapped as well.
+	st, err := srv.newStorage(ps.WithName("test"), ps.WithLocation("us-east-1"),
+		ps.WithHTTPClientOptions(&httpclient.Options{}))
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	if st.service.Config.HTTPClient == srv.sess.Config.HTTPClient {
+		t.Fatalf("expected the http client rebuilt")
+	}
+	v, ok := st.service.Config.HTTPClient.Transport.(*cassetteTransport)
+	if !ok || v.cassetteTape != srv.cassette {
+		t.Errorf("expected the rebuilt transport wrapped by the cassette of the service")
+	}
+}
@@ -0,0 +1,140 @@
+package s3
+
+import (
+	"context"
+	"strings"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// VerifyFinding is the difference of an object reported by Verify.
+type VerifyFinding string
+
+// All available verify findings are listed here.
+const (
+	// VerifyFindingMissing means the object exists in src but not in dst.
+	VerifyFindingMissing VerifyFinding = "missing"
+	// VerifyFindingExtra means the object exists in dst but not in src.
+	VerifyFindingExtra VerifyFinding = "extra"
+	// VerifyFindingSizeMismatch means the object has different sizes in src and dst.
+	VerifyFindingSizeMismatch VerifyFinding = "size_mismatch"
+	// VerifyFindingEtagMismatch means the object has the same size but different etags in src
+	// and dst, only etags of objects uploaded by a single PUT are compared.
+	VerifyFindingEtagMismatch VerifyFinding = "etag_mismatch"
+)
+
+// VerifyEntry is an object reported by Verify.
+type VerifyEntry struct {
+	Path    string
+	Finding VerifyFinding
+	// Src and Dst are the object listed in src and dst, one of them is nil for missing and
+	// extra objects.
+	Src *typ.Object
+	Dst *typ.Object
+}
+
+// VerifyIterator iterates the objects reported by Verify.
+type VerifyIterator struct {
+	src, dst *verifyCursor
+}
+
+// verifyCursor is the next file listed of a storager, dirs are skipped.
+type verifyCursor struct {
+	it *typ.ObjectIterator
+	o  *typ.Object
+}
+
+func (c *verifyCursor) advance() error {
+	for {
+		o, err := c.it.Next()
+		if err == typ.IterateDone {
+			c.o = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !o.Mode.IsDir() {
+			c.o = o
+			return nil
+		}
+	}
+}
+
+// Verify will compare the objects under prefix in src and dst, and report objects missing,
+// extra or mismatched in dst via the returned iterator, in the order of paths. It's used to
+// check the result of migrations or the health of replication.
+//
+// Objects are compared by size and etag, etags of multipart uploads (with a `-N` suffix) depend
+// on the part size, so only their sizes are compared. Both listings are walked along, so only a
+// page of each one is held in memory.
+func Verify(ctx context.Context, src, dst typ.Storager, prefix string) (*VerifyIterator, error) {
+	it := &VerifyIterator{}
+	for _, v := range []struct {
+		store  typ.Storager
+		cursor **verifyCursor
+	}{{src, &it.src}, {dst, &it.dst}} {
+		lit, err := v.store.ListWithContext(ctx, prefix, ps.WithListMode(typ.ListModePrefix))
+		if err != nil {
+			return nil, err
+		}
+		*v.cursor = &verifyCursor{it: lit}
+		if err = (*v.cursor).advance(); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// Next returns the next object reported, typ.IterateDone will be returned after all objects
+// have been compared.
+func (it *VerifyIterator) Next() (*VerifyEntry, error) {
+	for it.src.o != nil || it.dst.o != nil {
+		so, do := it.src.o, it.dst.o
+
+		var e *VerifyEntry
+		switch {
+		case do == nil || (so != nil && so.Path < do.Path):
+			e = &VerifyEntry{Path: so.Path, Finding: VerifyFindingMissing, Src: so}
+		case so == nil || do.Path < so.Path:
+			e = &VerifyEntry{Path: do.Path, Finding: VerifyFindingExtra, Dst: do}
+		default:
+			if finding := compareObjects(so, do); finding != "" {
+				e = &VerifyEntry{Path: so.Path, Finding: finding, Src: so, Dst: do}
+			}
+		}
+
+		// Advance the cursors of the objects compared.
+		if e == nil || e.Src != nil {
+			if err := it.src.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if e == nil || e.Dst != nil {
+			if err := it.dst.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if e != nil {
+			return e, nil
+		}
+	}
+	return nil, typ.IterateDone
+}
+
+// compareObjects returns an empty finding if the objects are considered the same.
+func compareObjects(src, dst *typ.Object) VerifyFinding {
+	ss, _ := src.GetContentLength()
+	ds, _ := dst.GetContentLength()
+	if ss != ds {
+		return VerifyFindingSizeMismatch
+	}
+
+	se, sok := src.GetEtag()
+	de, dok := dst.GetEtag()
+	if sok && dok && !strings.Contains(se, "-") && !strings.Contains(de, "-") && se != de {
+		return VerifyFindingEtagMismatch
+	}
+	return ""
+}
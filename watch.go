@@ -0,0 +1,122 @@
+package s3
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// WatchEventType is the type of a change detected by Watch.
+type WatchEventType string
+
+// All available watch event types are listed here.
+const (
+	WatchEventCreated WatchEventType = "created"
+	WatchEventUpdated WatchEventType = "updated"
+	WatchEventDeleted WatchEventType = "deleted"
+)
+
+// WatchEvent is a change of an object detected by Watch.
+type WatchEvent struct {
+	Type WatchEventType
+	Path string
+	// Object is the object listed, which is the last one listed for deleted objects.
+	Object *typ.Object
+	// Err is the error of a listing, objects are compared again by the next listing.
+	Err error
+}
+
+// Watch will list objects under prefix every interval, and send the changes since the previous
+// listing to the returned channel, which is useful to distribute configs and hot reload without
+// event notifications. Objects are considered updated if the size, etag or last modified changes.
+//
+// The first listing is the baseline without events, its error will be returned directly. The
+// channel will be closed after ctx is done, and events are sent in the order of paths.
+func (s *Storage) Watch(ctx context.Context, prefix string, interval time.Duration) (<-chan WatchEvent, error) {
+	objects, err := s.listWatchObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan WatchEvent)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			var events []WatchEvent
+			current, err := s.listWatchObjects(ctx, prefix)
+			if err != nil {
+				events = append(events, WatchEvent{Err: err})
+			} else {
+				events = diffWatchObjects(objects, current)
+				objects = current
+			}
+			for _, e := range events {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// watchObject is the object listed along with the fields compared, which are captured while
+// listing so that they will never be refreshed by the object.
+type watchObject struct {
+	o        *typ.Object
+	size     int64
+	etag     string
+	modified time.Time
+}
+
+func (s *Storage) listWatchObjects(ctx context.Context, prefix string) (map[string]watchObject, error) {
+	objects, err := listFiles(ctx, s, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]watchObject, len(objects))
+	for p, o := range objects {
+		v := watchObject{o: o}
+		v.size, _ = o.GetContentLength()
+		v.etag, _ = o.GetEtag()
+		v.modified, _ = o.GetLastModified()
+		m[p] = v
+	}
+	return m, nil
+}
+
+// diffWatchObjects returns the changes from prev to cur sorted by path.
+func diffWatchObjects(prev, cur map[string]watchObject) (events []WatchEvent) {
+	for p, v := range cur {
+		old, ok := prev[p]
+		switch {
+		case !ok:
+			events = append(events, WatchEvent{Type: WatchEventCreated, Path: p, Object: v.o})
+		case old.size != v.size || old.etag != v.etag || !old.modified.Equal(v.modified):
+			events = append(events, WatchEvent{Type: WatchEventUpdated, Path: p, Object: v.o})
+		}
+	}
+	for p, v := range prev {
+		if _, ok := cur[p]; !ok {
+			events = append(events, WatchEvent{Type: WatchEventDeleted, Path: p, Object: v.o})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	return events
+}
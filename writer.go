@@ -0,0 +1,224 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	ps "github.com/minhjh/go-storage/v4/pairs"
+	typ "github.com/minhjh/go-storage/v4/types"
+)
+
+// writerPartSize is the part size used by Writer, which limits the object size to about 80GB.
+const writerPartSize = 8 * 1024 * 1024
+
+// errWriterClosed will be returned while writing to a closed Writer.
+var errWriterClosed = errors.New("s3: write to closed writer")
+
+// Writer implements io.WriteCloser over a multipart upload.
+//
+// Written bytes will be buffered until a full part is collected, and parts will be uploaded
+// in the background. Objects smaller than a part will be written via a single write on Close.
+// The object will only be visible after Close returns without error.
+type Writer struct {
+	s     *Storage
+	ctx   context.Context
+	path  string
+	pairs []typ.Pair
+	// partPairs are the pairs passed to every part.
+	partPairs []typ.Pair
+
+	buf    []byte
+	closed bool
+	// written is the number of bytes written, which is the total size after closed.
+	written  int64
+	progress *progressTracker
+
+	o     *typ.Object
+	index int
+	partc chan []byte
+	done  chan struct{}
+
+	mu    sync.Mutex
+	parts []*typ.Part
+	err   error
+}
+
+// NewWriter will create a Writer for the object at path.
+//
+// pairs will be passed to write or create_multipart, so only pairs supported by create_multipart
+// are accepted regardless of the size, other pairs like content_md5 and if_none_match will be
+// returned as services.PairUnsupportedError by Write and Close. Server-side encryption customer
+// key pairs, excepted_bucket_owner and io_callback will also be passed to every part. The progress
+// reported by progress_callback counts the uploaded bytes, whose total is unknown until Close is
+// called.
+//
+// Parts are uploaded one at a time, while the next part is being buffered, so up to three parts
+// (24MiB) are held in memory.
+func (s *Storage) NewWriter(ctx context.Context, path string, pairs ...typ.Pair) *Writer {
+	progress, pairs := splitProgressPair(pairs)
+
+	w := &Writer{
+		s:     s,
+		ctx:   ctx,
+		path:  path,
+		pairs: pairs,
+		buf:   make([]byte, 0, writerPartSize),
+		partPairs: filterPairs(pairs,
+			"excepted_bucket_owner",
+			"server_side_encryption_customer_algorithm",
+			"server_side_encryption_customer_key",
+			"io_callback",
+		),
+	}
+	// Reject pairs not supported by create_multipart up front, so that the behavior won't change
+	// once the object grows larger than a part.
+	if _, err := s.parsePairStorageCreateMultipart(w.createPairs()); err != nil {
+		w.err = s.formatError("create_multipart", err, path)
+	}
+	if progress != nil {
+		w.progress = newProgressTracker(progress, -1, 0)
+	}
+	return w
+}
+
+// Write implements io.Writer.
+func (w *Writer) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, errWriterClosed
+	}
+	if err = w.getErr(); err != nil {
+		return 0, err
+	}
+
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		n += m
+		w.written += int64(m)
+		p = p[m:]
+
+		if len(w.buf) < cap(w.buf) {
+			break
+		}
+		if err = w.flush(); err != nil {
+			return
+		}
+	}
+	return n, nil
+}
+
+// flush will send the buffered part to the background uploader.
+func (w *Writer) flush() (err error) {
+	if w.o == nil {
+		w.o, err = w.s.CreateMultipartWithContext(w.ctx, w.path, w.createPairs()...)
+		if err != nil {
+			w.setErr(err)
+			return
+		}
+
+		w.partc = make(chan []byte, 1)
+		w.done = make(chan struct{})
+		go w.upload()
+	}
+
+	w.partc <- w.buf
+	w.buf = make([]byte, 0, writerPartSize)
+	return w.getErr()
+}
+
+// createPairs returns the pairs passed to create_multipart, io_callback is passed to parts instead.
+func (w *Writer) createPairs() (pairs []typ.Pair) {
+	for _, v := range w.pairs {
+		if v.Key != "io_callback" {
+			pairs = append(pairs, v)
+		}
+	}
+	return
+}
+
+func (w *Writer) upload() {
+	defer close(w.done)
+
+	for b := range w.partc {
+		// Drain the remaining parts once failed, so that Write won't be blocked.
+		if w.getErr() != nil {
+			continue
+		}
+
+		_, part, err := w.s.WriteMultipartWithContext(w.ctx, w.o, bytes.NewReader(b), int64(len(b)), w.index, w.partPairs...)
+		if err != nil {
+			w.setErr(err)
+			continue
+		}
+		w.index++
+		if w.progress != nil {
+			w.progress.add(int64(len(b)))
+		}
+
+		w.mu.Lock()
+		w.parts = append(w.parts, part)
+		w.mu.Unlock()
+	}
+}
+
+// Close implements io.Closer, it will upload the remaining data and complete the upload.
+//
+// The multipart upload will be aborted if any error occurred.
+func (w *Writer) Close() (err error) {
+	if w.closed {
+		return w.getErr()
+	}
+	w.closed = true
+	if w.progress != nil {
+		w.progress.setTotal(w.written)
+	}
+
+	if w.o == nil {
+		if err = w.getErr(); err != nil {
+			return
+		}
+		_, err = w.s.WriteWithContext(w.ctx, w.path, bytes.NewReader(w.buf), int64(len(w.buf)), w.pairs...)
+		w.setErr(err)
+		if err == nil && w.progress != nil {
+			w.progress.add(int64(len(w.buf)))
+		}
+		return
+	}
+
+	if len(w.buf) > 0 {
+		w.partc <- w.buf
+		w.buf = nil
+	}
+	close(w.partc)
+	<-w.done
+
+	if err = w.getErr(); err == nil {
+		err = w.s.CompleteMultipartWithContext(w.ctx, w.o, w.parts, filterPairs(w.pairs, "excepted_bucket_owner")...)
+	}
+	if err != nil {
+		w.setErr(err)
+		// Abort the upload so that the uploaded parts won't be charged.
+		_ = w.s.DeleteWithContext(w.ctx, w.path, ps.WithMultipartID(w.o.MustGetMultipartID()))
+	}
+	return
+}
+
+func (w *Writer) getErr() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *Writer) setErr(err error) {
+	if err == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}